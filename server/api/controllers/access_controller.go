@@ -0,0 +1,54 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+
+	"github.com/vincetiu8/penn-spark-server/api/models"
+)
+
+// GetFolderAccess gets the access level of the user in a folder, explaining which access role decided it.
+// Admins can get the access level of another user with the user_id query parameter.
+func (s *Server) GetFolderAccess(w http.ResponseWriter, r *http.Request, user models.User) {
+	vars := mux.Vars(r)
+	fid, err := strconv.ParseUint(vars["id"], 10, 32)
+	if err != nil {
+		ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	var userID uint
+	if value := r.URL.Query().Get("user_id"); value != "" {
+		uid, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			ERROR(w, http.StatusBadRequest, err)
+			return
+		}
+		userID = uint(uid)
+	}
+	if userID != 0 && userID != user.ID && !user.IsAdmin {
+		ERROR(w, http.StatusForbidden, ErrUserForbidden)
+		return
+	}
+
+	s.Mutex.RLock()
+	if userID != 0 && userID != user.ID {
+		user, err = models.GetUserByID(s.DB, userID)
+		if err != nil {
+			s.Mutex.RUnlock()
+			ERROR(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+
+	decision, err := models.GetUserAccessDecision(s.DB, user, uint(fid))
+	s.Mutex.RUnlock()
+	if err != nil {
+		ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	JSON(w, http.StatusOK, decision)
+}
@@ -64,6 +64,7 @@ func (s *Server) GetAccessRoleByID(w http.ResponseWriter, r *http.Request, _ mod
 }
 
 // UpdateAccessRole updates an existing access role based on its id.
+// Setting starts_at or expires_at to null clears that bound of the grant window, while leaving one out keeps it.
 func (s *Server) UpdateAccessRole(w http.ResponseWriter, r *http.Request, _ models.User) {
 	vars := mux.Vars(r)
 	uid, err := strconv.ParseUint(vars["id"], 10, 32)
@@ -85,10 +86,23 @@ func (s *Server) UpdateAccessRole(w http.ResponseWriter, r *http.Request, _ mode
 		return
 	}
 
+	fields := make(map[string]json.RawMessage)
+	err = json.Unmarshal(body, &fields)
+	if err != nil {
+		ERROR(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	var windowColumns []string
+	for _, column := range []string{"starts_at", "expires_at"} {
+		if _, ok := fields[column]; ok {
+			windowColumns = append(windowColumns, column)
+		}
+	}
+
 	accessRole.ID = uint(uid)
 
 	s.Mutex.Lock()
-	accessRole, err = models.UpdateAccessRole(s.DB, accessRole)
+	accessRole, err = models.UpdateAccessRole(s.DB, accessRole, windowColumns...)
 	s.Mutex.Unlock()
 	if err != nil {
 		ERROR(w, http.StatusBadRequest, err)
@@ -118,3 +132,61 @@ func (s *Server) DeleteAccessRole(w http.ResponseWriter, r *http.Request, _ mode
 	w.Header().Set("Entity", fmt.Sprintf("%d", uid))
 	JSON(w, http.StatusNoContent, "")
 }
+
+// GetDirectAccessRoles gets the access roles granted directly to a user.
+func (s *Server) GetDirectAccessRoles(w http.ResponseWriter, r *http.Request, _ models.User) {
+	vars := mux.Vars(r)
+	uid, err := strconv.ParseUint(vars["id"], 10, 32)
+	if err != nil {
+		ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	s.Mutex.RLock()
+	accessRoles, err := models.GetDirectAccessRoles(s.DB, uint(uid))
+	s.Mutex.RUnlock()
+	if err != nil {
+		ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	JSON(w, http.StatusOK, accessRoles)
+}
+
+// CreateDirectAccessRole grants an access role directly to a user, without assigning them a user role.
+// The access role can be updated and deleted like any other access role.
+func (s *Server) CreateDirectAccessRole(w http.ResponseWriter, r *http.Request, _ models.User) {
+	vars := mux.Vars(r)
+	uid, err := strconv.ParseUint(vars["id"], 10, 32)
+	if err != nil {
+		ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		ERROR(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	accessRole := models.AccessRole{}
+	err = json.Unmarshal(body, &accessRole)
+	if err != nil {
+		ERROR(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	if accessRole.UserRoleID != 0 {
+		ERROR(w, http.StatusBadRequest, models.ErrInvalidAccessRoleTarget)
+		return
+	}
+	accessRole.UserID = uint(uid)
+
+	s.Mutex.Lock()
+	accessRole, err = models.CreateAccessRole(s.DB, accessRole)
+	s.Mutex.Unlock()
+	if err != nil {
+		ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	JSON(w, http.StatusCreated, accessRole)
+}
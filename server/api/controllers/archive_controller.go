@@ -0,0 +1,201 @@
+package controllers
+
+import (
+	"archive/zip"
+	"html"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/vincetiu8/penn-spark-server/api/models"
+)
+
+// archiveEntry describes a folder or file to be written to a ZIP archive.
+// Files are read from their blob by checksum.
+type archiveEntry struct {
+	path     string
+	isFolder bool
+	checksum string
+	modified time.Time
+}
+
+// GetFolderArchive streams a folder and all its descendants as a ZIP archive.
+// The same visibility rules as GetFolderByID apply to every folder in the archive,
+// so child folders the user has no access to and draft files they can't see are left out.
+// Files whose data hasn't been found clean by the malware scanner are left out as well.
+// The contents are listed under the read lock, but the data is streamed without holding it.
+func (s *Server) GetFolderArchive(w http.ResponseWriter, r *http.Request, user models.User) {
+	vars := mux.Vars(r)
+	fid, err := strconv.ParseUint(vars["id"], 10, 32)
+	if err != nil {
+		ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	s.Mutex.RLock()
+	// Verify user has access to the folder
+	folder, accessLevel, err := models.GetUserAuthorizationFolder(s.DB, user, uint(fid))
+	if err != nil {
+		s.Mutex.RUnlock()
+		ERROR(w, http.StatusBadRequest, err)
+		return
+	} else if accessLevel < models.Viewer {
+		s.Mutex.RUnlock()
+		ERROR(w, http.StatusForbidden, ErrUserForbidden)
+		return
+	}
+
+	entries, err := s.getArchiveEntries(user, folder.ID)
+	s.Mutex.RUnlock()
+	if err != nil {
+		ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", contentDisposition("attachment", html.UnescapeString(folder.Name)+".zip"))
+	w.Header().Set("Cache-Control", "private, no-cache")
+	w.WriteHeader(http.StatusOK)
+
+	// The response has already started, so errors can only be logged and the archive is left incomplete.
+	archive := zip.NewWriter(w)
+	for _, entry := range entries {
+		err = s.writeArchiveEntry(archive, entry)
+		if err != nil {
+			log.Println("can't write folder archive:", err)
+			return
+		}
+	}
+	err = archive.Close()
+	if err != nil {
+		log.Println("can't write folder archive:", err)
+	}
+}
+
+// getArchiveEntries lists a folder and the contents the user can see, with each path starting with the folder's name.
+// The whole tree is loaded at once, rather than one folder at a time.
+// The caller must hold the read lock.
+func (s *Server) getArchiveEntries(user models.User, folderID uint) ([]archiveEntry, error) {
+	subtree, err := models.GetFolderSubtree(s.DB, folderID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Get the access level of the user to every folder in the tree at once
+	accessLevels, err := models.GetUserAuthorizationFolders(s.DB, user, subtree.FolderIDs)
+	if err != nil {
+		return nil, err
+	}
+	folders, err := models.GetFolders(s.DB, subtree.FolderIDs)
+	if err != nil {
+		return nil, err
+	}
+	folderByID := make(map[uint]models.Folder, len(folders))
+	for _, folder := range folders {
+		folderByID[folder.ID] = folder
+	}
+
+	// The subtree lists every folder after its parent, so folders are only added once their parent is
+	// Leave out the folders the user can't view, which also leaves out the folders nested inside them
+	var entries []archiveEntry
+	paths := map[uint]string{}
+	query := models.FileQuery{FolderIDs: []uint{}, DraftFolderIDs: []uint{}, DraftEditorID: user.ID}
+	for i, id := range subtree.FolderIDs {
+		folder, ok := folderByID[id]
+		if !ok || accessLevels[id] < models.Viewer {
+			continue
+		}
+		if i == 0 {
+			paths[id] = archiveName(folder.Name)
+		} else if parentPath, ok := paths[*folder.ParentFolderID]; ok {
+			paths[id] = parentPath + "/" + archiveName(folder.Name)
+		} else {
+			continue
+		}
+
+		entries = append(entries, archiveEntry{
+			path:     paths[id] + "/",
+			isFolder: true,
+			modified: folder.UpdatedAt,
+		})
+		query.FolderIDs = append(query.FolderIDs, id)
+		if accessLevels[id] >= models.Publisher {
+			query.DraftFolderIDs = append(query.DraftFolderIDs, id)
+		}
+	}
+
+	// Leave out draft files so user can't see them, unless user is the uploader (last editor of a draft file)
+	files, _, err := models.QueryFiles(s.DB, query)
+	if err != nil {
+		return nil, err
+	}
+	fileIDs := make([]uint, 0, len(files))
+	for _, file := range files {
+		fileIDs = append(fileIDs, file.ID)
+	}
+	versions, err := models.GetLatestFileVersions(s.DB, fileIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, file := range files {
+		// Data that hasn't been scanned yet or is infected can't be downloaded,
+		// including data stored before versioning was introduced, which has never been scanned
+		version, ok := versions[file.ID]
+		if !ok || version.ScanStatus != models.ScanClean {
+			continue
+		}
+		entries = append(entries, archiveEntry{
+			path:     paths[file.FolderID] + "/" + archiveName(file.Name),
+			checksum: version.Checksum,
+			modified: version.CreatedAt,
+		})
+	}
+
+	// Every folder comes before its contents
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].path < entries[j].path
+	})
+	return entries, nil
+}
+
+// writeArchiveEntry adds a folder or file to a ZIP archive, streaming the file's data from the store.
+func (s *Server) writeArchiveEntry(archive *zip.Writer, entry archiveEntry) error {
+	if entry.isFolder {
+		_, err := archive.CreateHeader(&zip.FileHeader{
+			Name:     entry.path,
+			Method:   zip.Store,
+			Modified: entry.modified,
+		})
+		return err
+	}
+
+	data, err := s.FileSystem.GetBlobRaw(entry.checksum)
+	if err != nil {
+		return err
+	}
+	defer data.Close()
+
+	writer, err := archive.CreateHeader(&zip.FileHeader{
+		Name:     entry.path,
+		Method:   zip.Deflate,
+		Modified: entry.modified,
+	})
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(writer, data)
+	return err
+}
+
+// archiveName converts a folder or file name to a single element of a path in a ZIP archive.
+// Names are stored escaped, and slashes would otherwise create extra folders.
+func archiveName(name string) string {
+	return strings.NewReplacer("/", "_", "\\", "_").Replace(html.UnescapeString(name))
+}
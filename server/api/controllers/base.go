@@ -6,6 +6,7 @@ import (
 	"log"
 	"net/http"
 	"sync"
+	"time"
 
 	"github.com/gorilla/handlers"
 	"github.com/gorilla/mux"
@@ -15,25 +16,38 @@ import (
 
 	"github.com/vincetiu8/penn-spark-server/api/filesystem"
 	"github.com/vincetiu8/penn-spark-server/api/models"
+	"github.com/vincetiu8/penn-spark-server/api/scanner"
 )
 
 // Server provides a struct that houses all aspects of the backend server.
 // The Server Mutex protects against concurrency issues.
+// MaxUploadSize limits the size in bytes of uploaded file data, with 0 meaning no limit.
+// MaxImportSize limits the total size in bytes of the files extracted from an imported archive, with 0 meaning no limit.
+// MaxImportEntries limits the number of entries in an imported archive, with 0 meaning no limit.
+// UploadSessionLifetime is how long an upload session is kept after it last received data.
+// Scanner checks uploaded file data for malware, and defaults to a NoopScanner.
 type Server struct {
-	Mutex      sync.RWMutex
-	FileSystem filesystem.FileSystem
-	DB         *gorm.DB
-	Router     *mux.Router
+	Mutex                 sync.RWMutex
+	FileSystem            filesystem.FileSystem
+	DB                    *gorm.DB
+	Router                *mux.Router
+	MaxUploadSize         int64
+	MaxImportSize         int64
+	MaxImportEntries      int
+	UploadSessionLifetime time.Duration
+	Scanner               scanner.Scanner
 }
 
 // Initialize sets up the server.
-func (s *Server) Initialize(DbPath, ApiPath, FsPath string) {
+// File data is kept in the given BlobStore, or in the FsPath folder if it is nil.
+func (s *Server) Initialize(DbPath, ApiPath, FsPath string, Store filesystem.BlobStore) {
 	var err error
 
 	// Setting up the filesystem.
 	s.FileSystem = filesystem.FileSystem{
 		Fs:       afero.NewOsFs(),
 		FilePath: FsPath,
+		Store:    Store,
 	}
 
 	// Make a folder to host files
@@ -53,10 +67,20 @@ func (s *Server) Initialize(DbPath, ApiPath, FsPath string) {
 	}
 
 	// Creating tables for all structs in the database
-	err = s.DB.AutoMigrate(&models.User{}, &models.Folder{}, &models.File{}, &models.UserRole{}, &models.AccessRole{})
+	err = models.SetupJoinTables(s.DB)
+	if err != nil {
+		log.Fatalln("can't set up join tables", err)
+	}
+	err = s.DB.AutoMigrate(&models.User{}, &models.Folder{}, &models.File{}, &models.FileVersion{}, &models.Blob{},
+		&models.UploadSession{}, &models.UploadChunk{}, &models.UserRole{}, &models.AccessRole{}, &models.UploadPolicy{},
+		&models.GrantAuditEntry{})
 	if err != nil {
 		log.Fatalln("can't migrate tables", err)
 	}
+	err = models.MigrateSearchIndex(s.DB)
+	if err != nil {
+		log.Fatalln("can't migrate search index", err)
+	}
 
 	// Seed the database with the minimum amount of information to be usable
 	s.SeedDatabase()
@@ -120,6 +144,11 @@ func (s *Server) Run(addr string) {
 		"Cache-Control",
 		"X-Requested-With",
 	})
+	// Listings describe their pages in headers, which browsers only let clients read if they are exposed.
+	exposedOk := handlers.ExposedHeaders([]string{
+		"X-Total-Count",
+		"X-Next-Cursor",
+	})
 	originsOk := handlers.AllowedOrigins([]string{"*"})
 	credentialsOk := handlers.AllowCredentials()
 	methodsOk := handlers.AllowedMethods([]string{
@@ -131,5 +160,5 @@ func (s *Server) Run(addr string) {
 	})
 
 	fmt.Printf("Listening to port %s\n", addr)
-	log.Fatal(http.ListenAndServe(addr, handlers.CORS(originsOk, headersOk, methodsOk, credentialsOk)(s.Router)))
+	log.Fatal(http.ListenAndServe(addr, handlers.CORS(originsOk, headersOk, exposedOk, methodsOk, credentialsOk)(s.Router)))
 }
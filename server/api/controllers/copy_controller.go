@@ -0,0 +1,280 @@
+package controllers
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+
+	"github.com/vincetiu8/penn-spark-server/api/filesystem"
+	"github.com/vincetiu8/penn-spark-server/api/models"
+)
+
+// CopyRequest is the body of a request to copy a file or folder into the folder with id CopyRequest.FolderID.
+// CopyRequest.Name defaults to the name of the file or folder being copied.
+// CopyRequest.CopyAccessRoles only applies to folders, and can only be set by admins as it grants access to the copies.
+type CopyRequest struct {
+	FolderID        uint   `json:"folder_id"`
+	Name            string `json:"name"`
+	CopyAccessRoles bool   `json:"copy_access_roles"`
+}
+
+// FolderCopyReport is the copy of a folder, along with the files that weren't copied.
+type FolderCopyReport struct {
+	models.Folder
+	Conflicts []CopyConflict `json:"conflicts"`
+}
+
+// CopyConflict describes a file that wasn't copied as the upload policy of the destination doesn't allow its data.
+type CopyConflict struct {
+	FileID uint   `json:"file_id"`
+	Name   string `json:"name"`
+	Error  string `json:"error"`
+}
+
+// parseCopyRequest reads the CopyRequest in the body of a request.
+func parseCopyRequest(r *http.Request) (CopyRequest, int, error) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return CopyRequest{}, http.StatusUnprocessableEntity, err
+	}
+	copyRequest := CopyRequest{}
+	err = json.Unmarshal(body, &copyRequest)
+	if err != nil {
+		return CopyRequest{}, http.StatusUnprocessableEntity, err
+	}
+	if copyRequest.FolderID == 0 {
+		return CopyRequest{}, http.StatusBadRequest, models.ErrRequiredFolderID
+	}
+	return copyRequest, http.StatusOK, nil
+}
+
+// CopyFile copies a file and its data into another folder, in which the user must be at least an uploader.
+// The copy is a draft unless the file is published and the user is a publisher in the other folder.
+// The data must be allowed by the upload policy of the other folder.
+func (s *Server) CopyFile(w http.ResponseWriter, r *http.Request, user models.User) {
+	vars := mux.Vars(r)
+	fid, err := strconv.ParseUint(vars["id"], 10, 32)
+	if err != nil {
+		ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+	fileID := uint(fid)
+
+	copyRequest, status, err := parseCopyRequest(r)
+	if err != nil {
+		ERROR(w, status, err)
+		return
+	}
+
+	s.Mutex.Lock()
+	// Verify the user can see the file
+	file, accessLevel, err := models.GetUserAuthorizationFile(s.DB, user, fileID)
+	if err != nil {
+		s.Mutex.Unlock()
+		ERROR(w, http.StatusBadRequest, err)
+		return
+	} else if accessLevel < models.Viewer && file.LastEditorID != user.ID {
+		s.Mutex.Unlock()
+		ERROR(w, http.StatusForbidden, ErrUserForbidden)
+		return
+	} else if !file.IsPublished && file.LastEditorID != user.ID && accessLevel < models.Publisher {
+		s.Mutex.Unlock()
+		ERROR(w, http.StatusForbidden, ErrUserForbidden)
+		return
+	}
+
+	// Verify the user can upload files to the destination folder
+	_, destinationAccessLevel, err := models.GetUserAuthorizationFolder(s.DB, user, copyRequest.FolderID)
+	if err != nil {
+		s.Mutex.Unlock()
+		ERROR(w, http.StatusBadRequest, err)
+		return
+	} else if destinationAccessLevel < models.Uploader {
+		s.Mutex.Unlock()
+		ERROR(w, http.StatusForbidden, ErrUserForbidden)
+		return
+	}
+
+	// Verify the upload policy of the destination folder allows the data
+	versions, err := s.getCopiedFileVersions([]uint{fileID})
+	if err != nil {
+		s.Mutex.Unlock()
+		ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+	if version, ok := versions[fileID]; ok {
+		status, err := s.checkUploadPolicy(s.DB, copyRequest.FolderID, version.Size, version.ContentType)
+		if err != nil {
+			s.Mutex.Unlock()
+			ERROR(w, status, err)
+			return
+		}
+	}
+
+	isPublished := file.IsPublished && destinationAccessLevel >= models.Publisher
+	file, err = models.CopyFile(s.DB, fileID, copyRequest.FolderID, copyRequest.Name, user.ID, isPublished)
+	s.Mutex.Unlock()
+	if err != nil {
+		ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	JSON(w, http.StatusCreated, file)
+}
+
+// CopyFolder copies a folder and everything nested inside it into another folder, in which the user must be a publisher.
+// The same visibility rules as GetFolderByID apply to every folder copied,
+// so child folders the user has no access to and draft files they can't see are left out.
+// The files keep whether they are published, and share the data of the files they were copied from.
+// Files whose data the upload policy of the destination doesn't allow are left out and listed in the report's conflicts.
+func (s *Server) CopyFolder(w http.ResponseWriter, r *http.Request, user models.User) {
+	vars := mux.Vars(r)
+	fid, err := strconv.ParseUint(vars["id"], 10, 32)
+	if err != nil {
+		ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+	folderID := uint(fid)
+
+	copyRequest, status, err := parseCopyRequest(r)
+	if err != nil {
+		ERROR(w, status, err)
+		return
+	}
+	if copyRequest.CopyAccessRoles && !user.IsAdmin {
+		ERROR(w, http.StatusForbidden, ErrUserForbidden)
+		return
+	}
+
+	s.Mutex.Lock()
+	// Verify the user can create folders in the destination folder
+	_, destinationAccessLevel, err := models.GetUserAuthorizationFolder(s.DB, user, copyRequest.FolderID)
+	if err != nil {
+		s.Mutex.Unlock()
+		ERROR(w, http.StatusBadRequest, err)
+		return
+	} else if destinationAccessLevel < models.Publisher {
+		s.Mutex.Unlock()
+		ERROR(w, http.StatusForbidden, ErrUserForbidden)
+		return
+	}
+
+	subtree, err := models.GetFolderSubtree(s.DB, folderID)
+	if err != nil {
+		s.Mutex.Unlock()
+		ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	// Get the access level of the user to every folder being copied at once
+	accessLevels, err := models.GetUserAuthorizationFolders(s.DB, user, subtree.FolderIDs)
+	if err != nil {
+		s.Mutex.Unlock()
+		ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+	if accessLevels[folderID] < models.Viewer {
+		s.Mutex.Unlock()
+		ERROR(w, http.StatusForbidden, ErrUserForbidden)
+		return
+	}
+
+	// Leave out the folders the user can't view, which also leaves out the folders nested inside them
+	folderCopy := models.FolderCopy{
+		ParentFolderID:  copyRequest.FolderID,
+		Name:            copyRequest.Name,
+		EditorID:        user.ID,
+		FolderIDs:       []uint{},
+		CopyAccessRoles: copyRequest.CopyAccessRoles,
+	}
+	query := models.FileQuery{FolderIDs: []uint{}, DraftFolderIDs: []uint{}, DraftEditorID: user.ID}
+	for _, id := range subtree.FolderIDs {
+		if accessLevels[id] < models.Viewer {
+			continue
+		}
+		folderCopy.FolderIDs = append(folderCopy.FolderIDs, id)
+		query.FolderIDs = append(query.FolderIDs, id)
+		if accessLevels[id] >= models.Publisher {
+			query.DraftFolderIDs = append(query.DraftFolderIDs, id)
+		}
+	}
+
+	// Leave out draft files so user can't copy them, unless user is the uploader (last editor of a draft file)
+	files, _, err := models.QueryFiles(s.DB, query)
+	if err != nil {
+		s.Mutex.Unlock()
+		ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+	fileIDs := make([]uint, 0, len(files))
+	for _, file := range files {
+		fileIDs = append(fileIDs, file.ID)
+	}
+	versions, err := s.getCopiedFileVersions(fileIDs)
+	if err != nil {
+		s.Mutex.Unlock()
+		ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	// Upload policies aren't copied, so every copied folder uses the upload policy of the destination folder
+	// Leave out the files whose data it doesn't allow
+	policy, err := models.GetEffectiveUploadPolicy(s.DB, copyRequest.FolderID)
+	if err != nil {
+		s.Mutex.Unlock()
+		ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+	report := FolderCopyReport{Conflicts: []CopyConflict{}}
+	for _, file := range files {
+		if version, ok := versions[file.ID]; ok {
+			_, err = checkPolicy(policy, version.Size, version.ContentType)
+			if err != nil {
+				report.Conflicts = append(report.Conflicts, CopyConflict{
+					FileID: file.ID,
+					Name:   file.Name,
+					Error:  err.Error(),
+				})
+				continue
+			}
+		}
+		folderCopy.FileIDs = append(folderCopy.FileIDs, file.ID)
+	}
+
+	report.Folder, err = models.CopyFolder(s.DB, folderCopy)
+	s.Mutex.Unlock()
+	if err != nil {
+		ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	JSON(w, http.StatusCreated, report)
+}
+
+// getCopiedFileVersions gets the latest version of each file being copied, keyed by file id.
+// Data stored before versioning was introduced is stored as the first version of its file first, so it is copied too.
+// Files without any data are left out.
+// The caller must hold the write lock.
+func (s *Server) getCopiedFileVersions(fileIDs []uint) (map[uint]models.FileVersion, error) {
+	versions, err := models.GetLatestFileVersions(s.DB, fileIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, fileID := range fileIDs {
+		if _, ok := versions[fileID]; ok {
+			continue
+		}
+		version, err := s.migrateLegacyFileData(fileID)
+		if err == filesystem.ErrBlobNotFound {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		versions[fileID] = version
+	}
+	return versions, nil
+}
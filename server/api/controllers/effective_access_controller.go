@@ -0,0 +1,132 @@
+package controllers
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"html"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+
+	"github.com/vincetiu8/penn-spark-server/api/models"
+)
+
+// ErrInvalidFormat returned when a report format other than json or csv is specified.
+var ErrInvalidFormat = errors.New("invalid format")
+
+// effectiveAccessColumns are the header of an effective access report exported as CSV.
+var effectiveAccessColumns = []string{
+	"user_id", "username", "folder_id", "folder_name", "access_level", "reason", "explanation",
+	"access_role_id", "user_role_id", "source_folder_id",
+}
+
+// parseReportFormat parses the format query parameter of a request, either json or csv, and returns whether it is csv.
+func parseReportFormat(r *http.Request) (bool, error) {
+	switch r.URL.Query().Get("format") {
+	case "", "json":
+		return false, nil
+	case "csv":
+		return true, nil
+	}
+	return false, ErrInvalidFormat
+}
+
+// GetFolderEffectiveAccess gets the access level of every user in a folder, and the access role that decided it.
+// The report is exported as CSV if the format query parameter is csv.
+func (s *Server) GetFolderEffectiveAccess(w http.ResponseWriter, r *http.Request, _ models.User) {
+	vars := mux.Vars(r)
+	fid, err := strconv.ParseUint(vars["id"], 10, 32)
+	if err != nil {
+		ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+	isCSV, err := parseReportFormat(r)
+	if err != nil {
+		ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	s.Mutex.RLock()
+	effectiveAccess, err := models.GetFolderEffectiveAccess(s.DB, uint(fid))
+	s.Mutex.RUnlock()
+	if err != nil {
+		ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if isCSV {
+		writeEffectiveAccessCSV(w, fmt.Sprintf("folder-%d-effective-access.csv", fid), effectiveAccess)
+		return
+	}
+	JSON(w, http.StatusOK, effectiveAccess)
+}
+
+// GetUserEffectiveAccess gets every folder a user can view, along with their access level in it
+// and the access role that decided it.
+// The report is exported as CSV if the format query parameter is csv.
+func (s *Server) GetUserEffectiveAccess(w http.ResponseWriter, r *http.Request, _ models.User) {
+	vars := mux.Vars(r)
+	uid, err := strconv.ParseUint(vars["id"], 10, 32)
+	if err != nil {
+		ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+	isCSV, err := parseReportFormat(r)
+	if err != nil {
+		ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	s.Mutex.RLock()
+	effectiveAccess, err := models.GetUserEffectiveAccess(s.DB, uint(uid))
+	s.Mutex.RUnlock()
+	if err != nil {
+		ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if isCSV {
+		writeEffectiveAccessCSV(w, fmt.Sprintf("user-%d-effective-access.csv", uid), effectiveAccess)
+		return
+	}
+	JSON(w, http.StatusOK, effectiveAccess)
+}
+
+// writeEffectiveAccessCSV writes an effective access report as a CSV attachment with the given file name.
+// The access levels are written by name, and folder names are unescaped.
+// Cells set by users are neutralized with csvText, so spreadsheets don't run them as formulas.
+func writeEffectiveAccessCSV(w http.ResponseWriter, name string, effectiveAccess []models.EffectiveAccess) {
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", contentDisposition("attachment", name))
+	w.WriteHeader(http.StatusOK)
+
+	writer := csv.NewWriter(w)
+	_ = writer.Write(effectiveAccessColumns)
+	for _, access := range effectiveAccess {
+		_ = writer.Write([]string{
+			fmt.Sprint(access.UserID),
+			csvText(access.Username),
+			fmt.Sprint(access.FolderID),
+			csvText(html.UnescapeString(access.FolderName)),
+			access.AccessLevel.String(),
+			string(access.Reason),
+			csvText(access.Explanation),
+			fmt.Sprint(access.AccessRoleID),
+			fmt.Sprint(access.UserRoleID),
+			fmt.Sprint(access.SourceFolderID),
+		})
+	}
+	writer.Flush()
+}
+
+// csvText prefixes text starting with a character spreadsheets treat as the start of a formula with a quote,
+// so it is shown as text.
+func csvText(text string) string {
+	if text != "" && strings.ContainsRune("=+-@\t\r", rune(text[0])) {
+		return "'" + text
+	}
+	return text
+}
@@ -6,6 +6,7 @@ import (
 	"io/ioutil"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/gorilla/mux"
 
@@ -81,6 +82,126 @@ func (s *Server) GetFileByID(w http.ResponseWriter, r *http.Request, user models
 	JSON(w, http.StatusOK, file)
 }
 
+// GetFiles lists the files matching the filters in the query parameters, a page at a time.
+// The folder_id parameter limits the files to a folder, and to the folders nested inside it if recursive is true.
+// Without it, files in every folder are listed.
+// The files can also be filtered by published, editor_id, updated_after, an RFC 3339 time, and name_prefix.
+// The sort parameter is one of name, created_at or updated_at, and the order parameter is either asc or desc.
+// The limit parameter sets the number of files in a page, 20 by default, and the cursor parameter continues from a
+// previous page.
+// The total number of files and the cursor of the next page are returned in the X-Total-Count and X-Next-Cursor headers.
+// Only files in folders the user can view are listed, leaving out drafts the user isn't allowed to see.
+func (s *Server) GetFiles(w http.ResponseWriter, r *http.Request, user models.User) {
+	query, err := parseFileQuery(r)
+	if err != nil {
+		ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	var folderID uint
+	if value := r.URL.Query().Get("folder_id"); value != "" {
+		fid, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			ERROR(w, http.StatusBadRequest, err)
+			return
+		}
+		folderID = uint(fid)
+	}
+	recursive := r.URL.Query().Get("recursive") == "true"
+
+	s.Mutex.RLock()
+	// Find the folders to list files from.
+	var folderIDs []uint
+	if folderID == 0 {
+		folderIDs, err = models.GetAllFolderIDs(s.DB)
+	} else if recursive {
+		var subtree models.FolderSubtree
+		subtree, err = models.GetFolderSubtree(s.DB, folderID)
+		folderIDs = subtree.FolderIDs
+	} else {
+		_, err = models.GetFolderByID(s.DB, folderID)
+		folderIDs = []uint{folderID}
+	}
+	if err != nil {
+		s.Mutex.RUnlock()
+		ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	// Verify the user has access to the folders, leaving out the ones the user can't view.
+	accessLevels, err := models.GetUserAuthorizationFolders(s.DB, user, folderIDs)
+	if err != nil {
+		s.Mutex.RUnlock()
+		ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+	if folderID != 0 && accessLevels[folderID] < models.Viewer {
+		s.Mutex.RUnlock()
+		ERROR(w, http.StatusForbidden, ErrUserForbidden)
+		return
+	}
+	for _, id := range folderIDs {
+		if accessLevels[id] >= models.Viewer {
+			query.FolderIDs = append(query.FolderIDs, id)
+		}
+		// Publishers can see drafts, while other users can only see their own drafts.
+		if accessLevels[id] >= models.Publisher {
+			query.DraftFolderIDs = append(query.DraftFolderIDs, id)
+		}
+	}
+	query.DraftEditorID = user.ID
+
+	files, info, err := models.QueryFiles(s.DB, query)
+	s.Mutex.RUnlock()
+	if isPageError(err) {
+		ERROR(w, http.StatusBadRequest, err)
+		return
+	} else if err != nil {
+		ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	setPageHeaders(w, info)
+	JSON(w, http.StatusOK, files)
+}
+
+// parseFileQuery parses the filters, sort and page of a file listing from the query parameters of a request.
+func parseFileQuery(r *http.Request) (models.FileQuery, error) {
+	params := r.URL.Query()
+	query := models.FileQuery{
+		NamePrefix: params.Get("name_prefix"),
+	}
+
+	var err error
+	query.Page, err = parsePage(r, defaultPageLimit)
+	if err != nil {
+		return models.FileQuery{}, err
+	}
+
+	if value := params.Get("published"); value != "" {
+		published, err := strconv.ParseBool(value)
+		if err != nil {
+			return models.FileQuery{}, err
+		}
+		query.IsPublished = &published
+	}
+	if value := params.Get("editor_id"); value != "" {
+		editorID, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			return models.FileQuery{}, err
+		}
+		query.LastEditorID = uint(editorID)
+	}
+	if value := params.Get("updated_after"); value != "" {
+		query.UpdatedAfter, err = time.Parse(time.RFC3339, value)
+		if err != nil {
+			return models.FileQuery{}, err
+		}
+	}
+
+	return query, nil
+}
+
 // UpdateFile updates a file's metadata based on its id.
 func (s *Server) UpdateFile(w http.ResponseWriter, r *http.Request, user models.User) {
 	vars := mux.Vars(r)
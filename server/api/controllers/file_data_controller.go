@@ -1,18 +1,31 @@
 package controllers
 
 import (
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"html"
+	"io"
+	"mime"
+	"mime/multipart"
 	"net/http"
+	"path"
 	"strconv"
-	"time"
+	"strings"
 
 	"github.com/gorilla/mux"
+	"gorm.io/gorm"
 
+	"github.com/vincetiu8/penn-spark-server/api/filesystem"
 	"github.com/vincetiu8/penn-spark-server/api/models"
 )
 
-const maxUploadSize = 8 << (10 * 3)
-
-// CreateFileData saves a file to the server, and overwrites existing file data.
+// CreateFileData saves a file to the server as a new version of the file.
+// Previous versions of the file data are kept and can be retrieved or restored.
+// The upload is streamed to a temporary file, so the server lock is only held while the metadata is updated.
+// The content type of the data is detected and the upload is rejected if the upload policy of the file's folder
+// doesn't allow its content type or size. The data is scanned for malware, and can't be downloaded until it is clean.
+// The text of clean data is indexed so the file can be found by its contents.
 // If associated file metadata doesn't exist, the operation will fail.
 func (s *Server) CreateFileData(w http.ResponseWriter, r *http.Request, user models.User) {
 	vars := mux.Vars(r)
@@ -23,36 +36,88 @@ func (s *Server) CreateFileData(w http.ResponseWriter, r *http.Request, user mod
 	}
 	fileID := uint(fid)
 
-	err = r.ParseMultipartForm(maxUploadSize)
+	// Verify the user can upload the file before reading the upload.
+	s.Mutex.RLock()
+	file, err := models.GetFileByID(s.DB, fileID)
 	if err != nil {
+		s.Mutex.RUnlock()
 		ERROR(w, http.StatusBadRequest, err)
 		return
 	}
+	if user.ID != file.LastEditorID {
+		s.Mutex.RUnlock()
+		ERROR(w, http.StatusForbidden, ErrUserForbidden)
+		return
+	}
 
-	// Read the file in to memory.
-	fileData, _, err := r.FormFile("file")
+	// Stop reading uploads that are too large for the folder early.
+	policy, err := models.GetEffectiveUploadPolicy(s.DB, file.FolderID)
+	s.Mutex.RUnlock()
+	if err != nil {
+		ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+	maxSize := s.MaxUploadSize
+	if policy.MaxFileSize > 0 && (maxSize == 0 || policy.MaxFileSize < maxSize) {
+		maxSize = policy.MaxFileSize
+	}
+
+	reader, err := r.MultipartReader()
 	if err != nil {
 		ERROR(w, http.StatusBadRequest, err)
 		return
 	}
-	defer fileData.Close()
+	fileData, err := getFormFilePart(reader, "file")
+	if err != nil {
+		ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	// Stream the file to a temporary file.
+	tempFile, err := s.FileSystem.WriteTempFile(fileData, maxSize)
+	if err == filesystem.ErrFileTooLarge {
+		ERROR(w, http.StatusRequestEntityTooLarge, err)
+		return
+	} else if err != nil {
+		ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+	defer s.FileSystem.RemoveTempFile(tempFile)
+
+	// Scan the data and extract its text before taking the lock, as both can be slow.
+	scanStatus := s.scanTempFile(tempFile)
+	text, indexed := s.extractTempFileText(tempFile, file.Name, scanStatus)
 
 	s.Mutex.Lock()
-	// Verify file metadata for this file exists.
-	file, err := models.GetFileByID(s.DB, fileID)
+	// Verify the file wasn't deleted during the upload.
+	file, err = models.GetFileByID(s.DB, fileID)
 	if err != nil {
 		s.Mutex.Unlock()
 		ERROR(w, http.StatusBadRequest, err)
 		return
 	}
-	if user.ID != file.LastEditorID {
+
+	// Verify the data is allowed in the folder, whose policy may have changed during the upload.
+	contentType := detectContentType(tempFile.ContentType, html.UnescapeString(file.Name))
+	status, err := s.checkUploadPolicy(s.DB, file.FolderID, tempFile.Size, contentType)
+	if err != nil {
 		s.Mutex.Unlock()
-		ERROR(w, http.StatusForbidden, ErrUserForbidden)
+		ERROR(w, status, err)
 		return
 	}
 
-	// Upsert the file.
-	err = s.FileSystem.UpsertFileRaw(fileID, fileData)
+	// Store the file data as a new version.
+	_, err = s.createFileVersion(file, user.ID, tempFile, scanStatus)
+	if err == nil && indexed {
+		err = models.UpdateFileSearchText(s.DB, file.ID, tempFile.Checksum, text)
+	}
+	if err != nil {
+		s.Mutex.Unlock()
+		ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	file, err = models.GetFileByID(s.DB, fileID)
 	s.Mutex.Unlock()
 	if err != nil {
 		ERROR(w, http.StatusInternalServerError, err)
@@ -62,7 +127,116 @@ func (s *Server) CreateFileData(w http.ResponseWriter, r *http.Request, user mod
 	JSON(w, http.StatusOK, file)
 }
 
+// createFileVersion stores an uploaded temporary file as the next version of a file, along with the outcome of scanning it.
+// Data that is already stored by another version is shared rather than stored again.
+// Infected data is stored as well, but is quarantined so it can't be downloaded.
+// The caller must hold the write lock.
+func (s *Server) createFileVersion(file models.File, uploaderID uint, tempFile filesystem.TempFile,
+	scanStatus models.ScanStatus) (models.FileVersion, error) {
+	number, err := models.GetNextFileVersionNumber(s.DB, file.ID)
+	if err != nil {
+		return models.FileVersion{}, err
+	}
+
+	err = s.FileSystem.CommitBlobRaw(tempFile)
+	if err != nil {
+		return models.FileVersion{}, err
+	}
+
+	return models.CreateFileVersion(s.DB, models.FileVersion{
+		FileID:      file.ID,
+		Number:      number,
+		Size:        tempFile.Size,
+		Checksum:    tempFile.Checksum,
+		ContentType: detectContentType(tempFile.ContentType, html.UnescapeString(file.Name)),
+		ScanStatus:  scanStatus,
+		UploaderID:  uploaderID,
+	})
+}
+
+// checkUploadPolicy verifies the upload policy of a folder allows file data of the given size and content type.
+// Returns the status code to respond with if it doesn't.
+// The caller must hold the read or write lock.
+func (s *Server) checkUploadPolicy(db *gorm.DB, folderID uint, size int64, contentType string) (int, error) {
+	policy, err := models.GetEffectiveUploadPolicy(db, folderID)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	return checkPolicy(policy, size, contentType)
+}
+
+// checkPolicy verifies an upload policy allows file data of the given size and content type,
+// so the same policy can be checked against many files.
+// Returns the status code to respond with if it doesn't.
+func checkPolicy(policy models.UploadPolicy, size int64, contentType string) (int, error) {
+	if !policy.AllowsSize(size) {
+		return http.StatusRequestEntityTooLarge, filesystem.ErrFileTooLarge
+	}
+	if !policy.AllowsContentType(contentType) {
+		return http.StatusUnsupportedMediaType, models.ErrContentTypeNotAllowed
+	}
+	return http.StatusOK, nil
+}
+
+// extensionContentTypes maps file extensions to more specific content types than can be sniffed from file data,
+// keyed by the sniffed type they refine. Types browsers would run, such as HTML, are never listed.
+var extensionContentTypes = map[string]map[string]string{
+	"application/zip": {
+		".docx": "application/vnd.openxmlformats-officedocument.wordprocessingml.document",
+		".xlsx": "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+		".pptx": "application/vnd.openxmlformats-officedocument.presentationml.presentation",
+		".odt":  "application/vnd.oasis.opendocument.text",
+		".ods":  "application/vnd.oasis.opendocument.spreadsheet",
+		".odp":  "application/vnd.oasis.opendocument.presentation",
+		".epub": "application/epub+zip",
+	},
+	"text/plain": {
+		".csv": "text/csv",
+		".tsv": "text/tab-separated-values",
+		".md":  "text/markdown",
+	},
+}
+
+// detectContentType refines the content type sniffed from file data using the extension of the file's name.
+// The extension is only trusted to name a more specific type of the same kind of data,
+// such as a spreadsheet rather than a plain ZIP archive, so renaming a file can't disguise its content.
+// Parameters of the sniffed type, such as the charset of text, are kept.
+func detectContentType(sniffed, name string) string {
+	mediaType, _, err := mime.ParseMediaType(sniffed)
+	if err != nil {
+		return sniffed
+	}
+
+	refined, ok := extensionContentTypes[mediaType][strings.ToLower(path.Ext(name))]
+	if !ok {
+		return sniffed
+	}
+	return strings.Replace(sniffed, mediaType, refined, 1)
+}
+
+// getFormFilePart finds the part of a multipart form with the given form name.
+// Parts before it are skipped without being stored.
+func getFormFilePart(reader *multipart.Reader, name string) (*multipart.Part, error) {
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return nil, http.ErrMissingFile
+		} else if err != nil {
+			return nil, err
+		}
+
+		if part.FormName() == name {
+			return part, nil
+		}
+	}
+}
+
 // GetFileData gets a file's data based on its id.
+// The latest version is returned unless a specific version is requested with the version query parameter.
+// Data that hasn't been scanned for malware yet or that is infected isn't sent.
+// Data stored before versioning was introduced is stored as the file's first version to be scanned before it is sent.
+// The checksum of the data is sent in the ETag and Digest headers and its upload time in the Last-Modified header,
+// so clients can make conditional and range requests with If-None-Match, If-Modified-Since and If-Range.
 func (s *Server) GetFileData(w http.ResponseWriter, r *http.Request, user models.User) {
 	// Get file id
 	vars := mux.Vars(r)
@@ -73,6 +247,17 @@ func (s *Server) GetFileData(w http.ResponseWriter, r *http.Request, user models
 	}
 	fileID := uint(fid)
 
+	// Get the requested version, if any
+	versionNumber := uint(0)
+	if v := r.URL.Query().Get("version"); v != "" {
+		number, err := strconv.ParseUint(v, 10, 32)
+		if err != nil {
+			ERROR(w, http.StatusBadRequest, err)
+			return
+		}
+		versionNumber = uint(number)
+	}
+
 	// Get read lock
 	s.Mutex.RLock()
 
@@ -95,14 +280,107 @@ func (s *Server) GetFileData(w http.ResponseWriter, r *http.Request, user models
 		return
 	}
 
-	// Load the file data into memory
-	fileData, err := s.FileSystem.GetFileRaw(fileID)
+	// Find the version of the file data to send
+	var version models.FileVersion
+	if versionNumber == 0 {
+		version, err = models.GetLatestFileVersion(s.DB, fileID)
+	} else {
+		version, err = models.GetFileVersion(s.DB, fileID, versionNumber)
+	}
+	if err != nil && (err != models.ErrFileVersionNotFound || versionNumber != 0) {
+		s.Mutex.RUnlock()
+		ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	// Data stored before versioning was introduced has never been scanned
+	// It becomes the first version of the file, which isn't sent until it has been scanned
+	if err == models.ErrFileVersionNotFound {
+		s.Mutex.RUnlock()
+		s.Mutex.Lock()
+		_, err = models.GetLatestFileVersion(s.DB, fileID)
+		if err == models.ErrFileVersionNotFound {
+			_, err = s.migrateLegacyFileData(fileID)
+		}
+		s.Mutex.Unlock()
+		if err == filesystem.ErrBlobNotFound {
+			ERROR(w, http.StatusBadRequest, models.ErrFileVersionNotFound)
+			return
+		} else if err != nil {
+			ERROR(w, http.StatusInternalServerError, err)
+			return
+		}
+		ERROR(w, http.StatusConflict, models.ErrScanPending)
+		return
+	}
+
+	// Data is only sent once it has been scanned and found clean
+	if version.ScanStatus == models.ScanPending {
+		s.Mutex.RUnlock()
+		ERROR(w, http.StatusConflict, models.ErrScanPending)
+		return
+	} else if version.ScanStatus == models.ScanInfected {
+		s.Mutex.RUnlock()
+		ERROR(w, http.StatusForbidden, models.ErrFileInfected)
+		return
+	}
+
+	// Open the file data, which is only read from the store as it is sent
+	fileData, err := s.FileSystem.GetBlobRaw(version.Checksum)
 	s.Mutex.RUnlock()
 	if err != nil {
 		ERROR(w, http.StatusInternalServerError, err)
 		return
 	}
+	defer fileData.Close()
+
+	// Describe the data sent so clients can verify and cache it
+	setChecksumHeaders(w, version.Checksum)
+	name := html.UnescapeString(file.Name)
+	w.Header().Set("Content-Disposition", contentDisposition("inline", name))
+
+	// Send the content type detected when the data was uploaded, and stop browsers from guessing another one
+	if version.ContentType != "" {
+		w.Header().Set("Content-Type", version.ContentType)
+	}
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+
+	// The data is only available to authorized users, so shared caches mustn't store it
+	// and browsers have to check their cached copy is still current, which is cheap with conditional requests
+	w.Header().Set("Cache-Control", "private, no-cache")
 
 	// Send the file content back to the client
-	http.ServeContent(w, r, file.Name, time.Now(), fileData)
+	http.ServeContent(w, r, name, version.CreatedAt, fileData)
+}
+
+// contentDisposition formats a Content-Disposition header suggesting a file name, as described in RFC 6266.
+// The name is sent as is in the filename* parameter, with a plain ASCII version for clients that don't support it.
+func contentDisposition(disposition, name string) string {
+	var fallback, encoded strings.Builder
+	for _, r := range name {
+		if r < ' ' || r > '~' || r == '"' || r == '\\' {
+			fallback.WriteByte('_')
+		} else {
+			fallback.WriteRune(r)
+		}
+	}
+	for _, b := range []byte(name) {
+		if 'A' <= b && b <= 'Z' || 'a' <= b && b <= 'z' || '0' <= b && b <= '9' || strings.IndexByte("!#$&+-.^_`|~", b) >= 0 {
+			encoded.WriteByte(b)
+		} else {
+			encoded.WriteString(fmt.Sprintf("%%%02X", b))
+		}
+	}
+
+	return fmt.Sprintf("%s; filename=\"%s\"; filename*=UTF-8''%s", disposition, fallback.String(), encoded.String())
+}
+
+// setChecksumHeaders sets the ETag and Digest headers from the hex-encoded SHA-256 checksum of file data.
+func setChecksumHeaders(w http.ResponseWriter, checksum string) {
+	w.Header().Set("ETag", fmt.Sprintf("\"%s\"", checksum))
+
+	digest, err := hex.DecodeString(checksum)
+	if err == nil {
+		w.Header().Set("Digest", "sha-256="+base64.StdEncoding.EncodeToString(digest))
+	}
 }
@@ -0,0 +1,112 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+
+	"github.com/vincetiu8/penn-spark-server/api/models"
+)
+
+// GetFileVersions gets the version history of a file's data based on its id.
+func (s *Server) GetFileVersions(w http.ResponseWriter, r *http.Request, user models.User) {
+	vars := mux.Vars(r)
+	fid, err := strconv.ParseUint(vars["id"], 10, 32)
+	if err != nil {
+		ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+	fileID := uint(fid)
+
+	s.Mutex.RLock()
+	// Verify the user has access rights to the file.
+	file, accessLevel, err := models.GetUserAuthorizationFile(s.DB, user, fileID)
+	if err != nil {
+		s.Mutex.RUnlock()
+		ERROR(w, http.StatusBadRequest, err)
+		return
+	} else if accessLevel < models.Viewer && file.LastEditorID != user.ID {
+		s.Mutex.RUnlock()
+		ERROR(w, http.StatusForbidden, ErrUserForbidden)
+		return
+	}
+
+	if !file.IsPublished && file.LastEditorID != user.ID && accessLevel < models.Publisher {
+		s.Mutex.RUnlock()
+		ERROR(w, http.StatusForbidden, ErrUserForbidden)
+		return
+	}
+
+	versions, err := models.GetFileVersions(s.DB, fileID)
+	s.Mutex.RUnlock()
+	if err != nil {
+		ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	JSON(w, http.StatusOK, versions)
+}
+
+// RestoreFileVersion makes an older version of a file's data the current one.
+// A new version sharing the older version's data is created, so the version history is never rewritten
+// and no data is copied.
+func (s *Server) RestoreFileVersion(w http.ResponseWriter, r *http.Request, user models.User) {
+	vars := mux.Vars(r)
+	fid, err := strconv.ParseUint(vars["id"], 10, 32)
+	if err != nil {
+		ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+	fileID := uint(fid)
+
+	vid, err := strconv.ParseUint(vars["version"], 10, 32)
+	if err != nil {
+		ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	s.Mutex.Lock()
+	// Only the uploader of the file and publishers can change a file's data.
+	file, accessLevel, err := models.GetUserAuthorizationFile(s.DB, user, fileID)
+	if err != nil {
+		s.Mutex.Unlock()
+		ERROR(w, http.StatusBadRequest, err)
+		return
+	} else if file.LastEditorID != user.ID && accessLevel < models.Publisher {
+		s.Mutex.Unlock()
+		ERROR(w, http.StatusForbidden, ErrUserForbidden)
+		return
+	}
+
+	oldVersion, err := models.GetFileVersion(s.DB, fileID, uint(vid))
+	if err != nil {
+		s.Mutex.Unlock()
+		ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	number, err := models.GetNextFileVersionNumber(s.DB, fileID)
+	if err != nil {
+		s.Mutex.Unlock()
+		ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	version, err := models.CreateFileVersion(s.DB, models.FileVersion{
+		FileID:      fileID,
+		Number:      number,
+		Size:        oldVersion.Size,
+		Checksum:    oldVersion.Checksum,
+		ContentType: oldVersion.ContentType,
+		ScanStatus:  oldVersion.ScanStatus,
+		UploaderID:  user.ID,
+	})
+	s.Mutex.Unlock()
+	if err != nil {
+		ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	JSON(w, http.StatusCreated, version)
+}
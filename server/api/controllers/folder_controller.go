@@ -58,6 +58,8 @@ func (s *Server) CreateFolder(w http.ResponseWriter, r *http.Request, user model
 }
 
 // GetFolderByID gets a folder by its id.
+// The files in the folder are sorted by name by default, and can be paged through with the limit, cursor,
+// sort and order query parameters, with the total number of files set in the X-Total-Count header.
 func (s *Server) GetFolderByID(w http.ResponseWriter, r *http.Request, user models.User) {
 	// Get folder id
 	vars := mux.Vars(r)
@@ -68,82 +70,73 @@ func (s *Server) GetFolderByID(w http.ResponseWriter, r *http.Request, user mode
 	}
 	folderID := uint(fid)
 
+	page, err := parsePage(r, defaultPageLimit)
+	if err != nil {
+		ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
 	// Get a read lock
 	s.Mutex.RLock()
 
-	// Verify user has access to the folder
-	folder, accessLevel, err := models.GetUserAuthorizationFolder(s.DB, user, folderID)
+	// Files are loaded separately below, so only the page that was asked for is loaded
+	folder, err := models.GetFolderWithChildren(s.DB, folderID)
 	if err != nil {
 		s.Mutex.RUnlock()
 		ERROR(w, http.StatusBadRequest, err)
 		return
-	} else if accessLevel < models.Viewer {
+	}
+
+	// Get the access level of the user to the folder and its child folders at once
+	folderIDs := []uint{folderID}
+	for _, childFolder := range folder.ChildFolders {
+		folderIDs = append(folderIDs, childFolder.ID)
+	}
+	accessLevels, err := models.GetUserAuthorizationFolders(s.DB, user, folderIDs)
+	if err != nil {
+		s.Mutex.RUnlock()
+		ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	// Verify user has access to the folder
+	accessLevel := accessLevels[folderID]
+	if accessLevel < models.Viewer {
 		s.Mutex.RUnlock()
 		ERROR(w, http.StatusForbidden, ErrUserForbidden)
 		return
 	}
 
-	// Remove draft files so user can't see them
-	if accessLevel < models.Publisher {
-		numFiles := len(folder.Files)
-
-		// Counts number of deleted files
-		deleted := 0
-		for i := 0; i < numFiles; i++ {
-			// Need to subtract the number of deleted files from the current index
-			// This is because we decrease the length of the slice by 1 each time we delete a file
-			// In order for the index to properly line up we make this correction
-			file := folder.Files[i-deleted]
-
-			// If user is the uploader (last editor of a draft file), let them see the file
-			if !file.IsPublished && file.LastEditorID != user.ID {
-
-				// Delete the file from the slice
-				// We swap the file to be deleted with the last element in the slice and then cut the last element
-				// This is more efficient from a memory standpoint
-				lastIndex := numFiles - deleted - 1
-
-				// No need to swap elements if the file is already the last element
-				if lastIndex > 0 {
-					folder.Files[i-deleted] = folder.Files[lastIndex]
-				}
-				folder.Files = folder.Files[:lastIndex]
-				deleted += 1
-			}
+	// Leave out draft files so user can't see them, unless user is the uploader (last editor of a draft file)
+	query := models.FileQuery{FolderIDs: []uint{folderID}, DraftEditorID: user.ID, Page: page}
+	if accessLevel >= models.Publisher {
+		query.DraftFolderIDs = []uint{folderID}
+	}
+	files, info, err := models.QueryFiles(s.DB, query)
+	if err != nil {
+		s.Mutex.RUnlock()
+		if isPageError(err) {
+			ERROR(w, http.StatusBadRequest, err)
+			return
 		}
+		ERROR(w, http.StatusInternalServerError, err)
+		return
 	}
+	folder.Files = files
 
 	// Remove child folders user doesn't have access to
-	numFolders := len(folder.ChildFolders)
-	deleted := 0
-	for i := 0; i < numFolders; i++ {
-		// Get the access level of the user to the child folder
-		_, childAccessLevel, err := models.GetUserAuthorizationFolder(s.DB, user, folder.ChildFolders[i-deleted].ID)
-		if err != nil {
-			s.Mutex.RUnlock()
-			ERROR(w, http.StatusInternalServerError, err)
-			return
-
-			// If user doesn't have access to the child folder, remove it from the list of returned folders
-		} else if childAccessLevel == models.None {
-
-			// Delete the folder from the slice
-			// We swap the folder to be deleted with the last element in the slice and then cut the last element
-			// This is more efficient from a memory standpoint
-			lastIndex := numFolders - deleted - 1
-
-			// No need to swap elements if the folder is already the last element
-			if lastIndex > 0 {
-				folder.ChildFolders[i-deleted] = folder.ChildFolders[lastIndex]
-			}
-			folder.ChildFolders = folder.ChildFolders[:lastIndex]
-			deleted += 1
+	childFolders := []models.Folder{}
+	for _, childFolder := range folder.ChildFolders {
+		if accessLevels[childFolder.ID] != models.None {
+			childFolders = append(childFolders, childFolder)
 		}
 	}
+	folder.ChildFolders = childFolders
 	s.Mutex.RUnlock()
 
 	// We return a custom struct that also contains the access level of the user in the folder
 	// This allows the GUI to render appropriate functions based on the access level
+	setPageHeaders(w, info)
 	JSON(w, http.StatusOK, struct {
 		models.Folder
 		AccessLevel models.AccessLevel `json:"access_level"`
@@ -177,7 +170,7 @@ func (s *Server) UpdateFolder(w http.ResponseWriter, r *http.Request, user model
 
 	s.Mutex.Lock()
 	// Verify folder exists.
-	currentFolder, err := models.GetFolderByID(s.DB, folder.ID)
+	currentFolder, err := models.GetFolderWithChildren(s.DB, folder.ID)
 	if err != nil {
 		s.Mutex.Unlock()
 		ERROR(w, http.StatusBadRequest, err)
@@ -224,6 +217,8 @@ func (s *Server) UpdateFolder(w http.ResponseWriter, r *http.Request, user model
 }
 
 // DeleteFolder deletes a folder by its id.
+// Non-empty folders are only deleted along with their contents if the recursive query parameter is set.
+// If the dry_run query parameter is set, the folders and files that would be deleted are returned instead.
 func (s *Server) DeleteFolder(w http.ResponseWriter, r *http.Request, user models.User) {
 	vars := mux.Vars(r)
 	fid, err := strconv.ParseUint(vars["id"], 10, 32)
@@ -233,8 +228,24 @@ func (s *Server) DeleteFolder(w http.ResponseWriter, r *http.Request, user model
 	}
 	folderID := uint(fid)
 
+	recursive, dryRun := false, false
+	if v := r.URL.Query().Get("recursive"); v != "" {
+		recursive, err = strconv.ParseBool(v)
+		if err != nil {
+			ERROR(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+	if v := r.URL.Query().Get("dry_run"); v != "" {
+		dryRun, err = strconv.ParseBool(v)
+		if err != nil {
+			ERROR(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+
 	s.Mutex.Lock()
-	currentFolder, err := models.GetFolderByID(s.DB, folderID)
+	currentFolder, err := models.GetFolderWithChildren(s.DB, folderID)
 	if err != nil {
 		s.Mutex.Unlock()
 		ERROR(w, http.StatusBadRequest, err)
@@ -253,7 +264,47 @@ func (s *Server) DeleteFolder(w http.ResponseWriter, r *http.Request, user model
 		return
 	}
 
-	err = models.DeleteFolder(s.DB, folderID, user.ID)
+	subtree, err := models.GetFolderSubtree(s.DB, folderID)
+	if err != nil {
+		s.Mutex.Unlock()
+		ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if !recursive && (subtree.FolderCount > 1 || subtree.FileCount > 0) {
+		s.Mutex.Unlock()
+		ERROR(w, http.StatusBadRequest, models.ErrFolderNotEmpty)
+		return
+	}
+
+	// Verify the user is authorized to delete the contents of every folder in the subtree.
+	if recursive {
+		accessLevels, err := models.GetUserAuthorizationFolders(s.DB, user, subtree.FolderIDs)
+		if err != nil {
+			s.Mutex.Unlock()
+			ERROR(w, http.StatusInternalServerError, err)
+			return
+		}
+		for _, subtreeFolderID := range subtree.FolderIDs {
+			if accessLevels[subtreeFolderID] < models.Publisher {
+				s.Mutex.Unlock()
+				ERROR(w, http.StatusForbidden, ErrUserForbidden)
+				return
+			}
+		}
+	}
+
+	if dryRun {
+		s.Mutex.Unlock()
+		JSON(w, http.StatusOK, subtree)
+		return
+	}
+
+	if recursive {
+		_, err = models.DeleteFolderRecursive(s.DB, folderID, user.ID)
+	} else {
+		err = models.DeleteFolder(s.DB, folderID, user.ID)
+	}
 	s.Mutex.Unlock()
 	if err != nil {
 		ERROR(w, http.StatusBadRequest, err)
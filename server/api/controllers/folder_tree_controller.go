@@ -0,0 +1,106 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+
+	"github.com/vincetiu8/penn-spark-server/api/models"
+)
+
+// defaultFolderTreeDepth is the number of levels below a folder returned in its tree when no depth is specified.
+const defaultFolderTreeDepth = 1
+
+// FolderTree is a folder the user can view, along with the folders nested inside it that the user can view.
+// FolderTree.FileCount counts the files in the folder the user can see, leaving out drafts the user isn't allowed to see.
+type FolderTree struct {
+	ID             uint               `json:"id"`
+	Name           string             `json:"name"`
+	ParentFolderID uint               `json:"parent_folder_id"`
+	AccessLevel    models.AccessLevel `json:"access_level"`
+	FileCount      int64              `json:"file_count"`
+	ChildFolders   []*FolderTree      `json:"child_folders"`
+}
+
+// GetFolderTree gets the tree of folders nested inside a folder, down to the number of levels in the depth query parameter.
+// The depth is 1 by default, only returning the child folders, and can be at most models.MaxFolderTreeDepth.
+// Folders the user can't view are left out along with everything inside them.
+func (s *Server) GetFolderTree(w http.ResponseWriter, r *http.Request, user models.User) {
+	vars := mux.Vars(r)
+	fid, err := strconv.ParseUint(vars["id"], 10, 32)
+	if err != nil {
+		ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+	folderID := uint(fid)
+
+	depth := defaultFolderTreeDepth
+	if value := r.URL.Query().Get("depth"); value != "" {
+		depth, err = strconv.Atoi(value)
+		if err != nil {
+			ERROR(w, http.StatusBadRequest, models.ErrInvalidDepth)
+			return
+		}
+	}
+
+	s.Mutex.RLock()
+	nodes, err := models.GetFolderTree(s.DB, folderID, depth, user.ID)
+	if err != nil {
+		s.Mutex.RUnlock()
+		ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	// Get the access level of the user to every folder in the tree at once
+	folderIDs := make([]uint, len(nodes))
+	for i, node := range nodes {
+		folderIDs[i] = node.ID
+	}
+	accessLevels, err := models.GetUserAuthorizationFolders(s.DB, user, folderIDs)
+	s.Mutex.RUnlock()
+	if err != nil {
+		ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	// Verify user has access to the folder
+	if accessLevels[folderID] < models.Viewer {
+		ERROR(w, http.StatusForbidden, ErrUserForbidden)
+		return
+	}
+
+	// Every folder comes after its parent, so a folder is only added if its parent was added
+	trees := make(map[uint]*FolderTree, len(nodes))
+	for _, node := range nodes {
+		parent, ok := trees[node.ParentFolderID]
+		if node.ID != folderID && !ok {
+			continue
+		}
+		accessLevel := accessLevels[node.ID]
+		if accessLevel < models.Viewer {
+			continue
+		}
+
+		// Remove draft files so user can't see them, unless user is the uploader (last editor of a draft file)
+		fileCount := node.PublishedFileCount + node.EditorDraftFileCount
+		if accessLevel >= models.Publisher {
+			fileCount = node.PublishedFileCount + node.DraftFileCount
+		}
+
+		tree := &FolderTree{
+			ID:             node.ID,
+			Name:           node.Name,
+			ParentFolderID: node.ParentFolderID,
+			AccessLevel:    accessLevel,
+			FileCount:      fileCount,
+			ChildFolders:   []*FolderTree{},
+		}
+		trees[node.ID] = tree
+		if node.ID != folderID {
+			parent.ChildFolders = append(parent.ChildFolders, tree)
+		}
+	}
+
+	JSON(w, http.StatusOK, trees[folderID])
+}
@@ -0,0 +1,32 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/vincetiu8/penn-spark-server/api/models"
+)
+
+// GetGrantAudit gets a page of the audit trail of access roles and user role assignments that have expired.
+// The limit and cursor query parameters page through the entries, sorted by id or created_at in the given order.
+// The total number of entries and the cursor of the next page are returned in the X-Total-Count and X-Next-Cursor headers.
+func (s *Server) GetGrantAudit(w http.ResponseWriter, r *http.Request, _ models.User) {
+	page, err := parsePage(r, defaultPageLimit)
+	if err != nil {
+		ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	s.Mutex.RLock()
+	entries, info, err := models.GetGrantAuditPage(s.DB, page)
+	s.Mutex.RUnlock()
+	if isPageError(err) {
+		ERROR(w, http.StatusBadRequest, err)
+		return
+	} else if err != nil {
+		ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	setPageHeaders(w, info)
+	JSON(w, http.StatusOK, entries)
+}
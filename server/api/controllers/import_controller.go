@@ -0,0 +1,392 @@
+package controllers
+
+import (
+	"archive/zip"
+	"errors"
+	"io"
+	"net/http"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+
+	"github.com/vincetiu8/penn-spark-server/api/filesystem"
+	"github.com/vincetiu8/penn-spark-server/api/models"
+)
+
+// ErrInvalidArchivePath returned when an entry of an archive has a path outside the folder it is extracted to.
+var ErrInvalidArchivePath = errors.New("invalid archive path")
+
+// ErrArchiveTooLarge returned when the files of an archive are larger in total than the import limit.
+var ErrArchiveTooLarge = errors.New("archive too large")
+
+// ErrTooManyArchiveEntries returned when an archive has more entries than the import limit.
+var ErrTooManyArchiveEntries = errors.New("too many archive entries")
+
+// ImportReport describes the folders and files created by importing an archive into a folder.
+// ImportReport.Conflicts lists the entries of the archive that weren't imported.
+type ImportReport struct {
+	Folders   []models.Folder  `json:"folders"`
+	Files     []models.File    `json:"files"`
+	Conflicts []ImportConflict `json:"conflicts"`
+}
+
+// ImportConflict describes an entry of an archive that couldn't be imported, such as a file whose name is taken.
+type ImportConflict struct {
+	Path  string `json:"path"`
+	Error string `json:"error"`
+}
+
+// importEntry is a folder or file of an archive, with a file's data extracted to a temporary file, scanned and
+// its text extracted for search.
+type importEntry struct {
+	path       string
+	isFolder   bool
+	tempFile   filesystem.TempFile
+	scanStatus models.ScanStatus
+	text       string
+	indexed    bool
+}
+
+// importFolder is a folder an archive is extracted into, along with the user's access level to it.
+// Folders created by the import are treated as having the access level of the folder they were created in.
+// The error is set if the folder couldn't be created, so nothing is extracted into it.
+type importFolder struct {
+	id          uint
+	accessLevel models.AccessLevel
+	err         error
+}
+
+// ImportFolderArchive extracts a ZIP archive into a folder as child folders and draft files.
+// Creating files requires uploader access and creating folders requires publisher access to the folder they are created in.
+// Entries that conflict with existing files, that the upload policy of their folder doesn't allow
+// or that the user isn't allowed to create are reported and skipped,
+// while any other error undoes the whole import.
+// The archive is extracted to temporary files before the lock is taken, so the lock is only held to store them.
+func (s *Server) ImportFolderArchive(w http.ResponseWriter, r *http.Request, user models.User) {
+	vars := mux.Vars(r)
+	fid, err := strconv.ParseUint(vars["id"], 10, 32)
+	if err != nil {
+		ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+	folderID := uint(fid)
+
+	// Verify the user can upload files to the folder before reading the upload.
+	s.Mutex.RLock()
+	_, accessLevel, err := models.GetUserAuthorizationFolder(s.DB, user, folderID)
+	s.Mutex.RUnlock()
+	if err != nil {
+		ERROR(w, http.StatusBadRequest, err)
+		return
+	} else if accessLevel < models.Uploader {
+		ERROR(w, http.StatusForbidden, ErrUserForbidden)
+		return
+	}
+
+	reader, err := r.MultipartReader()
+	if err != nil {
+		ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+	archiveData, err := getFormFilePart(reader, "file")
+	if err != nil {
+		ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	// Stream the archive to a temporary file, as its contents can only be listed once it is complete.
+	archiveFile, err := s.FileSystem.WriteTempFile(archiveData, s.MaxUploadSize)
+	if err == filesystem.ErrFileTooLarge {
+		ERROR(w, http.StatusRequestEntityTooLarge, err)
+		return
+	} else if err != nil {
+		ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+	defer s.FileSystem.RemoveTempFile(archiveFile)
+
+	report := ImportReport{
+		Folders:   []models.Folder{},
+		Files:     []models.File{},
+		Conflicts: []ImportConflict{},
+	}
+	entries, err := s.extractArchive(archiveFile, &report)
+	defer func() {
+		for _, entry := range entries {
+			s.FileSystem.RemoveTempFile(entry.tempFile)
+		}
+	}()
+	if err == zip.ErrFormat || err == zip.ErrAlgorithm || err == zip.ErrChecksum {
+		ERROR(w, http.StatusBadRequest, err)
+		return
+	} else if err == ErrArchiveTooLarge || err == ErrTooManyArchiveEntries {
+		ERROR(w, http.StatusRequestEntityTooLarge, err)
+		return
+	} else if err != nil {
+		ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	s.Mutex.Lock()
+	var checksums []string
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		// The user's access may have changed during the upload.
+		_, accessLevel, err := models.GetUserAuthorizationFolder(tx, user, folderID)
+		if err != nil {
+			return err
+		}
+		folders := map[string]*importFolder{
+			".": {id: folderID, accessLevel: accessLevel},
+		}
+
+		for _, entry := range entries {
+			if entry.isFolder {
+				_, err = s.importArchiveFolder(tx, user, entry.path, folders, &report)
+			} else {
+				err = s.importArchiveFile(tx, user, entry, folders, &report, &checksums)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		// Remove any data that was only stored for the import.
+		for _, checksum := range checksums {
+			_, blobErr := models.GetBlob(s.DB, checksum)
+			if blobErr == models.ErrBlobNotFound {
+				_ = s.FileSystem.DeleteBlobRaw(checksum)
+			}
+		}
+		s.Mutex.Unlock()
+		ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+	s.Mutex.Unlock()
+
+	JSON(w, http.StatusCreated, report)
+}
+
+// extractArchive lists the folders and files of a ZIP archive and extracts the data of each file to a temporary file,
+// which is scanned for malware.
+// Entries are ordered by path, so every folder comes before its contents.
+// Entries with invalid paths or that are too large are added to the report's conflicts and skipped.
+// The archive is rejected if it has too many entries or its files are too large in total, which is checked both
+// against the sizes the archive declares and the data actually extracted.
+func (s *Server) extractArchive(archiveFile filesystem.TempFile, report *ImportReport) ([]importEntry, error) {
+	file, err := s.FileSystem.Open(archiveFile.Path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	archive, err := zip.NewReader(file, archiveFile.Size)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.MaxImportEntries > 0 && len(archive.File) > s.MaxImportEntries {
+		return nil, ErrTooManyArchiveEntries
+	}
+	if s.MaxImportSize > 0 {
+		var declaredSize uint64
+		for _, archiveEntry := range archive.File {
+			declaredSize += archiveEntry.UncompressedSize64
+			if declaredSize > uint64(s.MaxImportSize) {
+				return nil, ErrArchiveTooLarge
+			}
+		}
+	}
+
+	var entries []importEntry
+	var extractedSize int64
+	for _, archiveEntry := range archive.File {
+		entry := importEntry{
+			path:     path.Clean(archiveEntry.Name),
+			isFolder: archiveEntry.FileInfo().IsDir(),
+		}
+		if entry.path == "." || path.IsAbs(entry.path) || entry.path == ".." || strings.HasPrefix(entry.path, "../") {
+			report.Conflicts = append(report.Conflicts, ImportConflict{
+				Path:  archiveEntry.Name,
+				Error: ErrInvalidArchivePath.Error(),
+			})
+			continue
+		}
+
+		if !entry.isFolder {
+			data, err := archiveEntry.Open()
+			if err != nil {
+				return entries, err
+			}
+			// Only read up to a byte past the remaining import limit, so the total can't be exceeded by much.
+			var entryData io.Reader = data
+			if s.MaxImportSize > 0 {
+				entryData = io.LimitReader(data, s.MaxImportSize-extractedSize+1)
+			}
+			entry.tempFile, err = s.FileSystem.WriteTempFile(entryData, s.MaxUploadSize)
+			data.Close()
+			if err == filesystem.ErrFileTooLarge {
+				report.Conflicts = append(report.Conflicts, ImportConflict{
+					Path:  entry.path,
+					Error: err.Error(),
+				})
+				continue
+			} else if err != nil {
+				return entries, err
+			}
+			extractedSize += entry.tempFile.Size
+			if s.MaxImportSize > 0 && extractedSize > s.MaxImportSize {
+				s.FileSystem.RemoveTempFile(entry.tempFile)
+				return entries, ErrArchiveTooLarge
+			}
+			entry.scanStatus = s.scanTempFile(entry.tempFile)
+			entry.text, entry.indexed = s.extractTempFileText(entry.tempFile, path.Base(entry.path), entry.scanStatus)
+		}
+		entries = append(entries, entry)
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].path < entries[j].path
+	})
+	return entries, nil
+}
+
+// importArchiveFolder finds the folder an archive path refers to, creating it and its parents if they don't exist.
+// Existing folders are reused, so an archive can add files to folders that already exist.
+// The caller must hold the write lock.
+func (s *Server) importArchiveFolder(db *gorm.DB, user models.User, folderPath string, folders map[string]*importFolder,
+	report *ImportReport) (*importFolder, error) {
+	if folder, ok := folders[folderPath]; ok {
+		return folder, nil
+	}
+
+	parent, err := s.importArchiveFolder(db, user, path.Dir(folderPath), folders, report)
+	if err != nil {
+		return nil, err
+	}
+	folder := &importFolder{err: parent.err}
+	folders[folderPath] = folder
+	if folder.err != nil {
+		return folder, nil
+	}
+
+	parentID := parent.id
+	existingFolder, err := models.GetFolderByPath(db, models.Folder{
+		Name:           path.Base(folderPath),
+		ParentFolderID: &parentID,
+	})
+	if err == nil {
+		folder.id = existingFolder.ID
+		_, folder.accessLevel, err = models.GetUserAuthorizationFolder(db, user, existingFolder.ID)
+		return folder, err
+	} else if err != models.ErrFolderNotFound {
+		return nil, err
+	}
+
+	if parent.accessLevel < models.Publisher {
+		folder.err = ErrUserForbidden
+		report.Conflicts = append(report.Conflicts, ImportConflict{
+			Path:  folderPath,
+			Error: folder.err.Error(),
+		})
+		return folder, nil
+	}
+
+	createdFolder, err := models.CreateFolder(db, models.Folder{
+		Name:           path.Base(folderPath),
+		ParentFolderID: &parentID,
+		LastEditorID:   user.ID,
+	})
+	if err != nil {
+		return nil, err
+	}
+	folder.id = createdFolder.ID
+	folder.accessLevel = parent.accessLevel
+	report.Folders = append(report.Folders, createdFolder)
+	return folder, nil
+}
+
+// importArchiveFile creates a draft file from an archive entry and stores its data as the file's first version.
+// The checksum of the stored data is recorded, so it can be removed if the import is undone.
+// The caller must hold the write lock.
+func (s *Server) importArchiveFile(db *gorm.DB, user models.User, entry importEntry, folders map[string]*importFolder,
+	report *ImportReport, checksums *[]string) error {
+	folder, err := s.importArchiveFolder(db, user, path.Dir(entry.path), folders, report)
+	if err != nil {
+		return err
+	}
+	contentType := detectContentType(entry.tempFile.ContentType, path.Base(entry.path))
+	if folder.err == nil && folder.accessLevel < models.Uploader {
+		err = ErrUserForbidden
+	} else {
+		err = folder.err
+	}
+	if err == nil {
+		var status int
+		status, err = s.checkUploadPolicy(db, folder.id, entry.tempFile.Size, contentType)
+		if status == http.StatusInternalServerError {
+			return err
+		}
+	}
+	if err != nil {
+		report.Conflicts = append(report.Conflicts, ImportConflict{
+			Path:  entry.path,
+			Error: err.Error(),
+		})
+		return nil
+	}
+
+	file, err := models.CreateFile(db, models.File{
+		Name:         path.Base(entry.path),
+		FolderID:     folder.id,
+		LastEditorID: user.ID,
+		IsPublished:  false,
+	})
+	if err == models.ErrFileAlreadyExists {
+		report.Conflicts = append(report.Conflicts, ImportConflict{
+			Path:  entry.path,
+			Error: err.Error(),
+		})
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	err = s.FileSystem.CommitBlobRaw(entry.tempFile)
+	if err != nil {
+		return err
+	}
+	*checksums = append(*checksums, entry.tempFile.Checksum)
+
+	_, err = models.CreateFileVersion(db, models.FileVersion{
+		FileID:      file.ID,
+		Number:      1,
+		Size:        entry.tempFile.Size,
+		Checksum:    entry.tempFile.Checksum,
+		ContentType: contentType,
+		ScanStatus:  entry.scanStatus,
+		UploaderID:  user.ID,
+	})
+	if err != nil {
+		return err
+	}
+
+	if entry.indexed {
+		err = models.UpdateFileSearchText(db, file.ID, entry.tempFile.Checksum, entry.text)
+		if err != nil {
+			return err
+		}
+	}
+
+	file, err = models.GetFileByID(db, file.ID)
+	if err != nil {
+		return err
+	}
+	report.Files = append(report.Files, file)
+	return nil
+}
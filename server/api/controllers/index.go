@@ -0,0 +1,86 @@
+package controllers
+
+import (
+	"html"
+	"io"
+	"log"
+
+	"github.com/vincetiu8/penn-spark-server/api/extract"
+	"github.com/vincetiu8/penn-spark-server/api/filesystem"
+	"github.com/vincetiu8/penn-spark-server/api/models"
+)
+
+// extractTempFileText extracts the text of uploaded data so its contents can be searched.
+// Only clean data is indexed, so returns false for data that is infected or hasn't been scanned yet,
+// which is indexed by IndexFileContents once it is found clean.
+// Data whose content type has no text to extract is indexed without any text.
+// The lock doesn't need to be held, as the temporary file belongs to the upload.
+func (s *Server) extractTempFileText(tempFile filesystem.TempFile, name string,
+	scanStatus models.ScanStatus) (string, bool) {
+	if scanStatus != models.ScanClean {
+		return "", false
+	}
+
+	file, err := s.FileSystem.Open(tempFile.Path)
+	if err != nil {
+		log.Println("can't extract text from uploaded data:", err)
+		return "", false
+	}
+	defer file.Close()
+
+	return extractText(file, detectContentType(tempFile.ContentType, html.UnescapeString(name)))
+}
+
+// IndexFileContents indexes the text of the data of every clean file that hasn't been indexed yet,
+// such as files whose data was scanned after it was uploaded or that were uploaded before search was introduced.
+// The text is extracted without holding the lock, so uploads aren't blocked by large documents.
+func (s *Server) IndexFileContents() error {
+	s.Mutex.RLock()
+	files, err := models.GetUnindexedFiles(s.DB)
+	s.Mutex.RUnlock()
+	if err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		text, ok := s.extractBlobText(file.Checksum, file.ContentType)
+		if !ok {
+			continue
+		}
+
+		// If the file's data changed while the text was extracted, the file is indexed again by the next sweep.
+		s.Mutex.Lock()
+		err = models.UpdateFileSearchText(s.DB, file.ID, file.Checksum, text)
+		s.Mutex.Unlock()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// extractBlobText extracts the text of stored data, returning false if the data can't be read.
+func (s *Server) extractBlobText(checksum, contentType string) (string, bool) {
+	data, err := s.FileSystem.GetBlobRaw(checksum)
+	if err != nil {
+		log.Println("can't extract text from stored data:", err)
+		return "", false
+	}
+	defer data.Close()
+
+	return extractText(data, contentType)
+}
+
+// extractText extracts the text of file data, logging any error.
+// Data that has no text to extract, such as images, or that can't be parsed is indexed without any text,
+// so it isn't extracted again.
+func extractText(data io.Reader, contentType string) (string, bool) {
+	text, err := extract.Text(data, contentType)
+	if err == extract.ErrUnsupportedContentType {
+		return "", true
+	} else if err != nil {
+		log.Println("can't extract text from file data:", err)
+		return "", true
+	}
+	return text, true
+}
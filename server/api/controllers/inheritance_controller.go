@@ -0,0 +1,51 @@
+package controllers
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+
+	"github.com/vincetiu8/penn-spark-server/api/models"
+)
+
+// InheritanceRequest is the body of a request to set whether a folder breaks inheritance.
+type InheritanceRequest struct {
+	BreakInheritance bool `json:"break_inheritance"`
+}
+
+// UpdateFolderInheritance sets whether a folder breaks inheritance.
+// A folder that breaks inheritance, and the folders nested inside it,
+// are no longer affected by the inheritable access roles of its ancestors.
+func (s *Server) UpdateFolderInheritance(w http.ResponseWriter, r *http.Request, _ models.User) {
+	vars := mux.Vars(r)
+	fid, err := strconv.ParseUint(vars["id"], 10, 32)
+	if err != nil {
+		ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		ERROR(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	inheritanceRequest := InheritanceRequest{}
+	err = json.Unmarshal(body, &inheritanceRequest)
+	if err != nil {
+		ERROR(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	s.Mutex.Lock()
+	folder, err := models.SetFolderInheritance(s.DB, uint(fid), inheritanceRequest.BreakInheritance)
+	s.Mutex.Unlock()
+	if err != nil {
+		ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	JSON(w, http.StatusOK, folder)
+}
@@ -0,0 +1,163 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/vincetiu8/penn-spark-server/api/models"
+)
+
+// IntegrityReport lists the problems found when comparing the stored file data with the file metadata.
+// MissingData lists blobs referenced by file versions whose data can't be found.
+// OrphanedData lists the names of stored data that don't belong to any file.
+// ChecksumMismatches lists blobs whose data doesn't match their size and checksum.
+// RefCountMismatches lists blobs whose reference count doesn't match the number of file versions referencing them.
+type IntegrityReport struct {
+	CheckedCount       int                `json:"checked_count"`
+	MissingData        []IntegrityProblem `json:"missing_data"`
+	OrphanedData       []string           `json:"orphaned_data"`
+	ChecksumMismatches []IntegrityProblem `json:"checksum_mismatches"`
+	RefCountMismatches []IntegrityProblem `json:"ref_count_mismatches"`
+}
+
+// IntegrityProblem describes a blob whose stored data or metadata is missing or corrupted.
+// IntegrityProblem.FileIDs lists the files with a version referencing the blob.
+type IntegrityProblem struct {
+	Checksum         string `json:"checksum"`
+	FileIDs          []uint `json:"file_ids"`
+	ExpectedSize     int64  `json:"expected_size"`
+	ActualSize       int64  `json:"actual_size"`
+	ActualChecksum   string `json:"actual_checksum"`
+	ExpectedRefCount int64  `json:"expected_ref_count"`
+	ActualRefCount   int64  `json:"actual_ref_count"`
+}
+
+// HasProblems returns whether any problem was found.
+func (report IntegrityReport) HasProblems() bool {
+	return len(report.MissingData) > 0 || len(report.OrphanedData) > 0 || len(report.ChecksumMismatches) > 0 ||
+		len(report.RefCountMismatches) > 0
+}
+
+// GetIntegrityReport verifies all stored file data and returns the problems found.
+func (s *Server) GetIntegrityReport(w http.ResponseWriter, _ *http.Request, _ models.User) {
+	report, err := s.CheckIntegrity()
+	if err != nil {
+		ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	JSON(w, http.StatusOK, report)
+}
+
+// CheckIntegrity compares the stored file data with the file metadata.
+// The metadata is read under the read lock, but the data is read without holding it.
+// Stored blobs never change, so only data removed during the check has to be checked again.
+func (s *Server) CheckIntegrity() (IntegrityReport, error) {
+	report := IntegrityReport{
+		MissingData:        []IntegrityProblem{},
+		OrphanedData:       []string{},
+		ChecksumMismatches: []IntegrityProblem{},
+		RefCountMismatches: []IntegrityProblem{},
+	}
+
+	s.Mutex.RLock()
+	blobs, err := models.GetAllBlobs(s.DB)
+	if err != nil {
+		s.Mutex.RUnlock()
+		return IntegrityReport{}, err
+	}
+	versions, err := models.GetAllFileVersions(s.DB)
+	if err != nil {
+		s.Mutex.RUnlock()
+		return IntegrityReport{}, err
+	}
+	fileIDs, err := models.GetAllFileIDs(s.DB)
+	if err != nil {
+		s.Mutex.RUnlock()
+		return IntegrityReport{}, err
+	}
+	storedData, err := s.FileSystem.ListStoredDataRaw()
+	s.Mutex.RUnlock()
+	if err != nil {
+		return IntegrityReport{}, err
+	}
+
+	// Describe every blob that should be stored, whether it has metadata or is only referenced by versions.
+	problems := map[string]*IntegrityProblem{}
+	var checksums []string
+	for _, blob := range blobs {
+		problems[blob.Checksum] = &IntegrityProblem{
+			Checksum:       blob.Checksum,
+			FileIDs:        []uint{},
+			ExpectedSize:   blob.Size,
+			ActualRefCount: blob.RefCount,
+		}
+		checksums = append(checksums, blob.Checksum)
+	}
+	for _, version := range versions {
+		problem, ok := problems[version.Checksum]
+		if !ok {
+			problem = &IntegrityProblem{
+				Checksum:     version.Checksum,
+				FileIDs:      []uint{},
+				ExpectedSize: version.Size,
+			}
+			problems[version.Checksum] = problem
+			checksums = append(checksums, version.Checksum)
+		}
+		problem.ExpectedRefCount++
+		if len(problem.FileIDs) == 0 || problem.FileIDs[len(problem.FileIDs)-1] != version.FileID {
+			problem.FileIDs = append(problem.FileIDs, version.FileID)
+		}
+	}
+
+	// Find stored data with no matching metadata.
+	// Data stored by file id before content addressing was introduced belongs to its file version until it is migrated,
+	// and data stored before versioning was introduced belongs to any file.
+	type versionKey struct {
+		fileID  uint
+		version uint
+	}
+	knownData := map[versionKey]bool{}
+	for _, version := range versions {
+		knownData[versionKey{version.FileID, version.Number}] = true
+	}
+	for _, fileID := range fileIDs {
+		knownData[versionKey{fileID, 0}] = true
+	}
+	for _, data := range storedData {
+		if data.Checksum != "" {
+			if problems[data.Checksum] == nil {
+				report.OrphanedData = append(report.OrphanedData, data.Name)
+			}
+		} else if data.FileID == 0 || !knownData[versionKey{data.FileID, data.Version}] {
+			report.OrphanedData = append(report.OrphanedData, data.Name)
+		}
+	}
+
+	// Verify the data of each blob matches its size and checksum, and that its references are counted correctly.
+	for _, checksum := range checksums {
+		problem := problems[checksum]
+		if problem.ActualRefCount != problem.ExpectedRefCount {
+			report.RefCountMismatches = append(report.RefCountMismatches, *problem)
+		}
+
+		report.CheckedCount++
+		problem.ActualSize, problem.ActualChecksum, err = s.FileSystem.ChecksumBlobRaw(checksum)
+		if err != nil {
+			// The blob may have been purged since the metadata was read.
+			s.Mutex.RLock()
+			references, err := models.CountBlobReferences(s.DB, checksum)
+			s.Mutex.RUnlock()
+			if err != nil {
+				return IntegrityReport{}, err
+			}
+			if references > 0 {
+				report.MissingData = append(report.MissingData, *problem)
+			}
+		} else if problem.ActualSize != problem.ExpectedSize || problem.ActualChecksum != checksum {
+			report.ChecksumMismatches = append(report.ChecksumMismatches, *problem)
+		}
+	}
+
+	return report, nil
+}
@@ -0,0 +1,139 @@
+package controllers
+
+import (
+	"log"
+
+	"github.com/vincetiu8/penn-spark-server/api/models"
+)
+
+// MigrateBlobs moves file data stored by file id to storage by checksum.
+// The data of each version is referenced by a Blob, and data stored before versioning was introduced
+// becomes the first version of its file. Such data is dropped if the file already has versions, as it was replaced.
+// Data that doesn't match its recorded checksum or doesn't belong to any file is left in place for the integrity check.
+// Data stored by file id is only removed once it is stored by checksum and referenced, so no data is lost if the
+// migration is interrupted, and running it again only migrates the data that's left without referencing a blob twice.
+// Returns the number of pieces of data migrated.
+func (s *Server) MigrateBlobs() (int, error) {
+	s.Mutex.Lock()
+	defer s.Mutex.Unlock()
+
+	storedData, err := s.FileSystem.ListStoredDataRaw()
+	if err != nil {
+		return 0, err
+	}
+	versions, err := models.GetAllFileVersions(s.DB)
+	if err != nil {
+		return 0, err
+	}
+	fileIDs, err := models.GetAllFileIDs(s.DB)
+	if err != nil {
+		return 0, err
+	}
+
+	type versionKey struct {
+		fileID  uint
+		version uint
+	}
+	knownVersions := map[versionKey]models.FileVersion{}
+	versionedFiles := map[uint]bool{}
+	for _, version := range versions {
+		knownVersions[versionKey{version.FileID, version.Number}] = version
+		versionedFiles[version.FileID] = true
+	}
+	knownFiles := map[uint]bool{}
+	for _, fileID := range fileIDs {
+		knownFiles[fileID] = true
+	}
+
+	migrated := 0
+	for _, data := range storedData {
+		if data.FileID == 0 {
+			continue
+		}
+
+		version, ok := knownVersions[versionKey{data.FileID, data.Version}]
+		if data.Version > 0 && !ok || data.Version == 0 && !knownFiles[data.FileID] {
+			continue
+		}
+		if data.Version == 0 && versionedFiles[data.FileID] {
+			err = s.FileSystem.DeleteStoredDataRaw(data.FileID, 0)
+			if err != nil {
+				return migrated, err
+			}
+			continue
+		}
+
+		if data.Version == 0 {
+			_, err = s.migrateLegacyFileData(data.FileID)
+			if err != nil {
+				return migrated, err
+			}
+			migrated++
+			continue
+		}
+
+		tempFile, err := s.FileSystem.CopyStoredDataRaw(data.FileID, data.Version)
+		if err != nil {
+			return migrated, err
+		}
+		if tempFile.Size != version.Size || tempFile.Checksum != version.Checksum {
+			log.Printf("data of version %d of file %d doesn't match its checksum, skipping\n", data.Version, data.FileID)
+			s.FileSystem.RemoveTempFile(tempFile)
+			continue
+		}
+
+		err = s.FileSystem.CommitBlobRaw(tempFile)
+		s.FileSystem.RemoveTempFile(tempFile)
+		if err != nil {
+			return migrated, err
+		}
+
+		// Versions created before content addressing was introduced don't reference their blob yet,
+		// unless a previous run was interrupted before removing the data stored by file id.
+		// The blob is only referenced again while it has fewer references than versions using it.
+		blob, err := models.GetBlob(s.DB, tempFile.Checksum)
+		if err != nil && err != models.ErrBlobNotFound {
+			return migrated, err
+		}
+		references, err := models.CountBlobReferences(s.DB, tempFile.Checksum)
+		if err != nil {
+			return migrated, err
+		}
+		if blob.RefCount < references {
+			_, err = models.AddBlobReference(s.DB, tempFile.Checksum, tempFile.Size)
+			if err != nil {
+				return migrated, err
+			}
+		}
+
+		err = s.FileSystem.DeleteStoredDataRaw(data.FileID, data.Version)
+		if err != nil {
+			return migrated, err
+		}
+		migrated++
+	}
+	return migrated, nil
+}
+
+// migrateLegacyFileData stores the data of a file stored before versioning was introduced by its checksum,
+// as the first version of the file. The data has never been scanned, so the version is left to be scanned by
+// ScanPendingFileVersions. The data stored by file id is only removed once the version is created.
+// The caller must hold the write lock.
+func (s *Server) migrateLegacyFileData(fileID uint) (models.FileVersion, error) {
+	tempFile, err := s.FileSystem.CopyStoredDataRaw(fileID, 0)
+	if err != nil {
+		return models.FileVersion{}, err
+	}
+
+	err = s.FileSystem.CommitBlobRaw(tempFile)
+	s.FileSystem.RemoveTempFile(tempFile)
+	if err != nil {
+		return models.FileVersion{}, err
+	}
+
+	version, err := models.CreateInitialFileVersion(s.DB, fileID, tempFile.Size, tempFile.Checksum)
+	if err != nil {
+		return models.FileVersion{}, err
+	}
+	return version, s.FileSystem.DeleteStoredDataRaw(fileID, 0)
+}
@@ -0,0 +1,75 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/vincetiu8/penn-spark-server/api/models"
+)
+
+// ErrInvalidOrder returned when an order other than asc or desc is specified.
+var ErrInvalidOrder = errors.New("invalid order")
+
+const (
+	// defaultPageLimit is the number of results returned in a page when no limit is specified.
+	defaultPageLimit = 20
+	// maxPageLimit is the largest number of results that can be returned in a page.
+	maxPageLimit = 100
+)
+
+// parseLimit parses the limit query parameter of a request, which must be between 1 and maxPageLimit.
+// Returns the default limit if it isn't specified.
+func parseLimit(r *http.Request, defaultLimit int) (int, error) {
+	value := r.URL.Query().Get("limit")
+	if value == "" {
+		return defaultLimit, nil
+	}
+
+	limit, err := strconv.Atoi(value)
+	if err != nil || limit < 1 || limit > maxPageLimit {
+		return 0, models.ErrInvalidLimit
+	}
+	return limit, nil
+}
+
+// parsePage parses the page of a listing from the limit, cursor, sort and order query parameters of a request.
+// The order is either asc or desc, and the limit defaults to the given limit, with 0 meaning no limit.
+// The sort column is checked when the page is queried.
+func parsePage(r *http.Request, defaultLimit int) (models.Page, error) {
+	params := r.URL.Query()
+	page := models.Page{
+		Sort:   params.Get("sort"),
+		Cursor: params.Get("cursor"),
+	}
+
+	var err error
+	page.Limit, err = parseLimit(r, defaultLimit)
+	if err != nil {
+		return models.Page{}, err
+	}
+
+	switch strings.ToLower(params.Get("order")) {
+	case "", "asc":
+	case "desc":
+		page.Descending = true
+	default:
+		return models.Page{}, ErrInvalidOrder
+	}
+	return page, nil
+}
+
+// setPageHeaders describes a page of a listing in the response headers.
+// X-Total-Count is the number of results across all pages, and X-Next-Cursor is the cursor of the next page if there is one.
+func setPageHeaders(w http.ResponseWriter, info models.PageInfo) {
+	w.Header().Set("X-Total-Count", strconv.FormatInt(info.Total, 10))
+	if info.NextCursor != "" {
+		w.Header().Set("X-Next-Cursor", info.NextCursor)
+	}
+}
+
+// isPageError returns whether an error was caused by the page requested, rather than by the server.
+func isPageError(err error) bool {
+	return err == models.ErrInvalidSort || err == models.ErrInvalidCursor || err == models.ErrInvalidLimit
+}
@@ -0,0 +1,119 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+
+	"github.com/vincetiu8/penn-spark-server/api/models"
+)
+
+// PathFolder is a Folder in the path to another Folder, along with the access level of the user to it.
+type PathFolder struct {
+	ID             uint               `json:"id"`
+	Name           string             `json:"name"`
+	ParentFolderID uint               `json:"parent_folder_id"`
+	AccessLevel    models.AccessLevel `json:"access_level"`
+}
+
+// PathResult is the File or Folder found at a path.
+// PathResult.Type is SearchResultFile or SearchResultFolder.
+// PathResult.FolderID is the folder containing a File or the parent of a Folder.
+type PathResult struct {
+	Type     string `json:"type"`
+	ID       uint   `json:"id"`
+	FolderID uint   `json:"folder_id"`
+}
+
+// GetFolderPath gets the path to a folder, starting from the root folder and ending with the folder itself.
+// Every folder in the path is returned so the whole path can be shown,
+// but the access level of the user to each folder lets clients only link to the ones the user can view.
+func (s *Server) GetFolderPath(w http.ResponseWriter, r *http.Request, user models.User) {
+	vars := mux.Vars(r)
+	fid, err := strconv.ParseUint(vars["id"], 10, 32)
+	if err != nil {
+		ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+	folderID := uint(fid)
+
+	s.Mutex.RLock()
+	folders, err := models.GetFolderPath(s.DB, folderID)
+	if err != nil {
+		s.Mutex.RUnlock()
+		ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	folderIDs := make([]uint, len(folders))
+	for i, folder := range folders {
+		folderIDs[i] = folder.ID
+	}
+	accessLevels, err := models.GetUserAuthorizationFolders(s.DB, user, folderIDs)
+	s.Mutex.RUnlock()
+	if err != nil {
+		ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	// Verify user has access to the folder
+	if accessLevels[folderID] < models.Viewer {
+		ERROR(w, http.StatusForbidden, ErrUserForbidden)
+		return
+	}
+
+	path := make([]PathFolder, len(folders))
+	for i, folder := range folders {
+		path[i] = PathFolder{
+			ID:             folder.ID,
+			Name:           folder.Name,
+			ParentFolderID: *folder.ParentFolderID,
+			AccessLevel:    accessLevels[folder.ID],
+		}
+	}
+	JSON(w, http.StatusOK, path)
+}
+
+// ResolvePath finds the file or folder at the path query parameter, such as /root/HR/Policies/handbook.pdf.
+// The user must be able to view the folder found, or the file found and the folder containing it.
+// Paths the user can't view are reported as not found, so the response doesn't reveal what exists.
+func (s *Server) ResolvePath(w http.ResponseWriter, r *http.Request, user models.User) {
+	s.Mutex.RLock()
+	resolved, err := models.ResolvePath(s.DB, r.URL.Query().Get("path"))
+	if err != nil {
+		s.Mutex.RUnlock()
+		ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if !resolved.IsFile {
+		_, accessLevel, err := models.GetUserAuthorizationFolder(s.DB, user, resolved.ID)
+		s.Mutex.RUnlock()
+		if err != nil {
+			ERROR(w, http.StatusInternalServerError, err)
+			return
+		} else if accessLevel < models.Viewer {
+			ERROR(w, http.StatusBadRequest, models.ErrPathNotFound)
+			return
+		}
+
+		JSON(w, http.StatusOK, PathResult{Type: SearchResultFolder, ID: resolved.ID, FolderID: resolved.FolderID})
+		return
+	}
+
+	file, accessLevel, err := models.GetUserAuthorizationFile(s.DB, user, resolved.ID)
+	s.Mutex.RUnlock()
+	if err != nil {
+		ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	// Draft files can only be found by their uploader or users who can publish them
+	if accessLevel < models.Viewer || (!file.IsPublished && file.LastEditorID != user.ID && accessLevel < models.Publisher) {
+		ERROR(w, http.StatusBadRequest, models.ErrPathNotFound)
+		return
+	}
+
+	JSON(w, http.StatusOK, PathResult{Type: SearchResultFile, ID: resolved.ID, FolderID: resolved.FolderID})
+}
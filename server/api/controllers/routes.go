@@ -48,7 +48,70 @@ func (s *Server) initializeRoutes(ApiPath string) {
 		s.DeleteFolder, s, false,
 	))).Methods("DELETE")
 
+	// Sets the routes for getting the path to a folder and finding the file or folder at a path.
+	s.Router.HandleFunc(ApiPath+"/folders/{id}/path", SetMiddlewareJSON(SetMiddlewareAuthentication(
+		s.GetFolderPath, s, false,
+	))).Methods("GET")
+	s.Router.HandleFunc(ApiPath+"/resolve", SetMiddlewareJSON(SetMiddlewareAuthentication(
+		s.ResolvePath, s, false,
+	))).Methods("GET")
+
+	// Sets the routes for copying files and folders into another folder.
+	s.Router.HandleFunc(ApiPath+"/files/{id}/copy", SetMiddlewareJSON(SetMiddlewareAuthentication(
+		s.CopyFile, s, false,
+	))).Methods("POST")
+	s.Router.HandleFunc(ApiPath+"/folders/{id}/copy", SetMiddlewareJSON(SetMiddlewareAuthentication(
+		s.CopyFolder, s, false,
+	))).Methods("POST")
+
+	// Sets the route for getting the tree of folders nested inside a folder.
+	s.Router.HandleFunc(ApiPath+"/folders/{id}/tree", SetMiddlewareJSON(SetMiddlewareAuthentication(
+		s.GetFolderTree, s, false,
+	))).Methods("GET")
+
+	// Sets the routes for downloading a folder as a ZIP archive and importing a ZIP archive into a folder.
+	// The download doesn't set the output header as JSON as it returns the archive.
+	s.Router.HandleFunc(ApiPath+"/folders/{id}/archive", SetMiddlewareAuthentication(
+		s.GetFolderArchive, s, false,
+	)).Methods("GET")
+	s.Router.HandleFunc(ApiPath+"/folders/{id}/import", SetMiddlewareJSON(SetMiddlewareAuthentication(
+		s.ImportFolderArchive, s, false,
+	))).Methods("POST")
+
+	// Sets the route for explaining the access level of a user in a folder.
+	s.Router.HandleFunc(ApiPath+"/folders/{id}/access", SetMiddlewareJSON(SetMiddlewareAuthentication(
+		s.GetFolderAccess, s, false,
+	))).Methods("GET")
+
+	// Sets the routes for reporting the effective access of every user in a folder, and of a user in every folder.
+	// The reports can be exported as CSV, which overrides the output header.
+	s.Router.HandleFunc(ApiPath+"/folders/{id}/effective-access", SetMiddlewareJSON(SetMiddlewareAuthentication(
+		s.GetFolderEffectiveAccess, s, true,
+	))).Methods("GET")
+	s.Router.HandleFunc(ApiPath+"/users/{id}/effective-access", SetMiddlewareJSON(SetMiddlewareAuthentication(
+		s.GetUserEffectiveAccess, s, true,
+	))).Methods("GET")
+
+	// Sets the route for breaking the inheritance of access roles in a folder, which only admins can change.
+	s.Router.HandleFunc(ApiPath+"/folders/{id}/inheritance", SetMiddlewareJSON(SetMiddlewareAuthentication(
+		s.UpdateFolderInheritance, s, true,
+	))).Methods("PUT")
+
+	// Sets the routes for folder upload policies, which only admins can change.
+	s.Router.HandleFunc(ApiPath+"/folders/{id}/upload-policy", SetMiddlewareJSON(SetMiddlewareAuthentication(
+		s.GetUploadPolicy, s, false,
+	))).Methods("GET")
+	s.Router.HandleFunc(ApiPath+"/folders/{id}/upload-policy", SetMiddlewareJSON(SetMiddlewareAuthentication(
+		s.UpdateUploadPolicy, s, true,
+	))).Methods("PUT")
+	s.Router.HandleFunc(ApiPath+"/folders/{id}/upload-policy", SetMiddlewareJSON(SetMiddlewareAuthentication(
+		s.DeleteUploadPolicy, s, true,
+	))).Methods("DELETE")
+
 	// Sets the routes for file endpoints.
+	s.Router.HandleFunc(ApiPath+"/files", SetMiddlewareJSON(SetMiddlewareAuthentication(
+		s.GetFiles, s, false,
+	))).Methods("GET")
 	s.Router.HandleFunc(ApiPath+"/files", SetMiddlewareJSON(SetMiddlewareAuthentication(
 		s.CreateFile, s, false,
 	))).Methods("POST")
@@ -62,6 +125,14 @@ func (s *Server) initializeRoutes(ApiPath string) {
 		s.DeleteFile, s, false,
 	))).Methods("DELETE")
 
+	// Sets the routes for file version endpoints.
+	s.Router.HandleFunc(ApiPath+"/files/{id}/versions", SetMiddlewareJSON(SetMiddlewareAuthentication(
+		s.GetFileVersions, s, false,
+	))).Methods("GET")
+	s.Router.HandleFunc(ApiPath+"/files/{id}/versions/{version}/restore", SetMiddlewareJSON(SetMiddlewareAuthentication(
+		s.RestoreFileVersion, s, false,
+	))).Methods("POST")
+
 	// Sets the routes for file data endpoints.
 	// These don't set the output header as JSON as they return raw file data.
 	s.Router.HandleFunc(ApiPath+"/file-data/{id}", SetMiddlewareAuthentication(
@@ -71,6 +142,45 @@ func (s *Server) initializeRoutes(ApiPath string) {
 		s.GetFileData, s, false,
 	)).Methods("GET")
 
+	// Sets the routes for upload session endpoints.
+	// Chunks are sent as raw data, so the chunk route doesn't expect a JSON body.
+	s.Router.HandleFunc(ApiPath+"/uploads", SetMiddlewareJSON(SetMiddlewareAuthentication(
+		s.CreateUploadSession, s, false,
+	))).Methods("POST")
+	s.Router.HandleFunc(ApiPath+"/uploads/{id}", SetMiddlewareJSON(SetMiddlewareAuthentication(
+		s.GetUploadSession, s, false,
+	))).Methods("GET")
+	s.Router.HandleFunc(ApiPath+"/uploads/{id}/chunks/{number}", SetMiddlewareJSON(SetMiddlewareAuthentication(
+		s.UploadChunk, s, false,
+	))).Methods("PUT")
+	s.Router.HandleFunc(ApiPath+"/uploads/{id}/finalize", SetMiddlewareJSON(SetMiddlewareAuthentication(
+		s.FinalizeUploadSession, s, false,
+	))).Methods("POST")
+	s.Router.HandleFunc(ApiPath+"/uploads/{id}", SetMiddlewareJSON(SetMiddlewareAuthentication(
+		s.DeleteUploadSession, s, false,
+	))).Methods("DELETE")
+
+	// Sets the route for searching files and folders.
+	s.Router.HandleFunc(ApiPath+"/search", SetMiddlewareJSON(SetMiddlewareAuthentication(
+		s.Search, s, false,
+	))).Methods("GET")
+
+	// Sets the routes for trash endpoints.
+	s.Router.HandleFunc(ApiPath+"/trash", SetMiddlewareJSON(SetMiddlewareAuthentication(
+		s.GetTrash, s, false,
+	))).Methods("GET")
+	s.Router.HandleFunc(ApiPath+"/trash/{type}/{id}/restore", SetMiddlewareJSON(SetMiddlewareAuthentication(
+		s.RestoreTrashItem, s, false,
+	))).Methods("POST")
+	s.Router.HandleFunc(ApiPath+"/trash/{type}/{id}", SetMiddlewareJSON(SetMiddlewareAuthentication(
+		s.PurgeTrashItem, s, true,
+	))).Methods("DELETE")
+
+	// Sets the route for verifying the integrity of the stored file data.
+	s.Router.HandleFunc(ApiPath+"/fsck", SetMiddlewareJSON(SetMiddlewareAuthentication(
+		s.GetIntegrityReport, s, true,
+	))).Methods("GET")
+
 	// Sets the routes for access role endpoints.
 	s.Router.HandleFunc(ApiPath+"/access-roles", SetMiddlewareJSON(SetMiddlewareAuthentication(
 		s.CreateAccessRole, s, true,
@@ -85,6 +195,11 @@ func (s *Server) initializeRoutes(ApiPath string) {
 		s.DeleteAccessRole, s, true,
 	))).Methods("DELETE")
 
+	// Sets the route for the audit trail of expired access roles and user role assignments.
+	s.Router.HandleFunc(ApiPath+"/grant-audit", SetMiddlewareJSON(SetMiddlewareAuthentication(
+		s.GetGrantAudit, s, true,
+	))).Methods("GET")
+
 	// Sets the routes for user role endpoints.
 	s.Router.HandleFunc(ApiPath+"/user-roles", SetMiddlewareJSON(SetMiddlewareAuthentication(
 		s.CreateUserRole, s, true,
@@ -111,5 +226,14 @@ func (s *Server) initializeRoutes(ApiPath string) {
 		s, true,
 	)).Methods("DELETE")
 
+	// Sets the routes for the access roles granted directly to a user.
+	// They are updated and deleted through the access role endpoints.
+	s.Router.HandleFunc(ApiPath+"/users/{id}/access-roles", SetMiddlewareJSON(SetMiddlewareAuthentication(
+		s.GetDirectAccessRoles, s, true,
+	))).Methods("GET")
+	s.Router.HandleFunc(ApiPath+"/users/{id}/access-roles", SetMiddlewareJSON(SetMiddlewareAuthentication(
+		s.CreateDirectAccessRole, s, true,
+	))).Methods("POST")
+
 	s.Router.PathPrefix("/").Handler(http.FileServer(http.Dir("../client/build/")))
 }
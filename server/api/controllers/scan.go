@@ -0,0 +1,91 @@
+package controllers
+
+import (
+	"log"
+
+	"github.com/vincetiu8/penn-spark-server/api/filesystem"
+	"github.com/vincetiu8/penn-spark-server/api/models"
+	"github.com/vincetiu8/penn-spark-server/api/scanner"
+)
+
+// fileScanner returns the Scanner checking file data for malware.
+func (s *Server) fileScanner() scanner.Scanner {
+	if s.Scanner == nil {
+		return scanner.NoopScanner{}
+	}
+	return s.Scanner
+}
+
+// scanTempFile scans uploaded data for malware before it is stored.
+// Data that can't be scanned is left pending, so it is scanned again by ScanPendingFileVersions.
+// The lock doesn't need to be held, as the temporary file belongs to the upload.
+func (s *Server) scanTempFile(tempFile filesystem.TempFile) models.ScanStatus {
+	file, err := s.FileSystem.Open(tempFile.Path)
+	if err != nil {
+		log.Println("can't scan uploaded data:", err)
+		return models.ScanPending
+	}
+	defer file.Close()
+
+	result, err := s.fileScanner().Scan(file)
+	return scanStatus(result, err)
+}
+
+// ScanPendingFileVersions scans the data of every file version that hasn't been scanned yet,
+// such as versions whose scan failed or that were created before scanning was introduced.
+// Versions sharing the same data are only scanned once.
+// The data is scanned without holding the lock, so uploads aren't blocked by slow scans.
+func (s *Server) ScanPendingFileVersions() error {
+	s.Mutex.RLock()
+	versions, err := models.GetPendingFileVersions(s.DB)
+	s.Mutex.RUnlock()
+	if err != nil {
+		return err
+	}
+
+	statuses := map[string]models.ScanStatus{}
+	for _, version := range versions {
+		status, ok := statuses[version.Checksum]
+		if !ok {
+			status = s.scanBlob(version.Checksum)
+			statuses[version.Checksum] = status
+		}
+		if status == models.ScanPending {
+			continue
+		}
+
+		// The version may have been purged while it was scanned.
+		s.Mutex.Lock()
+		_, err = models.UpdateFileVersionScanStatus(s.DB, version.ID, status)
+		s.Mutex.Unlock()
+		if err != nil && err != models.ErrFileVersionNotFound {
+			return err
+		}
+	}
+	return nil
+}
+
+// scanBlob scans stored data for malware, returning ScanPending if it can't be scanned.
+func (s *Server) scanBlob(checksum string) models.ScanStatus {
+	data, err := s.FileSystem.GetBlobRaw(checksum)
+	if err != nil {
+		log.Println("can't scan stored data:", err)
+		return models.ScanPending
+	}
+	defer data.Close()
+
+	result, err := s.fileScanner().Scan(data)
+	return scanStatus(result, err)
+}
+
+// scanStatus converts the outcome of a scan to the ScanStatus of the scanned data, logging any error.
+func scanStatus(result scanner.Result, err error) models.ScanStatus {
+	if err != nil {
+		log.Println("can't scan file data:", err)
+		return models.ScanPending
+	} else if result.Infected {
+		log.Println("quarantined file data infected with", result.Signature)
+		return models.ScanInfected
+	}
+	return models.ScanClean
+}
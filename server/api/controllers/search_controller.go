@@ -0,0 +1,166 @@
+package controllers
+
+import (
+	"html"
+	"net/http"
+	"strings"
+
+	"github.com/vincetiu8/penn-spark-server/api/models"
+)
+
+const (
+	// SearchResultFile is the SearchResult.Type of a matching File.
+	SearchResultFile = "file"
+	// SearchResultFolder is the SearchResult.Type of a matching Folder.
+	SearchResultFolder = "folder"
+)
+
+// SearchResult is a File or Folder matching a search query.
+// SearchResult.FolderID is the folder containing a File or the parent of a Folder.
+// SearchResult.Snippet is an HTML-escaped part of the name or contents that matched, with matching words in <mark> tags.
+type SearchResult struct {
+	Type     string  `json:"type"`
+	ID       uint    `json:"id"`
+	Name     string  `json:"name"`
+	FolderID *uint   `json:"folder_id"`
+	Snippet  string  `json:"snippet"`
+	Score    float64 `json:"score"`
+}
+
+// Search finds the files and folders whose names or the text of whose data match the q query parameter,
+// ordered from best to worst match.
+// Only folders the user can view and files the user can view in them are returned,
+// leaving out draft files the user isn't allowed to see.
+// The limit and cursor query parameters page through the results, returning 20 results by default and at most 100.
+// The total number of results and the cursor of the next page are returned in the X-Total-Count and X-Next-Cursor headers.
+func (s *Server) Search(w http.ResponseWriter, r *http.Request, user models.User) {
+	query, err := models.SearchQuery(r.URL.Query().Get("q"))
+	if err != nil {
+		ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	page, err := parsePage(r, defaultPageLimit)
+	if err != nil {
+		ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	s.Mutex.RLock()
+	hits, err := models.SearchIndex(s.DB, query)
+	if err != nil {
+		s.Mutex.RUnlock()
+		ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	// Load the files and folders that were hit at once
+	var fileIDs, folderIDs []uint
+	for _, hit := range hits {
+		if hit.FileID != 0 {
+			fileIDs = append(fileIDs, hit.FileID)
+		} else {
+			folderIDs = append(folderIDs, hit.FolderID)
+		}
+	}
+	files, err := models.GetFiles(s.DB, fileIDs)
+	if err != nil {
+		s.Mutex.RUnlock()
+		ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+	folders, err := models.GetFolders(s.DB, folderIDs)
+	if err != nil {
+		s.Mutex.RUnlock()
+		ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	// Get the access level of the user to every folder that was hit or contains a file that was hit at once
+	fileByID := make(map[uint]models.File, len(files))
+	for _, file := range files {
+		fileByID[file.ID] = file
+		folderIDs = append(folderIDs, file.FolderID)
+	}
+	folderByID := make(map[uint]models.Folder, len(folders))
+	for _, folder := range folders {
+		folderByID[folder.ID] = folder
+	}
+	accessLevels, err := models.GetUserAuthorizationFolders(s.DB, user, folderIDs)
+	s.Mutex.RUnlock()
+	if err != nil {
+		ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	// Leave out the hits the user can't see before paging through them, so every page is full
+	visibleHits := []models.SearchHit{}
+	for _, hit := range hits {
+		if hit.FileID != 0 {
+			file, ok := fileByID[hit.FileID]
+			if !ok || !canSeeFile(user, file, accessLevels[file.FolderID]) {
+				continue
+			}
+		} else if _, ok := folderByID[hit.FolderID]; !ok || accessLevels[hit.FolderID] < models.Viewer {
+			continue
+		}
+		visibleHits = append(visibleHits, hit)
+	}
+	visibleHits, info, err := models.PageSearchHits(visibleHits, page)
+	if err != nil {
+		ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	results := make([]SearchResult, 0, len(visibleHits))
+	for _, hit := range visibleHits {
+		if hit.FileID != 0 {
+			file := fileByID[hit.FileID]
+			folderID := file.FolderID
+			results = append(results, SearchResult{
+				Type:     SearchResultFile,
+				ID:       file.ID,
+				Name:     file.Name,
+				FolderID: &folderID,
+				Snippet:  formatSnippet(hit.Snippet),
+				Score:    hit.Score,
+			})
+			continue
+		}
+
+		// Folders in the root folder have no parent folder id, which is returned as 0
+		folder := folderByID[hit.FolderID]
+		parentFolderID := uint(0)
+		if folder.ParentFolderID != nil {
+			parentFolderID = *folder.ParentFolderID
+		}
+		results = append(results, SearchResult{
+			Type:     SearchResultFolder,
+			ID:       folder.ID,
+			Name:     folder.Name,
+			FolderID: &parentFolderID,
+			Snippet:  formatSnippet(hit.Snippet),
+			Score:    hit.Score,
+		})
+	}
+
+	setPageHeaders(w, info)
+	JSON(w, http.StatusOK, results)
+}
+
+// canSeeFile returns whether a user with the given access level to a file's folder can see the file.
+// Only publishers and the uploader of a draft file can see it.
+func canSeeFile(user models.User, file models.File, accessLevel models.AccessLevel) bool {
+	if accessLevel < models.Viewer && file.LastEditorID != user.ID {
+		return false
+	}
+	return file.IsPublished || file.LastEditorID == user.ID || accessLevel >= models.Publisher
+}
+
+// formatSnippet HTML-escapes a snippet and marks its matching words with <mark> tags.
+// Names are stored escaped, so the snippet is unescaped first to avoid escaping them twice.
+func formatSnippet(snippet string) string {
+	snippet = html.EscapeString(html.UnescapeString(snippet))
+	snippet = strings.ReplaceAll(snippet, models.SnippetMatchStart, "<mark>")
+	return strings.ReplaceAll(snippet, models.SnippetMatchEnd, "</mark>")
+}
@@ -0,0 +1,103 @@
+package controllers
+
+import (
+	"log"
+	"time"
+
+	"github.com/vincetiu8/penn-spark-server/api/models"
+)
+
+// StartTrashSweeper periodically purges items that have been in the trash for longer than the retention period.
+// The sweeper runs in the background for the lifetime of the server.
+func (s *Server) StartTrashSweeper(retention, interval time.Duration) {
+	startSweeper(interval, "trash", func() error {
+		return s.SweepTrash(retention)
+	})
+}
+
+// SweepTrash purges all items that have been in the trash for longer than the retention period.
+func (s *Server) SweepTrash(retention time.Duration) error {
+	s.Mutex.Lock()
+	defer s.Mutex.Unlock()
+
+	items, err := models.GetExpiredTrash(s.DB, time.Now().Add(-retention))
+	if err != nil {
+		return err
+	}
+
+	for _, item := range items {
+		// Items inside a purged folder are purged along with it, so they may already be gone.
+		err = s.purgeTrashItem(item)
+		if err != nil && err != models.ErrTrashItemNotFound {
+			return err
+		}
+	}
+	return nil
+}
+
+// StartUploadSessionSweeper periodically removes upload sessions that have expired.
+// The sweeper runs in the background for the lifetime of the server.
+func (s *Server) StartUploadSessionSweeper(interval time.Duration) {
+	startSweeper(interval, "upload sessions", s.SweepUploadSessions)
+}
+
+// SweepUploadSessions removes all expired upload sessions along with their chunks.
+func (s *Server) SweepUploadSessions() error {
+	s.Mutex.Lock()
+	defer s.Mutex.Unlock()
+
+	sessions, err := models.GetExpiredUploadSessions(s.DB, time.Now())
+	if err != nil {
+		return err
+	}
+
+	for _, session := range sessions {
+		err = s.deleteUploadSession(session.ID)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// StartScanSweeper periodically scans file data that hasn't been scanned for malware yet.
+// The sweeper runs in the background for the lifetime of the server.
+func (s *Server) StartScanSweeper(interval time.Duration) {
+	startSweeper(interval, "pending scans", s.ScanPendingFileVersions)
+}
+
+// StartSearchIndexSweeper periodically indexes the text of file data that hasn't been indexed for search yet.
+// The sweeper runs in the background for the lifetime of the server.
+func (s *Server) StartSearchIndexSweeper(interval time.Duration) {
+	startSweeper(interval, "search index", s.IndexFileContents)
+}
+
+// StartGrantExpirySweeper periodically removes access roles and user role assignments that have expired.
+// The sweeper runs in the background for the lifetime of the server.
+func (s *Server) StartGrantExpirySweeper(interval time.Duration) {
+	startSweeper(interval, "expired grants", s.SweepExpiredGrants)
+}
+
+// SweepExpiredGrants removes all expired access roles and user role assignments, recording them in the audit trail.
+func (s *Server) SweepExpiredGrants() error {
+	s.Mutex.Lock()
+	defer s.Mutex.Unlock()
+
+	_, err := models.ExpireGrants(s.DB, time.Now())
+	return err
+}
+
+// startSweeper runs a sweep in the background at every interval, logging any errors.
+func startSweeper(interval time.Duration, name string, sweep func() error) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			err := sweep()
+			if err != nil {
+				log.Printf("can't sweep %s: %v\n", name, err)
+			}
+		}
+	}()
+}
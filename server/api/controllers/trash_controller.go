@@ -0,0 +1,154 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+
+	"github.com/vincetiu8/penn-spark-server/api/models"
+)
+
+// GetTrash returns the deleted files and folders the user is able to restore.
+// Regular users only see items they deleted, while admins see items deleted by any user.
+func (s *Server) GetTrash(w http.ResponseWriter, _ *http.Request, user models.User) {
+	deletedByID := user.ID
+	if user.IsAdmin {
+		deletedByID = 0
+	}
+
+	s.Mutex.RLock()
+	items, err := models.GetTrash(s.DB, deletedByID)
+	if err != nil {
+		s.Mutex.RUnlock()
+		ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	// Remove items the user doesn't have the rights to restore
+	restorableItems := []models.TrashItem{}
+	for _, item := range items {
+		accessLevel, err := s.getTrashItemAuthorization(user, item)
+		if err != nil {
+			s.Mutex.RUnlock()
+			ERROR(w, http.StatusInternalServerError, err)
+			return
+		} else if accessLevel == models.Publisher {
+			restorableItems = append(restorableItems, item)
+		}
+	}
+	s.Mutex.RUnlock()
+
+	JSON(w, http.StatusOK, restorableItems)
+}
+
+// RestoreTrashItem restores a deleted file or folder by its type and id.
+// Deleted parent folders are restored along with the item.
+func (s *Server) RestoreTrashItem(w http.ResponseWriter, r *http.Request, user models.User) {
+	vars := mux.Vars(r)
+	iid, err := strconv.ParseUint(vars["id"], 10, 32)
+	if err != nil {
+		ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	s.Mutex.Lock()
+	item, err := models.GetTrashItem(s.DB, vars["type"], uint(iid))
+	if err != nil {
+		s.Mutex.Unlock()
+		ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	// Only admins can restore items deleted by other users.
+	if item.DeletedByID != user.ID && !user.IsAdmin {
+		s.Mutex.Unlock()
+		ERROR(w, http.StatusForbidden, ErrUserForbidden)
+		return
+	}
+
+	// Verify the user is able to create the item in the folder it is restored to.
+	accessLevel, err := s.getTrashItemAuthorization(user, item)
+	if err != nil {
+		s.Mutex.Unlock()
+		ERROR(w, http.StatusBadRequest, err)
+		return
+	} else if accessLevel < models.Publisher {
+		s.Mutex.Unlock()
+		ERROR(w, http.StatusForbidden, ErrUserForbidden)
+		return
+	}
+
+	item, err = models.RestoreTrashItem(s.DB, item, user.ID)
+	s.Mutex.Unlock()
+	if err != nil {
+		ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	JSON(w, http.StatusOK, item)
+}
+
+// PurgeTrashItem permanently deletes a deleted file or folder and its stored data.
+func (s *Server) PurgeTrashItem(w http.ResponseWriter, r *http.Request, _ models.User) {
+	vars := mux.Vars(r)
+	iid, err := strconv.ParseUint(vars["id"], 10, 32)
+	if err != nil {
+		ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	s.Mutex.Lock()
+	item, err := models.GetTrashItem(s.DB, vars["type"], uint(iid))
+	if err != nil {
+		s.Mutex.Unlock()
+		ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	err = s.purgeTrashItem(item)
+	s.Mutex.Unlock()
+	if err != nil {
+		ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.Header().Set("Entity", fmt.Sprintf("%d", iid))
+	JSON(w, http.StatusNoContent, "")
+}
+
+// getTrashItemAuthorization gets a user's access level to the folder a deleted item would be restored in.
+func (s *Server) getTrashItemAuthorization(user models.User, item models.TrashItem) (models.AccessLevel, error) {
+	folderID, err := models.GetRestoreFolderID(s.DB, item)
+	if err != nil {
+		return models.Unset, err
+	}
+
+	_, accessLevel, err := models.GetUserAuthorizationFolder(s.DB, user, folderID)
+	return accessLevel, err
+}
+
+// purgeTrashItem permanently deletes a deleted item's metadata and then the data of all purged files.
+// Data shared with files that weren't purged is kept.
+// The caller must hold the write lock.
+func (s *Server) purgeTrashItem(item models.TrashItem) error {
+	purged, err := models.PurgeTrashItem(s.DB, item)
+	if err != nil {
+		return err
+	}
+
+	for _, checksum := range purged.Checksums {
+		err = s.FileSystem.DeleteBlobRaw(checksum)
+		if err != nil {
+			return err
+		}
+	}
+	for _, fileID := range purged.FileIDs {
+		err = s.FileSystem.DeleteFileRaw(fileID)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
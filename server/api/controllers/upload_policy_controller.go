@@ -0,0 +1,102 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+
+	"github.com/vincetiu8/penn-spark-server/api/models"
+)
+
+// GetUploadPolicy gets the upload policy that applies to a folder, which may be set on one of its ancestors.
+// Any user who can see the folder can get its policy, so clients can check files before uploading them.
+func (s *Server) GetUploadPolicy(w http.ResponseWriter, r *http.Request, user models.User) {
+	vars := mux.Vars(r)
+	fid, err := strconv.ParseUint(vars["id"], 10, 32)
+	if err != nil {
+		ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	s.Mutex.RLock()
+	_, accessLevel, err := models.GetUserAuthorizationFolder(s.DB, user, uint(fid))
+	if err != nil {
+		s.Mutex.RUnlock()
+		ERROR(w, http.StatusBadRequest, err)
+		return
+	} else if accessLevel < models.Viewer {
+		s.Mutex.RUnlock()
+		ERROR(w, http.StatusForbidden, ErrUserForbidden)
+		return
+	}
+
+	policy, err := models.GetEffectiveUploadPolicy(s.DB, uint(fid))
+	s.Mutex.RUnlock()
+	if err != nil {
+		ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	JSON(w, http.StatusOK, policy)
+}
+
+// UpdateUploadPolicy sets the upload policy of a folder, which also applies to its descendants.
+func (s *Server) UpdateUploadPolicy(w http.ResponseWriter, r *http.Request, user models.User) {
+	vars := mux.Vars(r)
+	fid, err := strconv.ParseUint(vars["id"], 10, 32)
+	if err != nil {
+		ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		ERROR(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	policy := models.UploadPolicy{}
+	err = json.Unmarshal(body, &policy)
+	if err != nil {
+		ERROR(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	policy.FolderID = uint(fid)
+	policy.LastEditorID = user.ID
+
+	s.Mutex.Lock()
+	policy, err = models.UpsertUploadPolicy(s.DB, policy)
+	s.Mutex.Unlock()
+	if err != nil {
+		ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	JSON(w, http.StatusOK, policy)
+}
+
+// DeleteUploadPolicy removes the upload policy of a folder, so the policy of its ancestors applies instead.
+func (s *Server) DeleteUploadPolicy(w http.ResponseWriter, r *http.Request, _ models.User) {
+	vars := mux.Vars(r)
+	fid, err := strconv.ParseUint(vars["id"], 10, 32)
+	if err != nil {
+		ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	s.Mutex.Lock()
+	err = models.DeleteUploadPolicy(s.DB, uint(fid))
+	s.Mutex.Unlock()
+	if err != nil {
+		ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	w.Header().Set("Entity", fmt.Sprintf("%d", fid))
+	JSON(w, http.StatusNoContent, "")
+}
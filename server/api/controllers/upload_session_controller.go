@@ -0,0 +1,358 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+
+	"github.com/vincetiu8/penn-spark-server/api/filesystem"
+	"github.com/vincetiu8/penn-spark-server/api/models"
+)
+
+// ErrUploadSessionChanged returned when an upload session receives a chunk while it is being finalized.
+var ErrUploadSessionChanged = errors.New("upload session changed while finalizing")
+
+// CreateUploadSession starts a resumable upload of a file's data.
+// The request body specifies the file_id of the file and the length in bytes of the data that will be uploaded.
+func (s *Server) CreateUploadSession(w http.ResponseWriter, r *http.Request, user models.User) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		ERROR(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	session := models.UploadSession{}
+	err = json.Unmarshal(body, &session)
+	if err != nil {
+		ERROR(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	if s.MaxUploadSize > 0 && session.Length > s.MaxUploadSize {
+		ERROR(w, http.StatusRequestEntityTooLarge, filesystem.ErrFileTooLarge)
+		return
+	}
+
+	s.Mutex.Lock()
+	file, err := models.GetFileByID(s.DB, session.FileID)
+	if err != nil {
+		s.Mutex.Unlock()
+		ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	// Only the owner of a file can upload its data.
+	if user.ID != file.LastEditorID {
+		s.Mutex.Unlock()
+		ERROR(w, http.StatusForbidden, ErrUserForbidden)
+		return
+	}
+
+	// The content type can only be checked once the data is complete, but the size is already known.
+	policy, err := models.GetEffectiveUploadPolicy(s.DB, file.FolderID)
+	if err != nil {
+		s.Mutex.Unlock()
+		ERROR(w, http.StatusInternalServerError, err)
+		return
+	} else if !policy.AllowsSize(session.Length) {
+		s.Mutex.Unlock()
+		ERROR(w, http.StatusRequestEntityTooLarge, filesystem.ErrFileTooLarge)
+		return
+	}
+
+	session.UploaderID = user.ID
+	session.ExpiresAt = time.Now().Add(s.UploadSessionLifetime)
+	session, err = models.CreateUploadSession(s.DB, session)
+	s.Mutex.Unlock()
+	if err != nil {
+		ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	setUploadSessionHeaders(w, session)
+	w.Header().Set("Location", fmt.Sprintf("%s%s/%d", r.Host, r.RequestURI, session.ID))
+	JSON(w, http.StatusCreated, session)
+}
+
+// GetUploadSession gets an upload session by its id, including the ranges of data received so far.
+func (s *Server) GetUploadSession(w http.ResponseWriter, r *http.Request, user models.User) {
+	vars := mux.Vars(r)
+	sid, err := strconv.ParseUint(vars["id"], 10, 32)
+	if err != nil {
+		ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	s.Mutex.RLock()
+	session, err := models.GetUploadSessionByID(s.DB, uint(sid))
+	s.Mutex.RUnlock()
+	if err != nil {
+		ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if session.UploaderID != user.ID {
+		ERROR(w, http.StatusForbidden, ErrUserForbidden)
+		return
+	}
+
+	setUploadSessionHeaders(w, session)
+	JSON(w, http.StatusOK, session)
+}
+
+// UploadChunk receives a numbered chunk of an upload session's data.
+// The Upload-Offset header specifies the offset of the chunk's data and the request body contains the raw data.
+// Resending a chunk with the same number replaces the previously received chunk.
+func (s *Server) UploadChunk(w http.ResponseWriter, r *http.Request, user models.User) {
+	vars := mux.Vars(r)
+	sid, err := strconv.ParseUint(vars["id"], 10, 32)
+	if err != nil {
+		ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+	number, err := strconv.ParseUint(vars["number"], 10, 32)
+	if err != nil {
+		ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+	offset, err := strconv.ParseInt(r.Header.Get("Upload-Offset"), 10, 64)
+	if err != nil {
+		ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	s.Mutex.RLock()
+	session, err := models.GetUploadSessionByID(s.DB, uint(sid))
+	s.Mutex.RUnlock()
+	if err != nil {
+		ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if session.UploaderID != user.ID {
+		ERROR(w, http.StatusForbidden, ErrUserForbidden)
+		return
+	}
+	if offset < 0 || offset >= session.Length {
+		ERROR(w, http.StatusBadRequest, models.ErrChunkOutOfRange)
+		return
+	}
+
+	// Stream the chunk to a temporary file, without reading past the end of the upload.
+	tempFile, err := s.FileSystem.WriteTempFile(r.Body, session.Length-offset)
+	if err == filesystem.ErrFileTooLarge {
+		ERROR(w, http.StatusBadRequest, models.ErrChunkOutOfRange)
+		return
+	} else if err != nil {
+		ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+	defer s.FileSystem.RemoveTempFile(tempFile)
+
+	// The chunk is only recorded once its data is stored, so a failed store doesn't leave a chunk without data.
+	s.Mutex.Lock()
+	var commitErr error
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		session, err = models.AddUploadChunk(tx, models.UploadChunk{
+			UploadSessionID: session.ID,
+			Number:          uint(number),
+			Offset:          offset,
+			Size:            tempFile.Size,
+		}, time.Now().Add(s.UploadSessionLifetime))
+		if err != nil {
+			return err
+		}
+
+		commitErr = s.FileSystem.CommitUploadChunk(tempFile, session.ID, uint(number))
+		return commitErr
+	})
+	s.Mutex.Unlock()
+	if commitErr != nil {
+		ERROR(w, http.StatusInternalServerError, commitErr)
+		return
+	} else if err != nil {
+		ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	setUploadSessionHeaders(w, session)
+	JSON(w, http.StatusOK, session)
+}
+
+// FinalizeUploadSession joins the chunks of a complete upload session into a new version of the file.
+// The user must still own the file and be able to upload to its folder,
+// and the data must be allowed by the upload policy of the file's folder.
+// The upload session is removed once the version is created, and the text of clean data is indexed for search.
+func (s *Server) FinalizeUploadSession(w http.ResponseWriter, r *http.Request, user models.User) {
+	vars := mux.Vars(r)
+	sid, err := strconv.ParseUint(vars["id"], 10, 32)
+	if err != nil {
+		ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	s.Mutex.RLock()
+	session, err := models.GetUploadSessionByID(s.DB, uint(sid))
+	s.Mutex.RUnlock()
+	if err != nil {
+		ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if session.UploaderID != user.ID {
+		ERROR(w, http.StatusForbidden, ErrUserForbidden)
+		return
+	}
+	if !session.IsComplete() {
+		ERROR(w, http.StatusBadRequest, models.ErrUploadIncomplete)
+		return
+	}
+
+	// Chunks are loaded in offset order, so joining them in order rebuilds the file data.
+	// The chunks are joined without holding the lock, so the session is checked again afterwards.
+	numbers := make([]uint, 0, len(session.Chunks))
+	for _, chunk := range session.Chunks {
+		numbers = append(numbers, chunk.Number)
+	}
+	tempFile, err := s.FileSystem.AssembleUploadChunks(session.ID, numbers)
+	if err != nil {
+		ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+	defer s.FileSystem.RemoveTempFile(tempFile)
+	scanStatus := s.scanTempFile(tempFile)
+
+	// The file is looked up again to extract the text, whose content type depends on the file's name.
+	s.Mutex.RLock()
+	file, err := models.GetFileByID(s.DB, session.FileID)
+	s.Mutex.RUnlock()
+	if err != nil {
+		ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+	text, indexed := s.extractTempFileText(tempFile, file.Name, scanStatus)
+
+	s.Mutex.Lock()
+	// Verify no chunk was received and the file wasn't deleted while the chunks were joined.
+	current, err := models.GetUploadSessionByID(s.DB, session.ID)
+	if err != nil {
+		s.Mutex.Unlock()
+		ERROR(w, http.StatusBadRequest, err)
+		return
+	} else if !current.UpdatedAt.Equal(session.UpdatedAt) {
+		s.Mutex.Unlock()
+		ERROR(w, http.StatusConflict, ErrUploadSessionChanged)
+		return
+	}
+	file, err = models.GetFileByID(s.DB, session.FileID)
+	if err != nil {
+		s.Mutex.Unlock()
+		ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	// Verify the user still owns the file and can upload to its folder, as either may have changed during the session.
+	if user.ID != file.LastEditorID {
+		s.Mutex.Unlock()
+		ERROR(w, http.StatusForbidden, ErrUserForbidden)
+		return
+	}
+	accessLevels, err := models.GetUserAuthorizationFolders(s.DB, user, []uint{file.FolderID})
+	if err != nil {
+		s.Mutex.Unlock()
+		ERROR(w, http.StatusInternalServerError, err)
+		return
+	} else if accessLevels[file.FolderID] < models.Uploader {
+		s.Mutex.Unlock()
+		ERROR(w, http.StatusForbidden, ErrUserForbidden)
+		return
+	}
+
+	// Verify the data is allowed in the folder now that its content type can be detected.
+	contentType := detectContentType(tempFile.ContentType, html.UnescapeString(file.Name))
+	status, err := s.checkUploadPolicy(s.DB, file.FolderID, tempFile.Size, contentType)
+	if err != nil {
+		s.Mutex.Unlock()
+		ERROR(w, status, err)
+		return
+	}
+
+	version, err := s.createFileVersion(file, user.ID, tempFile, scanStatus)
+	if err == nil && indexed {
+		err = models.UpdateFileSearchText(s.DB, file.ID, tempFile.Checksum, text)
+	}
+	if err != nil {
+		s.Mutex.Unlock()
+		ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	err = s.deleteUploadSession(session.ID)
+	s.Mutex.Unlock()
+	if err != nil {
+		ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	JSON(w, http.StatusCreated, version)
+}
+
+// DeleteUploadSession abandons an upload session and removes the chunks received so far.
+func (s *Server) DeleteUploadSession(w http.ResponseWriter, r *http.Request, user models.User) {
+	vars := mux.Vars(r)
+	sid, err := strconv.ParseUint(vars["id"], 10, 32)
+	if err != nil {
+		ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	s.Mutex.Lock()
+	session, err := models.GetUploadSessionByID(s.DB, uint(sid))
+	if err != nil {
+		s.Mutex.Unlock()
+		ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if session.UploaderID != user.ID {
+		s.Mutex.Unlock()
+		ERROR(w, http.StatusForbidden, ErrUserForbidden)
+		return
+	}
+
+	err = s.deleteUploadSession(session.ID)
+	s.Mutex.Unlock()
+	if err != nil {
+		ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.Header().Set("Entity", fmt.Sprintf("%d", sid))
+	JSON(w, http.StatusNoContent, "")
+}
+
+// deleteUploadSession deletes an upload session's metadata and then its stored chunks.
+// The caller must hold the write lock.
+func (s *Server) deleteUploadSession(sessionID uint) error {
+	err := models.DeleteUploadSession(s.DB, sessionID)
+	if err != nil {
+		return err
+	}
+
+	return s.FileSystem.DeleteUploadChunks(sessionID)
+}
+
+// setUploadSessionHeaders sets the tus style headers describing the state of an upload session.
+func setUploadSessionHeaders(w http.ResponseWriter, session models.UploadSession) {
+	w.Header().Set("Upload-Offset", strconv.FormatInt(session.Offset, 10))
+	w.Header().Set("Upload-Length", strconv.FormatInt(session.Length, 10))
+	w.Header().Set("Upload-Expires", session.ExpiresAt.UTC().Format(http.TimeFormat))
+}
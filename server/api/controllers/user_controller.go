@@ -41,14 +41,29 @@ func (s *Server) CreateUser(w http.ResponseWriter, r *http.Request, _ models.Use
 }
 
 // GetAllUsers returns a list of all activated users.
-func (s *Server) GetAllUsers(w http.ResponseWriter, _ *http.Request, _ models.User) {
+// The limit and cursor query parameters page through the users, with defaultPageLimit returned if no limit is specified.
+// The users are sorted by the sort parameter, one of id, username, first_name, last_name or created_at,
+// in the order given by the order parameter, either asc or desc.
+// The total number of users and the cursor of the next page are returned in the X-Total-Count and X-Next-Cursor headers.
+func (s *Server) GetAllUsers(w http.ResponseWriter, r *http.Request, _ models.User) {
+	page, err := parsePage(r, defaultPageLimit)
+	if err != nil {
+		ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
 	s.Mutex.RLock()
-	users, err := models.GetAllUsers(s.DB)
+	users, info, err := models.GetUserPage(s.DB, page)
 	s.Mutex.RUnlock()
-	if err != nil {
+	if isPageError(err) {
+		ERROR(w, http.StatusBadRequest, err)
+		return
+	} else if err != nil {
 		ERROR(w, http.StatusInternalServerError, err)
 		return
 	}
+
+	setPageHeaders(w, info)
 	JSON(w, http.StatusOK, users)
 }
 
@@ -138,6 +153,7 @@ func (s *Server) DeleteUser(w http.ResponseWriter, r *http.Request, _ models.Use
 }
 
 // AddUserRole adds a user role to a user.
+// The starts_at and expires_at fields of the body limit when the user role grants access.
 func (s *Server) AddUserRole(w http.ResponseWriter, r *http.Request, _ models.User) {
 	vars := mux.Vars(r)
 	uid, err := strconv.ParseUint(vars["id"], 10, 32)
@@ -157,9 +173,15 @@ func (s *Server) AddUserRole(w http.ResponseWriter, r *http.Request, _ models.Us
 		ERROR(w, http.StatusUnprocessableEntity, err)
 		return
 	}
+	window := models.GrantWindow{}
+	err = json.Unmarshal(body, &window)
+	if err != nil {
+		ERROR(w, http.StatusUnprocessableEntity, err)
+		return
+	}
 
 	s.Mutex.Lock()
-	userUpdate, err := models.AddUserRole(s.DB, uint(uid), userRole)
+	userUpdate, err := models.AssignUserRole(s.DB, uint(uid), userRole, window)
 	s.Mutex.Unlock()
 	if err != nil {
 		ERROR(w, http.StatusBadRequest, err)
@@ -39,15 +39,31 @@ func (s *Server) CreateUserRole(w http.ResponseWriter, r *http.Request, _ models
 }
 
 // GetAllUserRoles returns a list of all the user roles.
-func (s *Server) GetAllUserRoles(w http.ResponseWriter, _ *http.Request, _ models.User) {
+// The limit and cursor query parameters page through the user roles, with defaultPageLimit returned if no limit is specified.
+// The user roles are sorted by the sort parameter, either id or name, in the order given by the order parameter,
+// either asc or desc.
+// The total number of user roles and the cursor of the next page are returned in the X-Total-Count and X-Next-Cursor
+// headers.
+func (s *Server) GetAllUserRoles(w http.ResponseWriter, r *http.Request, _ models.User) {
+	page, err := parsePage(r, defaultPageLimit)
+	if err != nil {
+		ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
 	s.Mutex.RLock()
-	users, err := models.GetAllUserRoles(s.DB)
+	userRoles, info, err := models.GetUserRolePage(s.DB, page)
 	s.Mutex.RUnlock()
-	if err != nil {
+	if isPageError(err) {
+		ERROR(w, http.StatusBadRequest, err)
+		return
+	} else if err != nil {
 		ERROR(w, http.StatusInternalServerError, err)
 		return
 	}
-	JSON(w, http.StatusOK, users)
+
+	setPageHeaders(w, info)
+	JSON(w, http.StatusOK, userRoles)
 }
 
 // GetUserRolesByID gets a user role by its id.
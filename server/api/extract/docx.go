@@ -0,0 +1,73 @@
+package extract
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"io"
+	"strings"
+)
+
+// docxBody is the part of a DOCX archive holding the main text of the document.
+const docxBody = "word/document.xml"
+
+// docxText returns the text of the paragraphs of a DOCX document, with each paragraph on its own line.
+func docxText(data []byte) (string, error) {
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return "", err
+	}
+
+	for _, file := range archive.File {
+		if file.Name != docxBody {
+			continue
+		}
+
+		reader, err := file.Open()
+		if err != nil {
+			return "", err
+		}
+		defer reader.Close()
+		return wordprocessingText(io.LimitReader(reader, MaxDocumentSize))
+	}
+	return "", nil
+}
+
+// wordprocessingText reads the text runs of a WordprocessingML document.
+// Text is kept in w:t elements, and tabs and breaks are separate elements between them.
+func wordprocessingText(reader io.Reader) (string, error) {
+	var text strings.Builder
+	inText := false
+
+	decoder := xml.NewDecoder(reader)
+	for text.Len() < MaxTextLength {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return "", err
+		}
+
+		switch token := token.(type) {
+		case xml.StartElement:
+			switch token.Name.Local {
+			case "t":
+				inText = true
+			case "tab", "br", "cr":
+				text.WriteByte(' ')
+			}
+		case xml.EndElement:
+			switch token.Name.Local {
+			case "t":
+				inText = false
+			case "p":
+				text.WriteByte('\n')
+			}
+		case xml.CharData:
+			if inText {
+				text.Write(token)
+			}
+		}
+	}
+	return text.String(), nil
+}
@@ -0,0 +1,110 @@
+// Package extract extracts the plain text of documents, so their contents can be searched.
+// Plain text, Markdown, HTML, DOCX and PDF documents are supported.
+package extract
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"mime"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// ErrUnsupportedContentType returned when text can't be extracted from documents of the given content type.
+var ErrUnsupportedContentType = errors.New("unsupported content type")
+
+// ErrDocumentTooLarge returned when a document that has to be read into memory is larger than MaxDocumentSize.
+var ErrDocumentTooLarge = errors.New("document too large to extract text from")
+
+// MaxDocumentSize limits the size in bytes of DOCX, PDF and HTML documents, which are read into memory.
+const MaxDocumentSize = 32 << 20
+
+// MaxTextLength limits the length in bytes of the text extracted from a document, which is truncated beyond it.
+const MaxTextLength = 1 << 20
+
+// whitespace matches runs of whitespace, which are collapsed into single spaces.
+var whitespace = regexp.MustCompile(`\s+`)
+
+// Text extracts the plain text of a document with the given content type, such as "application/pdf".
+// Fails with ErrUnsupportedContentType if the content type isn't supported.
+func Text(reader io.Reader, contentType string) (string, error) {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return "", ErrUnsupportedContentType
+	}
+	if charset, ok := params["charset"]; ok && !strings.EqualFold(charset, "utf-8") {
+		return "", ErrUnsupportedContentType
+	}
+
+	var text string
+	switch mediaType {
+	case "text/plain", "text/markdown", "text/csv", "text/tab-separated-values":
+		var data []byte
+		data, err = ioutil.ReadAll(io.LimitReader(reader, MaxTextLength))
+		text = string(data)
+	case "text/html":
+		var data []byte
+		data, err = readDocument(reader)
+		text = htmlText(data)
+	case "application/vnd.openxmlformats-officedocument.wordprocessingml.document":
+		var data []byte
+		data, err = readDocument(reader)
+		if err == nil {
+			text, err = docxText(data)
+		}
+	case "application/pdf":
+		var data []byte
+		data, err = readDocument(reader)
+		if err == nil {
+			text = pdfText(data)
+		}
+	default:
+		return "", ErrUnsupportedContentType
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return truncate(strings.TrimSpace(whitespace.ReplaceAllString(strings.ToValidUTF8(text, " "), " "))), nil
+}
+
+// readDocument reads a whole document into memory, failing with ErrDocumentTooLarge if it is larger than MaxDocumentSize.
+func readDocument(reader io.Reader) ([]byte, error) {
+	data, err := ioutil.ReadAll(io.LimitReader(reader, MaxDocumentSize+1))
+	if err != nil {
+		return nil, err
+	} else if len(data) > MaxDocumentSize {
+		return nil, ErrDocumentTooLarge
+	}
+	return data, nil
+}
+
+// truncate shortens text to at most MaxTextLength bytes without splitting a character.
+func truncate(text string) string {
+	if len(text) <= MaxTextLength {
+		return text
+	}
+	end := MaxTextLength
+	for end > 0 && !utf8.RuneStart(text[end]) {
+		end--
+	}
+	return text[:end]
+}
+
+// joinText joins pieces of text with spaces, skipping empty pieces.
+func joinText(pieces [][]byte) string {
+	var text bytes.Buffer
+	for _, piece := range pieces {
+		if len(piece) == 0 {
+			continue
+		}
+		if text.Len() > 0 {
+			text.WriteByte(' ')
+		}
+		text.Write(piece)
+	}
+	return text.String()
+}
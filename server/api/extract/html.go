@@ -0,0 +1,19 @@
+package extract
+
+import (
+	"html"
+	"regexp"
+)
+
+// htmlHidden matches the parts of an HTML document that aren't displayed as text: comments, scripts and styles.
+var htmlHidden = regexp.MustCompile(`(?is)<!--.*?-->|<script\b.*?</script\s*>|<style\b.*?</style\s*>|<head\b.*?</head\s*>`)
+
+// htmlTag matches any HTML tag.
+var htmlTag = regexp.MustCompile(`(?s)<[^>]*>`)
+
+// htmlText returns the displayed text of an HTML document, with tags replaced by spaces.
+func htmlText(data []byte) string {
+	data = htmlHidden.ReplaceAll(data, []byte(" "))
+	data = htmlTag.ReplaceAll(data, []byte(" "))
+	return html.UnescapeString(string(data))
+}
@@ -0,0 +1,185 @@
+package extract
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// pdfStream matches a stream object of a PDF document, capturing the data of the stream.
+var pdfStream = regexp.MustCompile(`(?s)stream\r?\n(.*?)\r?\nendstream`)
+
+// pdfDictionaryLength is how far before a stream its dictionary is searched for the filters of the stream.
+const pdfDictionaryLength = 512
+
+// pdfKerningSpace is the amount of negative kerning in a TJ array that is treated as a space between words,
+// in thousandths of the font size.
+const pdfKerningSpace = 200
+
+// pdfText returns the text shown by the content streams of a PDF document.
+// Only text in fonts with a simple single-byte encoding can be extracted, which covers most generated documents.
+// Streams compressed with anything other than FlateDecode, such as images, are skipped.
+func pdfText(data []byte) string {
+	var pieces [][]byte
+	total := 0
+	for _, match := range pdfStream.FindAllSubmatchIndex(data, -1) {
+		dictionaryStart := match[0] - pdfDictionaryLength
+		if dictionaryStart < 0 {
+			dictionaryStart = 0
+		}
+		dictionary := data[dictionaryStart:match[0]]
+		if i := bytes.LastIndex(dictionary, []byte("endobj")); i >= 0 {
+			dictionary = dictionary[i:]
+		}
+
+		content := data[match[2]:match[3]]
+		if bytes.Contains(dictionary, []byte("/Filter")) {
+			if !bytes.Contains(dictionary, []byte("/FlateDecode")) || bytes.Contains(dictionary, []byte("/DCTDecode")) {
+				continue
+			}
+			reader, err := zlib.NewReader(bytes.NewReader(content))
+			if err != nil {
+				continue
+			}
+			// Keep whatever could be decompressed, as streams are often followed by stray bytes.
+			content, _ = ioutil.ReadAll(io.LimitReader(reader, MaxDocumentSize))
+			reader.Close()
+		}
+		if !bytes.Contains(content, []byte("BT")) {
+			continue
+		}
+
+		piece := pdfContentText(content)
+		pieces = append(pieces, piece)
+		total += len(piece)
+		if total > MaxTextLength {
+			break
+		}
+	}
+	return joinText(pieces)
+}
+
+// pdfContentText returns the strings shown by the text operators of a content stream.
+// Operators that move to a new line and large negative kerning separate words with a space.
+func pdfContentText(content []byte) []byte {
+	var text bytes.Buffer
+	for i := 0; i < len(content); i++ {
+		switch c := content[i]; {
+		case c == '%':
+			// Skip comments up to the end of the line.
+			for i < len(content) && content[i] != '\n' && content[i] != '\r' {
+				i++
+			}
+		case c == '(':
+			var s []byte
+			s, i = pdfLiteralString(content, i)
+			writeLatin1(&text, s)
+		case c == '<' && i+1 < len(content) && content[i+1] != '<':
+			end := bytes.IndexByte(content[i:], '>')
+			if end < 0 {
+				return text.Bytes()
+			}
+			s, err := hex.DecodeString(strings.Map(func(r rune) rune {
+				if strings.ContainsRune(" \t\r\n", r) {
+					return -1
+				}
+				return r
+			}, string(content[i+1:i+end])))
+			if err == nil && isPrintable(s) {
+				writeLatin1(&text, s)
+			}
+			i += end
+		case c == '-' || c == '.' || '0' <= c && c <= '9':
+			start := i
+			for i+1 < len(content) && (content[i+1] == '.' || '0' <= content[i+1] && content[i+1] <= '9') {
+				i++
+			}
+			kerning, err := strconv.ParseFloat(string(content[start:i+1]), 64)
+			if err == nil && kerning < -pdfKerningSpace {
+				text.WriteByte(' ')
+			}
+		case 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || c == '*' || c == '\'' || c == '"':
+			start := i
+			for i+1 < len(content) && ('A' <= content[i+1] && content[i+1] <= 'Z' ||
+				'a' <= content[i+1] && content[i+1] <= 'z' || content[i+1] == '*') {
+				i++
+			}
+			switch string(content[start : i+1]) {
+			case "Td", "TD", "T*", "Tm", "'", "\"", "ET":
+				text.WriteByte(' ')
+			}
+		}
+	}
+	return text.Bytes()
+}
+
+// pdfLiteralString reads the literal string starting with the opening parenthesis at start.
+// Returns the unescaped string and the position of its closing parenthesis.
+func pdfLiteralString(content []byte, start int) ([]byte, int) {
+	var s []byte
+	depth := 0
+	for i := start; i < len(content); i++ {
+		switch c := content[i]; c {
+		case '(':
+			if depth > 0 {
+				s = append(s, c)
+			}
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return s, i
+			}
+			s = append(s, c)
+		case '\\':
+			i++
+			if i >= len(content) {
+				return s, i
+			}
+			switch e := content[i]; e {
+			case 'n', 'r', 't', 'b', 'f':
+				s = append(s, ' ')
+			case '\r', '\n':
+				// A backslash at the end of a line continues the string on the next line.
+			default:
+				if '0' <= e && e <= '7' {
+					end := i + 1
+					for end < len(content) && end < i+3 && '0' <= content[end] && content[end] <= '7' {
+						end++
+					}
+					value, _ := strconv.ParseUint(string(content[i:end]), 8, 8)
+					s = append(s, byte(value))
+					i = end - 1
+				} else {
+					s = append(s, e)
+				}
+			}
+		default:
+			s = append(s, c)
+		}
+	}
+	return s, len(content)
+}
+
+// isPrintable returns whether a string only contains printable characters,
+// which tells strings in a single-byte encoding apart from glyph ids.
+func isPrintable(s []byte) bool {
+	for _, c := range s {
+		if c < ' ' && c != '\t' && c != '\n' && c != '\r' {
+			return false
+		}
+	}
+	return true
+}
+
+// writeLatin1 writes a string in a single-byte PDF encoding as UTF-8, treating the encoding as Latin-1.
+func writeLatin1(text *bytes.Buffer, s []byte) {
+	for _, c := range s {
+		text.WriteRune(rune(c))
+	}
+}
@@ -0,0 +1,121 @@
+package filesystem
+
+import (
+	"errors"
+	"io"
+	"time"
+)
+
+// ErrBlobNotFound returned when no blob is stored with the given key.
+var ErrBlobNotFound = errors.New("blob not found")
+
+// ErrInvalidSeek returned when seeking to a negative position or with an unknown whence.
+var ErrInvalidSeek = errors.New("invalid seek")
+
+// BlobInfo describes a blob stored in a BlobStore.
+type BlobInfo struct {
+	Key     string
+	Size    int64
+	ModTime time.Time
+}
+
+// BlobStore stores blobs of data by key.
+// Keys are slash separated paths, such as "blobs/2c/2cf24dba..." or "uploads/3/1".
+// Putting a blob replaces any blob stored with the same key, and readers never see a partially written blob.
+type BlobStore interface {
+	// Put stores size bytes read from reader as the blob with the given key.
+	Put(key string, reader io.Reader, size int64) error
+	// Get returns length bytes of a blob starting at offset, or the rest of the blob if length is negative.
+	Get(key string, offset, length int64) (io.ReadCloser, error)
+	// Delete removes a blob, ignoring blobs that don't exist.
+	Delete(key string) error
+	// Stat describes a blob.
+	Stat(key string) (BlobInfo, error)
+	// List describes all the blobs whose keys start with the given prefix, ordered by key.
+	List(prefix string) ([]BlobInfo, error)
+}
+
+// ReadSeekCloser is the interface that groups the Read, Seek and Close methods.
+type ReadSeekCloser interface {
+	io.ReadSeeker
+	io.Closer
+}
+
+// blobReader reads a blob as an io.ReadSeeker.
+// The blob is only fetched once it is read, starting from the current offset, so seeking doesn't transfer any data.
+type blobReader struct {
+	store  BlobStore
+	key    string
+	size   int64
+	offset int64
+	body   io.ReadCloser
+}
+
+// newBlobReader returns a reader over the blob with the given key.
+func newBlobReader(store BlobStore, key string) (*blobReader, error) {
+	info, err := store.Stat(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return &blobReader{
+		store: store,
+		key:   key,
+		size:  info.Size,
+	}, nil
+}
+
+// Read reads from the blob at the current offset.
+func (r *blobReader) Read(p []byte) (int, error) {
+	if r.offset >= r.size {
+		return 0, io.EOF
+	}
+
+	if r.body == nil {
+		body, err := r.store.Get(r.key, r.offset, -1)
+		if err != nil {
+			return 0, err
+		}
+		r.body = body
+	}
+
+	n, err := r.body.Read(p)
+	r.offset += int64(n)
+	return n, err
+}
+
+// Seek sets the offset of the next Read.
+func (r *blobReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekCurrent:
+		offset += r.offset
+	case io.SeekEnd:
+		offset += r.size
+	case io.SeekStart:
+	default:
+		return 0, ErrInvalidSeek
+	}
+	if offset < 0 {
+		return 0, ErrInvalidSeek
+	}
+
+	// The blob has to be fetched again from the new offset.
+	if offset != r.offset {
+		err := r.Close()
+		if err != nil {
+			return 0, err
+		}
+		r.offset = offset
+	}
+	return r.offset, nil
+}
+
+// Close releases the blob being read, if any.
+func (r *blobReader) Close() error {
+	if r.body == nil {
+		return nil
+	}
+	err := r.body.Close()
+	r.body = nil
+	return err
+}
@@ -0,0 +1,430 @@
+package filesystem
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"strings"
+)
+
+// ErrInvalidMasterKey returned when a master key isn't a 256-bit AES key.
+var ErrInvalidMasterKey = errors.New("master key must be 32 bytes")
+
+// ErrUnknownMasterKey returned when a data key was wrapped by a master key that isn't configured.
+var ErrUnknownMasterKey = errors.New("data key wrapped by unknown master key")
+
+// encryptedChunkSize is the size of the plaintext chunks blobs are encrypted in.
+// Each chunk is sealed separately, so a range of a blob can be decrypted without reading the whole blob.
+const encryptedChunkSize = 64 * 1024
+
+// keyRecordPrefix is the prefix of the keys of the blobs holding the wrapped data keys.
+const keyRecordPrefix = "keys/"
+
+// keyRecord holds the wrapped data key of an encrypted blob, along with the blob's plaintext size.
+// It is stored separately from the blob, so the data key can be rewrapped without rewriting the blob.
+type keyRecord struct {
+	MasterKeyID string `json:"master_key_id"`
+	WrappedKey  []byte `json:"wrapped_key"`
+	Size        int64  `json:"size"`
+}
+
+// EncryptedBlobStore encrypts the blobs of another BlobStore with envelope encryption.
+// Every blob is encrypted with its own random data key using AES-GCM, in chunks of encryptedChunkSize bytes.
+// Data keys are wrapped by MasterKey and stored next to the blobs, under the "keys/" prefix.
+// OldMasterKeys are only used to unwrap data keys that haven't been rewrapped since the master key was rotated.
+// Blobs without a wrapped data key were stored before encryption was enabled and are read as they are.
+type EncryptedBlobStore struct {
+	Store         BlobStore
+	MasterKey     []byte
+	OldMasterKeys [][]byte
+}
+
+// masterKeyID identifies a master key without revealing it.
+func masterKeyID(masterKey []byte) string {
+	hash := sha256.Sum256(masterKey)
+	return hex.EncodeToString(hash[:8])
+}
+
+// newGCM returns an AES-GCM cipher using the given 256-bit key.
+func newGCM(key []byte) (cipher.AEAD, error) {
+	if len(key) != 32 {
+		return nil, ErrInvalidMasterKey
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// wrapKey encrypts a data key with the master key, binding it to the key of its blob.
+func (store *EncryptedBlobStore) wrapKey(key string, dataKey []byte) ([]byte, error) {
+	gcm, err := newGCM(store.MasterKey)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	_, err = rand.Read(nonce)
+	if err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, dataKey, []byte(key)), nil
+}
+
+// unwrapKey decrypts the data key of a key record with the master key that wrapped it.
+func (store *EncryptedBlobStore) unwrapKey(key string, record keyRecord) ([]byte, error) {
+	for _, masterKey := range append([][]byte{store.MasterKey}, store.OldMasterKeys...) {
+		if masterKeyID(masterKey) != record.MasterKeyID {
+			continue
+		}
+
+		gcm, err := newGCM(masterKey)
+		if err != nil {
+			return nil, err
+		}
+		if len(record.WrappedKey) < gcm.NonceSize() {
+			return nil, ErrUnknownMasterKey
+		}
+		nonce, wrappedKey := record.WrappedKey[:gcm.NonceSize()], record.WrappedKey[gcm.NonceSize():]
+		return gcm.Open(nil, nonce, wrappedKey, []byte(key))
+	}
+	return nil, ErrUnknownMasterKey
+}
+
+// getKeyRecord gets the key record of a blob.
+// Returns ErrBlobNotFound if the blob isn't encrypted.
+func (store *EncryptedBlobStore) getKeyRecord(key string) (keyRecord, error) {
+	data, err := store.Store.Get(keyRecordPrefix+key, 0, -1)
+	if err != nil {
+		return keyRecord{}, err
+	}
+	defer data.Close()
+
+	record := keyRecord{}
+	err = json.NewDecoder(data).Decode(&record)
+	return record, err
+}
+
+// putKeyRecord stores the key record of a blob.
+func (store *EncryptedBlobStore) putKeyRecord(key string, record keyRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	return store.Store.Put(keyRecordPrefix+key, bytes.NewReader(data), int64(len(data)))
+}
+
+// Put encrypts size bytes read from reader with a new data key and stores them as the blob with the given key.
+// The blob is stored before its key record, so a blob that fails to be stored keeps the key record it was encrypted with.
+// If the key record then can't be stored, the blob is removed rather than left with a key record that can't decrypt it.
+func (store *EncryptedBlobStore) Put(key string, reader io.Reader, size int64) error {
+	dataKey := make([]byte, 32)
+	_, err := rand.Read(dataKey)
+	if err != nil {
+		return err
+	}
+	gcm, err := newGCM(dataKey)
+	if err != nil {
+		return err
+	}
+
+	record := keyRecord{
+		MasterKeyID: masterKeyID(store.MasterKey),
+		Size:        size,
+	}
+	record.WrappedKey, err = store.wrapKey(key, dataKey)
+	if err != nil {
+		return err
+	}
+
+	encryptedReader := &encryptingReader{
+		gcm:       gcm,
+		source:    reader,
+		remaining: size,
+		lastChunk: chunkCount(size) - 1,
+	}
+	err = store.Store.Put(key, encryptedReader, encryptedSize(size))
+	if err != nil {
+		return err
+	}
+
+	err = store.putKeyRecord(key, record)
+	if err != nil {
+		_ = store.Delete(key)
+		return err
+	}
+	return nil
+}
+
+// Get decrypts length bytes of a blob starting at offset, or the rest of the blob if length is negative.
+// Only the chunks holding the requested range are read.
+func (store *EncryptedBlobStore) Get(key string, offset, length int64) (io.ReadCloser, error) {
+	record, err := store.getKeyRecord(key)
+	if err == ErrBlobNotFound {
+		return store.Store.Get(key, offset, length)
+	} else if err != nil {
+		return nil, err
+	}
+
+	if offset > record.Size {
+		offset = record.Size
+	}
+	if length < 0 || offset+length > record.Size {
+		length = record.Size - offset
+	}
+	if length == 0 {
+		return ioutil.NopCloser(bytes.NewReader(nil)), nil
+	}
+
+	dataKey, err := store.unwrapKey(key, record)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := newGCM(dataKey)
+	if err != nil {
+		return nil, err
+	}
+
+	// Read whole chunks from the start of the chunk holding the offset.
+	firstChunk := offset / encryptedChunkSize
+	chunks := (offset+length-1)/encryptedChunkSize - firstChunk + 1
+	encryptedOffset := firstChunk * (encryptedChunkSize + int64(gcm.Overhead()))
+	encryptedLength := encryptedSize(record.Size) - encryptedOffset
+	if encryptedLength > chunks*(encryptedChunkSize+int64(gcm.Overhead())) {
+		encryptedLength = chunks * (encryptedChunkSize + int64(gcm.Overhead()))
+	}
+	data, err := store.Store.Get(key, encryptedOffset, encryptedLength)
+	if err != nil {
+		return nil, err
+	}
+
+	reader := &decryptingReader{
+		gcm:       gcm,
+		source:    data,
+		chunk:     firstChunk,
+		lastChunk: chunkCount(record.Size) - 1,
+	}
+	_, err = io.CopyN(ioutil.Discard, reader, offset-firstChunk*encryptedChunkSize)
+	if err != nil {
+		data.Close()
+		return nil, err
+	}
+
+	return struct {
+		io.Reader
+		io.Closer
+	}{io.LimitReader(reader, length), data}, nil
+}
+
+// Delete removes a blob along with its key record, ignoring blobs that don't exist.
+func (store *EncryptedBlobStore) Delete(key string) error {
+	err := store.Store.Delete(key)
+	if err != nil {
+		return err
+	}
+	return store.Store.Delete(keyRecordPrefix + key)
+}
+
+// Stat describes a blob, giving the size of its decrypted data.
+func (store *EncryptedBlobStore) Stat(key string) (BlobInfo, error) {
+	info, err := store.Store.Stat(key)
+	if err != nil {
+		return BlobInfo{}, err
+	}
+
+	record, err := store.getKeyRecord(key)
+	if err == ErrBlobNotFound {
+		return info, nil
+	} else if err != nil {
+		return BlobInfo{}, err
+	}
+
+	info.Size = record.Size
+	return info, nil
+}
+
+// List describes all the blobs whose keys start with the given prefix, ordered by key.
+// Key records aren't blobs, so they aren't listed.
+func (store *EncryptedBlobStore) List(prefix string) ([]BlobInfo, error) {
+	blobs, err := store.Store.List(prefix)
+	if err != nil {
+		return nil, err
+	}
+	records, err := store.Store.List(keyRecordPrefix + prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	encryptedKeys := map[string]bool{}
+	for _, record := range records {
+		encryptedKeys[strings.TrimPrefix(record.Key, keyRecordPrefix)] = true
+	}
+
+	decryptedBlobs := []BlobInfo{}
+	for _, blob := range blobs {
+		if strings.HasPrefix(blob.Key, keyRecordPrefix) {
+			continue
+		}
+		if encryptedKeys[blob.Key] {
+			blob.Size = decryptedSize(blob.Size)
+		}
+		decryptedBlobs = append(decryptedBlobs, blob)
+	}
+	return decryptedBlobs, nil
+}
+
+// RotateKeys rewraps every data key that isn't wrapped by MasterKey, without rewriting the blobs themselves.
+// Returns the number of data keys rewrapped.
+func (store *EncryptedBlobStore) RotateKeys() (int, error) {
+	records, err := store.Store.List(keyRecordPrefix)
+	if err != nil {
+		return 0, err
+	}
+
+	rotated := 0
+	currentKeyID := masterKeyID(store.MasterKey)
+	for _, recordInfo := range records {
+		key := strings.TrimPrefix(recordInfo.Key, keyRecordPrefix)
+		record, err := store.getKeyRecord(key)
+		if err != nil {
+			return rotated, err
+		}
+		if record.MasterKeyID == currentKeyID {
+			continue
+		}
+
+		dataKey, err := store.unwrapKey(key, record)
+		if err != nil {
+			return rotated, err
+		}
+		record.MasterKeyID = currentKeyID
+		record.WrappedKey, err = store.wrapKey(key, dataKey)
+		if err != nil {
+			return rotated, err
+		}
+		err = store.putKeyRecord(key, record)
+		if err != nil {
+			return rotated, err
+		}
+		rotated++
+	}
+	return rotated, nil
+}
+
+// chunkCount returns the number of chunks data of the given size is encrypted in.
+// Empty data is still sealed in a single chunk, so truncated blobs can be detected.
+func chunkCount(size int64) int64 {
+	if size == 0 {
+		return 1
+	}
+	return (size + encryptedChunkSize - 1) / encryptedChunkSize
+}
+
+// encryptedSize returns the size of the encrypted data for plaintext of the given size.
+func encryptedSize(size int64) int64 {
+	return size + chunkCount(size)*16
+}
+
+// decryptedSize returns the size of the plaintext of encrypted data of the given size.
+func decryptedSize(size int64) int64 {
+	chunks := (size + encryptedChunkSize + 16 - 1) / (encryptedChunkSize + 16)
+	return size - chunks*16
+}
+
+// chunkNonce returns the nonce a chunk is sealed with.
+// Data keys are never reused, so the chunk index is unique, and marking the last chunk prevents truncation.
+func chunkNonce(chunk int64, last bool) []byte {
+	nonce := make([]byte, 12)
+	if last {
+		nonce[0] = 1
+	}
+	binary.BigEndian.PutUint64(nonce[4:], uint64(chunk))
+	return nonce
+}
+
+// encryptingReader encrypts data read from a source chunk by chunk.
+type encryptingReader struct {
+	gcm       cipher.AEAD
+	source    io.Reader
+	remaining int64
+	chunk     int64
+	lastChunk int64
+	buffer    []byte
+}
+
+// Read returns the encrypted data, sealing the next chunk of the source once the previous chunk has been read.
+func (r *encryptingReader) Read(p []byte) (int, error) {
+	if len(r.buffer) == 0 {
+		if r.chunk > r.lastChunk {
+			return 0, io.EOF
+		}
+
+		size := r.remaining
+		if size > encryptedChunkSize {
+			size = encryptedChunkSize
+		}
+		plaintext := make([]byte, size)
+		_, err := io.ReadFull(r.source, plaintext)
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return 0, err
+		}
+
+		r.buffer = r.gcm.Seal(plaintext[:0], chunkNonce(r.chunk, r.chunk == r.lastChunk), plaintext, nil)
+		r.remaining -= size
+		r.chunk++
+	}
+
+	n := copy(p, r.buffer)
+	r.buffer = r.buffer[n:]
+	return n, nil
+}
+
+// decryptingReader decrypts data read from a source chunk by chunk.
+type decryptingReader struct {
+	gcm       cipher.AEAD
+	source    io.Reader
+	chunk     int64
+	lastChunk int64
+	buffer    []byte
+}
+
+// Read returns the decrypted data, opening the next chunk of the source once the previous chunk has been read.
+func (r *decryptingReader) Read(p []byte) (int, error) {
+	for len(r.buffer) == 0 {
+		if r.chunk > r.lastChunk {
+			return 0, io.EOF
+		}
+
+		ciphertext := make([]byte, encryptedChunkSize+r.gcm.Overhead())
+		n, err := io.ReadFull(r.source, ciphertext)
+		if err == io.EOF || (err == io.ErrUnexpectedEOF && r.chunk == r.lastChunk) {
+			err = nil
+		}
+		if err != nil {
+			return 0, err
+		}
+
+		r.buffer, err = r.gcm.Open(ciphertext[:0], chunkNonce(r.chunk, r.chunk == r.lastChunk), ciphertext[:n], nil)
+		if err != nil {
+			return 0, err
+		}
+		r.chunk++
+	}
+
+	n := copy(p, r.buffer)
+	r.buffer = r.buffer[n:]
+	return n, nil
+}
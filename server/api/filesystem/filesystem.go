@@ -1,30 +1,422 @@
-// Package filesystem provides a wrapper around the afero filesystem.
+// Package filesystem stores file data in a BlobStore, using an afero filesystem for temporary files.
+// File data is content addressed: it is stored once under its SHA-256 checksum, however many files share it.
 package filesystem
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"io"
+	"log"
+	"net/http"
+	"os"
+	"path"
 	"strconv"
+	"strings"
 
 	"github.com/spf13/afero"
 )
 
-// FileSystem struct provides a wrapper around the afero filesystem.
+// ErrFileTooLarge returned when file data is larger than the maximum allowed size.
+var ErrFileTooLarge = errors.New("file too large")
+
+// TempFile represents file data that has been written to a temporary file but not yet stored as a blob.
+// TempFile.ContentType is the MIME type detected from the start of the data.
+type TempFile struct {
+	Path        string
+	Size        int64
+	Checksum    string
+	ContentType string
+}
+
+// StoredData represents a piece of file data found in the BlobStore.
+// StoredData.Checksum is set for data stored by its checksum.
+// StoredData.FileID is set for data stored by file id before content addressing was introduced,
+// and StoredData.Version is 0 for such data stored before versioning was introduced.
+// Neither is set if the name doesn't belong to any file's data.
+type StoredData struct {
+	Name     string
+	Checksum string
+	FileID   uint
+	Version  uint
+}
+
+// FileSystem struct provides access to the stored file data.
+// File data is kept in the Store, which defaults to a LocalBlobStore in the FilePath folder of the afero filesystem.
+// Temporary files are always written to the afero filesystem, so uploads can be checked before they are stored.
 type FileSystem struct {
 	afero.Fs
 	FilePath string
+	Store    BlobStore
+}
+
+// store returns the BlobStore holding the file data.
+func (fs *FileSystem) store() BlobStore {
+	if fs.Store == nil {
+		return &LocalBlobStore{Fs: fs.Fs, Path: fs.FilePath}
+	}
+	return fs.Store
+}
+
+// sniffLen is the number of bytes at the start of file data used to detect its content type.
+const sniffLen = 512
+
+// blobPrefix is the common prefix of the keys of data stored by its checksum.
+const blobPrefix = "blobs/"
+
+// checksumToKey converts the hex-encoded SHA-256 checksum of file data to the key of the data.
+// Keys are spread over folders named after the first two characters of the checksum, so no folder grows too large.
+func checksumToKey(checksum string) string {
+	if len(checksum) < 2 {
+		return blobPrefix + checksum
+	}
+	return blobPrefix + checksum[:2] + "/" + checksum
+}
+
+// idToKey converts a file's id to the key its data was stored under before versioning was introduced.
+func idToKey(id uint) string {
+	return strconv.Itoa(int(id))
+}
+
+// versionToKey converts a file's id and version number to the key its data was stored under
+// before content addressing was introduced. Version 0 refers to data stored before versioning was introduced.
+func versionToKey(id, version uint) string {
+	if version == 0 {
+		return idToKey(id)
+	}
+	return idToKey(id) + "." + strconv.Itoa(int(version))
 }
 
-// idToFilePath converts a file's id to its local path.
-func (fs *FileSystem) idToFilePath(id uint) string {
-	return fs.FilePath + "/" + strconv.Itoa(int(id))
+// uploadPrefix returns the common prefix of the keys of an upload session's chunks.
+func uploadPrefix(sessionID uint) string {
+	return "uploads/" + strconv.Itoa(int(sessionID)) + "/"
 }
 
-// UpsertFileRaw upserts a file's data in the local filesystem by its id.
+// chunkToKey converts an upload session's id and chunk number to the key of the chunk's data.
+func chunkToKey(sessionID, number uint) string {
+	return uploadPrefix(sessionID) + strconv.Itoa(int(number))
+}
+
+// tempFolderPath returns the local path of the folder holding temporary files.
+func (fs *FileSystem) tempFolderPath() string {
+	return fs.FilePath + "/tmp"
+}
+
+// UpsertFileRaw upserts a file's data by its id, as it was stored before versioning was introduced.
 func (fs *FileSystem) UpsertFileRaw(id uint, reader io.Reader) error {
-	return afero.WriteReader(fs, fs.idToFilePath(id), reader)
+	tempFile, err := fs.WriteTempFile(reader, 0)
+	if err != nil {
+		return err
+	}
+	defer fs.RemoveTempFile(tempFile)
+
+	return fs.storeTempFile(tempFile, idToKey(id))
+}
+
+// GetFileRaw returns a file's data by its id, for files with data stored before versioning was introduced.
+func (fs *FileSystem) GetFileRaw(id uint) (ReadSeekCloser, error) {
+	return newBlobReader(fs.store(), idToKey(id))
+}
+
+// CreateBlobRaw stores file data by its checksum.
+// Returns the size and hex-encoded SHA-256 checksum of the stored data.
+func (fs *FileSystem) CreateBlobRaw(reader io.Reader) (int64, string, error) {
+	tempFile, err := fs.WriteTempFile(reader, 0)
+	if err != nil {
+		return 0, "", err
+	}
+	defer fs.RemoveTempFile(tempFile)
+
+	err = fs.CommitBlobRaw(tempFile)
+	if err != nil {
+		return 0, "", err
+	}
+	return tempFile.Size, tempFile.Checksum, nil
+}
+
+// WriteTempFile streams data into a new temporary file without holding it in memory.
+// The checksum and content type are computed while the data is written, so the data is only read once.
+// Fails with ErrFileTooLarge if the data is larger than maxSize bytes, unless maxSize is 0.
+func (fs *FileSystem) WriteTempFile(reader io.Reader, maxSize int64) (TempFile, error) {
+	err := fs.MkdirAll(fs.tempFolderPath(), 0700)
+	if err != nil {
+		return TempFile{}, err
+	}
+
+	file, err := afero.TempFile(fs, fs.tempFolderPath(), "upload-")
+	if err != nil {
+		return TempFile{}, err
+	}
+	tempFile := TempFile{Path: file.Name()}
+
+	// Read one byte past the limit to tell data of exactly maxSize bytes apart from larger data.
+	if maxSize > 0 {
+		reader = io.LimitReader(reader, maxSize+1)
+	}
+
+	hash := sha256.New()
+	head := &sniffBuffer{}
+	tempFile.Size, err = io.Copy(file, io.TeeReader(reader, io.MultiWriter(hash, head)))
+	if err == nil && maxSize > 0 && tempFile.Size > maxSize {
+		err = ErrFileTooLarge
+	}
+	closeErr := file.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		fs.RemoveTempFile(tempFile)
+		return TempFile{}, err
+	}
+
+	tempFile.Checksum = hex.EncodeToString(hash.Sum(nil))
+	tempFile.ContentType = http.DetectContentType(head.data)
+	return tempFile, nil
+}
+
+// sniffBuffer keeps the start of the data written to it, as much as is needed to detect its content type.
+type sniffBuffer struct {
+	data []byte
+}
+
+func (b *sniffBuffer) Write(p []byte) (int, error) {
+	if remaining := sniffLen - len(b.data); remaining > 0 {
+		if len(p) < remaining {
+			remaining = len(p)
+		}
+		b.data = append(b.data, p[:remaining]...)
+	}
+	return len(p), nil
+}
+
+// storeTempFile stores a temporary file as the blob with the given key.
+// A LocalBlobStore on the same afero filesystem takes the file over by renaming it, so it isn't copied.
+// Otherwise the file is copied to the store, and is left for the caller to remove.
+func (fs *FileSystem) storeTempFile(tempFile TempFile, key string) error {
+	store := fs.store()
+	if local, ok := store.(*LocalBlobStore); ok && local.Fs == fs.Fs {
+		return local.moveFile(tempFile.Path, key)
+	}
+
+	file, err := fs.Open(tempFile.Path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	return store.Put(key, file, tempFile.Size)
+}
+
+// CommitBlobRaw stores a temporary file by its checksum.
+// Data that is already stored isn't stored again, so the temporary file is left for the caller to remove.
+// Blobs are never partially written, so the data appears all at once.
+func (fs *FileSystem) CommitBlobRaw(tempFile TempFile) error {
+	key := checksumToKey(tempFile.Checksum)
+	_, err := fs.store().Stat(key)
+	if err == nil {
+		return nil
+	} else if err != ErrBlobNotFound {
+		return err
+	}
+
+	return fs.storeTempFile(tempFile, key)
+}
+
+// RemoveTempFile removes a temporary file if it still exists.
+func (fs *FileSystem) RemoveTempFile(tempFile TempFile) {
+	err := fs.Remove(tempFile.Path)
+	if err != nil && !os.IsNotExist(err) {
+		log.Println("can't remove temporary file:", err)
+	}
+}
+
+// GetBlobRaw returns the file data stored with the given checksum.
+// The data is only fetched from the store as it is read.
+func (fs *FileSystem) GetBlobRaw(checksum string) (ReadSeekCloser, error) {
+	return newBlobReader(fs.store(), checksumToKey(checksum))
+}
+
+// DeleteBlobRaw permanently removes the file data stored with the given checksum.
+func (fs *FileSystem) DeleteBlobRaw(checksum string) error {
+	return fs.store().Delete(checksumToKey(checksum))
+}
+
+// ChecksumBlobRaw reads the file data stored with the given checksum and returns its actual size and hex-encoded SHA-256 checksum.
+func (fs *FileSystem) ChecksumBlobRaw(checksum string) (int64, string, error) {
+	return fs.checksumKey(checksumToKey(checksum))
+}
+
+// checksumKey reads the blob with the given key and returns its size and hex-encoded SHA-256 checksum.
+func (fs *FileSystem) checksumKey(key string) (int64, string, error) {
+	data, err := fs.store().Get(key, 0, -1)
+	if err != nil {
+		return 0, "", err
+	}
+	defer data.Close()
+
+	hash := sha256.New()
+	size, err := io.Copy(hash, data)
+	if err != nil {
+		return 0, "", err
+	}
+	return size, hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// ListStoredDataRaw lists all the file data in the store.
+// Upload chunks aren't file data, so they aren't listed.
+func (fs *FileSystem) ListStoredDataRaw() ([]StoredData, error) {
+	blobs, err := fs.store().List("")
+	if err != nil {
+		return nil, err
+	}
+
+	data := []StoredData{}
+	for _, blob := range blobs {
+		if strings.HasPrefix(blob.Key, blobPrefix) {
+			checksum := path.Base(blob.Key)
+			storedData := StoredData{Name: blob.Key}
+			if checksumToKey(checksum) == blob.Key {
+				storedData.Checksum = checksum
+			}
+			data = append(data, storedData)
+			continue
+		} else if strings.Contains(blob.Key, "/") {
+			continue
+		}
+
+		storedData := StoredData{Name: blob.Key}
+		parts := strings.SplitN(blob.Key, ".", 2)
+		id, err := strconv.ParseUint(parts[0], 10, 32)
+		if err == nil && len(parts) == 2 {
+			version, err := strconv.ParseUint(parts[1], 10, 32)
+			if err == nil && version > 0 {
+				storedData.FileID = uint(id)
+				storedData.Version = uint(version)
+			}
+		} else if err == nil {
+			storedData.FileID = uint(id)
+		}
+		data = append(data, storedData)
+	}
+	return data, nil
+}
+
+// DeleteFileRaw permanently removes all the data stored by a file's id before content addressing was introduced.
+// Missing data is ignored, as most files have no data stored by their id.
+func (fs *FileSystem) DeleteFileRaw(id uint) error {
+	store := fs.store()
+	blobs, err := store.List(idToKey(id) + ".")
+	if err != nil {
+		return err
+	}
+
+	keys := []string{idToKey(id)}
+	for _, blob := range blobs {
+		keys = append(keys, blob.Key)
+	}
+	for _, key := range keys {
+		err = store.Delete(key)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CopyStoredDataRaw copies the data a version of a file was stored under by its id into a temporary file,
+// so it can be stored by its checksum. Version 0 refers to data stored before versioning was introduced.
+func (fs *FileSystem) CopyStoredDataRaw(id, version uint) (TempFile, error) {
+	data, err := fs.store().Get(versionToKey(id, version), 0, -1)
+	if err != nil {
+		return TempFile{}, err
+	}
+	defer data.Close()
+
+	return fs.WriteTempFile(data, 0)
+}
+
+// DeleteStoredDataRaw removes the data a version of a file was stored under by its id.
+// Version 0 refers to data stored before versioning was introduced.
+func (fs *FileSystem) DeleteStoredDataRaw(id, version uint) error {
+	return fs.store().Delete(versionToKey(id, version))
+}
+
+// CommitUploadChunk stores a temporary file as a chunk of an upload session.
+// Any chunk previously stored with the same number is replaced.
+func (fs *FileSystem) CommitUploadChunk(tempFile TempFile, sessionID, number uint) error {
+	return fs.storeTempFile(tempFile, chunkToKey(sessionID, number))
+}
+
+// AssembleUploadChunks joins the chunks of an upload session into a single temporary file.
+// The chunks are joined in the order given and are streamed one at a time.
+func (fs *FileSystem) AssembleUploadChunks(sessionID uint, numbers []uint) (TempFile, error) {
+	reader := &chunkReader{store: fs.store()}
+	for _, number := range numbers {
+		reader.keys = append(reader.keys, chunkToKey(sessionID, number))
+	}
+	defer reader.Close()
+
+	return fs.WriteTempFile(reader, 0)
+}
+
+// DeleteUploadChunks removes all the chunks stored for an upload session.
+func (fs *FileSystem) DeleteUploadChunks(sessionID uint) error {
+	store := fs.store()
+	blobs, err := store.List(uploadPrefix(sessionID))
+	if err != nil {
+		return err
+	}
+
+	for _, blob := range blobs {
+		err = store.Delete(blob.Key)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// chunkReader reads a list of chunks one after the other, only keeping one of them open at a time.
+type chunkReader struct {
+	store   BlobStore
+	keys    []string
+	current io.ReadCloser
+}
+
+// Read reads from the current chunk, moving on to the next chunk once it is exhausted.
+func (r *chunkReader) Read(p []byte) (int, error) {
+	for {
+		if r.current == nil {
+			if len(r.keys) == 0 {
+				return 0, io.EOF
+			}
+
+			var err error
+			r.current, err = r.store.Get(r.keys[0], 0, -1)
+			if err != nil {
+				return 0, err
+			}
+			r.keys = r.keys[1:]
+		}
+
+		n, err := r.current.Read(p)
+		if err == io.EOF {
+			err = r.Close()
+			if n > 0 || err != nil {
+				return n, err
+			}
+			continue
+		}
+		return n, err
+	}
 }
 
-// GetFileRaw returns a file's data by its id.
-func (fs *FileSystem) GetFileRaw(id uint) (io.ReadSeeker, error) {
-	return fs.Open(fs.idToFilePath(id))
+// Close closes the current chunk, if any.
+func (r *chunkReader) Close() error {
+	if r.current == nil {
+		return nil
+	}
+	err := r.current.Close()
+	r.current = nil
+	return err
 }
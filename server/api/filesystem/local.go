@@ -0,0 +1,169 @@
+package filesystem
+
+import (
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/spf13/afero"
+)
+
+// LocalBlobStore stores blobs as files in a folder of an afero filesystem.
+// Blobs are written to a temporary file and then renamed into place.
+type LocalBlobStore struct {
+	Fs   afero.Fs
+	Path string
+}
+
+// keyToPath converts a blob's key to its local path.
+func (store *LocalBlobStore) keyToPath(key string) string {
+	return store.Path + "/" + key
+}
+
+// Put stores size bytes read from reader as the blob with the given key.
+func (store *LocalBlobStore) Put(key string, reader io.Reader, size int64) error {
+	tempFolderPath := store.Path + "/tmp"
+	err := store.Fs.MkdirAll(tempFolderPath, 0700)
+	if err != nil {
+		return err
+	}
+
+	file, err := afero.TempFile(store.Fs, tempFolderPath, "blob-")
+	if err != nil {
+		return err
+	}
+	_, err = io.CopyN(file, reader, size)
+	closeErr := file.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = store.moveFile(file.Name(), key)
+	}
+	if err != nil {
+		_ = store.Fs.Remove(file.Name())
+	}
+	return err
+}
+
+// moveFile renames a file of the store's filesystem into place as the blob with the given key.
+func (store *LocalBlobStore) moveFile(filePath, key string) error {
+	err := store.Fs.MkdirAll(path.Dir(store.keyToPath(key)), 0700)
+	if err != nil {
+		return err
+	}
+
+	return store.Fs.Rename(filePath, store.keyToPath(key))
+}
+
+// Get returns length bytes of a blob starting at offset, or the rest of the blob if length is negative.
+func (store *LocalBlobStore) Get(key string, offset, length int64) (io.ReadCloser, error) {
+	file, err := store.Fs.Open(store.keyToPath(key))
+	if os.IsNotExist(err) {
+		return nil, ErrBlobNotFound
+	} else if err != nil {
+		return nil, err
+	}
+
+	_, err = file.Seek(offset, io.SeekStart)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	if length < 0 {
+		return file, nil
+	}
+
+	return struct {
+		io.Reader
+		io.Closer
+	}{io.LimitReader(file, length), file}, nil
+}
+
+// Delete removes a blob, ignoring blobs that don't exist.
+// Folders left empty by removing the blob are removed as well.
+func (store *LocalBlobStore) Delete(key string) error {
+	err := store.Fs.Remove(store.keyToPath(key))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	for folder := path.Dir(key); folder != "."; folder = path.Dir(folder) {
+		empty, err := afero.IsEmpty(store.Fs, store.keyToPath(folder))
+		if err != nil || !empty {
+			break
+		}
+		err = store.Fs.Remove(store.keyToPath(folder))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Stat describes a blob.
+func (store *LocalBlobStore) Stat(key string) (BlobInfo, error) {
+	info, err := store.Fs.Stat(store.keyToPath(key))
+	if os.IsNotExist(err) || (err == nil && info.IsDir()) {
+		return BlobInfo{}, ErrBlobNotFound
+	} else if err != nil {
+		return BlobInfo{}, err
+	}
+
+	return BlobInfo{
+		Key:     key,
+		Size:    info.Size(),
+		ModTime: info.ModTime(),
+	}, nil
+}
+
+// List describes all the blobs whose keys start with the given prefix, ordered by key.
+// Temporary files written by LocalBlobStore.Put aren't blobs, so they aren't listed.
+func (store *LocalBlobStore) List(prefix string) ([]BlobInfo, error) {
+	blobs := []BlobInfo{}
+	err := afero.Walk(store.Fs, store.Path, func(filePath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		key, err := filepath.Rel(store.Path, filePath)
+		if err != nil {
+			return err
+		}
+		key = filepath.ToSlash(key)
+		if key == "." {
+			key = ""
+		}
+
+		if info.IsDir() {
+			// Only walk folders that can contain keys with the prefix.
+			if key != "" && (key == "tmp" ||
+				!strings.HasPrefix(key+"/", prefix) && !strings.HasPrefix(prefix, key+"/")) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if strings.HasPrefix(key, prefix) {
+			blobs = append(blobs, BlobInfo{
+				Key:     key,
+				Size:    info.Size(),
+				ModTime: info.ModTime(),
+			})
+		}
+		return nil
+	})
+	if os.IsNotExist(err) {
+		return blobs, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(blobs, func(i, j int) bool {
+		return blobs[i].Key < blobs[j].Key
+	})
+	return blobs, nil
+}
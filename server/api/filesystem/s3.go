@@ -0,0 +1,354 @@
+package filesystem
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ErrS3RequestFailed returned when the S3 API responds to a request with an error.
+var ErrS3RequestFailed = errors.New("s3 request failed")
+
+// emptyPayloadHash is the hex-encoded SHA-256 checksum of an empty request body.
+const emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// unsignedPayload is sent instead of the payload checksum when uploading blobs, so they can be streamed.
+const unsignedPayload = "UNSIGNED-PAYLOAD"
+
+// S3BlobStore stores blobs as objects in a bucket of an S3 compatible API, such as AWS S3 or MinIO.
+// Requests use path style URLs of the form Endpoint/Bucket/key and are signed with AWS Signature Version 4.
+// Client defaults to http.DefaultClient.
+type S3BlobStore struct {
+	Endpoint        string
+	Region          string
+	Bucket          string
+	AccessKeyID     string
+	SecretAccessKey string
+	Client          *http.Client
+}
+
+// s3ListResult is the response to a ListObjectsV2 request.
+type s3ListResult struct {
+	Contents []struct {
+		Key          string    `xml:"Key"`
+		Size         int64     `xml:"Size"`
+		LastModified time.Time `xml:"LastModified"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+// s3Error is the body of an error response of the S3 API.
+type s3Error struct {
+	Code    string `xml:"Code"`
+	Message string `xml:"Message"`
+}
+
+// Put stores size bytes read from reader as the blob with the given key.
+// The payload isn't signed, so the data is streamed without being read twice.
+func (store *S3BlobStore) Put(key string, reader io.Reader, size int64) error {
+	req, err := store.newRequest(http.MethodPut, key, nil, ioutil.NopCloser(io.LimitReader(reader, size)))
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+	if size == 0 {
+		req.Body = http.NoBody
+	}
+
+	resp, err := store.do(req, unsignedPayload)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+// Get returns length bytes of a blob starting at offset, or the rest of the blob if length is negative.
+func (store *S3BlobStore) Get(key string, offset, length int64) (io.ReadCloser, error) {
+	if length == 0 {
+		return ioutil.NopCloser(bytes.NewReader(nil)), nil
+	}
+
+	req, err := store.newRequest(http.MethodGet, key, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if length < 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	} else {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", offset, offset+length-1))
+	}
+
+	resp, err := store.do(req, emptyPayloadHash)
+	if err == ErrBlobNotFound {
+		return nil, err
+	} else if resp != nil && resp.StatusCode == http.StatusRequestedRangeNotSatisfiable {
+		// Reading from the end of a blob returns no data.
+		return ioutil.NopCloser(bytes.NewReader(nil)), nil
+	} else if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// Delete removes a blob, ignoring blobs that don't exist.
+func (store *S3BlobStore) Delete(key string) error {
+	req, err := store.newRequest(http.MethodDelete, key, nil, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := store.do(req, emptyPayloadHash)
+	if err == ErrBlobNotFound {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+// Stat describes a blob.
+func (store *S3BlobStore) Stat(key string) (BlobInfo, error) {
+	req, err := store.newRequest(http.MethodHead, key, nil, nil)
+	if err != nil {
+		return BlobInfo{}, err
+	}
+
+	resp, err := store.do(req, emptyPayloadHash)
+	if err != nil {
+		return BlobInfo{}, err
+	}
+	resp.Body.Close()
+
+	info := BlobInfo{
+		Key:  key,
+		Size: resp.ContentLength,
+	}
+	info.ModTime, _ = http.ParseTime(resp.Header.Get("Last-Modified"))
+	return info, nil
+}
+
+// List describes all the blobs whose keys start with the given prefix, ordered by key.
+// Results are fetched one page at a time until the bucket has no more matching objects.
+func (store *S3BlobStore) List(prefix string) ([]BlobInfo, error) {
+	blobs := []BlobInfo{}
+	continuationToken := ""
+	for {
+		query := url.Values{}
+		query.Set("list-type", "2")
+		query.Set("prefix", prefix)
+		if continuationToken != "" {
+			query.Set("continuation-token", continuationToken)
+		}
+
+		req, err := store.newRequest(http.MethodGet, "", query, nil)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := store.do(req, emptyPayloadHash)
+		if err != nil {
+			return nil, err
+		}
+
+		result := s3ListResult{}
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, object := range result.Contents {
+			blobs = append(blobs, BlobInfo{
+				Key:     object.Key,
+				Size:    object.Size,
+				ModTime: object.LastModified,
+			})
+		}
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			return blobs, nil
+		}
+		continuationToken = result.NextContinuationToken
+	}
+}
+
+// newRequest creates a request for an object of the bucket, or for the bucket itself if the key is empty.
+func (store *S3BlobStore) newRequest(method, key string, query url.Values, body io.ReadCloser) (*http.Request, error) {
+	endpoint, err := url.Parse(store.Endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	endpoint.Path = strings.TrimSuffix(endpoint.Path, "/") + "/" + store.Bucket
+	if key != "" {
+		endpoint.Path += "/" + key
+	}
+	endpoint.RawPath = s3EscapePath(endpoint.Path)
+	endpoint.RawQuery = s3CanonicalQuery(query)
+
+	req, err := http.NewRequest(method, endpoint.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Body = body
+	}
+	return req, nil
+}
+
+// do signs and sends a request, turning error responses into errors.
+// Missing objects are reported as ErrBlobNotFound.
+// The response is still returned along with any error describing a failed request.
+func (store *S3BlobStore) do(req *http.Request, payloadHash string) (*http.Response, error) {
+	store.SignRequest(req, payloadHash, time.Now())
+
+	client := store.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 300 {
+		return resp, nil
+	}
+
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound && req.URL.RawQuery == "" {
+		return resp, ErrBlobNotFound
+	}
+
+	// HEAD responses have no body to describe the error.
+	responseErr := s3Error{}
+	err = xml.NewDecoder(resp.Body).Decode(&responseErr)
+	if err != nil || responseErr.Code == "" {
+		return resp, fmt.Errorf("%w: %s %s: %s", ErrS3RequestFailed, req.Method, req.URL.Path, resp.Status)
+	}
+	return resp, fmt.Errorf("%w: %s %s: %s: %s", ErrS3RequestFailed, req.Method, req.URL.Path,
+		responseErr.Code, responseErr.Message)
+}
+
+// SignRequest signs a request to the S3 API with AWS Signature Version 4.
+// The host, range and x-amz-* headers are signed, along with the given hex-encoded SHA-256 checksum of the payload.
+func (store *S3BlobStore) SignRequest(req *http.Request, payloadHash string, t time.Time) {
+	t = t.UTC()
+	amzDate := t.Format("20060102T150405Z")
+	scope := t.Format("20060102") + "/" + store.Region + "/s3/aws4_request"
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	// Build the canonical headers from the headers that need to be signed.
+	headers := map[string]string{"host": req.URL.Host}
+	if req.Host != "" {
+		headers["host"] = req.Host
+	}
+	for name, values := range req.Header {
+		name = strings.ToLower(name)
+		if name == "range" || strings.HasPrefix(name, "x-amz-") {
+			headers[name] = strings.TrimSpace(strings.Join(values, ","))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	canonicalHeaders := ""
+	for _, name := range names {
+		canonicalHeaders += name + ":" + headers[name] + "\n"
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		s3EscapePath(req.URL.Path),
+		s3CanonicalQuery(req.URL.Query()),
+		canonicalHeaders,
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	canonicalRequestHash := sha256.Sum256([]byte(canonicalRequest))
+
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		hex.EncodeToString(canonicalRequestHash[:]),
+	}, "\n")
+
+	// Derive the signing key from the secret key and the scope of the request.
+	key := hmacSHA256([]byte("AWS4"+store.SecretAccessKey), t.Format("20060102"))
+	key = hmacSHA256(key, store.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		store.AccessKeyID, scope, signedHeaders, signature,
+	))
+}
+
+// hmacSHA256 returns the HMAC-SHA256 of data using the given key.
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// s3Escape URI encodes a string as required by AWS Signature Version 4.
+// Only unreserved characters are left as is, and slashes are also kept if keepSlash is set.
+func s3Escape(s string, keepSlash bool) string {
+	var builder strings.Builder
+	for _, b := range []byte(s) {
+		if 'A' <= b && b <= 'Z' || 'a' <= b && b <= 'z' || '0' <= b && b <= '9' ||
+			b == '-' || b == '_' || b == '.' || b == '~' || keepSlash && b == '/' {
+			builder.WriteByte(b)
+		} else {
+			builder.WriteString(fmt.Sprintf("%%%02X", b))
+		}
+	}
+	return builder.String()
+}
+
+// s3EscapePath URI encodes a URL path as required by AWS Signature Version 4.
+func s3EscapePath(path string) string {
+	if path == "" {
+		return "/"
+	}
+	return s3Escape(path, true)
+}
+
+// s3CanonicalQuery encodes query parameters sorted by name and value, as required by AWS Signature Version 4.
+func s3CanonicalQuery(query url.Values) string {
+	parameters := [][2]string{}
+	for name, values := range query {
+		for _, value := range values {
+			parameters = append(parameters, [2]string{s3Escape(name, false), s3Escape(value, false)})
+		}
+	}
+	sort.Slice(parameters, func(i, j int) bool {
+		if parameters[i][0] != parameters[j][0] {
+			return parameters[i][0] < parameters[j][0]
+		}
+		return parameters[i][1] < parameters[j][1]
+	})
+
+	encodedParameters := make([]string, len(parameters))
+	for i, parameter := range parameters {
+		encodedParameters[i] = parameter[0] + "=" + parameter[1]
+	}
+	return strings.Join(encodedParameters, "&")
+}
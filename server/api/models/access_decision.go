@@ -0,0 +1,253 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// AccessReason represents what decided a User's AccessLevel in a Folder.
+type AccessReason string
+
+const (
+	// ReasonDefault represents a User without any AccessRole applying to the Folder.
+	ReasonDefault AccessReason = "default"
+
+	// ReasonAdmin represents an admin without any AccessRole granting more than the Viewer access admins have by default.
+	ReasonAdmin AccessReason = "admin"
+
+	// ReasonGranted represents an AccessRole in the Folder granting the AccessLevel.
+	ReasonGranted AccessReason = "granted"
+
+	// ReasonInherited represents an inheritable AccessRole in an ancestor of the Folder granting the AccessLevel.
+	ReasonInherited AccessReason = "inherited"
+
+	// ReasonDenied represents an AccessRole in the Folder denying access.
+	ReasonDenied AccessReason = "denied"
+
+	// ReasonInheritedDenied represents an inheritable AccessRole in an ancestor of the Folder denying access.
+	ReasonInheritedDenied AccessReason = "inherited_denied"
+)
+
+// accessReasonExplanations explains each AccessReason in the responses.
+var accessReasonExplanations = map[AccessReason]string{
+	ReasonDefault:         "no access role applies to the folder",
+	ReasonAdmin:           "admins can view every folder",
+	ReasonGranted:         "granted by an access role in the folder",
+	ReasonInherited:       "inherited from an access role in an ancestor folder",
+	ReasonDenied:          "denied by an access role in the folder, which overrides all grants in the folder and its ancestors",
+	ReasonInheritedDenied: "denied by an access role in an ancestor folder, which overrides grants in that folder and further ancestors",
+}
+
+// AccessDecision represents a User's AccessLevel in a Folder and the reason for it.
+// AccessDecision.AccessRoleID and AccessDecision.SourceFolderID are the AccessRole deciding the AccessLevel and its Folder,
+// and are 0 if no AccessRole decided it.
+// AccessDecision.UserRoleID is the UserRole of the AccessRole, and is 0 if it was granted directly to the User.
+//
+// The AccessRoles applying to a Folder are its own AccessRoles and the inheritable AccessRoles of its ancestors,
+// up to the nearest Folder with Folder.BreakInheritance set.
+// An AccessRole with the None AccessLevel denies access, and they are resolved as follows:
+//
+// - AccessRoles in a nearer Folder take precedence over the AccessRoles in its ancestors.
+// A grant in a Folder therefore overrides a denial inherited from its ancestors.
+//
+// - A denial in a Folder overrides all the grants in the same Folder, even from other UserRoles of the User
+// or AccessRoles granted directly to the User,
+// along with all the grants inherited from further ancestors.
+//
+// - Otherwise, the highest AccessLevel granted applies.
+//
+// Admins keep the Viewer access they have by default even when denied access, so they can still assign AccessRoles.
+type AccessDecision struct {
+	FolderID       uint         `json:"folder_id"`
+	AccessLevel    AccessLevel  `json:"access_level"`
+	Reason         AccessReason `json:"reason"`
+	Explanation    string       `json:"explanation"`
+	AccessRoleID   uint         `json:"access_role_id"`
+	UserRoleID     uint         `json:"user_role_id"`
+	SourceFolderID uint         `json:"source_folder_id"`
+}
+
+// GetUserAccessDecision gets a User's AccessDecision in a Folder.
+func GetUserAccessDecision(db *gorm.DB, user User, folderID uint) (AccessDecision, error) {
+	if folderID == 0 {
+		return AccessDecision{}, ErrRequiredFolderID
+	}
+	_, err := getFolderByIDRaw(db, folderID)
+	if err != nil {
+		return AccessDecision{}, err
+	}
+
+	decisions, err := GetUserAccessDecisions(db, user, []uint{folderID})
+	if err != nil {
+		return AccessDecision{}, err
+	}
+	return decisions[folderID], nil
+}
+
+// GetUserAccessDecisions gets a User's AccessDecision in each of the given Folders, keyed by their Model.ID.
+// The User's AccessRoles and the ancestors of the Folders are only loaded once.
+func GetUserAccessDecisions(db *gorm.DB, user User, folderIDs []uint) (map[uint]AccessDecision, error) {
+	// Get all the user's access roles from the database
+	accessRoles, err := getUserAccessRoles(db, user)
+	if err != nil {
+		return nil, err
+	}
+
+	// Ancestors only need to be looked up if any of the access roles are inherited
+	ancestry := map[uint]folderNode{}
+	for _, accessRole := range accessRoles {
+		if accessRole.IsInheritable() {
+			ancestry, err = getFolderAncestry(db, folderIDs)
+			if err != nil {
+				return nil, err
+			}
+			break
+		}
+	}
+
+	decisions := make(map[uint]AccessDecision, len(folderIDs))
+	for _, folderID := range folderIDs {
+		decisions[folderID] = userAccessDecision(user, accessRoles, ancestry, folderID)
+	}
+	return decisions, nil
+}
+
+// GetUserAuthorizationFolders gets a User's AccessLevel in each of the given Folders, keyed by their Model.ID.
+// The AccessLevels are the same as returned by GetUserAuthorizationFolder,
+// but the User's AccessRoles and the ancestors of the Folders are only loaded once.
+func GetUserAuthorizationFolders(db *gorm.DB, user User, folderIDs []uint) (map[uint]AccessLevel, error) {
+	decisions, err := GetUserAccessDecisions(db, user, folderIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	accessLevels := make(map[uint]AccessLevel, len(decisions))
+	for folderID, decision := range decisions {
+		accessLevels[folderID] = decision.AccessLevel
+	}
+	return accessLevels, nil
+}
+
+// getUserAccessRoles gets the AccessRoles of all of a User's UserRoles, along with those granted directly to the User.
+// AccessRoles and assignments of UserRoles outside their GrantWindow are left out.
+func getUserAccessRoles(db *gorm.DB, user User) ([]AccessRole, error) {
+	var accessRoles []AccessRole
+	if user.ID == 0 {
+		return accessRoles, nil
+	}
+
+	now := time.Now()
+	err := db.Where(`(access_roles.user_id = @user OR access_roles.user_role_id IN (
+			SELECT assigned_user_roles.user_role_id FROM assigned_user_roles
+			WHERE assigned_user_roles.user_id = @user AND `+activeGrantWindow("assigned_user_roles")+`
+		)) AND `+activeGrantWindow("access_roles"),
+		sql.Named("user", user.ID), sql.Named("now", now)).Find(&accessRoles).Error
+	return accessRoles, err
+}
+
+// folderNode is the place of a Folder in the Folder tree, used to find the AccessRoles it inherits.
+type folderNode struct {
+	ID               uint
+	ParentFolderID   uint
+	BreakInheritance bool
+}
+
+// getFolderAncestry gets the folderNode of each of the given Folders and all their ancestors, keyed by their Model.ID,
+// in a single query.
+func getFolderAncestry(db *gorm.DB, folderIDs []uint) (map[uint]folderNode, error) {
+	var nodes []folderNode
+	err := db.Raw(`WITH RECURSIVE ancestors(id) AS (
+			SELECT id FROM folders WHERE id IN ? AND deleted_at IS NULL
+			UNION
+			SELECT folders.parent_folder_id FROM folders JOIN ancestors ON folders.id = ancestors.id
+			WHERE COALESCE(folders.parent_folder_id, 0) != 0 AND folders.deleted_at IS NULL
+		)
+		SELECT id, COALESCE(parent_folder_id, 0) AS parent_folder_id, break_inheritance FROM folders
+		WHERE id IN (SELECT id FROM ancestors) AND deleted_at IS NULL`, folderIDs).Scan(&nodes).Error
+	if err != nil {
+		return nil, err
+	}
+
+	ancestry := make(map[uint]folderNode, len(nodes))
+	for _, node := range nodes {
+		ancestry[node.ID] = node
+	}
+	return ancestry, nil
+}
+
+// userAccessDecision decides a User's AccessLevel in a Folder from the AccessRoles of the User's UserRoles and the User,
+// following the rules described in AccessDecision.
+// Inheritable AccessRoles of the ancestors of the Folder in the ancestry also apply.
+func userAccessDecision(user User, accessRoles []AccessRole, ancestry map[uint]folderNode, folderID uint) AccessDecision {
+	decision := AccessDecision{FolderID: folderID, AccessLevel: None, Reason: ReasonDefault}
+
+	// applyAccessRoles applies the AccessRoles in a Folder to the decision,
+	// returning whether the AccessRoles of further ancestors no longer matter.
+	applyAccessRoles := func(sourceFolderID uint, inherited bool) bool {
+		grantReason, denyReason := ReasonGranted, ReasonDenied
+		if inherited {
+			grantReason, denyReason = ReasonInherited, ReasonInheritedDenied
+		}
+
+		// The user can have multiple user roles with different access roles in the same folder
+		// A denial overrides all of them, otherwise we take the one granting the most privileges
+		grant := decision
+		for _, accessRole := range accessRoles {
+			if accessRole.FolderID != sourceFolderID || (inherited && !accessRole.IsInheritable()) {
+				continue
+			}
+
+			if accessRole.AccessLevel == None {
+				// Grants in nearer folders take precedence over the denial
+				if decision.AccessLevel == None {
+					decision = AccessDecision{
+						FolderID:       folderID,
+						AccessLevel:    None,
+						Reason:         denyReason,
+						AccessRoleID:   accessRole.ID,
+						UserRoleID:     accessRole.UserRoleID,
+						SourceFolderID: sourceFolderID,
+					}
+				}
+				return true
+			}
+
+			if accessRole.AccessLevel > grant.AccessLevel {
+				grant = AccessDecision{
+					FolderID:       folderID,
+					AccessLevel:    accessRole.AccessLevel,
+					Reason:         grantReason,
+					AccessRoleID:   accessRole.ID,
+					UserRoleID:     accessRole.UserRoleID,
+					SourceFolderID: sourceFolderID,
+				}
+			}
+		}
+
+		decision = grant
+		// No point continuing if highest access role is achieved
+		return decision.AccessLevel == Publisher
+	}
+
+	// Walk up the ancestors, taking inheritable access roles into account until inheritance is broken
+	// The walk is limited to the size of the ancestry, so it always ends
+	done := applyAccessRoles(folderID, false)
+	node, ok := ancestry[folderID]
+	for steps := 0; !done && ok && !node.BreakInheritance && steps < len(ancestry); steps++ {
+		node, ok = ancestry[node.ParentFolderID]
+		if ok {
+			done = applyAccessRoles(node.ID, true)
+		}
+	}
+
+	// Admins need viewer permissions to all folders by default
+	// This allows them to grant roles to other users
+	if user.IsAdmin && decision.AccessLevel < Viewer {
+		decision = AccessDecision{FolderID: folderID, AccessLevel: Viewer, Reason: ReasonAdmin}
+	}
+
+	decision.Explanation = accessReasonExplanations[decision.Reason]
+	return decision
+}
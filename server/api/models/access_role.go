@@ -21,13 +21,30 @@ var ErrAccessRoleNotFound = errors.New("access role not found")
 // ErrAccessRoleAlreadyExists returned when an AccessRole with the given information already exists.
 var ErrAccessRoleAlreadyExists = errors.New("access role already exists")
 
-// AccessRole represents a single AccessLevel to a single Folder assigned to a UserRole.
-// Only 1 AccessRole can exist per UserRole per Folder.
+// ErrInvalidAccessRoleTarget returned when an AccessRole targets both a UserRole and a User,
+// or is changed from targeting one to the other.
+var ErrInvalidAccessRoleTarget = errors.New("access role must target either a user role or a user")
+
+// AccessRole represents a single AccessLevel to a single Folder assigned to a UserRole,
+// or directly to a User with AccessRole.UserID instead of AccessRole.UserRoleID.
+// Only 1 AccessRole can exist per UserRole or User per Folder.
+// The AccessRole only grants access during its GrantWindow.
+// An inheritable AccessRole also grants its AccessLevel in all the descendants of the Folder,
+// down to the nearest Folder with Folder.BreakInheritance set.
+// AccessRole.Inheritable is a pointer so it can be unset in UpdateAccessRole.
 type AccessRole struct {
 	ID          uint        `gorm:"primaryKey" json:"id"`
 	FolderID    uint        `gorm:"not null" json:"folder_id"`
 	UserRoleID  uint        `gorm:"not null" json:"user_role_id"`
+	UserID      uint        `gorm:"not null;default:0;index" json:"user_id"`
 	AccessLevel AccessLevel `gorm:"not null" json:"access_level"`
+	Inheritable *bool       `gorm:"not null;default:false" json:"inheritable"`
+	GrantWindow
+}
+
+// IsInheritable returns whether the AccessRole applies to the descendants of its Folder.
+func (accessRole AccessRole) IsInheritable() bool {
+	return accessRole.Inheritable != nil && *accessRole.Inheritable
 }
 
 // AccessLevel represents the AccessLevel of a User.
@@ -39,12 +56,15 @@ const (
 	Unset AccessLevel = iota
 
 	// None represents a lack of access to a Folder.
-	// Currently obsolete as by default, users have no access to a child Folder even if they have access to the parent.
-	// Left in place in case future upgrades make it necessary to have a None access level.
+	// By default, users have no access to a child Folder even if they have access to the parent,
+	// unless their AccessRole in the parent is inheritable.
+	// An AccessRole with the None AccessLevel denies access to the Folder, overriding the grants of other AccessRoles
+	// as described in AccessDecision.
 	None
 
 	// Viewer represents a user that can only view a File inside a Folder, but cannot edit it.
-	// In order to access a child Folder, a user must have at least the Viewer role in the parent Folder.
+	// In order to access a child Folder, a user must have at least the Viewer role in the child Folder,
+	// either directly or through an inheritable AccessRole in one of its ancestors.
 	Viewer
 
 	// Uploader represents a user that has all the permissions of Viewer but can also upload a draft File into a folder.
@@ -55,46 +75,113 @@ const (
 	// Publisher represents a user that has all the permissions of Uploader but can also publish a draft File.
 	// A Publisher can also edit a File's metadata and delete a File.
 	// A Publisher can also create a child Folder in a parent Folder, but by default will not have any rights in them.
-	// To interact with created child folders, a Publisher must contact an admin to assign an appropriate AccessRole.
+	// To interact with created child folders, a Publisher must contact an admin to assign an appropriate AccessRole,
+	// unless their AccessRole in the parent Folder is inheritable.
 	Publisher
 )
 
+// accessLevelNames names each AccessLevel.
+var accessLevelNames = map[AccessLevel]string{
+	Unset:     "unset",
+	None:      "none",
+	Viewer:    "viewer",
+	Uploader:  "uploader",
+	Publisher: "publisher",
+}
+
+// String returns the name of an AccessLevel.
+func (accessLevel AccessLevel) String() string {
+	if name, ok := accessLevelNames[accessLevel]; ok {
+		return name
+	}
+	return "invalid"
+}
+
 // CreateAccessRole creates an AccessRole.
 func CreateAccessRole(db *gorm.DB, accessRole AccessRole) (AccessRole, error) {
 	// Validate access role information
 	if accessRole.FolderID == 0 {
 		return AccessRole{}, ErrRequiredFolderID
 	}
-	if accessRole.UserRoleID == 0 {
+	if accessRole.UserRoleID == 0 && accessRole.UserID == 0 {
 		return AccessRole{}, ErrRequiredUserRoleID
 	}
+	if accessRole.UserRoleID != 0 && accessRole.UserID != 0 {
+		return AccessRole{}, ErrInvalidAccessRoleTarget
+	}
 	if accessRole.AccessLevel == Unset {
 		return AccessRole{}, ErrRequiredAccessLevel
 	}
 	if accessRole.AccessLevel > Publisher {
 		return AccessRole{}, ErrInvalidAccessLevel
 	}
+	accessRole.GrantWindow.prepare()
+	err := accessRole.GrantWindow.validate()
+	if err != nil {
+		return AccessRole{}, err
+	}
 
 	// Check folder referenced in access role exists
-	_, err := getFolderByIDRaw(db, accessRole.FolderID)
+	_, err = getFolderByIDRaw(db, accessRole.FolderID)
 	if err != nil {
 		return AccessRole{}, err
 	}
 
+	err = checkAccessRoleTarget(db, accessRole)
+	if err != nil {
+		return AccessRole{}, err
+	}
+
+	return accessRole, db.Create(&accessRole).Take(&accessRole).Error
+}
+
+// checkAccessRoleTarget checks the UserRole or User targeted by an AccessRole exists,
+// and has no other AccessRole in the same Folder.
+func checkAccessRoleTarget(db *gorm.DB, accessRole AccessRole) error {
+	if accessRole.UserID != 0 {
+		// Check if referenced user exists.
+		_, err := getUserByIDRaw(db, accessRole.UserID)
+		if err != nil {
+			return err
+		}
+
+		// Assert that no other access role with the same folder id is granted to the user.
+		var count int64
+		err = db.Model(&AccessRole{}).Where("user_id = ? AND folder_id = ? AND id != ?",
+			accessRole.UserID, accessRole.FolderID, accessRole.ID).Count(&count).Error
+		if err != nil {
+			return err
+		} else if count > 0 {
+			return ErrAccessRoleAlreadyExists
+		}
+		return nil
+	}
+
 	// Check if referenced user role exists.
 	userRole, err := GetUserRoleByID(db, accessRole.UserRoleID)
 	if err != nil {
-		return AccessRole{}, err
+		return err
 	}
 
-	// Assert that no access role with the same folder id exists in the user role.
+	// Assert that no other access role with the same folder id exists in the user role.
 	for _, role := range userRole.AccessRoles {
-		if role.FolderID == accessRole.FolderID {
-			return AccessRole{}, ErrAccessRoleAlreadyExists
+		if role.FolderID == accessRole.FolderID && role.ID != accessRole.ID {
+			return ErrAccessRoleAlreadyExists
 		}
 	}
+	return nil
+}
 
-	return accessRole, db.Create(&accessRole).Take(&accessRole).Error
+// GetDirectAccessRoles returns the AccessRoles granted directly to a User by its Model.ID.
+func GetDirectAccessRoles(db *gorm.DB, userID uint) ([]AccessRole, error) {
+	_, err := getUserByIDRaw(db, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	accessRoles := []AccessRole{}
+	err = db.Where("user_id = ?", userID).Order("id").Find(&accessRoles).Error
+	return accessRoles, err
 }
 
 // GetAccessRoleByID returns an AccessRole by its AccessRole.ID.
@@ -115,7 +202,9 @@ func GetAccessRoleByID(db *gorm.DB, roleID uint) (AccessRole, error) {
 }
 
 // UpdateAccessRole updates an AccessRole by its AccessRole.ID.
-func UpdateAccessRole(db *gorm.DB, accessRole AccessRole) (AccessRole, error) {
+// GrantWindow bounds that are nil are left unchanged, unless their column ("starts_at" or "expires_at")
+// is listed in windowColumns, in which case they are cleared.
+func UpdateAccessRole(db *gorm.DB, accessRole AccessRole, windowColumns ...string) (AccessRole, error) {
 	if accessRole.AccessLevel > Publisher {
 		return AccessRole{}, ErrInvalidAccessLevel
 	}
@@ -133,30 +222,72 @@ func UpdateAccessRole(db *gorm.DB, accessRole AccessRole) (AccessRole, error) {
 			return AccessRole{}, err
 		}
 	}
-	if accessRole.UserRoleID == 0 {
-		accessRole.UserRoleID = oldRole.UserRoleID
+
+	// An access role granted directly to a user stays granted to a user, and likewise for user roles.
+	if oldRole.UserID != 0 {
+		if accessRole.UserRoleID != 0 {
+			return AccessRole{}, ErrInvalidAccessRoleTarget
+		}
+		if accessRole.UserID == 0 {
+			accessRole.UserID = oldRole.UserID
+		}
+	} else {
+		if accessRole.UserID != 0 {
+			return AccessRole{}, ErrInvalidAccessRoleTarget
+		}
+		if accessRole.UserRoleID == 0 {
+			accessRole.UserRoleID = oldRole.UserRoleID
+		}
 	}
 
-	// Check if referenced user role exists.
-	userRole, err := GetUserRoleByID(db, accessRole.UserRoleID)
+	err = checkAccessRoleTarget(db, accessRole)
 	if err != nil {
 		return AccessRole{}, err
 	}
 
-	// Assert that no access role with the same folder id exists in the user role if the folder id was changed.
-	if accessRole.FolderID != oldRole.FolderID {
-		for _, role := range userRole.AccessRoles {
-			if role.FolderID == accessRole.FolderID {
-				return AccessRole{}, ErrAccessRoleAlreadyExists
-			}
+	if accessRole.AccessLevel == Unset {
+		accessRole.AccessLevel = oldRole.AccessLevel
+	}
+
+	accessRole.GrantWindow.prepare()
+
+	// Updates skips nil fields, so the listed window columns are set separately to be able to clear them.
+	windowUpdates := make(map[string]interface{}, len(windowColumns))
+	for _, column := range windowColumns {
+		switch column {
+		case "starts_at":
+			windowUpdates[column] = accessRole.StartsAt
+		case "expires_at":
+			windowUpdates[column] = accessRole.ExpiresAt
 		}
 	}
 
-	if accessRole.AccessLevel == Unset {
-		accessRole.AccessLevel = oldRole.AccessLevel
+	// The grant window is checked along with the bounds that aren't changed.
+	window := accessRole.GrantWindow
+	if _, ok := windowUpdates["starts_at"]; !ok && window.StartsAt == nil {
+		window.StartsAt = oldRole.StartsAt
+	}
+	if _, ok := windowUpdates["expires_at"]; !ok && window.ExpiresAt == nil {
+		window.ExpiresAt = oldRole.ExpiresAt
+	}
+	err = window.validate()
+	if err != nil {
+		return AccessRole{}, err
 	}
 
-	err = db.Model(&accessRole).Updates(&accessRole).Take(&accessRole).Error
+	err = db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&accessRole).Updates(&accessRole).Error
+		if err != nil {
+			return err
+		}
+		if len(windowUpdates) > 0 {
+			err = tx.Model(&accessRole).Updates(windowUpdates).Error
+			if err != nil {
+				return err
+			}
+		}
+		return tx.Take(&accessRole).Error
+	})
 	return accessRole, err
 }
 
@@ -0,0 +1,110 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ErrRequiredChecksum returned when no checksum is specified.
+var ErrRequiredChecksum = errors.New("required checksum")
+
+// ErrBlobNotFound returned when no Blob matches the given criteria.
+var ErrBlobNotFound = errors.New("blob not found")
+
+// Blob represents a piece of file data, stored once by its hex-encoded SHA-256 checksum.
+// Every FileVersion with the same FileVersion.Checksum shares the Blob, so identical uploads are only stored once.
+// Blob.RefCount counts the FileVersion referencing the Blob, and the Blob is removed once nothing references it.
+type Blob struct {
+	Checksum  string    `gorm:"primaryKey" json:"checksum"`
+	Size      int64     `gorm:"not null" json:"size"`
+	RefCount  int64     `gorm:"not null" json:"ref_count"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// AddBlobReference adds a reference to the Blob with the given checksum, creating the Blob if it isn't referenced yet.
+func AddBlobReference(db *gorm.DB, checksum string, size int64) (Blob, error) {
+	if checksum == "" {
+		return Blob{}, ErrRequiredChecksum
+	}
+
+	blob, err := GetBlob(db, checksum)
+	if err == ErrBlobNotFound {
+		blob = Blob{
+			Checksum: checksum,
+			Size:     size,
+			RefCount: 1,
+		}
+		err = db.Create(&blob).Error
+		return blob, err
+	} else if err != nil {
+		return Blob{}, err
+	}
+
+	err = db.Model(&blob).Update("ref_count", gorm.Expr("ref_count + 1")).Error
+	if err != nil {
+		return Blob{}, err
+	}
+	return GetBlob(db, checksum)
+}
+
+// RemoveBlobReference removes a reference to the Blob with the given checksum.
+// The Blob is deleted once its last reference is removed, and the return value reports whether it was.
+// Removing a reference to a Blob that doesn't exist does nothing, as data stored by file id has no Blob.
+func RemoveBlobReference(db *gorm.DB, checksum string) (bool, error) {
+	if checksum == "" {
+		return false, ErrRequiredChecksum
+	}
+
+	blob, err := GetBlob(db, checksum)
+	if err == ErrBlobNotFound {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	if blob.RefCount > 1 {
+		err = db.Model(&blob).Update("ref_count", gorm.Expr("ref_count - 1")).Error
+		return false, err
+	}
+
+	err = db.Delete(&blob).Error
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// GetBlob gets a Blob by its Blob.Checksum.
+func GetBlob(db *gorm.DB, checksum string) (Blob, error) {
+	if checksum == "" {
+		return Blob{}, ErrRequiredChecksum
+	}
+
+	blob := Blob{}
+	err := db.Where(&Blob{Checksum: checksum}).Take(&blob).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return Blob{}, ErrBlobNotFound
+	}
+	return blob, err
+}
+
+// CountBlobReferences counts the FileVersion referencing the Blob with the given checksum.
+// Unlike Blob.RefCount, the FileVersion are counted directly, so this also works for data that has no Blob.
+func CountBlobReferences(db *gorm.DB, checksum string) (int64, error) {
+	if checksum == "" {
+		return 0, ErrRequiredChecksum
+	}
+
+	var count int64
+	err := db.Model(&FileVersion{}).Where(&FileVersion{Checksum: checksum}).Count(&count).Error
+	return count, err
+}
+
+// GetAllBlobs returns every Blob, ordered by Blob.Checksum.
+func GetAllBlobs(db *gorm.DB) ([]Blob, error) {
+	blobs := []Blob{}
+	err := db.Order("checksum").Find(&blobs).Error
+	return blobs, err
+}
@@ -0,0 +1,196 @@
+package models
+
+import (
+	"html"
+
+	"gorm.io/gorm"
+)
+
+// FolderCopy describes a copy of a Folder and the Folders and Files nested inside it.
+// FolderCopy.FolderIDs lists the Folders to copy, starting with the Folder itself, and every Folder must come after its parent.
+// Folders whose parent isn't copied are left out, and only the Files in FolderCopy.FileIDs are copied.
+// The copy of the Folder is created in FolderCopy.ParentFolderID, named FolderCopy.Name or the name of the Folder if it's empty.
+// FolderCopy.CopyAccessRoles also copies the AccessRoles of the Folders and whether they break inheritance,
+// granting the same access to the copies.
+type FolderCopy struct {
+	ParentFolderID  uint
+	Name            string
+	EditorID        uint
+	FolderIDs       []uint
+	FileIDs         []uint
+	CopyAccessRoles bool
+}
+
+// CopyFile creates a copy of a File in a Folder, named name or the name of the File if it's empty.
+// The copy shares the data of the latest FileVersion of the File, which becomes its first FileVersion uploaded by the editor,
+// so the data is referenced again instead of being stored twice.
+// Data stored before versioning was introduced isn't copied, so it must be stored as a FileVersion first.
+// The text of the data is indexed for searching by the search index sweeper.
+func CopyFile(db *gorm.DB, fileID, folderID uint, name string, editorID uint, isPublished bool) (File, error) {
+	var file File
+	err := db.Transaction(func(tx *gorm.DB) error {
+		source, err := GetFileByID(tx, fileID)
+		if err != nil {
+			return err
+		}
+
+		file, err = copyFile(tx, source, folderID, name, editorID, isPublished)
+		return err
+	})
+	if err != nil {
+		return File{}, err
+	}
+	return file, nil
+}
+
+// copyFile creates a copy of a File along with the data of its latest FileVersion.
+func copyFile(db *gorm.DB, source File, folderID uint, name string, editorID uint, isPublished bool) (File, error) {
+	// Names are stored escaped, so the name is unescaped to avoid escaping it twice.
+	if name == "" {
+		name = html.UnescapeString(source.Name)
+	}
+
+	file, err := CreateFile(db, File{
+		Name:         name,
+		FolderID:     folderID,
+		LastEditorID: editorID,
+		IsPublished:  isPublished,
+	})
+	if err != nil {
+		return File{}, err
+	}
+
+	version, err := GetLatestFileVersion(db, source.ID)
+	if err == ErrFileVersionNotFound {
+		return file, nil
+	} else if err != nil {
+		return File{}, err
+	}
+
+	// The data is the same, so the outcome of scanning it still applies.
+	_, err = createFileVersion(db, FileVersion{
+		FileID:      file.ID,
+		Number:      1,
+		Size:        version.Size,
+		Checksum:    version.Checksum,
+		ContentType: version.ContentType,
+		ScanStatus:  version.ScanStatus,
+		UploaderID:  editorID,
+	})
+	if err != nil {
+		return File{}, err
+	}
+	return GetFileByID(db, file.ID)
+}
+
+// CopyFolder copies a Folder and the Folders and Files nested inside it in a single transaction,
+// so either the whole copy is created or nothing is.
+// The Files are copied as with CopyFile, keeping whether they are published.
+// Returns the copy of the Folder.
+func CopyFolder(db *gorm.DB, folderCopy FolderCopy) (Folder, error) {
+	if len(folderCopy.FolderIDs) == 0 {
+		return Folder{}, ErrRequiredFolderID
+	}
+
+	var copyID uint
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var folders []Folder
+		err := tx.Where("id IN ?", folderCopy.FolderIDs).Find(&folders).Error
+		if err != nil {
+			return err
+		}
+		sources := make(map[uint]Folder, len(folders))
+		for _, folder := range folders {
+			sources[folder.ID] = folder
+		}
+
+		// Map the Model.ID of each copied Folder to the Model.ID of its copy.
+		copies := make(map[uint]uint, len(folderCopy.FolderIDs))
+		for i, folderID := range folderCopy.FolderIDs {
+			source, ok := sources[folderID]
+			if !ok {
+				if i == 0 {
+					return ErrFolderNotFound
+				}
+				continue
+			}
+
+			folder := Folder{
+				Name:         html.UnescapeString(source.Name),
+				LastEditorID: folderCopy.EditorID,
+			}
+			if i == 0 {
+				folder.ParentFolderID = &folderCopy.ParentFolderID
+				if folderCopy.Name != "" {
+					folder.Name = folderCopy.Name
+				}
+			} else if source.ParentFolderID == nil || copies[*source.ParentFolderID] == 0 {
+				continue
+			} else {
+				parentFolderID := copies[*source.ParentFolderID]
+				folder.ParentFolderID = &parentFolderID
+			}
+
+			folder, err = CreateFolder(tx, folder)
+			if err != nil {
+				return err
+			}
+			copies[folderID] = folder.ID
+
+			if folderCopy.CopyAccessRoles && source.BreakInheritance {
+				err = tx.Model(&folder).Update("break_inheritance", true).Error
+				if err != nil {
+					return err
+				}
+			}
+		}
+		copyID = copies[folderCopy.FolderIDs[0]]
+
+		var files []File
+		err = tx.Where("id IN ?", folderCopy.FileIDs).Order("id").Find(&files).Error
+		if err != nil {
+			return err
+		}
+		for _, file := range files {
+			folderID, ok := copies[file.FolderID]
+			if !ok {
+				continue
+			}
+			_, err = copyFile(tx, file, folderID, "", folderCopy.EditorID, file.IsPublished)
+			if err != nil {
+				return err
+			}
+		}
+
+		if !folderCopy.CopyAccessRoles {
+			return nil
+		}
+		var accessRoles []AccessRole
+		err = tx.Where("folder_id IN ?", folderCopy.FolderIDs).Order("id").Find(&accessRoles).Error
+		if err != nil {
+			return err
+		}
+		for _, accessRole := range accessRoles {
+			folderID, ok := copies[accessRole.FolderID]
+			if !ok {
+				continue
+			}
+			err = tx.Create(&AccessRole{
+				FolderID:    folderID,
+				UserRoleID:  accessRole.UserRoleID,
+				UserID:      accessRole.UserID,
+				AccessLevel: accessRole.AccessLevel,
+				Inheritable: accessRole.Inheritable,
+				GrantWindow: accessRole.GrantWindow,
+			}).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return Folder{}, err
+	}
+	return GetFolderByID(db, copyID)
+}
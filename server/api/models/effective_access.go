@@ -0,0 +1,130 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// EffectiveAccess represents a User's AccessDecision in a Folder, along with the names of the User and Folder.
+type EffectiveAccess struct {
+	UserID     uint   `json:"user_id"`
+	Username   string `json:"username"`
+	FolderName string `json:"folder_name"`
+	AccessDecision
+}
+
+// GetFolderEffectiveAccess gets the EffectiveAccess of every present User in a Folder, sorted by their Model.ID.
+// The AccessRoles of all the Users are loaded at once.
+func GetFolderEffectiveAccess(db *gorm.DB, folderID uint) ([]EffectiveAccess, error) {
+	if folderID == 0 {
+		return nil, ErrRequiredFolderID
+	}
+	folder, err := getFolderByIDRaw(db, folderID)
+	if err != nil {
+		return nil, err
+	}
+
+	var users []User
+	err = db.Order("id").Find(&users).Error
+	if err != nil {
+		return nil, err
+	}
+
+	accessRoles, err := getAllUserAccessRoles(db)
+	if err != nil {
+		return nil, err
+	}
+
+	ancestry, err := getFolderAncestry(db, []uint{folderID})
+	if err != nil {
+		return nil, err
+	}
+
+	effectiveAccess := make([]EffectiveAccess, len(users))
+	for i, user := range users {
+		effectiveAccess[i] = EffectiveAccess{
+			UserID:         user.ID,
+			Username:       user.Username,
+			FolderName:     folder.Name,
+			AccessDecision: userAccessDecision(user, accessRoles[user.ID], ancestry, folderID),
+		}
+	}
+	return effectiveAccess, nil
+}
+
+// GetUserEffectiveAccess gets the EffectiveAccess of a User in every present Folder they can view, sorted by their Model.ID.
+func GetUserEffectiveAccess(db *gorm.DB, userID uint) ([]EffectiveAccess, error) {
+	user, err := getUserByIDRaw(db, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	accessRoles, err := getUserAccessRoles(db, user)
+	if err != nil {
+		return nil, err
+	}
+
+	// Every folder is checked, so the ancestry is made of all the folders.
+	var folders []Folder
+	err = db.Order("id").Find(&folders).Error
+	if err != nil {
+		return nil, err
+	}
+	ancestry := make(map[uint]folderNode, len(folders))
+	for _, folder := range folders {
+		node := folderNode{ID: folder.ID, BreakInheritance: folder.BreakInheritance}
+		if folder.ParentFolderID != nil {
+			node.ParentFolderID = *folder.ParentFolderID
+		}
+		ancestry[folder.ID] = node
+	}
+
+	effectiveAccess := []EffectiveAccess{}
+	for _, folder := range folders {
+		decision := userAccessDecision(user, accessRoles, ancestry, folder.ID)
+		if decision.AccessLevel < Viewer {
+			continue
+		}
+		effectiveAccess = append(effectiveAccess, EffectiveAccess{
+			UserID:         user.ID,
+			Username:       user.Username,
+			FolderName:     folder.Name,
+			AccessDecision: decision,
+		})
+	}
+	return effectiveAccess, nil
+}
+
+// getAllUserAccessRoles gets the AccessRoles of every User, as returned by getUserAccessRoles, keyed by their Model.ID.
+func getAllUserAccessRoles(db *gorm.DB) (map[uint][]AccessRole, error) {
+	now := sql.Named("now", time.Now())
+
+	var accessRoles []AccessRole
+	err := db.Where(activeGrantWindow("access_roles"), now).Find(&accessRoles).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var assignments []AssignedUserRole
+	err = db.Where(activeGrantWindow("assigned_user_roles"), now).Find(&assignments).Error
+	if err != nil {
+		return nil, err
+	}
+
+	userAccessRoles := map[uint][]AccessRole{}
+	userRoleAccessRoles := map[uint][]AccessRole{}
+	for _, accessRole := range accessRoles {
+		if accessRole.UserID != 0 {
+			userAccessRoles[accessRole.UserID] = append(userAccessRoles[accessRole.UserID], accessRole)
+		} else {
+			userRoleAccessRoles[accessRole.UserRoleID] = append(userRoleAccessRoles[accessRole.UserRoleID], accessRole)
+		}
+	}
+	for _, assignment := range assignments {
+		userAccessRoles[assignment.UserID] = append(userAccessRoles[assignment.UserID],
+			userRoleAccessRoles[assignment.UserRoleID]...)
+	}
+	return userAccessRoles, nil
+}
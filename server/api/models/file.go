@@ -22,13 +22,19 @@ var ErrFileAlreadyExists = errors.New("file already exists")
 // Doesn't contain the actual file in memory - files can be accessed by querying the file system.
 // A File object doesn't guarantee a file exists, as the file data needs to be uploaded after the creation of a File.
 // File names are unique per FolderID.
+// File.Size, File.Checksum, File.ContentType and File.ScanStatus describe the current file data
+// and are only set when a FileVersion is created.
 type File struct {
 	Model
-	Name         string `gorm:"not null" json:"name"`
-	FolderID     uint   `gorm:"not null" json:"folder_id"`
-	LastEditorID uint   `gorm:"not null" json:"last_editor_id"`
-	LastEditor   User   `gorm:"foreignKey:LastEditorID" json:"-"`
-	IsPublished  bool   `json:"is_published"`
+	Name         string     `gorm:"not null" json:"name"`
+	FolderID     uint       `gorm:"not null" json:"folder_id"`
+	LastEditorID uint       `gorm:"not null" json:"last_editor_id"`
+	LastEditor   User       `gorm:"foreignKey:LastEditorID" json:"-"`
+	IsPublished  bool       `json:"is_published"`
+	Size         int64      `gorm:"not null;default:0" json:"size"`
+	Checksum     string     `gorm:"not null;default:''" json:"checksum"`
+	ContentType  string     `gorm:"not null;default:''" json:"content_type"`
+	ScanStatus   ScanStatus `gorm:"not null;default:''" json:"scan_status"`
 }
 
 // prepare escapes File.Name before processing.
@@ -43,6 +49,12 @@ func CreateFile(db *gorm.DB, file File) (File, error) {
 		return File{}, ErrRequiredLastEditorID
 	}
 
+	// No data has been uploaded for a new file.
+	file.Size = 0
+	file.Checksum = ""
+	file.ContentType = ""
+	file.ScanStatus = ""
+
 	_, err := getFolderByIDRaw(db, file.FolderID)
 	if err != nil {
 		return File{}, err
@@ -76,6 +88,14 @@ func GetFileByID(db *gorm.DB, fileID uint) (File, error) {
 	return file, err
 }
 
+// GetFiles gets the Files with the given Model.IDs at once.
+// Files that don't exist are left out.
+func GetFiles(db *gorm.DB, fileIDs []uint) ([]File, error) {
+	files := []File{}
+	err := db.Where("id IN ?", fileIDs).Find(&files).Error
+	return files, err
+}
+
 // GetFileByPath gets a File by its File.Name and File.FolderID.
 func GetFileByPath(db *gorm.DB, file File) (File, error) {
 	file.prepare()
@@ -110,6 +130,11 @@ func UpdateFile(db *gorm.DB, file File) (File, error) {
 	if file.Name == "" {
 		file.Name = oldFile.Name
 	}
+	// The file data can only be changed by creating a FileVersion.
+	file.Size = oldFile.Size
+	file.Checksum = oldFile.Checksum
+	file.ContentType = oldFile.ContentType
+	file.ScanStatus = oldFile.ScanStatus
 	if file.FolderID == 0 {
 		file.FolderID = oldFile.FolderID
 	} else {
@@ -145,6 +170,13 @@ func DeleteFile(db *gorm.DB, fileID, userID uint) error {
 	return db.Where(&file).Delete(&file).Error
 }
 
+// GetAllFileIDs returns the Model.ID of every File, including deleted files whose data hasn't been purged yet.
+func GetAllFileIDs(db *gorm.DB) ([]uint, error) {
+	var fileIDs []uint
+	err := db.Unscoped().Model(&File{}).Order("id").Pluck("id", &fileIDs).Error
+	return fileIDs, err
+}
+
 // GetUserAuthorizationFile gets a User's AccessLevel to a certain File.
 func GetUserAuthorizationFile(db *gorm.DB, user User, fileID uint) (File, AccessLevel, error) {
 	file, err := GetFileByID(db, fileID)
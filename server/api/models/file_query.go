@@ -0,0 +1,73 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// fileSortColumns lists the columns Files can be sorted by, besides their Model.ID.
+var fileSortColumns = sortColumns{
+	"name":       false,
+	"created_at": true,
+	"updated_at": true,
+}
+
+// FileQuery filters and sorts the Files in a set of Folders.
+// Files are returned from FileQuery.FolderIDs, leaving out drafts unless they are in FileQuery.DraftFolderIDs
+// or were last edited by FileQuery.DraftEditorID, which lets callers hide drafts a User isn't allowed to see.
+// Files are only filtered by the optional fields that are set.
+// The Files can be sorted by name, created_at or updated_at, and are sorted by name by default.
+type FileQuery struct {
+	FolderIDs      []uint
+	DraftFolderIDs []uint
+	DraftEditorID  uint
+	IsPublished    *bool
+	LastEditorID   uint
+	UpdatedAfter   time.Time
+	NamePrefix     string
+	Page           Page
+}
+
+// QueryFiles gets a Page of the Files matching a FileQuery.
+func QueryFiles(db *gorm.DB, query FileQuery) ([]File, PageInfo, error) {
+	if query.Page.Sort == "" {
+		query.Page.Sort = "name"
+	}
+
+	tx := db.Model(&File{}).Where("folder_id IN ?", query.FolderIDs).
+		Where(db.Where("is_published = ?", true).
+			Or("folder_id IN ?", query.DraftFolderIDs).
+			Or("last_editor_id = ?", query.DraftEditorID))
+
+	if query.IsPublished != nil {
+		tx = tx.Where("is_published = ?", *query.IsPublished)
+	}
+	if query.LastEditorID != 0 {
+		tx = tx.Where("last_editor_id = ?", query.LastEditorID)
+	}
+	if !query.UpdatedAfter.IsZero() {
+		tx = tx.Where("updated_at > ?", query.UpdatedAfter.Local())
+	}
+	if query.NamePrefix != "" {
+		// Names are stored escaped, so the prefix is escaped the same way before it is matched.
+		prefix := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(prepareString(query.NamePrefix))
+		tx = tx.Where(`name LIKE ? ESCAPE '\'`, prefix+"%")
+	}
+
+	files := []File{}
+	info, err := paginate(tx, query.Page, fileSortColumns, &files, func(i int) (interface{}, uint) {
+		switch query.Page.Sort {
+		case "created_at":
+			return files[i].CreatedAt, files[i].ID
+		case "updated_at":
+			return files[i].UpdatedAt, files[i].ID
+		}
+		return files[i].Name, files[i].ID
+	})
+	if err != nil {
+		return nil, PageInfo{}, err
+	}
+	return files, info, nil
+}
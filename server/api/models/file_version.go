@@ -0,0 +1,294 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ErrRequiredFileVersionNumber returned when no FileVersion.Number is specified.
+var ErrRequiredFileVersionNumber = errors.New("required file version number")
+
+// ErrRequiredUploaderID returned when no FileVersion.UploaderID is specified.
+var ErrRequiredUploaderID = errors.New("required uploader id")
+
+// ErrFileVersionNotFound returned when no FileVersion matches the given criteria.
+var ErrFileVersionNotFound = errors.New("file version not found")
+
+// ErrFileVersionAlreadyExists returned when a FileVersion with the given information already exists.
+var ErrFileVersionAlreadyExists = errors.New("file version already exists")
+
+// ErrInvalidScanStatus returned when an invalid ScanStatus is specified.
+var ErrInvalidScanStatus = errors.New("invalid scan status")
+
+// ErrScanPending returned when file data is requested before it has been scanned for malware.
+var ErrScanPending = errors.New("file data hasn't been scanned yet")
+
+// ErrFileInfected returned when file data is requested that has been found to contain malware.
+var ErrFileInfected = errors.New("file data is infected")
+
+// ScanStatus represents the outcome of scanning a FileVersion's data for malware.
+type ScanStatus string
+
+const (
+	// ScanPending represents data that hasn't been scanned yet, or couldn't be scanned.
+	ScanPending ScanStatus = "pending"
+	// ScanClean represents data in which no malware was found.
+	ScanClean ScanStatus = "clean"
+	// ScanInfected represents data in which malware was found, which is quarantined.
+	ScanInfected ScanStatus = "infected"
+)
+
+// FileVersion represents a single upload of a File's data.
+// Versions are immutable - each upload creates a new FileVersion with the next FileVersion.Number.
+// The data itself is the Blob whose Blob.Checksum is the FileVersion.Checksum.
+// FileVersion.ContentType is the MIME type detected when the data was uploaded, and is empty if it is unknown.
+// FileVersion.ScanStatus is the outcome of scanning the data for malware - the data can only be downloaded once it is clean.
+// The FileVersion with the highest FileVersion.Number is the current data of the File.
+// Version numbers are unique per FileID and start at 1.
+type FileVersion struct {
+	ID          uint       `gorm:"primaryKey" json:"id"`
+	FileID      uint       `gorm:"not null;uniqueIndex:idx_file_version_number" json:"file_id"`
+	Number      uint       `gorm:"not null;uniqueIndex:idx_file_version_number" json:"number"`
+	Size        int64      `gorm:"not null" json:"size"`
+	Checksum    string     `gorm:"not null" json:"checksum"`
+	ContentType string     `gorm:"not null;default:''" json:"content_type"`
+	ScanStatus  ScanStatus `gorm:"not null;default:pending" json:"scan_status"`
+	UploaderID  uint       `gorm:"not null" json:"uploader_id"`
+	Uploader    User       `gorm:"foreignKey:UploaderID" json:"-"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+// CreateFileVersion creates a FileVersion.
+// The FileVersion.Number must not already be used by another FileVersion of the same File.
+// The FileVersion.ScanStatus defaults to ScanPending.
+// The File.Size, File.Checksum, File.ContentType and File.ScanStatus of the File are updated to match the new FileVersion,
+// and a reference is added to the Blob holding its data.
+func CreateFileVersion(db *gorm.DB, version FileVersion) (FileVersion, error) {
+	if version.FileID == 0 {
+		return FileVersion{}, ErrRequiredFileID
+	}
+	if version.Number == 0 {
+		return FileVersion{}, ErrRequiredFileVersionNumber
+	}
+	if version.UploaderID == 0 {
+		return FileVersion{}, ErrRequiredUploaderID
+	}
+	if version.Checksum == "" {
+		return FileVersion{}, ErrRequiredChecksum
+	}
+	if version.ScanStatus == "" {
+		version.ScanStatus = ScanPending
+	} else if !version.ScanStatus.isValid() {
+		return FileVersion{}, ErrInvalidScanStatus
+	}
+
+	// Check the file referenced by the version exists.
+	_, err := GetFileByID(db, version.FileID)
+	if err != nil {
+		return FileVersion{}, err
+	}
+
+	// Make sure versions are never overwritten.
+	_, err = GetFileVersion(db, version.FileID, version.Number)
+	if err == nil {
+		return FileVersion{}, ErrFileVersionAlreadyExists
+	} else if err != ErrFileVersionNotFound {
+		return FileVersion{}, err
+	}
+
+	return createFileVersion(db, version)
+}
+
+// CreateInitialFileVersion creates the first FileVersion of a File from data stored before versioning was introduced.
+// The File.LastEditorID is recorded as the uploader, and the data is left to be scanned.
+// Deleted Files are included, so their data is kept if they are restored from the trash.
+func CreateInitialFileVersion(db *gorm.DB, fileID uint, size int64, checksum string) (FileVersion, error) {
+	if fileID == 0 {
+		return FileVersion{}, ErrRequiredFileID
+	}
+	if checksum == "" {
+		return FileVersion{}, ErrRequiredChecksum
+	}
+
+	file := File{}
+	err := db.Unscoped().Take(&file, fileID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return FileVersion{}, ErrFileNotFound
+	} else if err != nil {
+		return FileVersion{}, err
+	}
+
+	_, err = GetLatestFileVersion(db, fileID)
+	if err == nil {
+		return FileVersion{}, ErrFileVersionAlreadyExists
+	} else if err != ErrFileVersionNotFound {
+		return FileVersion{}, err
+	}
+
+	return createFileVersion(db, FileVersion{
+		FileID:     fileID,
+		Number:     1,
+		Size:       size,
+		Checksum:   checksum,
+		ScanStatus: ScanPending,
+		UploaderID: file.LastEditorID,
+	})
+}
+
+// createFileVersion creates a FileVersion, updates its File and references its Blob in a single transaction.
+func createFileVersion(db *gorm.DB, version FileVersion) (FileVersion, error) {
+	version.ID = 0
+	err := db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Create(&version).Take(&version).Error
+		if err != nil {
+			return err
+		}
+
+		_, err = AddBlobReference(tx, version.Checksum, version.Size)
+		if err != nil {
+			return err
+		}
+
+		return tx.Unscoped().Model(&File{Model: Model{ID: version.FileID}}).Updates(map[string]interface{}{
+			"size":         version.Size,
+			"checksum":     version.Checksum,
+			"content_type": version.ContentType,
+			"scan_status":  version.ScanStatus,
+		}).Error
+	})
+	if err != nil {
+		return FileVersion{}, err
+	}
+	return version, nil
+}
+
+// isValid returns whether the ScanStatus is one of the defined statuses.
+func (status ScanStatus) isValid() bool {
+	return status == ScanPending || status == ScanClean || status == ScanInfected
+}
+
+// GetPendingFileVersions returns every FileVersion whose data hasn't been scanned yet, oldest first.
+func GetPendingFileVersions(db *gorm.DB) ([]FileVersion, error) {
+	versions := []FileVersion{}
+	err := db.Where(&FileVersion{ScanStatus: ScanPending}).Order("id").Find(&versions).Error
+	return versions, err
+}
+
+// UpdateFileVersionScanStatus records the outcome of scanning a FileVersion's data.
+// If the FileVersion is the latest of its File, the File.ScanStatus is updated as well.
+func UpdateFileVersionScanStatus(db *gorm.DB, versionID uint, status ScanStatus) (FileVersion, error) {
+	if !status.isValid() {
+		return FileVersion{}, ErrInvalidScanStatus
+	}
+
+	version := FileVersion{}
+	err := db.Take(&version, versionID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return FileVersion{}, ErrFileVersionNotFound
+	} else if err != nil {
+		return FileVersion{}, err
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&version).Update("scan_status", status).Error
+		if err != nil {
+			return err
+		}
+
+		latest, err := GetLatestFileVersion(tx, version.FileID)
+		if err != nil || latest.ID != version.ID {
+			return err
+		}
+		return tx.Unscoped().Model(&File{Model: Model{ID: version.FileID}}).Update("scan_status", status).Error
+	})
+	if err != nil {
+		return FileVersion{}, err
+	}
+	version.ScanStatus = status
+	return version, nil
+}
+
+// GetFileVersions returns all the FileVersion of a File, ordered by FileVersion.Number.
+func GetFileVersions(db *gorm.DB, fileID uint) ([]FileVersion, error) {
+	if fileID == 0 {
+		return nil, ErrRequiredFileID
+	}
+
+	versions := []FileVersion{}
+	err := db.Where(&FileVersion{FileID: fileID}).Order("number").Find(&versions).Error
+	return versions, err
+}
+
+// GetAllFileVersions returns the FileVersion of every File, ordered by FileVersion.FileID and FileVersion.Number.
+func GetAllFileVersions(db *gorm.DB) ([]FileVersion, error) {
+	versions := []FileVersion{}
+	err := db.Order("file_id").Order("number").Find(&versions).Error
+	return versions, err
+}
+
+// GetFileVersion gets a FileVersion by its FileVersion.FileID and FileVersion.Number.
+func GetFileVersion(db *gorm.DB, fileID, number uint) (FileVersion, error) {
+	if fileID == 0 {
+		return FileVersion{}, ErrRequiredFileID
+	}
+	if number == 0 {
+		return FileVersion{}, ErrRequiredFileVersionNumber
+	}
+
+	version := FileVersion{
+		FileID: fileID,
+		Number: number,
+	}
+
+	err := db.Where(&version).Take(&version).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return FileVersion{}, ErrFileVersionNotFound
+	}
+	return version, err
+}
+
+// GetLatestFileVersion gets the FileVersion with the highest FileVersion.Number of a File.
+// Returns ErrFileVersionNotFound if no data has been uploaded for the File.
+func GetLatestFileVersion(db *gorm.DB, fileID uint) (FileVersion, error) {
+	if fileID == 0 {
+		return FileVersion{}, ErrRequiredFileID
+	}
+
+	version := FileVersion{}
+	err := db.Where(&FileVersion{FileID: fileID}).Order("number desc").Take(&version).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return FileVersion{}, ErrFileVersionNotFound
+	}
+	return version, err
+}
+
+// GetLatestFileVersions gets the latest FileVersion of each of the given Files at once, keyed by FileVersion.FileID.
+// Files no data has been uploaded for are left out.
+func GetLatestFileVersions(db *gorm.DB, fileIDs []uint) (map[uint]FileVersion, error) {
+	var versions []FileVersion
+	err := db.Where("file_id IN ?", fileIDs).
+		Where("number = (SELECT MAX(latest.number) FROM file_versions AS latest WHERE latest.file_id = file_versions.file_id)").
+		Find(&versions).Error
+	if err != nil {
+		return nil, err
+	}
+
+	latestVersions := make(map[uint]FileVersion, len(versions))
+	for _, version := range versions {
+		latestVersions[version.FileID] = version
+	}
+	return latestVersions, nil
+}
+
+// GetNextFileVersionNumber returns the FileVersion.Number the next upload of a File should use.
+func GetNextFileVersionNumber(db *gorm.DB, fileID uint) (uint, error) {
+	version, err := GetLatestFileVersion(db, fileID)
+	if err == ErrFileVersionNotFound {
+		return 1, nil
+	} else if err != nil {
+		return 0, err
+	}
+	return version.Number + 1, nil
+}
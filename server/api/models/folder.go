@@ -2,6 +2,7 @@ package models
 
 import (
 	"errors"
+	"time"
 
 	"gorm.io/gorm"
 )
@@ -32,17 +33,18 @@ var ErrInvalidParentFolderID = errors.New("invalid parent folder id")
 
 // Folder represents a single Folder in the document system.
 // Is merely a representation - a File is not stored by its true path when saved.
-// The folder path is reconstructed by the browser client.
+// The folder path can be found with GetFolderPath, and a Folder or File can be found by its path with ResolvePath.
 // Folder names are unique per ParentFolderID.
 type Folder struct {
 	Model
-	Name           string       `gorm:"not null" json:"name"`
-	ParentFolderID *uint        `gorm:"not_null" json:"parent_folder_id"`
-	ChildFolders   []Folder     `gorm:"foreignKey:ParentFolderID" json:"child_folders"`
-	Files          []File       `gorm:"foreignKey:FolderID" json:"files"`
-	LastEditorID   uint         `gorm:"not null" json:"last_editor_id"`
-	LastEditor     User         `gorm:"foreignKey:LastEditorID" json:"last_editor"`
-	AccessRoles    []AccessRole `gorm:"foreignKey:FolderID" json:"access_roles"`
+	Name             string       `gorm:"not null" json:"name"`
+	ParentFolderID   *uint        `gorm:"not_null" json:"parent_folder_id"`
+	ChildFolders     []Folder     `gorm:"foreignKey:ParentFolderID" json:"child_folders"`
+	Files            []File       `gorm:"foreignKey:FolderID" json:"files"`
+	LastEditorID     uint         `gorm:"not null" json:"last_editor_id"`
+	LastEditor       User         `gorm:"foreignKey:LastEditorID" json:"last_editor"`
+	AccessRoles      []AccessRole `gorm:"foreignKey:FolderID" json:"access_roles"`
+	BreakInheritance bool         `gorm:"not null;default:false" json:"break_inheritance"`
 }
 
 // prepare escapes Folder.Name before processing.
@@ -86,7 +88,9 @@ func CreateFolder(db *gorm.DB, folder Folder) (Folder, error) {
 		return Folder{}, err
 	}
 
+	// Inheritance can only be broken by SetFolderInheritance.
 	folder.ID = 0
+	folder.BreakInheritance = false
 	err = db.Create(&folder).Take(&folder).Error
 	folder.formatFolderContents()
 	return folder, err
@@ -113,6 +117,22 @@ func getFolderByIDRaw(db *gorm.DB, folderID uint) (Folder, error) {
 
 // GetFolderByID adds a wrapper around getFolderByIDRaw.
 func GetFolderByID(db *gorm.DB, folderID uint) (Folder, error) {
+	folder, err := GetFolderWithChildren(db, folderID)
+	if err != nil {
+		return Folder{}, err
+	}
+
+	err = db.Model(&folder).Association("Files").Find(&folder.Files)
+	if err != nil {
+		return Folder{}, err
+	}
+
+	return folder, err
+}
+
+// GetFolderWithChildren gets a Folder along with its AccessRoles and ChildFolders, but without its Files.
+// The Files of large Folders can be loaded a page at a time with QueryFiles instead.
+func GetFolderWithChildren(db *gorm.DB, folderID uint) (Folder, error) {
 	if folderID == 0 {
 		return Folder{}, ErrRequiredFolderID
 	}
@@ -132,12 +152,15 @@ func GetFolderByID(db *gorm.DB, folderID uint) (Folder, error) {
 		return Folder{}, err
 	}
 
-	err = db.Model(&folder).Association("Files").Find(&folder.Files)
-	if err != nil {
-		return Folder{}, err
-	}
+	return folder, nil
+}
 
-	return folder, err
+// GetFolders gets the Folders with the given Model.IDs at once, without their AccessRoles, ChildFolders or Files.
+// Folders that don't exist are left out.
+func GetFolders(db *gorm.DB, folderIDs []uint) ([]Folder, error) {
+	folders := []Folder{}
+	err := db.Where("id IN ?", folderIDs).Find(&folders).Error
+	return folders, err
 }
 
 // GetFolderByPath gets a Folder by its Folder.Name and Folder.ParentFolderID.
@@ -149,6 +172,7 @@ func GetFolderByPath(db *gorm.DB, folder Folder) (Folder, error) {
 
 	folder.ID = 0
 	folder.LastEditorID = 0
+	folder.BreakInheritance = false
 	err := db.Where(&folder).Take(&folder).Error
 	if errors.Is(err, gorm.ErrRecordNotFound) {
 		return Folder{}, ErrFolderNotFound
@@ -195,6 +219,8 @@ func UpdateFolder(db *gorm.DB, folder Folder) (Folder, error) {
 	if folder.Name == "" {
 		folder.Name = oldFolder.Name
 	}
+	// Inheritance can only be broken by SetFolderInheritance.
+	folder.BreakInheritance = oldFolder.BreakInheritance
 
 	// Check that no existing Folder with the same name in the parent folder.
 	existingFolder, err := GetFolderByPath(db, folder)
@@ -211,6 +237,20 @@ func UpdateFolder(db *gorm.DB, folder Folder) (Folder, error) {
 	return folder, err
 }
 
+// SetFolderInheritance sets whether a Folder breaks inheritance,
+// ignoring the inheritable AccessRoles of its ancestors when breakInheritance is true.
+func SetFolderInheritance(db *gorm.DB, folderID uint, breakInheritance bool) (Folder, error) {
+	folder, err := getFolderByIDRaw(db, folderID)
+	if err != nil {
+		return Folder{}, err
+	}
+	err = db.Model(&folder).Update("break_inheritance", breakInheritance).Error
+	if err != nil {
+		return Folder{}, err
+	}
+	return GetFolderByID(db, folderID)
+}
+
 // DeleteFolder deletes a Folder by its Model.ID.
 func DeleteFolder(db *gorm.DB, folderID, userID uint) error {
 	folder, err := GetFolderByID(db, folderID)
@@ -226,42 +266,127 @@ func DeleteFolder(db *gorm.DB, folderID, userID uint) error {
 	return db.Select("AccessRoles").Delete(&folder).Error
 }
 
-// GetUserAuthorizationFolder gets a User's AccessLevel in a Folder.
-func GetUserAuthorizationFolder(db *gorm.DB, user User, folderID uint) (Folder, AccessLevel, error) {
-	// Get the folder
-	folder, err := GetFolderByID(db, folderID)
+// FolderSubtree represents a Folder along with all the Folders and Files nested inside it.
+// FolderSubtree.FolderIDs starts with the Folder itself, followed by its descendants level by level.
+type FolderSubtree struct {
+	FolderIDs       []uint `json:"folder_ids"`
+	FileIDs         []uint `json:"file_ids"`
+	AccessRoleIDs   []uint `json:"access_role_ids"`
+	FolderCount     int    `json:"folder_count"`
+	FileCount       int    `json:"file_count"`
+	AccessRoleCount int    `json:"access_role_count"`
+}
+
+// GetFolderSubtree gets the Model.ID of a Folder and all the Folders, Files and AccessRoles nested inside it.
+func GetFolderSubtree(db *gorm.DB, folderID uint) (FolderSubtree, error) {
+	if folderID == 0 {
+		return FolderSubtree{}, ErrRequiredFolderID
+	}
+
+	_, err := getFolderByIDRaw(db, folderID)
 	if err != nil {
-		return Folder{}, Unset, err
+		return FolderSubtree{}, err
+	}
+
+	subtree := FolderSubtree{
+		FolderIDs:     []uint{folderID},
+		FileIDs:       []uint{},
+		AccessRoleIDs: []uint{},
 	}
 
-	// Get all the user's access roles from the database
-	var accessRoles []AccessRole
-	err = db.Model(&user.UserRoles).Association("AccessRoles").Find(&accessRoles)
+	// Walk down the tree one level at a time, so each level only takes a single query.
+	level := []uint{folderID}
+	for len(level) > 0 {
+		var childIDs []uint
+		err = db.Model(&Folder{}).Where("parent_folder_id IN ?", level).Pluck("id", &childIDs).Error
+		if err != nil {
+			return FolderSubtree{}, err
+		}
+		subtree.FolderIDs = append(subtree.FolderIDs, childIDs...)
+		level = childIDs
+	}
+
+	err = db.Model(&File{}).Where("folder_id IN ?", subtree.FolderIDs).Pluck("id", &subtree.FileIDs).Error
 	if err != nil {
-		return Folder{}, Unset, err
+		return FolderSubtree{}, err
+	}
+
+	err = db.Model(&AccessRole{}).Where("folder_id IN ?", subtree.FolderIDs).Pluck("id", &subtree.AccessRoleIDs).Error
+	if err != nil {
+		return FolderSubtree{}, err
 	}
 
-	// Default access level is none
-	accessLevel := None
-	// Admins need viewer permissions to all folders by default
-	// This allows them to grant roles to other users
-	if user.IsAdmin {
-		accessLevel = Viewer
+	subtree.FolderCount = len(subtree.FolderIDs)
+	subtree.FileCount = len(subtree.FileIDs)
+	subtree.AccessRoleCount = len(subtree.AccessRoleIDs)
+	return subtree, nil
+}
+
+// GetAllFolderIDs returns the Model.ID of every Folder that hasn't been deleted.
+func GetAllFolderIDs(db *gorm.DB) ([]uint, error) {
+	var folderIDs []uint
+	err := db.Model(&Folder{}).Order("id").Pluck("id", &folderIDs).Error
+	return folderIDs, err
+}
+
+// DeleteFolderRecursive deletes a Folder along with all the Folders, Files and AccessRoles nested inside it.
+// Everything is deleted in a single transaction, so either the whole subtree is deleted or nothing is.
+// Folders and Files are soft deleted and can be restored from the trash, but the AccessRoles are removed.
+// Every Folder and File is given the same deletion time, so restoring the Folder restores everything deleted with it.
+func DeleteFolderRecursive(db *gorm.DB, folderID, userID uint) (FolderSubtree, error) {
+	if userID == 0 {
+		return FolderSubtree{}, ErrRequiredLastEditorID
 	}
 
-	// Loop through all the user's access roles
-	// The user can have multiple user roles with different access roles in the same folder
-	// Therefore, we take the one granting the most privileges
-	for _, accessRole := range accessRoles {
-		if accessRole.FolderID == folderID && accessRole.AccessLevel > accessLevel {
-			accessLevel = accessRole.AccessLevel
+	var subtree FolderSubtree
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		subtree, err = GetFolderSubtree(tx, folderID)
+		if err != nil {
+			return err
+		}
 
-			// No point continuing loop if highest access role is achieved
-			if accessLevel == Publisher {
-				break
+		// The deleting user is recorded as the last editor, so they are able to restore the items.
+		deleted := map[string]interface{}{
+			"last_editor_id": userID,
+			"deleted_at":     time.Now(),
+		}
+		if subtree.FileCount > 0 {
+			err = tx.Model(&File{}).Where("id IN ?", subtree.FileIDs).Updates(deleted).Error
+			if err != nil {
+				return err
 			}
 		}
+
+		if subtree.AccessRoleCount > 0 {
+			err = tx.Delete(&AccessRole{}, subtree.AccessRoleIDs).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		return tx.Model(&Folder{}).Where("id IN ?", subtree.FolderIDs).Updates(deleted).Error
+	})
+	if err != nil {
+		return FolderSubtree{}, err
+	}
+	return subtree, nil
+}
+
+// GetUserAuthorizationFolder gets a User's AccessLevel in a Folder.
+// The Folder is loaded without its Files, which can be loaded a page at a time with QueryFiles.
+// GetUserAccessDecision also explains how the AccessLevel was decided.
+func GetUserAuthorizationFolder(db *gorm.DB, user User, folderID uint) (Folder, AccessLevel, error) {
+	// Get the folder
+	folder, err := GetFolderWithChildren(db, folderID)
+	if err != nil {
+		return Folder{}, Unset, err
+	}
+
+	accessLevels, err := GetUserAuthorizationFolders(db, user, []uint{folderID})
+	if err != nil {
+		return Folder{}, Unset, err
 	}
 
-	return folder, accessLevel, nil
+	return folder, accessLevels[folderID], nil
 }
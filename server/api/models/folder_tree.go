@@ -0,0 +1,60 @@
+package models
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+// MaxFolderTreeDepth is the largest number of levels below a Folder that GetFolderTree can get.
+const MaxFolderTreeDepth = 10
+
+// ErrInvalidDepth returned when a depth that is negative or larger than MaxFolderTreeDepth is specified.
+var ErrInvalidDepth = errors.New("invalid depth")
+
+// FolderTreeNode is a Folder in a tree of Folders, along with the number of Files in it.
+// FolderTreeNode.Depth is the number of levels the Folder is below the top of the tree.
+// FolderTreeNode.EditorDraftFileCount counts the drafts last edited by the editor given to GetFolderTree.
+type FolderTreeNode struct {
+	ID                   uint
+	Name                 string
+	ParentFolderID       uint
+	Depth                int
+	PublishedFileCount   int64
+	DraftFileCount       int64
+	EditorDraftFileCount int64
+}
+
+// GetFolderTree gets a Folder and the Folders nested inside it, down to depth levels below it, in a single query.
+// The Folders are ordered level by level, so every Folder comes after its parent, and are sorted by name in each level.
+func GetFolderTree(db *gorm.DB, folderID uint, depth int, editorID uint) ([]FolderTreeNode, error) {
+	if folderID == 0 {
+		return nil, ErrRequiredFolderID
+	}
+	if depth < 0 || depth > MaxFolderTreeDepth {
+		return nil, ErrInvalidDepth
+	}
+
+	nodes := []FolderTreeNode{}
+	err := db.Raw(`WITH RECURSIVE tree(id, depth) AS (
+			SELECT id, 0 FROM folders WHERE id = ? AND deleted_at IS NULL
+			UNION ALL
+			SELECT folders.id, tree.depth + 1 FROM folders JOIN tree ON folders.parent_folder_id = tree.id
+			WHERE tree.depth < ? AND folders.deleted_at IS NULL
+		)
+		SELECT folders.id, folders.name, COALESCE(folders.parent_folder_id, 0) AS parent_folder_id, tree.depth,
+			COALESCE(SUM(files.is_published = 1), 0) AS published_file_count,
+			COALESCE(SUM(files.is_published = 0), 0) AS draft_file_count,
+			COALESCE(SUM(files.is_published = 0 AND files.last_editor_id = ?), 0) AS editor_draft_file_count
+		FROM tree JOIN folders ON folders.id = tree.id
+		LEFT JOIN files ON files.folder_id = tree.id AND files.deleted_at IS NULL
+		GROUP BY tree.id
+		ORDER BY tree.depth, folders.name, folders.id`, folderID, depth, editorID).Scan(&nodes).Error
+	if err != nil {
+		return nil, err
+	}
+	if len(nodes) == 0 {
+		return nil, ErrFolderNotFound
+	}
+	return nodes, nil
+}
@@ -0,0 +1,166 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ErrInvalidGrantWindow returned when a GrantWindow ends before it starts.
+var ErrInvalidGrantWindow = errors.New("invalid grant window")
+
+// GrantWindow limits when an AccessRole or the assignment of a UserRole to a User grants access.
+// A grant applies from GrantWindow.StartsAt until GrantWindow.ExpiresAt, and either can be left out for no limit.
+// Expired grants are removed by ExpireGrants, which records them in the GrantAuditEntry trail.
+type GrantWindow struct {
+	StartsAt  *time.Time `json:"starts_at"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// prepare converts the bounds of a GrantWindow to local time before they are stored.
+// SQLite compares times as text, so they are only compared correctly in the same time zone.
+func (window *GrantWindow) prepare() {
+	if window.StartsAt != nil {
+		startsAt := window.StartsAt.Local()
+		window.StartsAt = &startsAt
+	}
+	if window.ExpiresAt != nil {
+		expiresAt := window.ExpiresAt.Local()
+		window.ExpiresAt = &expiresAt
+	}
+}
+
+// validate checks a GrantWindow doesn't end before it starts.
+func (window GrantWindow) validate() error {
+	if window.StartsAt != nil && window.ExpiresAt != nil && !window.ExpiresAt.After(*window.StartsAt) {
+		return ErrInvalidGrantWindow
+	}
+	return nil
+}
+
+// IsActive returns whether a grant with the GrantWindow applies at the given time.
+func (window GrantWindow) IsActive(now time.Time) bool {
+	return (window.StartsAt == nil || !window.StartsAt.After(now)) &&
+		(window.ExpiresAt == nil || window.ExpiresAt.After(now))
+}
+
+// activeGrantWindow is the condition selecting the rows of a table with an active GrantWindow at a time.
+// The time must be in local time, like the stored GrantWindows.
+func activeGrantWindow(table string) string {
+	return "(" + table + ".starts_at IS NULL OR " + table + ".starts_at <= @now) AND (" +
+		table + ".expires_at IS NULL OR " + table + ".expires_at > @now)"
+}
+
+// AssignedUserRole represents the assignment of a UserRole to a User, which can be limited to a GrantWindow.
+type AssignedUserRole struct {
+	UserID     uint `gorm:"primaryKey" json:"user_id"`
+	UserRoleID uint `gorm:"primaryKey" json:"user_role_id"`
+	GrantWindow
+}
+
+// SetupJoinTables sets up the join tables with extra information, and must be called before migrating the tables.
+func SetupJoinTables(db *gorm.DB) error {
+	return db.SetupJoinTable(&User{}, "UserRoles", &AssignedUserRole{})
+}
+
+// GrantEvent represents what happened to a grant recorded in a GrantAuditEntry.
+type GrantEvent string
+
+// GrantExpired represents a grant removed by ExpireGrants after its GrantWindow.ExpiresAt.
+const GrantExpired GrantEvent = "expired"
+
+// GrantAuditEntry records an event that happened to an AccessRole or the assignment of a UserRole to a User.
+// GrantAuditEntry.AccessRoleID is 0 for assignments, and GrantAuditEntry.FolderID and GrantAuditEntry.AccessLevel
+// are only set for AccessRoles.
+type GrantAuditEntry struct {
+	ID           uint        `gorm:"primaryKey" json:"id"`
+	CreatedAt    time.Time   `json:"created_at"`
+	Event        GrantEvent  `gorm:"not null" json:"event"`
+	AccessRoleID uint        `json:"access_role_id"`
+	FolderID     uint        `json:"folder_id"`
+	UserRoleID   uint        `json:"user_role_id"`
+	UserID       uint        `json:"user_id"`
+	AccessLevel  AccessLevel `json:"access_level"`
+	GrantWindow
+}
+
+// grantAuditSortColumns lists the columns GrantAuditEntries can be sorted by, besides their ID.
+var grantAuditSortColumns = sortColumns{
+	"created_at": true,
+}
+
+// GetGrantAuditPage returns a Page of the GrantAuditEntries.
+// The GrantAuditEntries can be sorted by created_at, and are sorted by GrantAuditEntry.ID by default.
+func GetGrantAuditPage(db *gorm.DB, page Page) ([]GrantAuditEntry, PageInfo, error) {
+	entries := []GrantAuditEntry{}
+	info, err := paginate(db.Model(&GrantAuditEntry{}), page, grantAuditSortColumns, &entries,
+		func(i int) (interface{}, uint) {
+			return entries[i].CreatedAt, entries[i].ID
+		})
+	if err != nil {
+		return nil, PageInfo{}, err
+	}
+	return entries, info, nil
+}
+
+// ExpireGrants removes the AccessRoles and assignments of UserRoles to Users that expired at or before the given time,
+// recording each of them in the GrantAuditEntry trail in a single transaction.
+// Returns the GrantAuditEntries recorded.
+func ExpireGrants(db *gorm.DB, now time.Time) ([]GrantAuditEntry, error) {
+	// GrantWindows are stored in local time, so they are only compared correctly in the same time zone.
+	now = now.Local()
+	entries := []GrantAuditEntry{}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var accessRoles []AccessRole
+		err := tx.Where("expires_at <= ?", now).Order("id").Find(&accessRoles).Error
+		if err != nil {
+			return err
+		}
+		for _, accessRole := range accessRoles {
+			entries = append(entries, GrantAuditEntry{
+				Event:        GrantExpired,
+				AccessRoleID: accessRole.ID,
+				FolderID:     accessRole.FolderID,
+				UserRoleID:   accessRole.UserRoleID,
+				UserID:       accessRole.UserID,
+				AccessLevel:  accessRole.AccessLevel,
+				GrantWindow:  accessRole.GrantWindow,
+			})
+		}
+		if len(accessRoles) > 0 {
+			err = tx.Delete(&accessRoles).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		var assignments []AssignedUserRole
+		err = tx.Where("expires_at <= ?", now).Order("user_id, user_role_id").Find(&assignments).Error
+		if err != nil {
+			return err
+		}
+		for _, assignment := range assignments {
+			entries = append(entries, GrantAuditEntry{
+				Event:       GrantExpired,
+				UserRoleID:  assignment.UserRoleID,
+				UserID:      assignment.UserID,
+				GrantWindow: assignment.GrantWindow,
+			})
+			err = tx.Where("user_id = ? AND user_role_id = ?", assignment.UserID, assignment.UserRoleID).
+				Delete(&AssignedUserRole{}).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		if len(entries) == 0 {
+			return nil
+		}
+		return tx.Create(&entries).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
@@ -0,0 +1,151 @@
+package models
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ErrInvalidSort returned when a Page.Sort that the rows can't be sorted by is specified.
+var ErrInvalidSort = errors.New("invalid sort")
+
+// ErrInvalidCursor returned when a cursor that wasn't returned by a previous query with the same sort order is specified.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// ErrInvalidLimit returned when a negative limit is specified.
+var ErrInvalidLimit = errors.New("invalid limit")
+
+// Page selects a page of at most Page.Limit rows sorted by the Page.Sort column, with 0 meaning no limit.
+// Ties in the sort column are broken by Model.ID, so every row has a unique position in the results.
+// Page.Cursor continues from the position after the last row of a previous page.
+type Page struct {
+	Sort       string
+	Descending bool
+	Cursor     string
+	Limit      int
+}
+
+// PageInfo describes the results of a query returning a Page.
+// PageInfo.Total counts the rows matching the query across all pages.
+// PageInfo.NextCursor is the Page.Cursor of the next page, which is empty if there are no more rows.
+type PageInfo struct {
+	Total      int64
+	NextCursor string
+}
+
+// sortColumns lists the columns rows can be sorted by, and whether each of them holds times.
+type sortColumns map[string]bool
+
+// pageCursor is the position of a row in the results of a query, encoded in Page.Cursor.
+// The sort key of the row is kept as the string sort keys are compared with, or as RFC 3339 for times.
+type pageCursor struct {
+	Sort       string `json:"s"`
+	Descending bool   `json:"d"`
+	Key        string `json:"k"`
+	ID         uint   `json:"i"`
+}
+
+// paginate counts the rows matching a query and loads a Page of them into rows, which points to a slice.
+// The key function returns the sort key and Model.ID of a loaded row, and is used to make the cursor of the next page.
+// The Page.Sort defaults to the Model.ID.
+func paginate(tx *gorm.DB, page Page, columns sortColumns, rows interface{},
+	key func(i int) (interface{}, uint)) (PageInfo, error) {
+	if page.Sort == "" {
+		page.Sort = "id"
+	}
+	isTime, ok := columns[page.Sort]
+	if !ok && page.Sort != "id" {
+		return PageInfo{}, ErrInvalidSort
+	}
+	if page.Limit < 0 {
+		return PageInfo{}, ErrInvalidLimit
+	}
+
+	info := PageInfo{}
+	err := tx.Session(&gorm.Session{}).Count(&info.Total).Error
+	if err != nil {
+		return PageInfo{}, err
+	}
+
+	direction, comparison := "ASC", ">"
+	if page.Descending {
+		direction, comparison = "DESC", "<"
+	}
+	if page.Cursor != "" {
+		cursor, err := decodePageCursor(page)
+		if err != nil {
+			return PageInfo{}, err
+		}
+
+		if page.Sort == "id" {
+			tx = tx.Where("id "+comparison+" ?", cursor.ID)
+		} else {
+			var key interface{} = cursor.Key
+			if isTime {
+				t, err := time.Parse(time.RFC3339Nano, cursor.Key)
+				if err != nil {
+					return PageInfo{}, ErrInvalidCursor
+				}
+				// Times are stored in local time, so they are only compared correctly in the same time zone.
+				key = t.Local()
+			}
+			tx = tx.Where("("+page.Sort+" "+comparison+" ? OR ("+page.Sort+" = ? AND id "+comparison+" ?))",
+				key, key, cursor.ID)
+		}
+	}
+
+	if page.Sort != "id" {
+		tx = tx.Order(page.Sort + " " + direction)
+	}
+	tx = tx.Order("id " + direction)
+	if page.Limit > 0 {
+		// One more row than requested is loaded to find out if there is a next page.
+		tx = tx.Limit(page.Limit + 1)
+	}
+	err = tx.Find(rows).Error
+	if err != nil {
+		return PageInfo{}, err
+	}
+
+	loaded := reflect.ValueOf(rows).Elem()
+	if page.Limit == 0 || loaded.Len() <= page.Limit {
+		return info, nil
+	}
+	loaded.Set(loaded.Slice(0, page.Limit))
+
+	sortKey, id := key(page.Limit - 1)
+	cursor := pageCursor{Sort: page.Sort, Descending: page.Descending, ID: id}
+	switch sortKey := sortKey.(type) {
+	case time.Time:
+		cursor.Key = sortKey.Format(time.RFC3339Nano)
+	case string:
+		cursor.Key = sortKey
+	}
+	info.NextCursor = encodePageCursor(cursor)
+	return info, nil
+}
+
+// encodePageCursor encodes the position of a row as an opaque string.
+func encodePageCursor(cursor pageCursor) string {
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodePageCursor decodes the Page.Cursor encoded by encodePageCursor, verifying it was made for the same sort order.
+func decodePageCursor(page Page) (pageCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(page.Cursor)
+	if err != nil {
+		return pageCursor{}, ErrInvalidCursor
+	}
+
+	var cursor pageCursor
+	err = json.Unmarshal(data, &cursor)
+	if err != nil || cursor.Sort != page.Sort || cursor.Descending != page.Descending || cursor.ID == 0 {
+		return pageCursor{}, ErrInvalidCursor
+	}
+	return cursor, nil
+}
@@ -0,0 +1,111 @@
+package models
+
+import (
+	"errors"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// MaxPathSegments is the largest number of names a path resolved by ResolvePath can have.
+const MaxPathSegments = 100
+
+// ErrInvalidPath returned when a path that isn't absolute, has empty names or has too many names is specified.
+var ErrInvalidPath = errors.New("invalid path")
+
+// ErrPathNotFound returned when no Folder or File can be found at a path.
+var ErrPathNotFound = errors.New("path not found")
+
+// ResolvedPath is the Folder or File found at a path.
+// ResolvedPath.ID is the Model.ID of the Folder or File, depending on ResolvedPath.IsFile.
+// ResolvedPath.FolderID is the Folder containing the File or the parent of the Folder.
+type ResolvedPath struct {
+	ID       uint
+	FolderID uint
+	IsFile   bool
+}
+
+// GetFolderPath gets a Folder and its ancestors, starting from the root Folder and ending with the Folder itself.
+func GetFolderPath(db *gorm.DB, folderID uint) ([]Folder, error) {
+	if folderID == 0 {
+		return nil, ErrRequiredFolderID
+	}
+
+	folders := []Folder{}
+	err := db.Raw(`WITH RECURSIVE ancestors(id, parent_folder_id, depth) AS (
+			SELECT id, parent_folder_id, 0 FROM folders WHERE id = ? AND deleted_at IS NULL
+			UNION ALL
+			SELECT folders.id, folders.parent_folder_id, ancestors.depth + 1 FROM folders
+			JOIN ancestors ON folders.id = ancestors.parent_folder_id
+			WHERE folders.deleted_at IS NULL
+		)
+		SELECT folders.* FROM folders JOIN ancestors ON folders.id = ancestors.id
+		ORDER BY ancestors.depth DESC`, folderID).Scan(&folders).Error
+	if err != nil {
+		return nil, err
+	}
+	if len(folders) == 0 {
+		return nil, ErrFolderNotFound
+	}
+
+	for i := range folders {
+		if folders[i].ParentFolderID == nil {
+			parentID := uint(0)
+			folders[i].ParentFolderID = &parentID
+		}
+		folders[i].formatFolderContents()
+	}
+	return folders, nil
+}
+
+// ResolvePath finds the Folder or File at a path of names separated by slashes, such as /root/HR/handbook.pdf.
+// The first name is the name of the root Folder, and the last name can be the name of a Folder or a File,
+// with Folders being found first.
+func ResolvePath(db *gorm.DB, path string) (ResolvedPath, error) {
+	if !strings.HasPrefix(path, "/") {
+		return ResolvedPath{}, ErrInvalidPath
+	}
+	names := strings.Split(strings.TrimSuffix(path[1:], "/"), "/")
+	if len(names) > MaxPathSegments {
+		return ResolvedPath{}, ErrInvalidPath
+	}
+
+	// Names are stored escaped, so they are escaped the same way before they are matched.
+	segments := make([]string, len(names))
+	values := make([]interface{}, 0, 2*len(names)+3)
+	for i, name := range names {
+		if name == "" {
+			return ResolvedPath{}, ErrInvalidPath
+		}
+		segments[i] = "(?, ?)"
+		values = append(values, i+1, prepareString(name))
+	}
+	depth := len(names)
+	values = append(values, depth, depth-1, prepareString(names[depth-1]))
+
+	resolved := ResolvedPath{}
+	result := db.Raw(`WITH RECURSIVE segments(depth, name) AS (VALUES `+strings.Join(segments, ", ")+`),
+		resolved(id, parent_folder_id, depth) AS (
+			SELECT folders.id, 0, 1 FROM folders
+			JOIN segments ON segments.depth = 1 AND folders.name = segments.name
+			WHERE COALESCE(folders.parent_folder_id, 0) = 0 AND folders.deleted_at IS NULL
+			UNION ALL
+			SELECT folders.id, folders.parent_folder_id, resolved.depth + 1 FROM resolved
+			JOIN segments ON segments.depth = resolved.depth + 1
+			JOIN folders ON folders.parent_folder_id = resolved.id AND folders.name = segments.name
+			WHERE folders.deleted_at IS NULL
+		)
+		SELECT resolved.id AS id, resolved.parent_folder_id AS folder_id, FALSE AS is_file FROM resolved
+		WHERE resolved.depth = ?
+		UNION ALL
+		SELECT files.id, files.folder_id, TRUE FROM resolved JOIN files ON files.folder_id = resolved.id
+		WHERE resolved.depth = ? AND files.name = ? AND files.deleted_at IS NULL
+		ORDER BY is_file LIMIT 1`, values...).Scan(&resolved)
+	if result.Error != nil {
+		return ResolvedPath{}, result.Error
+	}
+	if resolved.ID == 0 {
+		return ResolvedPath{}, ErrPathNotFound
+	}
+	return resolved, nil
+}
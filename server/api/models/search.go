@@ -0,0 +1,288 @@
+package models
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"gorm.io/gorm"
+)
+
+// ErrRequiredSearchQuery returned when a search query without any words is specified.
+var ErrRequiredSearchQuery = errors.New("required search query")
+
+// ErrFTS5Unavailable returned when SQLite wasn't built with the FTS5 extension, which the search index requires.
+var ErrFTS5Unavailable = errors.New("sqlite wasn't built with fts5, build with the sqlite_fts5 tag")
+
+// SearchHit represents a File or Folder matching a search query.
+// SearchHit.Snippet is the part of the name or contents that matched, with each matching word between
+// SnippetMatchStart and SnippetMatchEnd. A higher SearchHit.Score means a better match.
+type SearchHit struct {
+	FileID   uint
+	FolderID uint
+	Snippet  string
+	Score    float64
+}
+
+const (
+	// SnippetMatchStart marks the start of a matching word in a SearchHit.Snippet.
+	SnippetMatchStart = "\x01"
+	// SnippetMatchEnd marks the end of a matching word in a SearchHit.Snippet.
+	SnippetMatchEnd = "\x02"
+	// snippetEllipsis marks text left out of a SearchHit.Snippet.
+	snippetEllipsis = "…"
+	// snippetTokens is the number of words in a SearchHit.Snippet.
+	snippetTokens = 12
+)
+
+// The search index is kept in two full-text search tables, one for Files and one for Folders,
+// using the rowid of each row as the id of the File or Folder it indexes.
+// Triggers keep the names in the index in sync with the files and folders tables,
+// while the text extracted from a File's data is set with UpdateFileSearchText.
+// The checksum column records which data of the File the indexed text was extracted from.
+const (
+	fileSearchTable   = "file_search_index"
+	folderSearchTable = "folder_search_index"
+)
+
+// searchTriggers keep the names in the search index in sync with the files and folders tables.
+var searchTriggers = []string{
+	`CREATE TRIGGER IF NOT EXISTS file_search_insert AFTER INSERT ON files BEGIN
+		INSERT INTO file_search_index(rowid, checksum, name, content) VALUES (new.id, '', new.name, '');
+	END`,
+	`CREATE TRIGGER IF NOT EXISTS file_search_update AFTER UPDATE OF name ON files BEGIN
+		UPDATE file_search_index SET name = new.name WHERE rowid = new.id;
+	END`,
+	`CREATE TRIGGER IF NOT EXISTS file_search_delete AFTER DELETE ON files BEGIN
+		DELETE FROM file_search_index WHERE rowid = old.id;
+	END`,
+	`CREATE TRIGGER IF NOT EXISTS folder_search_insert AFTER INSERT ON folders BEGIN
+		INSERT INTO folder_search_index(rowid, name) VALUES (new.id, new.name);
+	END`,
+	`CREATE TRIGGER IF NOT EXISTS folder_search_update AFTER UPDATE OF name ON folders BEGIN
+		UPDATE folder_search_index SET name = new.name WHERE rowid = new.id;
+	END`,
+	`CREATE TRIGGER IF NOT EXISTS folder_search_delete AFTER DELETE ON folders BEGIN
+		DELETE FROM folder_search_index WHERE rowid = old.id;
+	END`,
+}
+
+// Column weights used to rank search hits, so matching names rank above matching contents.
+var (
+	fileSearchWeights   = []float64{0, 10, 1}
+	folderSearchWeights = []float64{10}
+)
+
+// MigrateSearchIndex creates the search index if it doesn't exist yet, indexing the names of existing Files and Folders.
+// The index uses FTS5, so SQLite must be built with it using the sqlite_fts5 build tag.
+// The text of the data of existing Files is indexed later, by finding them with GetUnindexedFiles.
+func MigrateSearchIndex(db *gorm.DB) error {
+	var fts5 bool
+	err := db.Raw("SELECT sqlite_compileoption_used('ENABLE_FTS5')").Row().Scan(&fts5)
+	if err != nil {
+		return err
+	} else if !fts5 {
+		return ErrFTS5Unavailable
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		if !tx.Migrator().HasTable(fileSearchTable) {
+			err := tx.Exec("CREATE VIRTUAL TABLE " + fileSearchTable + " USING fts5(checksum UNINDEXED, name, content)").Error
+			if err != nil {
+				return err
+			}
+			err = tx.Exec(`INSERT INTO file_search_index(rowid, checksum, name, content)
+				SELECT id, '', name, '' FROM files`).Error
+			if err != nil {
+				return err
+			}
+		}
+		if !tx.Migrator().HasTable(folderSearchTable) {
+			err := tx.Exec("CREATE VIRTUAL TABLE " + folderSearchTable + " USING fts5(name)").Error
+			if err != nil {
+				return err
+			}
+			err = tx.Exec(`INSERT INTO folder_search_index(rowid, name) SELECT id, name FROM folders`).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		for _, trigger := range searchTriggers {
+			err := tx.Exec(trigger).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// UpdateFileSearchText sets the text extracted from a File's data in the search index.
+// The checksum identifies the data the text was extracted from.
+func UpdateFileSearchText(db *gorm.DB, fileID uint, checksum, text string) error {
+	if fileID == 0 {
+		return ErrRequiredFileID
+	}
+
+	return db.Exec("UPDATE file_search_index SET checksum = ?, content = ? WHERE rowid = ?",
+		checksum, text, fileID).Error
+}
+
+// GetUnindexedFiles returns the Files whose current data is clean but hasn't had its text indexed yet, oldest first.
+func GetUnindexedFiles(db *gorm.DB) ([]File, error) {
+	files := []File{}
+	err := db.Joins("JOIN file_search_index ON file_search_index.rowid = files.id").
+		Where("files.scan_status = ? AND files.checksum != file_search_index.checksum", ScanClean).
+		Order("files.id").Find(&files).Error
+	return files, err
+}
+
+// SearchQuery converts text typed by a user into a full-text search query matching all its words.
+// Every word also matches longer words starting with it, so results can be shown while the user is typing.
+// Punctuation is ignored, so the query can't contain any search operators.
+func SearchQuery(text string) (string, error) {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	if len(words) == 0 {
+		return "", ErrRequiredSearchQuery
+	}
+
+	for i, word := range words {
+		words[i] = word + "*"
+	}
+	return strings.Join(words, " "), nil
+}
+
+// SearchIndex finds the Files and Folders whose names or contents match a query made with SearchQuery,
+// ordered from best to worst match. Deleted Files and Folders are left out, but no access checks are made.
+func SearchIndex(db *gorm.DB, query string) ([]SearchHit, error) {
+	fileHits, err := searchTable(db, fileSearchTable, "files", query, fileSearchWeights)
+	if err != nil {
+		return nil, err
+	}
+	folderHits, err := searchTable(db, folderSearchTable, "folders", query, folderSearchWeights)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range fileHits {
+		fileHits[i].FileID = fileHits[i].FolderID
+		fileHits[i].FolderID = 0
+	}
+	hits := append(fileHits, folderHits...)
+	sort.Slice(hits, func(i, j int) bool {
+		return searchHitLess(hits[i], hits[j])
+	})
+	return hits, nil
+}
+
+// searchHitLess reports whether a SearchHit comes before another in the results of SearchIndex.
+// Better matches come first, with ties broken by listing Files before Folders and then by their Model.ID,
+// so every SearchHit has a unique position in the results.
+func searchHitLess(a, b SearchHit) bool {
+	if a.Score != b.Score {
+		return a.Score > b.Score
+	}
+	if (a.FileID != 0) != (b.FileID != 0) {
+		return a.FileID != 0
+	}
+	return a.FileID+a.FolderID < b.FileID+b.FolderID
+}
+
+// PageSearchHits selects a Page of SearchHits ordered as returned by SearchIndex, such as the ones a User can see.
+// SearchHits are always ordered from best to worst match, so the Page.Sort can only be score and can't be descending.
+func PageSearchHits(hits []SearchHit, page Page) ([]SearchHit, PageInfo, error) {
+	if page.Sort == "" {
+		page.Sort = "score"
+	}
+	if page.Sort != "score" || page.Descending {
+		return nil, PageInfo{}, ErrInvalidSort
+	}
+	if page.Limit < 0 {
+		return nil, PageInfo{}, ErrInvalidLimit
+	}
+
+	info := PageInfo{Total: int64(len(hits))}
+	if page.Cursor != "" {
+		cursor, err := decodePageCursor(page)
+		if err != nil {
+			return nil, PageInfo{}, err
+		}
+
+		// The cursor's key is whether the last SearchHit was a File or a Folder, followed by its score.
+		last := SearchHit{}
+		key := strings.SplitN(cursor.Key, " ", 2)
+		if len(key) != 2 {
+			return nil, PageInfo{}, ErrInvalidCursor
+		}
+		switch key[0] {
+		case "file":
+			last.FileID = cursor.ID
+		case "folder":
+			last.FolderID = cursor.ID
+		default:
+			return nil, PageInfo{}, ErrInvalidCursor
+		}
+		last.Score, err = strconv.ParseFloat(key[1], 64)
+		if err != nil {
+			return nil, PageInfo{}, ErrInvalidCursor
+		}
+
+		hits = hits[sort.Search(len(hits), func(i int) bool {
+			return searchHitLess(last, hits[i])
+		}):]
+	}
+
+	if page.Limit == 0 || len(hits) <= page.Limit {
+		return hits, info, nil
+	}
+	hits = hits[:page.Limit]
+
+	last := hits[len(hits)-1]
+	kind, id := "folder", last.FolderID
+	if last.FileID != 0 {
+		kind, id = "file", last.FileID
+	}
+	info.NextCursor = encodePageCursor(pageCursor{
+		Sort: page.Sort,
+		Key:  kind + " " + strconv.FormatFloat(last.Score, 'g', -1, 64),
+		ID:   id,
+	})
+	return hits, info, nil
+}
+
+// searchTable finds the rows of a search table matching a query, with the id of each row in SearchHit.FolderID.
+// Rows are scored with the BM25 ranking of FTS5, negated so a higher score is a better match.
+func searchTable(db *gorm.DB, name, entityTable, query string, weights []float64) ([]SearchHit, error) {
+	weightArgs := make([]string, len(weights))
+	args := []interface{}{SnippetMatchStart, SnippetMatchEnd, snippetEllipsis, snippetTokens}
+	for i, weight := range weights {
+		weightArgs[i] = "?"
+		args = append(args, weight)
+	}
+	args = append(args, query)
+
+	rows, err := db.Raw("SELECT "+name+".rowid, snippet("+name+", -1, ?, ?, ?, ?), -bm25("+name+", "+
+		strings.Join(weightArgs, ", ")+") FROM "+name+
+		" JOIN "+entityTable+" ON "+entityTable+".id = "+name+".rowid"+
+		" WHERE "+name+" MATCH ? AND "+entityTable+".deleted_at IS NULL", args...).Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	hits := []SearchHit{}
+	for rows.Next() {
+		hit := SearchHit{}
+		err = rows.Scan(&hit.FolderID, &hit.Snippet, &hit.Score)
+		if err != nil {
+			return nil, err
+		}
+		hits = append(hits, hit)
+	}
+	return hits, rows.Err()
+}
@@ -0,0 +1,465 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ErrInvalidTrashItemType returned when a TrashItem.Type other than TrashItemFile or TrashItemFolder is specified.
+var ErrInvalidTrashItemType = errors.New("invalid trash item type")
+
+// ErrTrashItemNotFound returned when no deleted File or Folder matches the given criteria.
+var ErrTrashItemNotFound = errors.New("trash item not found")
+
+const (
+	// TrashItemFile is the TrashItem.Type of a deleted File.
+	TrashItemFile = "file"
+
+	// TrashItemFolder is the TrashItem.Type of a deleted Folder.
+	TrashItemFolder = "folder"
+)
+
+// TrashItem represents a deleted File or Folder that can still be restored.
+// Files and Folders are only soft deleted, so they stay in the trash until they are purged.
+// The user who deleted an item is recorded as its LastEditorID when it is deleted.
+type TrashItem struct {
+	Type           string    `json:"type"`
+	ID             uint      `json:"id"`
+	Name           string    `json:"name"`
+	ParentFolderID uint      `json:"parent_folder_id"`
+	DeletedByID    uint      `json:"deleted_by_id"`
+	DeletedAt      time.Time `json:"deleted_at"`
+}
+
+// trashItem converts a deleted File to a TrashItem.
+func (file *File) trashItem() TrashItem {
+	return TrashItem{
+		Type:           TrashItemFile,
+		ID:             file.ID,
+		Name:           file.Name,
+		ParentFolderID: file.FolderID,
+		DeletedByID:    file.LastEditorID,
+		DeletedAt:      file.DeletedAt.Time,
+	}
+}
+
+// trashItem converts a deleted Folder to a TrashItem.
+func (folder *Folder) trashItem() TrashItem {
+	item := TrashItem{
+		Type:        TrashItemFolder,
+		ID:          folder.ID,
+		Name:        folder.Name,
+		DeletedByID: folder.LastEditorID,
+		DeletedAt:   folder.DeletedAt.Time,
+	}
+	if folder.ParentFolderID != nil {
+		item.ParentFolderID = *folder.ParentFolderID
+	}
+	return item
+}
+
+// GetTrash returns all deleted Files and Folders, ordered by deletion time.
+// If deletedByID is not 0, only items deleted by that User are returned.
+func GetTrash(db *gorm.DB, deletedByID uint) ([]TrashItem, error) {
+	return getTrashWhere(db, func(db *gorm.DB) *gorm.DB {
+		if deletedByID == 0 {
+			return db
+		}
+		return db.Where("last_editor_id = ?", deletedByID)
+	})
+}
+
+// GetExpiredTrash returns all Files and Folders deleted before a given time.
+func GetExpiredTrash(db *gorm.DB, deletedBefore time.Time) ([]TrashItem, error) {
+	return getTrashWhere(db, func(db *gorm.DB) *gorm.DB {
+		return db.Where("deleted_at < ?", deletedBefore)
+	})
+}
+
+// getTrashWhere returns all deleted Files and Folders matching a query scope.
+func getTrashWhere(db *gorm.DB, scope func(*gorm.DB) *gorm.DB) ([]TrashItem, error) {
+	var folders []Folder
+	err := db.Unscoped().Scopes(scope).Where("deleted_at IS NOT NULL").Order("deleted_at").Find(&folders).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var files []File
+	err = db.Unscoped().Scopes(scope).Where("deleted_at IS NOT NULL").Order("deleted_at").Find(&files).Error
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]TrashItem, 0, len(folders)+len(files))
+	for _, folder := range folders {
+		items = append(items, folder.trashItem())
+	}
+	for _, file := range files {
+		items = append(items, file.trashItem())
+	}
+	return items, nil
+}
+
+// GetTrashItem returns a single deleted File or Folder by its TrashItem.Type and TrashItem.ID.
+func GetTrashItem(db *gorm.DB, itemType string, id uint) (TrashItem, error) {
+	switch itemType {
+	case TrashItemFile:
+		file, err := getDeletedFile(db, id)
+		if err != nil {
+			return TrashItem{}, err
+		}
+		return file.trashItem(), nil
+	case TrashItemFolder:
+		folder, err := getDeletedFolder(db, id)
+		if err != nil {
+			return TrashItem{}, err
+		}
+		return folder.trashItem(), nil
+	}
+	return TrashItem{}, ErrInvalidTrashItemType
+}
+
+// getDeletedFile gets a deleted File by its Model.ID.
+func getDeletedFile(db *gorm.DB, fileID uint) (File, error) {
+	if fileID == 0 {
+		return File{}, ErrRequiredFileID
+	}
+
+	file := File{}
+	err := db.Unscoped().Where("deleted_at IS NOT NULL").Where("id = ?", fileID).Take(&file).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return File{}, ErrTrashItemNotFound
+	}
+	return file, err
+}
+
+// getDeletedFolder gets a deleted Folder by its Model.ID.
+func getDeletedFolder(db *gorm.DB, folderID uint) (Folder, error) {
+	if folderID == 0 {
+		return Folder{}, ErrRequiredFolderID
+	}
+
+	folder := Folder{}
+	err := db.Unscoped().Where("deleted_at IS NOT NULL").Where("id = ?", folderID).Take(&folder).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return Folder{}, ErrTrashItemNotFound
+	}
+	return folder, err
+}
+
+// GetRestoreFolderID returns the Model.ID of the closest existing Folder a TrashItem would be restored under.
+// Any deleted Folder between the item and this Folder is restored along with the item.
+func GetRestoreFolderID(db *gorm.DB, item TrashItem) (uint, error) {
+	folderID := item.ParentFolderID
+	for {
+		if folderID == 0 {
+			return 0, ErrFolderNotFound
+		}
+
+		_, err := getFolderByIDRaw(db, folderID)
+		if err == nil {
+			return folderID, nil
+		} else if err != ErrFolderNotFound {
+			return 0, err
+		}
+
+		folder, err := getDeletedFolder(db, folderID)
+		if err == ErrTrashItemNotFound {
+			return 0, ErrFolderNotFound
+		} else if err != nil {
+			return 0, err
+		}
+		folderID = folder.trashItem().ParentFolderID
+	}
+}
+
+// RestoreTrashItem restores a deleted File or Folder, along with any deleted parent Folder.
+// Restoring a Folder also restores the Files and Folders inside it that were deleted along with it.
+// If an item with the same name has since been created in the parent Folder, the restored item is renamed.
+func RestoreTrashItem(db *gorm.DB, item TrashItem, userID uint) (TrashItem, error) {
+	if userID == 0 {
+		return TrashItem{}, ErrRequiredLastEditorID
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		err := restoreFolderChain(tx, item.ParentFolderID, userID)
+		if err != nil {
+			return err
+		}
+
+		switch item.Type {
+		case TrashItemFile:
+			file, err := getDeletedFile(tx, item.ID)
+			if err != nil {
+				return err
+			}
+			file.Name, err = getAvailableFileName(tx, file)
+			if err != nil {
+				return err
+			}
+			item.Name = file.Name
+			return tx.Unscoped().Model(&file).Updates(map[string]interface{}{
+				"name":           file.Name,
+				"last_editor_id": userID,
+				"deleted_at":     nil,
+			}).Error
+		case TrashItemFolder:
+			folder, err := getDeletedFolder(tx, item.ID)
+			if err != nil {
+				return err
+			}
+			err = restoreFolderContents(tx, folder.ID, userID)
+			if err != nil {
+				return err
+			}
+			folder, err = restoreFolder(tx, folder, userID)
+			item.Name = folder.Name
+			return err
+		}
+		return ErrInvalidTrashItemType
+	})
+	if err != nil {
+		return TrashItem{}, err
+	}
+
+	item.DeletedByID = 0
+	item.DeletedAt = time.Time{}
+	return item, nil
+}
+
+// restoreFolderChain restores a Folder and all its parent Folders if they were deleted.
+func restoreFolderChain(db *gorm.DB, folderID, userID uint) error {
+	if folderID == 0 {
+		return ErrFolderNotFound
+	}
+
+	_, err := getFolderByIDRaw(db, folderID)
+	if err == nil {
+		return nil
+	} else if err != ErrFolderNotFound {
+		return err
+	}
+
+	folder, err := getDeletedFolder(db, folderID)
+	if err == ErrTrashItemNotFound {
+		return ErrFolderNotFound
+	} else if err != nil {
+		return err
+	}
+
+	// Parents need to be restored first so name conflicts are checked against the restored parent.
+	err = restoreFolderChain(db, folder.trashItem().ParentFolderID, userID)
+	if err != nil {
+		return err
+	}
+
+	_, err = restoreFolder(db, folder, userID)
+	return err
+}
+
+// restoreFolder restores a single deleted Folder, renaming it if its name is already taken.
+func restoreFolder(db *gorm.DB, folder Folder, userID uint) (Folder, error) {
+	var err error
+	folder.Name, err = getAvailableFolderName(db, folder)
+	if err != nil {
+		return Folder{}, err
+	}
+
+	err = db.Unscoped().Model(&folder).Updates(map[string]interface{}{
+		"name":           folder.Name,
+		"last_editor_id": userID,
+		"deleted_at":     nil,
+	}).Error
+	return folder, err
+}
+
+// restoreFolderContents restores the Files and Folders inside a deleted Folder that were deleted at the same time as it.
+// Items deleted before the Folder was deleted stay in the trash.
+// The contents must be restored before the Folder itself, as the Folder's deletion time is used to find them.
+// Nothing can be created inside a deleted Folder, so the restored items never need to be renamed.
+func restoreFolderContents(db *gorm.DB, folderID, userID uint) error {
+	deletedAt := db.Unscoped().Model(&Folder{}).Select("deleted_at").Where("id = ?", folderID)
+
+	var childFolders []Folder
+	err := db.Unscoped().Where("parent_folder_id = ? AND deleted_at = (?)", folderID, deletedAt).Find(&childFolders).Error
+	if err != nil {
+		return err
+	}
+
+	for _, childFolder := range childFolders {
+		err = restoreFolderContents(db, childFolder.ID, userID)
+		if err != nil {
+			return err
+		}
+	}
+
+	restored := map[string]interface{}{
+		"last_editor_id": userID,
+		"deleted_at":     nil,
+	}
+	err = db.Unscoped().Model(&File{}).Where("folder_id = ? AND deleted_at = (?)", folderID, deletedAt).Updates(restored).Error
+	if err != nil {
+		return err
+	}
+	return db.Unscoped().Model(&Folder{}).Where("parent_folder_id = ? AND deleted_at = (?)", folderID, deletedAt).Updates(restored).Error
+}
+
+// getAvailableFileName returns a File.Name not used by any other File in the File's Folder.
+// Taken names get a numbered suffix, such as "report (1)".
+func getAvailableFileName(db *gorm.DB, file File) (string, error) {
+	name := file.Name
+	for i := 1; ; i++ {
+		var count int64
+		err := db.Model(&File{}).Where(&File{Name: name, FolderID: file.FolderID}).Count(&count).Error
+		if err != nil {
+			return "", err
+		} else if count == 0 {
+			return name, nil
+		}
+		name = fmt.Sprintf("%s (%d)", file.Name, i)
+	}
+}
+
+// getAvailableFolderName returns a Folder.Name not used by any other Folder in the Folder's parent Folder.
+// Taken names get a numbered suffix, such as "reports (1)".
+func getAvailableFolderName(db *gorm.DB, folder Folder) (string, error) {
+	name := folder.Name
+	for i := 1; ; i++ {
+		var count int64
+		err := db.Model(&Folder{}).Where(&Folder{Name: name, ParentFolderID: folder.ParentFolderID}).Count(&count).Error
+		if err != nil {
+			return "", err
+		} else if count == 0 {
+			return name, nil
+		}
+		name = fmt.Sprintf("%s (%d)", folder.Name, i)
+	}
+}
+
+// PurgedData describes the stored data left behind by Files that were permanently deleted.
+// PurgedData.FileIDs lists the Model.ID of every purged File, whose data may still be stored by its id.
+// PurgedData.Checksums lists the Blobs that are no longer referenced by any FileVersion, whose data can be removed.
+type PurgedData struct {
+	FileIDs   []uint
+	Checksums []string
+}
+
+// PurgeTrashItem permanently deletes a deleted File or Folder.
+// Purging a Folder also purges every deleted File and Folder inside it.
+// Returns the data of the purged Files that can be removed from the file system.
+// AccessRoles of a Folder are already removed when it is deleted, but are removed again for consistency.
+func PurgeTrashItem(db *gorm.DB, item TrashItem) (PurgedData, error) {
+	purged := PurgedData{}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		switch item.Type {
+		case TrashItemFile:
+			_, err = getDeletedFile(tx, item.ID)
+			if err != nil {
+				return err
+			}
+			return purgeFiles(tx, []uint{item.ID}, &purged)
+		case TrashItemFolder:
+			_, err = getDeletedFolder(tx, item.ID)
+			if err != nil {
+				return err
+			}
+			return purgeFolder(tx, item.ID, &purged)
+		}
+		return ErrInvalidTrashItemType
+	})
+	if err != nil {
+		return PurgedData{}, err
+	}
+	return purged, nil
+}
+
+// purgeFolder permanently deletes a deleted Folder and all its deleted contents.
+func purgeFolder(db *gorm.DB, folderID uint, purged *PurgedData) error {
+	// A deleted folder can't contain a folder or file that wasn't also deleted.
+	var liveChildren int64
+	err := db.Model(&Folder{}).Where("parent_folder_id = ?", folderID).Count(&liveChildren).Error
+	if err != nil {
+		return err
+	}
+	if liveChildren == 0 {
+		err = db.Model(&File{}).Where("folder_id = ?", folderID).Count(&liveChildren).Error
+		if err != nil {
+			return err
+		}
+	}
+	if liveChildren > 0 {
+		return ErrFolderNotEmpty
+	}
+
+	var childFolders []Folder
+	err = db.Unscoped().Where("parent_folder_id = ?", folderID).Find(&childFolders).Error
+	if err != nil {
+		return err
+	}
+
+	for _, childFolder := range childFolders {
+		err = purgeFolder(db, childFolder.ID, purged)
+		if err != nil {
+			return err
+		}
+	}
+
+	var files []File
+	err = db.Unscoped().Where("folder_id = ?", folderID).Find(&files).Error
+	if err != nil {
+		return err
+	}
+	var childFileIDs []uint
+	for _, file := range files {
+		childFileIDs = append(childFileIDs, file.ID)
+	}
+	err = purgeFiles(db, childFileIDs, purged)
+	if err != nil {
+		return err
+	}
+
+	err = db.Where("folder_id = ?", folderID).Delete(&AccessRole{}).Error
+	if err != nil {
+		return err
+	}
+
+	err = db.Where("folder_id = ?", folderID).Delete(&UploadPolicy{}).Error
+	if err != nil {
+		return err
+	}
+
+	return db.Unscoped().Delete(&Folder{}, folderID).Error
+}
+
+// purgeFiles permanently deletes Files and their FileVersions, removing the references to their Blobs.
+func purgeFiles(db *gorm.DB, fileIDs []uint, purged *PurgedData) error {
+	if len(fileIDs) == 0 {
+		return nil
+	}
+
+	var versions []FileVersion
+	err := db.Where("file_id IN ?", fileIDs).Find(&versions).Error
+	if err != nil {
+		return err
+	}
+	for _, version := range versions {
+		unreferenced, err := RemoveBlobReference(db, version.Checksum)
+		if err != nil {
+			return err
+		}
+		if unreferenced {
+			purged.Checksums = append(purged.Checksums, version.Checksum)
+		}
+	}
+
+	err = db.Where("file_id IN ?", fileIDs).Delete(&FileVersion{}).Error
+	if err != nil {
+		return err
+	}
+	purged.FileIDs = append(purged.FileIDs, fileIDs...)
+	return db.Unscoped().Delete(&File{}, fileIDs).Error
+}
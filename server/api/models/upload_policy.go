@@ -0,0 +1,214 @@
+package models
+
+import (
+	"errors"
+	"mime"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ErrInvalidContentType returned when an UploadPolicy lists a content type that isn't a valid MIME type pattern.
+var ErrInvalidContentType = errors.New("invalid content type")
+
+// ErrInvalidMaxFileSize returned when an invalid UploadPolicy.MaxFileSize is specified.
+var ErrInvalidMaxFileSize = errors.New("invalid max file size")
+
+// ErrContentTypeNotAllowed returned when file data has a content type the UploadPolicy of its Folder doesn't allow.
+var ErrContentTypeNotAllowed = errors.New("content type not allowed")
+
+// ErrUploadPolicyNotFound returned when no UploadPolicy matches the given criteria.
+var ErrUploadPolicyNotFound = errors.New("upload policy not found")
+
+// UploadPolicy restricts the file data that can be uploaded to a Folder and all its descendants.
+// A Folder without its own UploadPolicy uses the UploadPolicy of its closest ancestor that has one.
+// Content types are MIME types such as "application/pdf", or patterns such as "image/*" matching any subtype.
+// Data matching UploadPolicy.DeniedTypes is always rejected and, if UploadPolicy.AllowedTypes isn't empty,
+// data must match one of them. UploadPolicy.MaxFileSize limits the size of the data in bytes, with 0 meaning no limit.
+// The type lists are stored as comma-separated strings.
+type UploadPolicy struct {
+	FolderID         uint      `gorm:"primaryKey" json:"folder_id"`
+	AllowedTypes     []string  `gorm:"-" json:"allowed_types"`
+	DeniedTypes      []string  `gorm:"-" json:"denied_types"`
+	MaxFileSize      int64     `gorm:"not null;default:0" json:"max_file_size"`
+	AllowedTypesList string    `gorm:"column:allowed_types;not null;default:''" json:"-"`
+	DeniedTypesList  string    `gorm:"column:denied_types;not null;default:''" json:"-"`
+	LastEditorID     uint      `gorm:"not null" json:"last_editor_id"`
+	CreatedAt        time.Time `json:"created_at"`
+	UpdatedAt        time.Time `json:"updated_at"`
+}
+
+// prepare normalizes the content types of an UploadPolicy and joins them into their stored lists.
+func (policy *UploadPolicy) prepare() error {
+	var err error
+	policy.AllowedTypes, err = prepareContentTypes(policy.AllowedTypes)
+	if err != nil {
+		return err
+	}
+	policy.DeniedTypes, err = prepareContentTypes(policy.DeniedTypes)
+	if err != nil {
+		return err
+	}
+	policy.AllowedTypesList = strings.Join(policy.AllowedTypes, ",")
+	policy.DeniedTypesList = strings.Join(policy.DeniedTypes, ",")
+	return nil
+}
+
+// formatContentTypes splits the stored lists of an UploadPolicy into its content types.
+func (policy *UploadPolicy) formatContentTypes() {
+	policy.AllowedTypes = splitContentTypes(policy.AllowedTypesList)
+	policy.DeniedTypes = splitContentTypes(policy.DeniedTypesList)
+}
+
+// prepareContentTypes lowercases content type patterns and removes duplicates and parameters.
+func prepareContentTypes(contentTypes []string) ([]string, error) {
+	prepared := []string{}
+	seen := map[string]bool{}
+	for _, contentType := range contentTypes {
+		mediaType, _, err := mime.ParseMediaType(contentType)
+		if err != nil || strings.Count(mediaType, "/") != 1 {
+			return nil, ErrInvalidContentType
+		}
+		parts := strings.Split(mediaType, "/")
+		if parts[0] == "" || parts[1] == "" || parts[0] == "*" && parts[1] != "*" {
+			return nil, ErrInvalidContentType
+		}
+		if !seen[mediaType] {
+			seen[mediaType] = true
+			prepared = append(prepared, mediaType)
+		}
+	}
+	return prepared, nil
+}
+
+// splitContentTypes splits a stored list of content types.
+func splitContentTypes(list string) []string {
+	if list == "" {
+		return []string{}
+	}
+	return strings.Split(list, ",")
+}
+
+// matchesContentType returns whether a MIME type matches any of the content type patterns.
+func matchesContentType(mediaType string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if pattern == "*/*" || pattern == mediaType ||
+			strings.HasSuffix(pattern, "/*") && strings.HasPrefix(mediaType, strings.TrimSuffix(pattern, "*")) {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowsContentType returns whether the UploadPolicy allows file data with the given content type.
+// Parameters of the content type, such as the charset, are ignored.
+func (policy UploadPolicy) AllowsContentType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType = "application/octet-stream"
+	}
+
+	if matchesContentType(mediaType, policy.DeniedTypes) {
+		return false
+	}
+	return len(policy.AllowedTypes) == 0 || matchesContentType(mediaType, policy.AllowedTypes)
+}
+
+// AllowsSize returns whether the UploadPolicy allows file data with the given size in bytes.
+func (policy UploadPolicy) AllowsSize(size int64) bool {
+	return policy.MaxFileSize == 0 || size <= policy.MaxFileSize
+}
+
+// UpsertUploadPolicy sets the UploadPolicy of a Folder, replacing any UploadPolicy it already has.
+func UpsertUploadPolicy(db *gorm.DB, policy UploadPolicy) (UploadPolicy, error) {
+	if policy.FolderID == 0 {
+		return UploadPolicy{}, ErrRequiredFolderID
+	}
+	if policy.LastEditorID == 0 {
+		return UploadPolicy{}, ErrRequiredLastEditorID
+	}
+	if policy.MaxFileSize < 0 {
+		return UploadPolicy{}, ErrInvalidMaxFileSize
+	}
+	err := policy.prepare()
+	if err != nil {
+		return UploadPolicy{}, err
+	}
+
+	_, err = getFolderByIDRaw(db, policy.FolderID)
+	if err != nil {
+		return UploadPolicy{}, err
+	}
+
+	oldPolicy, err := GetUploadPolicy(db, policy.FolderID)
+	if err == nil {
+		policy.CreatedAt = oldPolicy.CreatedAt
+	} else if err != ErrUploadPolicyNotFound {
+		return UploadPolicy{}, err
+	}
+
+	err = db.Save(&policy).Error
+	if err != nil {
+		return UploadPolicy{}, err
+	}
+	return GetUploadPolicy(db, policy.FolderID)
+}
+
+// GetUploadPolicy gets the UploadPolicy set on a Folder, ignoring the UploadPolicy of its ancestors.
+func GetUploadPolicy(db *gorm.DB, folderID uint) (UploadPolicy, error) {
+	if folderID == 0 {
+		return UploadPolicy{}, ErrRequiredFolderID
+	}
+
+	policy := UploadPolicy{}
+	err := db.Where(&UploadPolicy{FolderID: folderID}).Take(&policy).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return UploadPolicy{}, ErrUploadPolicyNotFound
+	} else if err != nil {
+		return UploadPolicy{}, err
+	}
+	policy.formatContentTypes()
+	return policy, nil
+}
+
+// GetEffectiveUploadPolicy gets the UploadPolicy that applies to file data uploaded to a Folder.
+// This is the UploadPolicy of the Folder or of its closest ancestor that has one.
+// If no UploadPolicy applies, an UploadPolicy allowing any data is returned with an UploadPolicy.FolderID of 0.
+func GetEffectiveUploadPolicy(db *gorm.DB, folderID uint) (UploadPolicy, error) {
+	folder, err := getFolderByIDRaw(db, folderID)
+	if err != nil {
+		return UploadPolicy{}, err
+	}
+
+	for {
+		policy, err := GetUploadPolicy(db, folder.ID)
+		if err != ErrUploadPolicyNotFound {
+			return policy, err
+		}
+
+		if *folder.ParentFolderID == 0 {
+			return UploadPolicy{
+				AllowedTypes: []string{},
+				DeniedTypes:  []string{},
+			}, nil
+		}
+		folder, err = getFolderByIDRaw(db, *folder.ParentFolderID)
+		if err != nil {
+			return UploadPolicy{}, err
+		}
+	}
+}
+
+// DeleteUploadPolicy removes the UploadPolicy set on a Folder, so it uses the UploadPolicy of its ancestors.
+func DeleteUploadPolicy(db *gorm.DB, folderID uint) error {
+	if folderID == 0 {
+		return ErrRequiredFolderID
+	}
+
+	_, err := GetUploadPolicy(db, folderID)
+	if err != nil {
+		return err
+	}
+	return db.Delete(&UploadPolicy{}, folderID).Error
+}
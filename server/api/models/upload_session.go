@@ -0,0 +1,206 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ErrRequiredUploadSessionID returned when no UploadSession.ID is specified.
+var ErrRequiredUploadSessionID = errors.New("required upload session id")
+
+// ErrUploadSessionNotFound returned when no UploadSession matches the given criteria.
+var ErrUploadSessionNotFound = errors.New("upload session not found")
+
+// ErrInvalidUploadLength returned when an invalid UploadSession.Length is specified.
+var ErrInvalidUploadLength = errors.New("invalid upload length")
+
+// ErrRequiredExpiresAt returned when no UploadSession.ExpiresAt is specified.
+var ErrRequiredExpiresAt = errors.New("required expiry time")
+
+// ErrRequiredChunkNumber returned when no UploadChunk.Number is specified.
+var ErrRequiredChunkNumber = errors.New("required chunk number")
+
+// ErrChunkOutOfRange returned when an UploadChunk doesn't fit inside the UploadSession.Length.
+var ErrChunkOutOfRange = errors.New("chunk out of range")
+
+// ErrChunkOverlaps returned when an UploadChunk overlaps with another UploadChunk of the UploadSession.
+var ErrChunkOverlaps = errors.New("chunk overlaps another chunk")
+
+// ErrUploadIncomplete returned when an UploadSession is finalized before all its data is received.
+var ErrUploadIncomplete = errors.New("upload incomplete")
+
+// UploadSession represents an upload of a File's data that is sent in multiple chunks.
+// Chunks can be sent in any order and resent if they fail, which allows interrupted uploads to be resumed.
+// Once every byte up to UploadSession.Length has been received, the session can be finalized into a FileVersion.
+// Sessions that aren't updated before UploadSession.ExpiresAt are abandoned and removed.
+type UploadSession struct {
+	ID             uint          `gorm:"primaryKey" json:"id"`
+	FileID         uint          `gorm:"not null" json:"file_id"`
+	UploaderID     uint          `gorm:"not null" json:"uploader_id"`
+	Length         int64         `gorm:"not null" json:"length"`
+	Offset         int64         `gorm:"-" json:"offset"`
+	ReceivedRanges [][2]int64    `gorm:"-" json:"received_ranges"`
+	Chunks         []UploadChunk `gorm:"foreignKey:UploadSessionID" json:"-"`
+	ExpiresAt      time.Time     `gorm:"not null" json:"expires_at"`
+	CreatedAt      time.Time     `json:"created_at"`
+	UpdatedAt      time.Time     `json:"updated_at"`
+}
+
+// UploadChunk represents a single chunk of data received in an UploadSession.
+// The chunk covers the bytes from UploadChunk.Offset up to, but not including, UploadChunk.Offset + UploadChunk.Size.
+// Chunk numbers are unique per UploadSession and resending a chunk replaces it.
+type UploadChunk struct {
+	ID              uint  `gorm:"primaryKey" json:"id"`
+	UploadSessionID uint  `gorm:"not null;uniqueIndex:idx_upload_chunk_number" json:"upload_session_id"`
+	Number          uint  `gorm:"not null;uniqueIndex:idx_upload_chunk_number" json:"number"`
+	Offset          int64 `gorm:"column:chunk_offset;not null" json:"offset"`
+	Size            int64 `gorm:"not null" json:"size"`
+}
+
+// computeReceivedRanges merges the UploadSession.Chunks into the ranges of bytes received so far.
+// UploadSession.Offset is set to the number of bytes received without any gap from the start of the upload.
+func (session *UploadSession) computeReceivedRanges() {
+	session.ReceivedRanges = [][2]int64{}
+	for _, chunk := range session.Chunks {
+		if chunk.Size == 0 {
+			continue
+		}
+
+		last := len(session.ReceivedRanges) - 1
+		if last >= 0 && session.ReceivedRanges[last][1] == chunk.Offset {
+			session.ReceivedRanges[last][1] += chunk.Size
+		} else {
+			session.ReceivedRanges = append(session.ReceivedRanges, [2]int64{chunk.Offset, chunk.Offset + chunk.Size})
+		}
+	}
+
+	session.Offset = 0
+	if len(session.ReceivedRanges) > 0 && session.ReceivedRanges[0][0] == 0 {
+		session.Offset = session.ReceivedRanges[0][1]
+	}
+}
+
+// IsComplete returns whether all the data of an UploadSession has been received.
+func (session *UploadSession) IsComplete() bool {
+	return session.Offset == session.Length
+}
+
+// CreateUploadSession creates an UploadSession.
+func CreateUploadSession(db *gorm.DB, session UploadSession) (UploadSession, error) {
+	if session.FileID == 0 {
+		return UploadSession{}, ErrRequiredFileID
+	}
+	if session.UploaderID == 0 {
+		return UploadSession{}, ErrRequiredUploaderID
+	}
+	if session.Length < 0 {
+		return UploadSession{}, ErrInvalidUploadLength
+	}
+	if session.ExpiresAt.IsZero() {
+		return UploadSession{}, ErrRequiredExpiresAt
+	}
+
+	// Check the file referenced by the session exists.
+	_, err := GetFileByID(db, session.FileID)
+	if err != nil {
+		return UploadSession{}, err
+	}
+
+	session.ID = 0
+	session.Chunks = nil
+	err = db.Create(&session).Take(&session).Error
+	if err != nil {
+		return UploadSession{}, err
+	}
+
+	session.computeReceivedRanges()
+	return session, nil
+}
+
+// GetUploadSessionByID gets an UploadSession by its UploadSession.ID, along with its received ranges.
+func GetUploadSessionByID(db *gorm.DB, sessionID uint) (UploadSession, error) {
+	if sessionID == 0 {
+		return UploadSession{}, ErrRequiredUploadSessionID
+	}
+
+	session := UploadSession{
+		ID: sessionID,
+	}
+
+	err := db.Where(&session).Take(&session).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return UploadSession{}, ErrUploadSessionNotFound
+	} else if err != nil {
+		return UploadSession{}, err
+	}
+
+	err = db.Where(&UploadChunk{UploadSessionID: sessionID}).Order("chunk_offset").Find(&session.Chunks).Error
+	if err != nil {
+		return UploadSession{}, err
+	}
+
+	session.computeReceivedRanges()
+	return session, nil
+}
+
+// AddUploadChunk records a received UploadChunk, replacing any chunk previously sent with the same number.
+// Receiving a chunk keeps the UploadSession alive until the new expiry time.
+func AddUploadChunk(db *gorm.DB, chunk UploadChunk, expiresAt time.Time) (UploadSession, error) {
+	if chunk.Number == 0 {
+		return UploadSession{}, ErrRequiredChunkNumber
+	}
+
+	session, err := GetUploadSessionByID(db, chunk.UploadSessionID)
+	if err != nil {
+		return UploadSession{}, err
+	}
+
+	if chunk.Offset < 0 || chunk.Size < 0 || chunk.Offset+chunk.Size > session.Length {
+		return UploadSession{}, ErrChunkOutOfRange
+	}
+
+	// Chunks can't overlap, as it would be ambiguous which chunk's data to use.
+	existingChunkID := uint(0)
+	for _, existingChunk := range session.Chunks {
+		if existingChunk.Number == chunk.Number {
+			existingChunkID = existingChunk.ID
+		} else if chunk.Offset < existingChunk.Offset+existingChunk.Size &&
+			existingChunk.Offset < chunk.Offset+chunk.Size {
+			return UploadSession{}, ErrChunkOverlaps
+		}
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		chunk.ID = existingChunkID
+		err := tx.Save(&chunk).Error
+		if err != nil {
+			return err
+		}
+
+		return tx.Model(&session).Update("expires_at", expiresAt).Error
+	})
+	if err != nil {
+		return UploadSession{}, err
+	}
+
+	return GetUploadSessionByID(db, session.ID)
+}
+
+// DeleteUploadSession deletes an UploadSession and its UploadChunks by its UploadSession.ID.
+func DeleteUploadSession(db *gorm.DB, sessionID uint) error {
+	session, err := GetUploadSessionByID(db, sessionID)
+	if err != nil {
+		return err
+	}
+
+	return db.Select("Chunks").Delete(&session).Error
+}
+
+// GetExpiredUploadSessions returns all UploadSessions that expired before a given time.
+func GetExpiredUploadSessions(db *gorm.DB, expiredBefore time.Time) ([]UploadSession, error) {
+	var sessions []UploadSession
+	err := db.Where("expires_at < ?", expiredBefore).Find(&sessions).Error
+	return sessions, err
+}
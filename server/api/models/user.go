@@ -108,17 +108,43 @@ func CreateUser(db *gorm.DB, user User) (User, error) {
 
 // GetAllUsers returns a list of all present User.
 func GetAllUsers(db *gorm.DB) ([]User, error) {
-	var users []User
-	err := db.Find(&users).Error
+	users, _, err := GetUserPage(db, Page{})
+	return users, err
+}
+
+// userSortColumns lists the columns Users can be sorted by, besides their Model.ID.
+var userSortColumns = sortColumns{
+	"username":   false,
+	"first_name": false,
+	"last_name":  false,
+	"created_at": true,
+}
+
+// GetUserPage returns a Page of the present Users along with their UserRoles.
+// The Users can be sorted by username, first_name, last_name or created_at, and are sorted by Model.ID by default.
+// The UserRoles of all the Users are loaded in a single query.
+func GetUserPage(db *gorm.DB, page Page) ([]User, PageInfo, error) {
+	users := []User{}
+	info, err := paginate(db.Model(&User{}).Preload("UserRoles"), page, userSortColumns, &users,
+		func(i int) (interface{}, uint) {
+			switch page.Sort {
+			case "username":
+				return users[i].Username, users[i].ID
+			case "first_name":
+				return users[i].FirstName, users[i].ID
+			case "last_name":
+				return users[i].LastName, users[i].ID
+			}
+			return users[i].CreatedAt, users[i].ID
+		})
 	if err != nil {
-		return nil, err
+		return nil, PageInfo{}, err
 	}
 
 	for i := range users {
 		users[i].Password = ""
-		err = db.Model(&users[i]).Association("UserRoles").Find(&users[i].UserRoles)
 	}
-	return users, nil
+	return users, info, nil
 }
 
 // getUserByIDRaw gets a User by its Model.ID.
@@ -254,6 +280,12 @@ func DeleteUser(db *gorm.DB, uid uint) error {
 		return err
 	}
 
+	// Remove the access roles granted directly to the user.
+	err = db.Where("user_id = ?", user.ID).Delete(&AccessRole{}).Error
+	if err != nil {
+		return err
+	}
+
 	return db.Model(&user).Association("UserRoles").Clear()
 }
 
@@ -284,6 +316,17 @@ func (user *User) checkUserRolePresent(db *gorm.DB, userRole UserRole) error {
 
 // AddUserRole adds a UserRole to a User.
 func AddUserRole(db *gorm.DB, uid uint, userRole UserRole) (User, error) {
+	return AssignUserRole(db, uid, userRole, GrantWindow{})
+}
+
+// AssignUserRole adds a UserRole to a User, only granting the access of the UserRole during the GrantWindow.
+func AssignUserRole(db *gorm.DB, uid uint, userRole UserRole, window GrantWindow) (User, error) {
+	window.prepare()
+	err := window.validate()
+	if err != nil {
+		return User{}, err
+	}
+
 	user, err := getUserByIDRaw(db, uid)
 	if err != nil {
 		return User{}, err
@@ -302,6 +345,14 @@ func AddUserRole(db *gorm.DB, uid uint, userRole UserRole) (User, error) {
 		return User{}, err
 	}
 
+	if window.StartsAt != nil || window.ExpiresAt != nil {
+		err = db.Model(&AssignedUserRole{}).Where("user_id = ? AND user_role_id = ?", user.ID, userRole.ID).
+			Updates(map[string]interface{}{"starts_at": window.StartsAt, "expires_at": window.ExpiresAt}).Error
+		if err != nil {
+			return User{}, err
+		}
+	}
+
 	return user, user.getUserRoles(db)
 }
 
@@ -59,16 +59,28 @@ func CreateUserRole(db *gorm.DB, userRole UserRole) (UserRole, error) {
 
 // GetAllUserRoles returns a list of all present UserRole.
 func GetAllUserRoles(db *gorm.DB) ([]UserRole, error) {
-	var userRoles []UserRole
-	err := db.Find(&userRoles).Error
-	if err != nil {
-		return nil, err
-	}
+	userRoles, _, err := GetUserRolePage(db, Page{})
+	return userRoles, err
+}
 
-	for i := range userRoles {
-		err = db.Model(&userRoles[i]).Association("AccessRoles").Find(&userRoles[i].AccessRoles)
+// userRoleSortColumns lists the columns UserRoles can be sorted by, besides their Model.ID.
+var userRoleSortColumns = sortColumns{
+	"name": false,
+}
+
+// GetUserRolePage returns a Page of the UserRoles along with their AccessRoles.
+// The UserRoles can be sorted by name, and are sorted by UserRole.ID by default.
+// The AccessRoles of all the UserRoles are loaded in a single query.
+func GetUserRolePage(db *gorm.DB, page Page) ([]UserRole, PageInfo, error) {
+	userRoles := []UserRole{}
+	info, err := paginate(db.Model(&UserRole{}).Preload("AccessRoles"), page, userRoleSortColumns, &userRoles,
+		func(i int) (interface{}, uint) {
+			return userRoles[i].Name, userRoles[i].ID
+		})
+	if err != nil {
+		return nil, PageInfo{}, err
 	}
-	return userRoles, nil
+	return userRoles, info, nil
 }
 
 // GetUserRoleByID gets a UserRole by its Model.ID.
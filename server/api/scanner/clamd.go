@@ -0,0 +1,111 @@
+package scanner
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+// ErrScanFailed returned when clamd reports an error instead of a result, such as data larger than its stream limit.
+var ErrScanFailed = errors.New("scan failed")
+
+// ErrUnknownReply returned when clamd sends a reply that isn't a scan result.
+var ErrUnknownReply = errors.New("unknown reply from clamd")
+
+// clamdChunkSize is the size of the chunks data is streamed to clamd in.
+const clamdChunkSize = 64 * 1024
+
+// ClamdScanner scans data with a ClamAV daemon, streaming it over a socket with the INSTREAM command.
+// Network is "unix" for a unix socket, such as /var/run/clamav/clamd.ctl, or "tcp" for an address such as localhost:3310.
+// Timeout limits how long each read from or write to the daemon may take, with 0 meaning no limit.
+type ClamdScanner struct {
+	Network string
+	Address string
+	Timeout time.Duration
+}
+
+// Scan streams the data to clamd and waits for its result.
+func (scanner *ClamdScanner) Scan(reader io.Reader) (Result, error) {
+	conn, err := net.DialTimeout(scanner.Network, scanner.Address, scanner.Timeout)
+	if err != nil {
+		return Result{}, err
+	}
+	defer conn.Close()
+
+	// Commands prefixed with z are terminated by a null byte, as are their replies.
+	err = scanner.write(conn, []byte("zINSTREAM\x00"))
+	if err != nil {
+		return Result{}, err
+	}
+
+	// The data is sent in chunks prefixed with their length, and a chunk of length 0 ends the stream.
+	buffer := make([]byte, 4+clamdChunkSize)
+	for {
+		n, readErr := io.ReadFull(reader, buffer[4:])
+		if n > 0 {
+			binary.BigEndian.PutUint32(buffer[:4], uint32(n))
+			err = scanner.write(conn, buffer[:4+n])
+			if err != nil {
+				return Result{}, err
+			}
+		}
+		if readErr == io.EOF || readErr == io.ErrUnexpectedEOF {
+			break
+		} else if readErr != nil {
+			return Result{}, readErr
+		}
+	}
+	err = scanner.write(conn, []byte{0, 0, 0, 0})
+	if err != nil {
+		return Result{}, err
+	}
+
+	if scanner.Timeout > 0 {
+		err = conn.SetReadDeadline(time.Now().Add(scanner.Timeout))
+		if err != nil {
+			return Result{}, err
+		}
+	}
+	reply, err := bufio.NewReader(conn).ReadString(0)
+	if err != nil && err != io.EOF {
+		return Result{}, err
+	}
+	return parseClamdReply(reply)
+}
+
+// write sends data to clamd, failing if it takes longer than the timeout.
+func (scanner *ClamdScanner) write(conn net.Conn, data []byte) error {
+	if scanner.Timeout > 0 {
+		err := conn.SetWriteDeadline(time.Now().Add(scanner.Timeout))
+		if err != nil {
+			return err
+		}
+	}
+	_, err := conn.Write(data)
+	return err
+}
+
+// parseClamdReply turns a reply to the INSTREAM command, such as "stream: Eicar-Signature FOUND", into a Result.
+func parseClamdReply(reply string) (Result, error) {
+	reply = strings.TrimSpace(strings.TrimSuffix(reply, "\x00"))
+	status := strings.TrimPrefix(reply, "stream: ")
+
+	switch {
+	case status == "OK":
+		return Result{}, nil
+	case strings.HasSuffix(status, " FOUND"):
+		return Result{
+			Infected:  true,
+			Signature: strings.TrimSuffix(status, " FOUND"),
+		}, nil
+	case strings.HasSuffix(status, " ERROR"):
+		return Result{}, fmt.Errorf("%w: %s", ErrScanFailed, strings.TrimSuffix(status, " ERROR"))
+	default:
+		return Result{}, fmt.Errorf("%w: %q", ErrUnknownReply, reply)
+	}
+}
@@ -0,0 +1,29 @@
+// Package scanner checks uploaded file data for malware.
+package scanner
+
+import (
+	"io"
+)
+
+// Result describes the outcome of scanning file data.
+// Result.Signature names the malware found in infected data.
+type Result struct {
+	Infected  bool
+	Signature string
+}
+
+// Scanner scans file data for malware.
+// An error means the data couldn't be scanned, not that it is infected, so the scan should be retried later.
+type Scanner interface {
+	// Scan reads all the data from reader and reports whether it is infected.
+	Scan(reader io.Reader) (Result, error)
+}
+
+// NoopScanner reports all data as clean without reading it.
+// It is meant for development, where no malware scanner is available.
+type NoopScanner struct{}
+
+// Scan reports the data as clean.
+func (NoopScanner) Scan(io.Reader) (Result, error) {
+	return Result{}, nil
+}
@@ -1,13 +1,21 @@
 package api
 
 import (
+	"encoding/base64"
+	"encoding/json"
 	"fmt"
 	"log"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
+	"github.com/spf13/afero"
 
 	"github.com/vincetiu8/penn-spark-server/api/controllers"
+	"github.com/vincetiu8/penn-spark-server/api/filesystem"
+	"github.com/vincetiu8/penn-spark-server/api/scanner"
 )
 
 var server = controllers.Server{}
@@ -16,20 +24,231 @@ var server = controllers.Server{}
 // Database path set with DB_PATH environment variable.
 // URL extension path set with API_PATH environment variable.
 // Path to stored documents relative to server folder set with FS_PATH environment variable.
+// Maximum size of uploaded files in bytes set with MAX_UPLOAD_SIZE environment variable.
+// Maximum total size in bytes of the files extracted from an imported archive set with MAX_IMPORT_SIZE environment variable.
+// Maximum number of entries in an imported archive set with MAX_IMPORT_ENTRIES environment variable.
+// Time deleted items are kept in the trash set with TRASH_RETENTION environment variable.
+// Time between purges of expired trash set with TRASH_SWEEP_INTERVAL environment variable.
+// Time upload sessions are kept after last receiving data set with UPLOAD_SESSION_LIFETIME environment variable.
+// Time between removals of expired upload sessions set with UPLOAD_SESSION_SWEEP_INTERVAL environment variable.
+// Storage of file data set with STORAGE_DRIVER environment variable, either local or s3.
+// The s3 driver is configured with the S3_ENDPOINT, S3_REGION, S3_BUCKET, S3_ACCESS_KEY_ID and
+// S3_SECRET_ACCESS_KEY environment variables.
+// Stored file data is encrypted if a base64 encoded 32 byte master key is set with ENCRYPTION_KEY environment variable.
+// Comma separated master keys used before the last key rotation set with OLD_ENCRYPTION_KEYS environment variable.
+// Malware scanning of uploaded file data set with SCANNER_DRIVER environment variable, either none or clamd.
+// The clamd driver connects to the daemon at CLAMD_ADDRESS over CLAMD_NETWORK, either unix or tcp,
+// with each read and write limited by CLAMD_TIMEOUT.
+// Time between scans of file data that couldn't be scanned when uploaded set with SCAN_SWEEP_INTERVAL environment variable.
+// Time between indexing the text of file data for search set with SEARCH_INDEX_SWEEP_INTERVAL environment variable.
+// Time between removing expired access roles and user role assignments set with GRANT_EXPIRY_SWEEP_INTERVAL environment variable.
 func Run() {
+	initialize()
+
 	var err error
-	err = godotenv.Load()
+	server.MaxUploadSize, err = strconv.ParseInt(os.Getenv("MAX_UPLOAD_SIZE"), 10, 64)
+	if err != nil {
+		log.Fatalln("can't parse max upload size")
+	}
+
+	server.MaxImportSize, err = strconv.ParseInt(os.Getenv("MAX_IMPORT_SIZE"), 10, 64)
+	if err != nil {
+		log.Fatalln("can't parse max import size")
+	}
+
+	server.MaxImportEntries, err = strconv.Atoi(os.Getenv("MAX_IMPORT_ENTRIES"))
+	if err != nil {
+		log.Fatalln("can't parse max import entries")
+	}
+
+	trashRetention, err := time.ParseDuration(os.Getenv("TRASH_RETENTION"))
+	if err != nil {
+		log.Fatalln("can't parse trash retention time")
+	}
+	trashSweepInterval, err := time.ParseDuration(os.Getenv("TRASH_SWEEP_INTERVAL"))
+	if err != nil {
+		log.Fatalln("can't parse trash sweep interval")
+	}
+	server.StartTrashSweeper(trashRetention, trashSweepInterval)
+
+	server.UploadSessionLifetime, err = time.ParseDuration(os.Getenv("UPLOAD_SESSION_LIFETIME"))
+	if err != nil {
+		log.Fatalln("can't parse upload session lifetime")
+	}
+	uploadSessionSweepInterval, err := time.ParseDuration(os.Getenv("UPLOAD_SESSION_SWEEP_INTERVAL"))
+	if err != nil {
+		log.Fatalln("can't parse upload session sweep interval")
+	}
+	server.StartUploadSessionSweeper(uploadSessionSweepInterval)
+
+	scanSweepInterval, err := time.ParseDuration(os.Getenv("SCAN_SWEEP_INTERVAL"))
+	if err != nil {
+		log.Fatalln("can't parse scan sweep interval")
+	}
+	server.StartScanSweeper(scanSweepInterval)
+
+	searchIndexSweepInterval, err := time.ParseDuration(os.Getenv("SEARCH_INDEX_SWEEP_INTERVAL"))
+	if err != nil {
+		log.Fatalln("can't parse search index sweep interval")
+	}
+	server.StartSearchIndexSweeper(searchIndexSweepInterval)
+
+	grantExpirySweepInterval, err := time.ParseDuration(os.Getenv("GRANT_EXPIRY_SWEEP_INTERVAL"))
+	if err != nil {
+		log.Fatalln("can't parse grant expiry sweep interval")
+	}
+	server.StartGrantExpirySweeper(grantExpirySweepInterval)
+
+	server.Run(fmt.Sprintf("0.0.0.0:%s", os.Getenv("PORT")))
+}
+
+// Fsck verifies the stored file data against the file metadata and prints the problems found.
+// Exits with a non-zero status if any problem is found.
+func Fsck() {
+	initialize()
+
+	report, err := server.CheckIntegrity()
+	if err != nil {
+		log.Fatalln("can't check integrity:", err)
+	}
+
+	output, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		log.Fatalln("can't encode integrity report:", err)
+	}
+	fmt.Println(string(output))
+
+	if report.HasProblems() {
+		os.Exit(1)
+	}
+}
+
+// RotateKeys rewraps the data keys of the stored file data with the current master key.
+// The old master keys must still be set until the rotation is complete.
+func RotateKeys() {
+	initialize()
+
+	store, ok := server.FileSystem.Store.(*filesystem.EncryptedBlobStore)
+	if !ok {
+		log.Fatalln("encryption isn't enabled")
+	}
+
+	rotated, err := store.RotateKeys()
+	if err != nil {
+		log.Fatalln("can't rotate keys:", err)
+	}
+	fmt.Printf("rewrapped %d data keys\n", rotated)
+}
+
+// MigrateBlobs moves the file data stored by file id to storage by checksum, so identical data is only stored once.
+// It can be run again if interrupted, and only migrates the data that's left.
+func MigrateBlobs() {
+	initialize()
+
+	migrated, err := server.MigrateBlobs()
+	if err != nil {
+		log.Fatalln("can't migrate file data:", err)
+	}
+	fmt.Printf("migrated %d pieces of file data\n", migrated)
+}
+
+// initialize loads the environment variables and sets up the server.
+func initialize() {
+	err := godotenv.Load()
 	if err != nil {
 		log.Fatalf("error getting env, not comming through %v", err)
 	} else {
 		fmt.Println("loaded env values")
 	}
 
+	store, err := newBlobStore()
+	if err != nil {
+		log.Fatalln("can't set up storage:", err)
+	}
+
+	server.Scanner, err = newScanner()
+	if err != nil {
+		log.Fatalln("can't set up malware scanning:", err)
+	}
+
 	server.Initialize(
 		os.Getenv("DB_PATH"),
 		os.Getenv("API_PATH"),
 		os.Getenv("FS_PATH"),
+		store,
 	)
+}
 
-	server.Run(fmt.Sprintf("0.0.0.0:%s", os.Getenv("PORT")))
+// newBlobStore sets up the storage of file data from the environment variables.
+// A nil BlobStore stores the data in the FS_PATH folder.
+func newBlobStore() (filesystem.BlobStore, error) {
+	var store filesystem.BlobStore
+	switch os.Getenv("STORAGE_DRIVER") {
+	case "", "local":
+	case "s3":
+		store = &filesystem.S3BlobStore{
+			Endpoint:        os.Getenv("S3_ENDPOINT"),
+			Region:          os.Getenv("S3_REGION"),
+			Bucket:          os.Getenv("S3_BUCKET"),
+			AccessKeyID:     os.Getenv("S3_ACCESS_KEY_ID"),
+			SecretAccessKey: os.Getenv("S3_SECRET_ACCESS_KEY"),
+		}
+	default:
+		return nil, fmt.Errorf("unknown storage driver %s", os.Getenv("STORAGE_DRIVER"))
+	}
+
+	if os.Getenv("ENCRYPTION_KEY") == "" {
+		return store, nil
+	}
+
+	if store == nil {
+		store = &filesystem.LocalBlobStore{Fs: afero.NewOsFs(), Path: os.Getenv("FS_PATH")}
+	}
+	encryptedStore := &filesystem.EncryptedBlobStore{Store: store}
+
+	var err error
+	encryptedStore.MasterKey, err = base64.StdEncoding.DecodeString(os.Getenv("ENCRYPTION_KEY"))
+	if err != nil {
+		return nil, err
+	}
+	for _, oldKey := range strings.Split(os.Getenv("OLD_ENCRYPTION_KEYS"), ",") {
+		oldKey = strings.TrimSpace(oldKey)
+		if oldKey == "" {
+			continue
+		}
+
+		masterKey, err := base64.StdEncoding.DecodeString(oldKey)
+		if err != nil {
+			return nil, err
+		}
+		encryptedStore.OldMasterKeys = append(encryptedStore.OldMasterKeys, masterKey)
+	}
+	if len(encryptedStore.MasterKey) != 32 {
+		return nil, filesystem.ErrInvalidMasterKey
+	}
+	return encryptedStore, nil
+}
+
+// newScanner sets up the malware scanning of uploaded file data from the environment variables.
+func newScanner() (scanner.Scanner, error) {
+	switch os.Getenv("SCANNER_DRIVER") {
+	case "", "none":
+		return scanner.NoopScanner{}, nil
+	case "clamd":
+		timeout := time.Duration(0)
+		if os.Getenv("CLAMD_TIMEOUT") != "" {
+			var err error
+			timeout, err = time.ParseDuration(os.Getenv("CLAMD_TIMEOUT"))
+			if err != nil {
+				return nil, err
+			}
+		}
+		return &scanner.ClamdScanner{
+			Network: os.Getenv("CLAMD_NETWORK"),
+			Address: os.Getenv("CLAMD_ADDRESS"),
+			Timeout: timeout,
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown scanner driver %s", os.Getenv("SCANNER_DRIVER"))
+	}
 }
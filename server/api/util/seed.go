@@ -13,6 +13,10 @@ func Load(db *gorm.DB) {
 	if err != nil {
 		log.Fatalf("cannot drop table: %v", err)
 	}
+	err = models.SetupJoinTables(db)
+	if err != nil {
+		log.Fatalf("cannot set up join tables: %v", err)
+	}
 	err = db.AutoMigrate(&models.User{}, &models.UserRole{}, &models.AccessRole{})
 	if err != nil {
 		log.Fatalf("cannot migrate table: %v", err)
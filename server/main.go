@@ -1,9 +1,29 @@
 package main
 
 import (
+	"os"
+
 	"github.com/vincetiu8/penn-spark-server/api"
 )
 
+// main runs the server, or runs a maintenance command given as an argument.
+// The fsck command checks the integrity of the stored file data.
+// The rotate-keys command rewraps the data keys of encrypted file data with the current master key.
+// The migrate-blobs command moves file data stored by file id to storage by checksum.
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "fsck":
+			api.Fsck()
+			return
+		case "rotate-keys":
+			api.RotateKeys()
+			return
+		case "migrate-blobs":
+			api.MigrateBlobs()
+			return
+		}
+	}
+
 	api.Run()
 }
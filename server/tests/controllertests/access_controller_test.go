@@ -0,0 +1,103 @@
+package controllertests
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/invincibot/penn-spark-server/api/models"
+)
+
+func TestGetFolderAccess(t *testing.T) {
+	err := testServer.SeedData()
+	require.NoError(t, err)
+
+	users := testServer.Data.Users
+	folders := testServer.Data.Folders
+
+	inheritable := true
+	users[3], err = testServer.GrantAccessRole(users[3], models.AccessRole{
+		FolderID:    folders[0].ID,
+		AccessLevel: models.Viewer,
+		Inheritable: &inheritable,
+	})
+	require.NoError(t, err)
+	users[3], err = testServer.GrantAccess(users[3], folders[2].ID, models.None)
+	require.NoError(t, err)
+
+	testCases := []struct {
+		user           models.User
+		id             uint
+		query          string
+		statusCode     int
+		expectedLevel  models.AccessLevel
+		expectedReason models.AccessReason
+	}{
+		{
+			user:           users[3],
+			id:             folders[1].ID,
+			statusCode:     http.StatusOK,
+			expectedLevel:  models.Viewer,
+			expectedReason: models.ReasonInherited,
+		},
+		{
+			user:           users[3],
+			id:             folders[2].ID,
+			statusCode:     http.StatusOK,
+			expectedLevel:  models.None,
+			expectedReason: models.ReasonDenied,
+		},
+		{
+			// Admins can explain the access of other users.
+			user:           users[0],
+			id:             folders[2].ID,
+			query:          fmt.Sprintf("?user_id=%d", users[3].ID),
+			statusCode:     http.StatusOK,
+			expectedLevel:  models.None,
+			expectedReason: models.ReasonDenied,
+		},
+		{
+			user:       users[3],
+			id:         folders[2].ID,
+			query:      fmt.Sprintf("?user_id=%d", users[0].ID),
+			statusCode: http.StatusForbidden,
+		},
+		{
+			user:       users[0],
+			id:         folders[2].ID,
+			query:      "?user_id=999",
+			statusCode: http.StatusBadRequest,
+		},
+		{
+			user:       users[3],
+			id:         999,
+			statusCode: http.StatusBadRequest,
+		},
+	}
+
+	for i, testCase := range testCases {
+		req, err := http.NewRequest("GET", "/access"+testCase.query, nil)
+		require.NoError(t, err)
+		req = mux.SetURLVars(req, map[string]string{"id": fmt.Sprint(testCase.id)})
+		rr := httptest.NewRecorder()
+		testServer.Server.GetFolderAccess(rr, req, testCase.user)
+		require.Equal(t, testCase.statusCode, rr.Code, i)
+		if testCase.statusCode != http.StatusOK {
+			continue
+		}
+
+		var decision models.AccessDecision
+		err = json.Unmarshal(rr.Body.Bytes(), &decision)
+		require.NoError(t, err)
+		assert.Equal(t, testCase.id, decision.FolderID, i)
+		assert.Equal(t, testCase.expectedLevel, decision.AccessLevel, i)
+		assert.Equal(t, testCase.expectedReason, decision.Reason, i)
+		assert.NotEmpty(t, decision.Explanation, i)
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/stretchr/testify/assert"
@@ -166,6 +167,29 @@ func TestUpdateAccessRole(t *testing.T) {
 			}
 		}
 	}
+
+	// Grant window bounds set to null are cleared, while the ones left out are kept.
+	startsAt := time.Now().Add(-time.Hour).Round(time.Second)
+	expiresAt := time.Now().Add(time.Hour).Round(time.Second)
+	_, err = models.UpdateAccessRole(testServer.Server.DB, models.AccessRole{
+		ID:          accessRole.ID,
+		GrantWindow: models.GrantWindow{StartsAt: &startsAt, ExpiresAt: &expiresAt},
+	})
+	require.NoError(t, err)
+
+	req, err := http.NewRequest("PUT", "/access-roles", bytes.NewBufferString(`{"expires_at": null}`))
+	require.NoError(t, err)
+	req = mux.SetURLVars(req, map[string]string{"id": fmt.Sprint(accessRole.ID)})
+	rr := httptest.NewRecorder()
+	testServer.Server.UpdateAccessRole(rr, req, models.User{})
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+	updatedRole, err := models.GetAccessRoleByID(testServer.Server.DB, accessRole.ID)
+	require.NoError(t, err)
+	if assert.NotNil(t, updatedRole.StartsAt) {
+		assert.True(t, startsAt.Equal(*updatedRole.StartsAt))
+	}
+	assert.Nil(t, updatedRole.ExpiresAt)
 }
 
 func TestDeleteAccessRole(t *testing.T) {
@@ -207,3 +231,100 @@ func TestDeleteAccessRole(t *testing.T) {
 		}
 	}
 }
+
+func TestCreateDirectAccessRole(t *testing.T) {
+	err := testServer.SeedData()
+	require.NoError(t, err)
+
+	users := testServer.Data.Users
+	folders := testServer.Data.Folders
+	userRole := testServer.Data.UserRoles[0]
+
+	testCases := []struct {
+		id              uint
+		inputAccessRole models.AccessRole
+		statusCode      int
+		expectedErr     error
+	}{
+		{
+			id: users[3].ID,
+			inputAccessRole: models.AccessRole{
+				FolderID:    folders[1].ID,
+				AccessLevel: models.Viewer,
+			},
+			statusCode: http.StatusCreated,
+		},
+		{
+			id: users[3].ID,
+			inputAccessRole: models.AccessRole{
+				FolderID:    folders[1].ID,
+				AccessLevel: models.Uploader,
+			},
+			statusCode:  http.StatusBadRequest,
+			expectedErr: models.ErrAccessRoleAlreadyExists,
+		},
+		{
+			id: users[3].ID,
+			inputAccessRole: models.AccessRole{
+				FolderID:    folders[2].ID,
+				UserRoleID:  userRole.ID,
+				AccessLevel: models.Viewer,
+			},
+			statusCode:  http.StatusBadRequest,
+			expectedErr: models.ErrInvalidAccessRoleTarget,
+		},
+		{
+			id: 999,
+			inputAccessRole: models.AccessRole{
+				FolderID:    folders[1].ID,
+				AccessLevel: models.Viewer,
+			},
+			statusCode:  http.StatusBadRequest,
+			expectedErr: models.ErrUserNotFound,
+		},
+	}
+
+	for _, testCase := range testCases {
+		inputJSON := util.AccessRoleToJSON(testCase.inputAccessRole)
+		req, err := http.NewRequest("POST", "/access-roles", bytes.NewBufferString(inputJSON))
+		require.NoError(t, err)
+		req = mux.SetURLVars(req, map[string]string{"id": fmt.Sprint(testCase.id)})
+		rr := httptest.NewRecorder()
+		testServer.Server.CreateDirectAccessRole(rr, req, users[0])
+
+		responseMap := make(map[string]interface{})
+		err = json.Unmarshal([]byte(rr.Body.String()), &responseMap)
+		require.NoError(t, err)
+		if assert.Equal(t, testCase.statusCode, rr.Code) {
+			switch testCase.statusCode {
+			case http.StatusCreated:
+				testCase.inputAccessRole.UserID = testCase.id
+				util.CheckAccessRolesEqual(t, testCase.inputAccessRole, responseMap)
+			case http.StatusBadRequest:
+				assert.Equal(t, testCase.expectedErr.Error(), responseMap["error"])
+			}
+		}
+	}
+
+	req, err := http.NewRequest("GET", "/access-roles", nil)
+	require.NoError(t, err)
+	req = mux.SetURLVars(req, map[string]string{"id": fmt.Sprint(users[3].ID)})
+	rr := httptest.NewRecorder()
+	testServer.Server.GetDirectAccessRoles(rr, req, users[0])
+	require.Equal(t, http.StatusOK, rr.Code)
+	var accessRoles []models.AccessRole
+	err = json.Unmarshal(rr.Body.Bytes(), &accessRoles)
+	require.NoError(t, err)
+	if assert.Len(t, accessRoles, 1) {
+		assert.Equal(t, folders[1].ID, accessRoles[0].FolderID)
+		assert.Equal(t, users[3].ID, accessRoles[0].UserID)
+	}
+
+	// The user can view the folder through the direct grant.
+	req, err = http.NewRequest("GET", "/folders", nil)
+	require.NoError(t, err)
+	req = mux.SetURLVars(req, map[string]string{"id": fmt.Sprint(folders[1].ID)})
+	rr = httptest.NewRecorder()
+	testServer.Server.GetFolderByID(rr, req, users[3])
+	assert.Equal(t, http.StatusOK, rr.Code)
+}
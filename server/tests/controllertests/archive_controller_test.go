@@ -0,0 +1,137 @@
+package controllertests
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/invincibot/penn-spark-server/api/controllers"
+	"github.com/invincibot/penn-spark-server/api/models"
+)
+
+func TestGetFolderArchive(t *testing.T) {
+	testServer.RefreshFileSystem()
+
+	err := testServer.SeedData()
+	require.NoError(t, err)
+
+	users := testServer.Data.Users
+	folders := testServer.Data.Folders
+	files := testServer.Data.Files
+
+	uploadFileData(t, files[0].ID, users[0], "one")
+	uploadFileData(t, files[1].ID, users[1], "two")
+	uploadFileData(t, files[2].ID, users[2], "three")
+
+	for _, folder := range folders {
+		users[2], err = testServer.GrantAccess(users[2], folder.ID, models.Viewer)
+		require.NoError(t, err)
+	}
+	users[1], err = testServer.GrantAccess(users[1], folders[1].ID, models.Viewer)
+	require.NoError(t, err)
+	users[1], err = testServer.GrantAccess(users[1], folders[2].ID, models.Viewer)
+	require.NoError(t, err)
+
+	// Folder2 is hidden from the user, but the rest of the tree isn't.
+	users[3], err = testServer.GrantAccess(users[3], folders[0].ID, models.Viewer)
+	require.NoError(t, err)
+	users[3], err = testServer.GrantAccess(users[3], folders[1].ID, models.Viewer)
+	require.NoError(t, err)
+
+	testCases := []struct {
+		id            uint
+		user          models.User
+		statusCode    int
+		expectedFiles map[string]string
+		expectedErr   error
+	}{
+		{
+			// The draft file is only visible to publishers and its uploader.
+			id:         folders[0].ID,
+			user:       users[2],
+			statusCode: http.StatusOK,
+			expectedFiles: map[string]string{
+				"root/":                      "",
+				"root/file1":                 "one",
+				"root/folder1/":              "",
+				"root/folder1/folder2/":      "",
+				"root/folder1/folder2/file3": "three",
+			},
+		},
+		{
+			id:         folders[1].ID,
+			user:       users[1],
+			statusCode: http.StatusOK,
+			expectedFiles: map[string]string{
+				"folder1/":              "",
+				"folder1/file2":         "two",
+				"folder1/folder2/":      "",
+				"folder1/folder2/file3": "three",
+			},
+		},
+		{
+			id:         folders[0].ID,
+			user:       users[3],
+			statusCode: http.StatusOK,
+			expectedFiles: map[string]string{
+				"root/":         "",
+				"root/file1":    "one",
+				"root/folder1/": "",
+			},
+		},
+		{
+			id:          folders[2].ID,
+			user:        users[3],
+			statusCode:  http.StatusForbidden,
+			expectedErr: controllers.ErrUserForbidden,
+		},
+		{
+			id:          999,
+			user:        users[0],
+			statusCode:  http.StatusBadRequest,
+			expectedErr: models.ErrFolderNotFound,
+		},
+	}
+
+	for _, testCase := range testCases {
+		req, err := http.NewRequest("GET", "/folders/archive", nil)
+		require.NoError(t, err)
+		req = mux.SetURLVars(req, map[string]string{"id": fmt.Sprint(testCase.id)})
+		rr := httptest.NewRecorder()
+		testServer.Server.GetFolderArchive(rr, req, testCase.user)
+
+		if assert.Equal(t, testCase.statusCode, rr.Code) {
+			switch testCase.statusCode {
+			case http.StatusOK:
+				assert.Equal(t, "application/zip", rr.Header().Get("Content-Type"))
+				archive, err := zip.NewReader(bytes.NewReader(rr.Body.Bytes()), int64(rr.Body.Len()))
+				require.NoError(t, err)
+
+				actualFiles := map[string]string{}
+				for _, entry := range archive.File {
+					reader, err := entry.Open()
+					require.NoError(t, err)
+					data, err := ioutil.ReadAll(reader)
+					require.NoError(t, err)
+					reader.Close()
+					actualFiles[entry.Name] = string(data)
+				}
+				assert.Equal(t, testCase.expectedFiles, actualFiles)
+			default:
+				responseMap := make(map[string]interface{})
+				err = json.Unmarshal(rr.Body.Bytes(), &responseMap)
+				require.NoError(t, err)
+				assert.Equal(t, testCase.expectedErr.Error(), responseMap["error"])
+			}
+		}
+	}
+}
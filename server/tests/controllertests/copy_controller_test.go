@@ -0,0 +1,277 @@
+package controllertests
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/invincibot/penn-spark-server/api/controllers"
+	"github.com/invincibot/penn-spark-server/api/filesystem"
+	"github.com/invincibot/penn-spark-server/api/models"
+)
+
+// copyRequest sends a copy request to the given handler with the given body and returns the response.
+func copyRequest(t *testing.T, handler func(http.ResponseWriter, *http.Request, models.User), user models.User,
+	id uint, body string) *httptest.ResponseRecorder {
+	req, err := http.NewRequest("POST", "/copy", bytes.NewBufferString(body))
+	require.NoError(t, err)
+	req = mux.SetURLVars(req, map[string]string{"id": fmt.Sprint(id)})
+	rr := httptest.NewRecorder()
+	handler(rr, req, user)
+	return rr
+}
+
+func TestCopyFile(t *testing.T) {
+	err := testServer.SeedData()
+	require.NoError(t, err)
+
+	users := testServer.Data.Users
+	folders := testServer.Data.Folders
+	files := testServer.Data.Files
+
+	users[0], err = testServer.GrantAccess(users[0], folders[0].ID, models.Publisher)
+	require.NoError(t, err)
+	users[1], err = testServer.GrantAccess(users[1], folders[0].ID, models.Viewer)
+	require.NoError(t, err)
+	users[1], err = testServer.GrantAccess(users[1], folders[1].ID, models.Uploader)
+	require.NoError(t, err)
+
+	testCases := []struct {
+		user              models.User
+		id                uint
+		body              string
+		statusCode        int
+		expectedName      string
+		expectedFolderID  uint
+		expectedPublished bool
+	}{
+		{
+			// Copies made by uploaders are drafts.
+			user:             users[1],
+			id:               files[0].ID,
+			body:             fmt.Sprintf(`{"folder_id": %d}`, folders[1].ID),
+			statusCode:       http.StatusCreated,
+			expectedName:     files[0].Name,
+			expectedFolderID: folders[1].ID,
+		},
+		{
+			user:              users[0],
+			id:                files[0].ID,
+			body:              fmt.Sprintf(`{"folder_id": %d, "name": "file1 copy"}`, folders[0].ID),
+			statusCode:        http.StatusCreated,
+			expectedName:      "file1 copy",
+			expectedFolderID:  folders[0].ID,
+			expectedPublished: true,
+		},
+		{
+			user:       users[0],
+			id:         files[0].ID,
+			body:       fmt.Sprintf(`{"folder_id": %d}`, folders[0].ID),
+			statusCode: http.StatusBadRequest,
+		},
+		{
+			user:       users[1],
+			id:         files[0].ID,
+			body:       fmt.Sprintf(`{"folder_id": %d, "name": "file1 copy 2"}`, folders[0].ID),
+			statusCode: http.StatusForbidden,
+		},
+		{
+			// Drafts can only be copied by their uploader and publishers.
+			user:       users[0],
+			id:         files[1].ID,
+			body:       fmt.Sprintf(`{"folder_id": %d}`, folders[0].ID),
+			statusCode: http.StatusForbidden,
+		},
+		{
+			user:       users[0],
+			id:         files[0].ID,
+			body:       `{"name": "file1 copy 3"}`,
+			statusCode: http.StatusBadRequest,
+		},
+		{
+			user:       users[0],
+			id:         999,
+			body:       fmt.Sprintf(`{"folder_id": %d}`, folders[0].ID),
+			statusCode: http.StatusBadRequest,
+		},
+		{
+			user:       users[0],
+			id:         files[0].ID,
+			body:       `folder`,
+			statusCode: http.StatusUnprocessableEntity,
+		},
+	}
+
+	for i, testCase := range testCases {
+		rr := copyRequest(t, testServer.Server.CopyFile, testCase.user, testCase.id, testCase.body)
+		require.Equal(t, testCase.statusCode, rr.Code, i)
+		if testCase.statusCode != http.StatusCreated {
+			continue
+		}
+
+		var file models.File
+		err = json.Unmarshal(rr.Body.Bytes(), &file)
+		require.NoError(t, err)
+		assert.NotEqual(t, testCase.id, file.ID, i)
+		assert.Equal(t, testCase.expectedName, file.Name, i)
+		assert.Equal(t, testCase.expectedFolderID, file.FolderID, i)
+		assert.Equal(t, testCase.expectedPublished, file.IsPublished, i)
+		assert.Equal(t, testCase.user.ID, file.LastEditorID, i)
+	}
+}
+
+func TestCopyFolder(t *testing.T) {
+	err := testServer.SeedData()
+	require.NoError(t, err)
+
+	users := testServer.Data.Users
+	folders := testServer.Data.Folders
+	files := testServer.Data.Files
+
+	users[0], err = testServer.GrantAccess(users[0], folders[0].ID, models.Publisher)
+	require.NoError(t, err)
+	users[1], err = testServer.GrantAccess(users[1], folders[0].ID, models.Uploader)
+	require.NoError(t, err)
+	users[1], err = testServer.GrantAccess(users[1], folders[1].ID, models.Viewer)
+	require.NoError(t, err)
+
+	// Drafts the user can't see aren't copied.
+	rr := copyRequest(t, testServer.Server.CopyFolder, users[0], folders[1].ID,
+		fmt.Sprintf(`{"folder_id": %d, "name": "project"}`, folders[0].ID))
+	require.Equal(t, http.StatusCreated, rr.Code)
+	var folder models.Folder
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &folder))
+	assert.Equal(t, "project", folder.Name)
+	assert.Equal(t, folders[0].ID, *folder.ParentFolderID)
+	assert.Len(t, folder.Files, 0)
+	assert.Len(t, folder.AccessRoles, 0)
+	if assert.Len(t, folder.ChildFolders, 1) {
+		childFolder, err := models.GetFolderByID(testServer.Server.DB, folder.ChildFolders[0].ID)
+		require.NoError(t, err)
+		assert.Equal(t, folders[2].Name, childFolder.Name)
+		if assert.Len(t, childFolder.Files, 1) {
+			assert.Equal(t, files[2].Name, childFolder.Files[0].Name)
+		}
+	}
+
+	// Admins can copy the access roles of the folders as well.
+	rr = copyRequest(t, testServer.Server.CopyFolder, users[0], folders[1].ID,
+		fmt.Sprintf(`{"folder_id": %d, "name": "project with roles", "copy_access_roles": true}`, folders[0].ID))
+	require.Equal(t, http.StatusCreated, rr.Code)
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &folder))
+	original, err := models.GetFolderByID(testServer.Server.DB, folders[1].ID)
+	require.NoError(t, err)
+	assert.Len(t, folder.AccessRoles, len(original.AccessRoles))
+
+	testCases := []struct {
+		user       models.User
+		id         uint
+		body       string
+		statusCode int
+	}{
+		{
+			// Only publishers can create folders in the destination.
+			user:       users[1],
+			id:         folders[1].ID,
+			body:       fmt.Sprintf(`{"folder_id": %d, "name": "copy"}`, folders[0].ID),
+			statusCode: http.StatusForbidden,
+		},
+		{
+			user:       users[1],
+			id:         folders[1].ID,
+			body:       fmt.Sprintf(`{"folder_id": %d, "name": "copy", "copy_access_roles": true}`, folders[0].ID),
+			statusCode: http.StatusForbidden,
+		},
+		{
+			user:       users[2],
+			id:         folders[1].ID,
+			body:       fmt.Sprintf(`{"folder_id": %d, "name": "copy"}`, folders[0].ID),
+			statusCode: http.StatusForbidden,
+		},
+		{
+			// Names must be unique in the destination.
+			user:       users[0],
+			id:         folders[1].ID,
+			body:       fmt.Sprintf(`{"folder_id": %d}`, folders[0].ID),
+			statusCode: http.StatusBadRequest,
+		},
+		{
+			user:       users[0],
+			id:         999,
+			body:       fmt.Sprintf(`{"folder_id": %d, "name": "copy"}`, folders[0].ID),
+			statusCode: http.StatusBadRequest,
+		},
+		{
+			user:       users[0],
+			id:         folders[1].ID,
+			body:       `{"name": "copy"}`,
+			statusCode: http.StatusBadRequest,
+		},
+	}
+
+	for i, testCase := range testCases {
+		rr := copyRequest(t, testServer.Server.CopyFolder, testCase.user, testCase.id, testCase.body)
+		assert.Equal(t, testCase.statusCode, rr.Code, i)
+	}
+}
+
+func TestCopyUploadPolicy(t *testing.T) {
+	testServer.RefreshFileSystem()
+
+	err := testServer.SeedData()
+	require.NoError(t, err)
+
+	users := testServer.Data.Users
+	folders := testServer.Data.Folders
+	files := testServer.Data.Files
+
+	for _, folder := range folders {
+		users[0], err = testServer.GrantAccess(users[0], folder.ID, models.Publisher)
+		require.NoError(t, err)
+	}
+	uploadFileData(t, files[0].ID, users[0], "some file data")
+	err = afero.WriteFile(testServer.Server.FileSystem, testServer.Server.FileSystem.FilePath+"/"+fmt.Sprint(files[2].ID),
+		[]byte("legacy file data"), 0666)
+	require.NoError(t, err)
+
+	_, err = models.UpsertUploadPolicy(testServer.Server.DB, models.UploadPolicy{
+		FolderID:     folders[1].ID,
+		MaxFileSize:  4,
+		LastEditorID: users[0].ID,
+	})
+	require.NoError(t, err)
+
+	// Copies must be allowed by the upload policy of the destination.
+	rr := copyRequest(t, testServer.Server.CopyFile, users[0], files[0].ID, fmt.Sprintf(`{"folder_id": %d}`, folders[1].ID))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rr.Code)
+
+	// Data stored before versioning was introduced is copied as well.
+	rr = copyRequest(t, testServer.Server.CopyFile, users[0], files[2].ID, fmt.Sprintf(`{"folder_id": %d}`, folders[0].ID))
+	require.Equal(t, http.StatusCreated, rr.Code)
+	var file models.File
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &file))
+	version, err := models.GetLatestFileVersion(testServer.Server.DB, file.ID)
+	require.NoError(t, err)
+	assert.Equal(t, checksum("legacy file data"), version.Checksum)
+	assert.Equal(t, models.ScanPending, version.ScanStatus)
+
+	// Files the upload policy doesn't allow are left out of folder copies and reported.
+	rr = copyRequest(t, testServer.Server.CopyFolder, users[0], folders[2].ID,
+		fmt.Sprintf(`{"folder_id": %d, "name": "copy"}`, folders[1].ID))
+	require.Equal(t, http.StatusCreated, rr.Code)
+	var report controllers.FolderCopyReport
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &report))
+	assert.Equal(t, "copy", report.Name)
+	assert.Len(t, report.Files, 0)
+	assert.Equal(t, []controllers.CopyConflict{
+		{FileID: files[2].ID, Name: files[2].Name, Error: filesystem.ErrFileTooLarge.Error()},
+	}, report.Conflicts)
+}
@@ -0,0 +1,109 @@
+package controllertests
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/invincibot/penn-spark-server/api/models"
+)
+
+// effectiveAccessRequest sends a request for an effective access report to the given handler and returns the response.
+func effectiveAccessRequest(t *testing.T, handler func(http.ResponseWriter, *http.Request, models.User), user models.User,
+	id uint, query string) *httptest.ResponseRecorder {
+	req, err := http.NewRequest("GET", "/effective-access"+query, nil)
+	require.NoError(t, err)
+	req = mux.SetURLVars(req, map[string]string{"id": fmt.Sprint(id)})
+	rr := httptest.NewRecorder()
+	handler(rr, req, user)
+	return rr
+}
+
+func TestGetFolderEffectiveAccess(t *testing.T) {
+	err := testServer.SeedData()
+	require.NoError(t, err)
+
+	users := testServer.Data.Users
+	folders := testServer.Data.Folders
+
+	users[3], err = testServer.GrantAccess(users[3], folders[1].ID, models.Publisher)
+	require.NoError(t, err)
+
+	rr := effectiveAccessRequest(t, testServer.Server.GetFolderEffectiveAccess, users[0], folders[1].ID, "")
+	require.Equal(t, http.StatusOK, rr.Code)
+	var effectiveAccess []models.EffectiveAccess
+	err = json.Unmarshal(rr.Body.Bytes(), &effectiveAccess)
+	require.NoError(t, err)
+	require.Len(t, effectiveAccess, len(users))
+	assert.Equal(t, users[3].ID, effectiveAccess[3].UserID)
+	assert.Equal(t, models.Publisher, effectiveAccess[3].AccessLevel)
+	assert.Equal(t, models.ReasonGranted, effectiveAccess[3].Reason)
+
+	// The report can be exported as CSV, with a header row.
+	rr = effectiveAccessRequest(t, testServer.Server.GetFolderEffectiveAccess, users[0], folders[1].ID, "?format=csv")
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "text/csv; charset=utf-8", rr.Header().Get("Content-Type"))
+	assert.Contains(t, rr.Header().Get("Content-Disposition"), "attachment")
+	records, err := csv.NewReader(rr.Body).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, records, len(users)+1)
+	assert.Equal(t, "user_id", records[0][0])
+	assert.Equal(t, []string{fmt.Sprint(users[3].ID), users[3].Username, fmt.Sprint(folders[1].ID), folders[1].Name,
+		"publisher", "granted"}, records[4][:6])
+
+	// Names that spreadsheets would run as formulas are exported as text.
+	err = testServer.Server.DB.Model(&folders[1]).Update("name", "=HYPERLINK(\"http://example.com\")").Error
+	require.NoError(t, err)
+	rr = effectiveAccessRequest(t, testServer.Server.GetFolderEffectiveAccess, users[0], folders[1].ID, "?format=csv")
+	require.Equal(t, http.StatusOK, rr.Code)
+	records, err = csv.NewReader(rr.Body).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, records, len(users)+1)
+	assert.Equal(t, "'=HYPERLINK(\"http://example.com\")", records[4][3])
+
+	rr = effectiveAccessRequest(t, testServer.Server.GetFolderEffectiveAccess, users[0], folders[1].ID, "?format=xml")
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	rr = effectiveAccessRequest(t, testServer.Server.GetFolderEffectiveAccess, users[0], 999, "")
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
+
+func TestGetUserEffectiveAccess(t *testing.T) {
+	err := testServer.SeedData()
+	require.NoError(t, err)
+
+	users := testServer.Data.Users
+	folders := testServer.Data.Folders
+
+	users[3], err = testServer.GrantAccess(users[3], folders[2].ID, models.Viewer)
+	require.NoError(t, err)
+
+	rr := effectiveAccessRequest(t, testServer.Server.GetUserEffectiveAccess, users[0], users[3].ID, "")
+	require.Equal(t, http.StatusOK, rr.Code)
+	var effectiveAccess []models.EffectiveAccess
+	err = json.Unmarshal(rr.Body.Bytes(), &effectiveAccess)
+	require.NoError(t, err)
+	if assert.Len(t, effectiveAccess, 1) {
+		assert.Equal(t, folders[2].ID, effectiveAccess[0].FolderID)
+		assert.Equal(t, models.Viewer, effectiveAccess[0].AccessLevel)
+	}
+
+	rr = effectiveAccessRequest(t, testServer.Server.GetUserEffectiveAccess, users[0], users[3].ID, "?format=csv")
+	require.Equal(t, http.StatusOK, rr.Code)
+	records, err := csv.NewReader(rr.Body).ReadAll()
+	require.NoError(t, err)
+	if assert.Len(t, records, 2) {
+		assert.Equal(t, fmt.Sprint(folders[2].ID), records[1][2])
+		assert.Equal(t, "viewer", records[1][4])
+	}
+
+	rr = effectiveAccessRequest(t, testServer.Server.GetUserEffectiveAccess, users[0], 999, "")
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
@@ -0,0 +1,118 @@
+package controllertests
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/invincibot/penn-spark-server/api/extract"
+)
+
+// docxDocument builds a DOCX document with the given paragraphs.
+func docxDocument(t *testing.T, paragraphs ...string) []byte {
+	var body strings.Builder
+	for _, paragraph := range paragraphs {
+		fmt.Fprintf(&body, `<w:p><w:r><w:t>%s</w:t></w:r></w:p>`, paragraph)
+	}
+
+	var data bytes.Buffer
+	archive := zip.NewWriter(&data)
+	writer, err := archive.Create("word/document.xml")
+	require.NoError(t, err)
+	_, err = fmt.Fprintf(writer, `<?xml version="1.0" encoding="UTF-8"?>`+
+		`<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"><w:body>%s</w:body></w:document>`,
+		body.String())
+	require.NoError(t, err)
+	require.NoError(t, archive.Close())
+	return data.Bytes()
+}
+
+// pdfDocument builds a PDF document with a compressed content stream showing the given text operators.
+func pdfDocument(t *testing.T, content string) []byte {
+	var compressed bytes.Buffer
+	writer := zlib.NewWriter(&compressed)
+	_, err := writer.Write([]byte(content))
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+
+	var data bytes.Buffer
+	data.WriteString("%PDF-1.4\n1 0 obj\n<< /Type /Catalog >>\nendobj\n")
+	fmt.Fprintf(&data, "4 0 obj\n<< /Length %d /Filter /FlateDecode >>\nstream\n", compressed.Len())
+	data.Write(compressed.Bytes())
+	data.WriteString("\nendstream\nendobj\n%%EOF\n")
+	return data.Bytes()
+}
+
+func TestExtractText(t *testing.T) {
+	testCases := []struct {
+		data         []byte
+		contentType  string
+		expectedText string
+		expectedErr  error
+	}{
+		{
+			data:         []byte("Meeting  notes\n\n- budget\tplan"),
+			contentType:  "text/plain; charset=utf-8",
+			expectedText: "Meeting notes - budget plan",
+		},
+		{
+			data:         []byte("# Title\n\nSome *markdown*"),
+			contentType:  "text/markdown; charset=utf-8",
+			expectedText: "# Title Some *markdown*",
+		},
+		{
+			data:        []byte("Latin-1 text"),
+			contentType: "text/plain; charset=windows-1252",
+			expectedErr: extract.ErrUnsupportedContentType,
+		},
+		{
+			data: []byte(`<html><head><title>Hidden</title></head><body><!-- comment -->` +
+				`<script>var x = 1;</script><p>Fish &amp; chips</p><p>served</p></body></html>`),
+			contentType:  "text/html; charset=utf-8",
+			expectedText: "Fish & chips served",
+		},
+		{
+			data:         docxDocument(t, "First paragraph", "Second &amp; last"),
+			contentType:  "application/vnd.openxmlformats-officedocument.wordprocessingml.document",
+			expectedText: "First paragraph Second & last",
+		},
+		{
+			data:         pdfDocument(t, "BT /F1 12 Tf 72 720 Td (Quarterly \\(Q3\\) report) Tj T* [(Reven) 20 (ue) -300 (grew)] TJ ET"),
+			contentType:  "application/pdf",
+			expectedText: "Quarterly (Q3) report Revenue grew",
+		},
+		{
+			data:         pdfDocument(t, "BT <48656c6c6f> Tj ET"),
+			contentType:  "application/pdf",
+			expectedText: "Hello",
+		},
+		{
+			data:        []byte{0x89, 'P', 'N', 'G'},
+			contentType: "image/png",
+			expectedErr: extract.ErrUnsupportedContentType,
+		},
+		{
+			data:        []byte("data"),
+			contentType: "",
+			expectedErr: extract.ErrUnsupportedContentType,
+		},
+	}
+
+	for _, testCase := range testCases {
+		text, err := extract.Text(bytes.NewReader(testCase.data), testCase.contentType)
+		assert.Equal(t, testCase.expectedErr, err, testCase.contentType)
+		assert.Equal(t, testCase.expectedText, text, testCase.contentType)
+	}
+
+	// Long text is truncated without splitting a character.
+	text, err := extract.Text(strings.NewReader(strings.Repeat("é", extract.MaxTextLength)), "text/plain")
+	require.NoError(t, err)
+	assert.LessOrEqual(t, len(text), extract.MaxTextLength)
+	assert.True(t, strings.HasSuffix(text, "é"))
+}
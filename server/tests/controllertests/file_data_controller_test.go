@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
@@ -17,6 +18,7 @@ import (
 	"github.com/stretchr/testify/require"
 
 	"github.com/invincibot/penn-spark-server/api/controllers"
+	"github.com/invincibot/penn-spark-server/api/filesystem"
 	"github.com/invincibot/penn-spark-server/api/models"
 	"github.com/invincibot/penn-spark-server/tests/util"
 )
@@ -86,8 +88,12 @@ func TestCreateFileData(t *testing.T) {
 			switch testCase.statusCode {
 			case http.StatusOK:
 				util.CheckFilesEqual(t, file, responseMap)
-				returnedData, err := afero.ReadFile(testServer.Server.FileSystem,
-					testServer.Server.FileSystem.FilePath+"/"+strconv.Itoa(int(file.ID)))
+				version, err := models.GetLatestFileVersion(testServer.Server.DB, file.ID)
+				require.NoError(t, err)
+				assert.Equal(t, int64(len("text")), version.Size)
+				versionData, err := testServer.Server.FileSystem.GetBlobRaw(version.Checksum)
+				require.NoError(t, err)
+				returnedData, err := ioutil.ReadAll(versionData)
 				require.NoError(t, err)
 				assert.Equal(t, []byte("text"), returnedData)
 			case http.StatusBadRequest:
@@ -119,6 +125,22 @@ func TestGetFileData(t *testing.T) {
 	)
 	require.NoError(t, err)
 
+	// Data stored before versioning was introduced isn't sent until it has been scanned.
+	req, err := http.NewRequest("GET", "/file-data", nil)
+	require.NoError(t, err)
+	req = mux.SetURLVars(req, map[string]string{"id": fmt.Sprint(file.ID)})
+	rr := httptest.NewRecorder()
+	testServer.Server.GetFileData(rr, req, users[0])
+	assert.Equal(t, http.StatusConflict, rr.Code)
+
+	version, err := models.GetLatestFileVersion(testServer.Server.DB, file.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.ScanPending, version.ScanStatus)
+	assert.Equal(t, checksum("text"), version.Checksum)
+
+	err = testServer.Server.ScanPendingFileVersions()
+	require.NoError(t, err)
+
 	testCases := []struct {
 		id          uint
 		user        models.User
@@ -169,3 +191,173 @@ func TestGetFileData(t *testing.T) {
 		}
 	}
 }
+
+func TestCreateFileDataTooLarge(t *testing.T) {
+	testServer.RefreshFileSystem()
+
+	err := testServer.SeedData()
+	require.NoError(t, err)
+
+	user := testServer.Data.Users[0]
+	file := testServer.Data.Files[0]
+
+	testServer.Server.MaxUploadSize = int64(len("text"))
+	defer func() {
+		testServer.Server.MaxUploadSize = 0
+	}()
+
+	testCases := []struct {
+		data        string
+		statusCode  int
+		expectedErr error
+	}{
+		{
+			data:       "text",
+			statusCode: http.StatusOK,
+		},
+		{
+			data:        "more text",
+			statusCode:  http.StatusRequestEntityTooLarge,
+			expectedErr: filesystem.ErrFileTooLarge,
+		},
+	}
+
+	for _, testCase := range testCases {
+		var b bytes.Buffer
+		w := multipart.NewWriter(&b)
+		err = w.WriteField("comment", "fields before the file are skipped")
+		require.NoError(t, err)
+		fw, err := w.CreateFormFile("file", file.Name)
+		require.NoError(t, err)
+		_, err = io.Copy(fw, bytes.NewBufferString(testCase.data))
+		require.NoError(t, err)
+		err = w.Close()
+		require.NoError(t, err)
+
+		req, err := http.NewRequest("PUT", "/file-data", &b)
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", w.FormDataContentType())
+		req = mux.SetURLVars(req, map[string]string{"id": fmt.Sprint(file.ID)})
+		rr := httptest.NewRecorder()
+		testServer.Server.CreateFileData(rr, req, user)
+
+		responseMap := make(map[string]interface{})
+		err = json.Unmarshal([]byte(rr.Body.String()), &responseMap)
+		require.NoError(t, err)
+		if assert.Equal(t, testCase.statusCode, rr.Code) {
+			switch testCase.statusCode {
+			case http.StatusRequestEntityTooLarge:
+				assert.Equal(t, testCase.expectedErr.Error(), responseMap["error"])
+			}
+		}
+	}
+
+	// Only the upload within the size limit is stored.
+	versions, err := models.GetFileVersions(testServer.Server.DB, file.ID)
+	require.NoError(t, err)
+	assert.Len(t, versions, 1)
+}
+
+func TestCreateFileDataDeduplicated(t *testing.T) {
+	testServer.RefreshFileSystem()
+
+	err := testServer.SeedData()
+	require.NoError(t, err)
+
+	users := testServer.Data.Users
+	files := testServer.Data.Files
+
+	uploadFileData(t, files[0].ID, users[0], "handbook")
+	uploadFileData(t, files[1].ID, users[1], "handbook")
+
+	// Both files reference the same data, which is only stored once.
+	version, err := models.GetLatestFileVersion(testServer.Server.DB, files[1].ID)
+	require.NoError(t, err)
+	blob, err := models.GetBlob(testServer.Server.DB, version.Checksum)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), blob.RefCount)
+	assert.Equal(t, int64(len("handbook")), blob.Size)
+
+	storedData, err := testServer.Server.FileSystem.ListStoredDataRaw()
+	require.NoError(t, err)
+	if assert.Len(t, storedData, 1) {
+		assert.Equal(t, version.Checksum, storedData[0].Checksum)
+	}
+}
+
+func TestGetFileDataConditional(t *testing.T) {
+	testServer.RefreshFileSystem()
+
+	err := testServer.SeedData()
+	require.NoError(t, err)
+
+	user := testServer.Data.Users[0]
+	file := testServer.Data.Files[0]
+
+	file.Name = "Q&A \"handbook\" é.pdf"
+	file, err = models.UpdateFile(testServer.Server.DB, file)
+	require.NoError(t, err)
+
+	uploadFileData(t, file.ID, user, "handbook")
+	version, err := models.GetLatestFileVersion(testServer.Server.DB, file.ID)
+	require.NoError(t, err)
+	etag := "\"" + version.Checksum + "\""
+	lastModified := version.CreatedAt.UTC().Format(http.TimeFormat)
+
+	testCases := []struct {
+		headers      map[string]string
+		statusCode   int
+		expectedData string
+	}{
+		{
+			statusCode:   http.StatusOK,
+			expectedData: "handbook",
+		},
+		{
+			headers:    map[string]string{"If-None-Match": etag},
+			statusCode: http.StatusNotModified,
+		},
+		{
+			headers:      map[string]string{"If-None-Match": "\"other\""},
+			statusCode:   http.StatusOK,
+			expectedData: "handbook",
+		},
+		{
+			headers:    map[string]string{"If-Modified-Since": lastModified},
+			statusCode: http.StatusNotModified,
+		},
+		{
+			headers:      map[string]string{"Range": "bytes=4-", "If-Range": etag},
+			statusCode:   http.StatusPartialContent,
+			expectedData: "book",
+		},
+		{
+			headers:      map[string]string{"Range": "bytes=4-", "If-Range": "\"other\""},
+			statusCode:   http.StatusOK,
+			expectedData: "handbook",
+		},
+	}
+
+	for _, testCase := range testCases {
+		req, err := http.NewRequest("GET", "/file-data", nil)
+		require.NoError(t, err)
+		for name, value := range testCase.headers {
+			req.Header.Set(name, value)
+		}
+		req = mux.SetURLVars(req, map[string]string{"id": fmt.Sprint(file.ID)})
+		rr := httptest.NewRecorder()
+		testServer.Server.GetFileData(rr, req, user)
+
+		if assert.Equal(t, testCase.statusCode, rr.Code) {
+			assert.Equal(t, testCase.expectedData, rr.Body.String())
+			assert.Equal(t, etag, rr.Header().Get("ETag"))
+			assert.Equal(t, "private, no-cache", rr.Header().Get("Cache-Control"))
+			if testCase.statusCode != http.StatusNotModified {
+				assert.Equal(t, lastModified, rr.Header().Get("Last-Modified"))
+				assert.Equal(t, "text/plain; charset=utf-8", rr.Header().Get("Content-Type"))
+				assert.Equal(t, "inline; filename=\"Q&A _handbook_ _.pdf\"; filename*=UTF-8''Q&A%20%22handbook%22%20%C3%A9.pdf",
+					rr.Header().Get("Content-Disposition"))
+			}
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package controllertests
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/invincibot/penn-spark-server/api/models"
+)
+
+// getFiles sends a file listing request with the given query parameters and returns the response.
+func getFiles(t *testing.T, user models.User, params url.Values) *httptest.ResponseRecorder {
+	req, err := http.NewRequest("GET", "/files?"+params.Encode(), nil)
+	require.NoError(t, err)
+	rr := httptest.NewRecorder()
+	testServer.Server.GetFiles(rr, req, user)
+	return rr
+}
+
+func TestGetFiles(t *testing.T) {
+	err := testServer.SeedData()
+	require.NoError(t, err)
+
+	users := testServer.Data.Users
+	folders := testServer.Data.Folders
+	files := testServer.Data.Files
+
+	// users[0] publishes in the root folder and, as an admin, views the other folders.
+	users[0], err = testServer.GrantAccess(users[0], folders[0].ID, models.Publisher)
+	require.NoError(t, err)
+	users[1], err = testServer.GrantAccess(users[1], folders[1].ID, models.Uploader)
+	require.NoError(t, err)
+	users[2], err = testServer.GrantAccess(users[2], folders[2].ID, models.None)
+	require.NoError(t, err)
+
+	draft, err := models.CreateFile(testServer.Server.DB, models.File{
+		Name:         "draft",
+		FolderID:     folders[0].ID,
+		LastEditorID: users[1].ID,
+	})
+	require.NoError(t, err)
+
+	testCases := []struct {
+		user           models.User
+		params         url.Values
+		expectedStatus int
+		expectedIDs    []uint
+	}{
+		{
+			user:           users[0],
+			params:         url.Values{},
+			expectedStatus: http.StatusOK,
+			expectedIDs:    []uint{draft.ID, files[0].ID, files[2].ID},
+		},
+		{
+			// Drafts awaiting publication across the whole tree.
+			user:           users[0],
+			params:         url.Values{"folder_id": {fmt.Sprint(folders[0].ID)}, "recursive": {"true"}, "published": {"false"}},
+			expectedStatus: http.StatusOK,
+			expectedIDs:    []uint{draft.ID},
+		},
+		{
+			user:           users[0],
+			params:         url.Values{"folder_id": {fmt.Sprint(folders[0].ID)}},
+			expectedStatus: http.StatusOK,
+			expectedIDs:    []uint{draft.ID, files[0].ID},
+		},
+		{
+			// Uploaders only see their own drafts.
+			user:           users[1],
+			params:         url.Values{"folder_id": {fmt.Sprint(folders[1].ID)}, "recursive": {"true"}},
+			expectedStatus: http.StatusOK,
+			expectedIDs:    []uint{files[1].ID},
+		},
+		{
+			user:           users[1],
+			params:         url.Values{"editor_id": {fmt.Sprint(users[1].ID)}, "sort": {"updated_at"}, "order": {"desc"}},
+			expectedStatus: http.StatusOK,
+			expectedIDs:    []uint{files[1].ID},
+		},
+		{
+			user:           users[0],
+			params:         url.Values{"name_prefix": {"file"}, "updated_after": {"2000-01-01T00:00:00Z"}},
+			expectedStatus: http.StatusOK,
+			expectedIDs:    []uint{files[0].ID, files[2].ID},
+		},
+		{
+			user:           users[2],
+			params:         url.Values{},
+			expectedStatus: http.StatusOK,
+			expectedIDs:    []uint{},
+		},
+		{
+			user:           users[2],
+			params:         url.Values{"folder_id": {fmt.Sprint(folders[2].ID)}},
+			expectedStatus: http.StatusForbidden,
+		},
+		{
+			user:           users[0],
+			params:         url.Values{"folder_id": {"100"}},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			user:           users[0],
+			params:         url.Values{"sort": {"size"}},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			user:           users[0],
+			params:         url.Values{"sort": {"name"}, "order": {"up"}},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			user:           users[0],
+			params:         url.Values{"updated_after": {"yesterday"}},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			user:           users[0],
+			params:         url.Values{"limit": {"0"}},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			user:           users[0],
+			params:         url.Values{"cursor": {"invalid"}},
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, testCase := range testCases {
+		rr := getFiles(t, testCase.user, testCase.params)
+		require.Equal(t, testCase.expectedStatus, rr.Code, testCase.params.Encode())
+		if testCase.expectedStatus != http.StatusOK {
+			continue
+		}
+
+		var page []models.File
+		err = json.Unmarshal(rr.Body.Bytes(), &page)
+		require.NoError(t, err)
+
+		ids := []uint{}
+		for _, file := range page {
+			ids = append(ids, file.ID)
+		}
+		assert.Equal(t, testCase.expectedIDs, ids, testCase.params.Encode())
+		assert.Equal(t, fmt.Sprint(len(testCase.expectedIDs)), rr.Header().Get("X-Total-Count"), testCase.params.Encode())
+		assert.Empty(t, rr.Header().Get("X-Next-Cursor"))
+	}
+
+	// The cursor continues from the previous page.
+	rr := getFiles(t, users[0], url.Values{"limit": {"2"}})
+	require.Equal(t, http.StatusOK, rr.Code)
+	var page []models.File
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &page))
+	require.Len(t, page, 2)
+	nextCursor := rr.Header().Get("X-Next-Cursor")
+	require.NotEmpty(t, nextCursor)
+	assert.Equal(t, "3", rr.Header().Get("X-Total-Count"))
+
+	rr = getFiles(t, users[0], url.Values{"limit": {"2"}, "cursor": {nextCursor}})
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &page))
+	require.Len(t, page, 1)
+	assert.Equal(t, files[2].ID, page[0].ID)
+	assert.Empty(t, rr.Header().Get("X-Next-Cursor"))
+}
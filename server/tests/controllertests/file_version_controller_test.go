@@ -0,0 +1,241 @@
+package controllertests
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/invincibot/penn-spark-server/api/controllers"
+	"github.com/invincibot/penn-spark-server/api/models"
+)
+
+func uploadFileData(t *testing.T, fileID uint, user models.User, data string) {
+	var b bytes.Buffer
+	w := multipart.NewWriter(&b)
+	fw, err := w.CreateFormFile("file", "file")
+	require.NoError(t, err)
+	_, err = io.Copy(fw, bytes.NewBufferString(data))
+	require.NoError(t, err)
+	err = w.Close()
+	require.NoError(t, err)
+
+	req, err := http.NewRequest("PUT", "/file-data", &b)
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", w.FormDataContentType())
+	req = mux.SetURLVars(req, map[string]string{"id": fmt.Sprint(fileID)})
+	rr := httptest.NewRecorder()
+	testServer.Server.CreateFileData(rr, req, user)
+	require.Equal(t, http.StatusOK, rr.Code)
+}
+
+func TestGetFileVersions(t *testing.T) {
+	testServer.RefreshFileSystem()
+
+	err := testServer.SeedData()
+	require.NoError(t, err)
+
+	users := testServer.Data.Users
+	file := testServer.Data.Files[0]
+
+	uploadFileData(t, file.ID, users[0], "first")
+	uploadFileData(t, file.ID, users[0], "second version")
+
+	testCases := []struct {
+		id          uint
+		user        models.User
+		statusCode  int
+		expectedErr error
+	}{
+		{
+			id:         file.ID,
+			user:       users[0],
+			statusCode: http.StatusOK,
+		},
+		{
+			id:          file.ID,
+			user:        users[3],
+			statusCode:  http.StatusForbidden,
+			expectedErr: controllers.ErrUserForbidden,
+		},
+		{
+			id:          999,
+			user:        users[0],
+			statusCode:  http.StatusBadRequest,
+			expectedErr: models.ErrFileNotFound,
+		},
+	}
+
+	for _, testCase := range testCases {
+		req, err := http.NewRequest("GET", "/files/versions", nil)
+		require.NoError(t, err)
+		req = mux.SetURLVars(req, map[string]string{"id": fmt.Sprint(testCase.id)})
+		rr := httptest.NewRecorder()
+		testServer.Server.GetFileVersions(rr, req, testCase.user)
+
+		if assert.Equal(t, testCase.statusCode, rr.Code) {
+			switch testCase.statusCode {
+			case http.StatusOK:
+				var versions []map[string]interface{}
+				err = json.Unmarshal(rr.Body.Bytes(), &versions)
+				require.NoError(t, err)
+				if assert.Len(t, versions, 2) {
+					assert.Equal(t, float64(1), versions[0]["number"])
+					assert.Equal(t, float64(len("first")), versions[0]["size"])
+					assert.Equal(t, float64(2), versions[1]["number"])
+					assert.Equal(t, float64(len("second version")), versions[1]["size"])
+					assert.Equal(t, float64(users[0].ID), versions[1]["uploader_id"])
+				}
+			case http.StatusBadRequest:
+				responseMap := make(map[string]interface{})
+				err = json.Unmarshal(rr.Body.Bytes(), &responseMap)
+				require.NoError(t, err)
+				assert.Equal(t, testCase.expectedErr.Error(), responseMap["error"])
+			}
+		}
+	}
+}
+
+func TestGetFileDataVersion(t *testing.T) {
+	testServer.RefreshFileSystem()
+
+	err := testServer.SeedData()
+	require.NoError(t, err)
+
+	user := testServer.Data.Users[0]
+	file := testServer.Data.Files[0]
+
+	uploadFileData(t, file.ID, user, "first")
+	uploadFileData(t, file.ID, user, "second")
+
+	testCases := []struct {
+		version      string
+		statusCode   int
+		expectedData string
+		expectedErr  error
+	}{
+		{
+			version:      "",
+			statusCode:   http.StatusOK,
+			expectedData: "second",
+		},
+		{
+			version:      "1",
+			statusCode:   http.StatusOK,
+			expectedData: "first",
+		},
+		{
+			version:     "3",
+			statusCode:  http.StatusBadRequest,
+			expectedErr: models.ErrFileVersionNotFound,
+		},
+	}
+
+	for _, testCase := range testCases {
+		url := "/file-data"
+		if testCase.version != "" {
+			url += "?version=" + testCase.version
+		}
+		req, err := http.NewRequest("GET", url, nil)
+		require.NoError(t, err)
+		req = mux.SetURLVars(req, map[string]string{"id": fmt.Sprint(file.ID)})
+		rr := httptest.NewRecorder()
+		testServer.Server.GetFileData(rr, req, user)
+
+		if assert.Equal(t, testCase.statusCode, rr.Code) {
+			switch testCase.statusCode {
+			case http.StatusOK:
+				assert.Equal(t, testCase.expectedData, rr.Body.String())
+				checksum := sha256.Sum256([]byte(testCase.expectedData))
+				assert.Equal(t, fmt.Sprintf("\"%x\"", checksum), rr.Header().Get("ETag"))
+				assert.Equal(t, "sha-256="+base64.StdEncoding.EncodeToString(checksum[:]), rr.Header().Get("Digest"))
+			case http.StatusBadRequest:
+				responseMap := make(map[string]interface{})
+				err = json.Unmarshal(rr.Body.Bytes(), &responseMap)
+				require.NoError(t, err)
+				assert.Equal(t, testCase.expectedErr.Error(), responseMap["error"])
+			}
+		}
+	}
+}
+
+func TestRestoreFileVersion(t *testing.T) {
+	testServer.RefreshFileSystem()
+
+	err := testServer.SeedData()
+	require.NoError(t, err)
+
+	users := testServer.Data.Users
+	file := testServer.Data.Files[0]
+
+	uploadFileData(t, file.ID, users[0], "first")
+	uploadFileData(t, file.ID, users[0], "second")
+
+	testCases := []struct {
+		version     string
+		user        models.User
+		statusCode  int
+		expectedErr error
+	}{
+		{
+			version:    "1",
+			user:       users[0],
+			statusCode: http.StatusCreated,
+		},
+		{
+			version:     "1",
+			user:        users[1],
+			statusCode:  http.StatusForbidden,
+			expectedErr: controllers.ErrUserForbidden,
+		},
+		{
+			version:     "999",
+			user:        users[0],
+			statusCode:  http.StatusBadRequest,
+			expectedErr: models.ErrFileVersionNotFound,
+		},
+	}
+
+	for _, testCase := range testCases {
+		req, err := http.NewRequest("POST", "/files/versions/restore", nil)
+		require.NoError(t, err)
+		req = mux.SetURLVars(req, map[string]string{"id": fmt.Sprint(file.ID), "version": testCase.version})
+		rr := httptest.NewRecorder()
+		testServer.Server.RestoreFileVersion(rr, req, testCase.user)
+
+		responseMap := make(map[string]interface{})
+		err = json.Unmarshal(rr.Body.Bytes(), &responseMap)
+		require.NoError(t, err)
+		if assert.Equal(t, testCase.statusCode, rr.Code) {
+			switch testCase.statusCode {
+			case http.StatusCreated:
+				assert.Equal(t, float64(3), responseMap["number"])
+				assert.Equal(t, float64(len("first")), responseMap["size"])
+			case http.StatusBadRequest:
+				assert.Equal(t, testCase.expectedErr.Error(), responseMap["error"])
+			}
+		}
+	}
+
+	// The restored data should now be the current data, with the history kept intact.
+	req, err := http.NewRequest("GET", "/file-data", nil)
+	require.NoError(t, err)
+	req = mux.SetURLVars(req, map[string]string{"id": fmt.Sprint(file.ID)})
+	rr := httptest.NewRecorder()
+	testServer.Server.GetFileData(rr, req, users[0])
+	assert.Equal(t, "first", rr.Body.String())
+
+	versions, err := models.GetFileVersions(testServer.Server.DB, file.ID)
+	require.NoError(t, err)
+	assert.Len(t, versions, 3)
+}
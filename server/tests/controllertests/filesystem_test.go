@@ -2,11 +2,16 @@ package controllertests
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
 	"os"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+
+	"github.com/invincibot/penn-spark-server/api/filesystem"
 )
 
 func TestFileSystem(t *testing.T) {
@@ -39,3 +44,41 @@ func TestFileSystem(t *testing.T) {
 	_, err = testServer.Server.FileSystem.Stat("1")
 	require.True(t, os.IsNotExist(err))
 }
+
+func TestFileSystemTempFile(t *testing.T) {
+	testServer.RefreshFileSystem()
+
+	testData := []byte("some file data")
+	tempFile, err := testServer.Server.FileSystem.WriteTempFile(bytes.NewReader(testData), int64(len(testData)))
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(testData)), tempFile.Size)
+	checksum := sha256.Sum256(testData)
+	assert.Equal(t, hex.EncodeToString(checksum[:]), tempFile.Checksum)
+
+	err = testServer.Server.FileSystem.CommitBlobRaw(tempFile)
+	require.NoError(t, err)
+
+	data, err := testServer.Server.FileSystem.GetBlobRaw(tempFile.Checksum)
+	require.NoError(t, err)
+	b, err := ioutil.ReadAll(data)
+	require.NoError(t, err)
+	assert.Equal(t, testData, b)
+
+	// The temporary file is moved into place, so it no longer exists.
+	_, err = testServer.Server.FileSystem.Stat(tempFile.Path)
+	assert.True(t, os.IsNotExist(err))
+
+	// Data that is already stored isn't stored again, so the temporary file is left in place.
+	tempFile, err = testServer.Server.FileSystem.WriteTempFile(bytes.NewReader(testData), 0)
+	require.NoError(t, err)
+	err = testServer.Server.FileSystem.CommitBlobRaw(tempFile)
+	require.NoError(t, err)
+	_, err = testServer.Server.FileSystem.Stat(tempFile.Path)
+	assert.NoError(t, err)
+	testServer.Server.FileSystem.RemoveTempFile(tempFile)
+	_, err = testServer.Server.FileSystem.Stat(tempFile.Path)
+	assert.True(t, os.IsNotExist(err))
+
+	_, err = testServer.Server.FileSystem.WriteTempFile(bytes.NewReader(testData), int64(len(testData)-1))
+	assert.Equal(t, filesystem.ErrFileTooLarge, err)
+}
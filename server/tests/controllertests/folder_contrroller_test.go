@@ -190,6 +190,59 @@ func TestGetFolderByID(t *testing.T) {
 	}
 }
 
+func TestGetFolderByIDPage(t *testing.T) {
+	err := testServer.SeedData()
+	require.NoError(t, err)
+
+	user := testServer.Data.Users[0]
+	folder := testServer.Data.Folders[0]
+	expectedIDs := []uint{testServer.Data.Files[0].ID}
+	for i := 0; i < 3; i++ {
+		file, err := models.CreateFile(testServer.Server.DB, models.File{
+			Name:         fmt.Sprintf("page%d", i),
+			FolderID:     folder.ID,
+			LastEditorID: user.ID,
+			IsPublished:  true,
+		})
+		require.NoError(t, err)
+		expectedIDs = append(expectedIDs, file.ID)
+	}
+
+	// The files are sorted by name by default, and the child folders aren't paged.
+	returnedIDs := []uint{}
+	cursor := ""
+	for {
+		req, err := http.NewRequest("GET", "/folders?limit=2&cursor="+cursor, nil)
+		require.NoError(t, err)
+		req = mux.SetURLVars(req, map[string]string{"id": fmt.Sprint(folder.ID)})
+		rr := httptest.NewRecorder()
+		testServer.Server.GetFolderByID(rr, req, user)
+		require.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, fmt.Sprint(len(expectedIDs)), rr.Header().Get("X-Total-Count"))
+
+		var returnedFolder models.Folder
+		err = json.Unmarshal(rr.Body.Bytes(), &returnedFolder)
+		require.NoError(t, err)
+		assert.Len(t, returnedFolder.ChildFolders, 1)
+		for _, file := range returnedFolder.Files {
+			returnedIDs = append(returnedIDs, file.ID)
+		}
+
+		cursor = rr.Header().Get("X-Next-Cursor")
+		if cursor == "" {
+			break
+		}
+	}
+	assert.Equal(t, expectedIDs, returnedIDs)
+
+	req, err := http.NewRequest("GET", "/folders?sort=size", nil)
+	require.NoError(t, err)
+	req = mux.SetURLVars(req, map[string]string{"id": fmt.Sprint(folder.ID)})
+	rr := httptest.NewRecorder()
+	testServer.Server.GetFolderByID(rr, req, user)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
+
 func TestUpdateFolder(t *testing.T) {
 	err := testServer.SeedData()
 	require.NoError(t, err)
@@ -334,3 +387,93 @@ func TestDeleteFolder(t *testing.T) {
 		}
 	}
 }
+
+func TestDeleteFolderRecursive(t *testing.T) {
+	err := testServer.SeedData()
+	require.NoError(t, err)
+
+	users := testServer.Data.Users
+	folders := testServer.Data.Folders
+	files := testServer.Data.Files
+
+	_, err = testServer.GrantAccess(users[1], folders[0].ID, models.Publisher)
+	require.NoError(t, err)
+	partialPublisher, err := testServer.GrantAccess(users[1], folders[1].ID, models.Publisher)
+	require.NoError(t, err)
+	publisher, err := testServer.GrantAccess(users[2], folders[0].ID, models.Publisher)
+	require.NoError(t, err)
+	_, err = testServer.GrantAccess(users[2], folders[1].ID, models.Publisher)
+	require.NoError(t, err)
+	publisher, err = testServer.GrantAccess(users[2], folders[2].ID, models.Publisher)
+	require.NoError(t, err)
+
+	testCases := []struct {
+		user        models.User
+		query       string
+		statusCode  int
+		expectedErr error
+	}{
+		{
+			user:        publisher,
+			query:       "",
+			statusCode:  http.StatusBadRequest,
+			expectedErr: models.ErrFolderNotEmpty,
+		},
+		{
+			// The user needs publisher rights in every folder of the subtree.
+			user:        partialPublisher,
+			query:       "?recursive=true",
+			statusCode:  http.StatusForbidden,
+			expectedErr: controllers.ErrUserForbidden,
+		},
+		{
+			user:       publisher,
+			query:      "?recursive=true&dry_run=true",
+			statusCode: http.StatusOK,
+		},
+		{
+			user:       publisher,
+			query:      "?recursive=true",
+			statusCode: http.StatusNoContent,
+		},
+		{
+			user:        publisher,
+			query:       "?recursive=true",
+			statusCode:  http.StatusBadRequest,
+			expectedErr: models.ErrFolderNotFound,
+		},
+	}
+	for _, testCase := range testCases {
+		req, err := http.NewRequest("DELETE", "/folders"+testCase.query, nil)
+		require.NoError(t, err)
+		req = mux.SetURLVars(req, map[string]string{"id": fmt.Sprint(folders[1].ID)})
+		rr := httptest.NewRecorder()
+		testServer.Server.DeleteFolder(rr, req, testCase.user)
+
+		if assert.Equal(t, testCase.statusCode, rr.Code) {
+			switch testCase.statusCode {
+			case http.StatusOK:
+				responseMap := make(map[string]interface{})
+				err = json.Unmarshal([]byte(rr.Body.String()), &responseMap)
+				require.NoError(t, err)
+				assert.Equal(t, float64(2), responseMap["folder_count"])
+				assert.Equal(t, float64(2), responseMap["file_count"])
+				assert.Equal(t, []interface{}{float64(folders[1].ID), float64(folders[2].ID)}, responseMap["folder_ids"])
+
+				// Nothing is deleted in a dry run.
+				_, err = models.GetFileByID(testServer.Server.DB, files[2].ID)
+				assert.NoError(t, err)
+			case http.StatusNoContent:
+				_, err = models.GetFolderByID(testServer.Server.DB, folders[2].ID)
+				assert.Equal(t, models.ErrFolderNotFound, err)
+				_, err = models.GetFileByID(testServer.Server.DB, files[2].ID)
+				assert.Equal(t, models.ErrFileNotFound, err)
+			case http.StatusBadRequest:
+				responseMap := make(map[string]interface{})
+				err = json.Unmarshal([]byte(rr.Body.String()), &responseMap)
+				require.NoError(t, err)
+				assert.Equal(t, testCase.expectedErr.Error(), responseMap["error"])
+			}
+		}
+	}
+}
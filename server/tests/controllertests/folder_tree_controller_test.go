@@ -0,0 +1,126 @@
+package controllertests
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/invincibot/penn-spark-server/api/controllers"
+	"github.com/invincibot/penn-spark-server/api/models"
+)
+
+// getFolderTree sends a folder tree request with the given query parameters and returns the response.
+func getFolderTree(t *testing.T, user models.User, id string, params url.Values) *httptest.ResponseRecorder {
+	req, err := http.NewRequest("GET", "/folders/tree?"+params.Encode(), nil)
+	require.NoError(t, err)
+	req = mux.SetURLVars(req, map[string]string{"id": id})
+	rr := httptest.NewRecorder()
+	testServer.Server.GetFolderTree(rr, req, user)
+	return rr
+}
+
+func TestGetFolderTree(t *testing.T) {
+	err := testServer.SeedData()
+	require.NoError(t, err)
+
+	users := testServer.Data.Users
+	folders := testServer.Data.Folders
+
+	users[0], err = testServer.GrantAccess(users[0], folders[0].ID, models.Publisher)
+	require.NoError(t, err)
+	users[1], err = testServer.GrantAccess(users[1], folders[0].ID, models.Viewer)
+	require.NoError(t, err)
+	users[1], err = testServer.GrantAccess(users[1], folders[1].ID, models.Uploader)
+	require.NoError(t, err)
+
+	_, err = models.CreateFile(testServer.Server.DB, models.File{
+		Name:         "draft",
+		FolderID:     folders[0].ID,
+		LastEditorID: users[1].ID,
+	})
+	require.NoError(t, err)
+
+	// Publishers count every draft, while admins only view the folders they can't publish in.
+	rr := getFolderTree(t, users[0], fmt.Sprint(folders[0].ID), url.Values{"depth": {"2"}})
+	require.Equal(t, http.StatusOK, rr.Code)
+	var tree controllers.FolderTree
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &tree))
+	assert.Equal(t, folders[0].ID, tree.ID)
+	assert.Equal(t, models.Publisher, tree.AccessLevel)
+	assert.Equal(t, int64(2), tree.FileCount)
+	if assert.Len(t, tree.ChildFolders, 1) {
+		child := tree.ChildFolders[0]
+		assert.Equal(t, folders[1].ID, child.ID)
+		assert.Equal(t, folders[0].ID, child.ParentFolderID)
+		assert.Equal(t, models.Viewer, child.AccessLevel)
+		assert.Equal(t, int64(0), child.FileCount)
+		if assert.Len(t, child.ChildFolders, 1) {
+			assert.Equal(t, folders[2].ID, child.ChildFolders[0].ID)
+			assert.Equal(t, int64(1), child.ChildFolders[0].FileCount)
+			assert.Len(t, child.ChildFolders[0].ChildFolders, 0)
+		}
+	}
+
+	// Only one level is returned by default.
+	rr = getFolderTree(t, users[0], fmt.Sprint(folders[0].ID), url.Values{})
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &tree))
+	if assert.Len(t, tree.ChildFolders, 1) {
+		assert.Len(t, tree.ChildFolders[0].ChildFolders, 0)
+	}
+
+	// Uploaders count their own drafts, and folders they can't view are left out.
+	rr = getFolderTree(t, users[1], fmt.Sprint(folders[0].ID), url.Values{"depth": {"5"}})
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &tree))
+	assert.Equal(t, int64(2), tree.FileCount)
+	if assert.Len(t, tree.ChildFolders, 1) {
+		assert.Equal(t, models.Uploader, tree.ChildFolders[0].AccessLevel)
+		assert.Equal(t, int64(1), tree.ChildFolders[0].FileCount)
+		assert.Len(t, tree.ChildFolders[0].ChildFolders, 0)
+	}
+
+	testCases := []struct {
+		user       models.User
+		id         string
+		params     url.Values
+		statusCode int
+	}{
+		{
+			user:       users[1],
+			id:         fmt.Sprint(folders[2].ID),
+			params:     url.Values{},
+			statusCode: http.StatusForbidden,
+		},
+		{
+			user:       users[0],
+			id:         "999",
+			params:     url.Values{},
+			statusCode: http.StatusBadRequest,
+		},
+		{
+			user:       users[0],
+			id:         fmt.Sprint(folders[0].ID),
+			params:     url.Values{"depth": {"all"}},
+			statusCode: http.StatusBadRequest,
+		},
+		{
+			user:       users[0],
+			id:         fmt.Sprint(folders[0].ID),
+			params:     url.Values{"depth": {fmt.Sprint(models.MaxFolderTreeDepth + 1)}},
+			statusCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, testCase := range testCases {
+		rr := getFolderTree(t, testCase.user, testCase.id, testCase.params)
+		assert.Equal(t, testCase.statusCode, rr.Code, testCase.params.Encode())
+	}
+}
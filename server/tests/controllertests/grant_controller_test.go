@@ -0,0 +1,91 @@
+package controllertests
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/invincibot/penn-spark-server/api/models"
+)
+
+func TestSweepExpiredGrants(t *testing.T) {
+	err := testServer.SeedData()
+	require.NoError(t, err)
+
+	users := testServer.Data.Users
+	folders := testServer.Data.Folders
+
+	userRole, err := models.CreateUserRole(testServer.Server.DB, models.UserRole{Name: "auditors"})
+	require.NoError(t, err)
+	_, err = models.CreateAccessRole(testServer.Server.DB, models.AccessRole{
+		FolderID:    folders[1].ID,
+		UserRoleID:  userRole.ID,
+		AccessLevel: models.Viewer,
+	})
+	require.NoError(t, err)
+
+	// The user role is assigned until it expires, with the expiry sent in a time zone 5 hours behind the server's.
+	_, offset := time.Now().Zone()
+	expiresAt := time.Now().Add(2 * time.Hour).In(time.FixedZone("", offset-5*60*60))
+	body := fmt.Sprintf(`{"id": %d, "expires_at": %q}`, userRole.ID, expiresAt.Format(time.RFC3339Nano))
+	req, err := http.NewRequest("POST", "/users/user-roles", bytes.NewBufferString(body))
+	require.NoError(t, err)
+	req = mux.SetURLVars(req, map[string]string{"id": fmt.Sprint(users[3].ID)})
+	rr := httptest.NewRecorder()
+	testServer.Server.AddUserRole(rr, req, users[0])
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	_, accessLevel, err := models.GetUserAuthorizationFolder(testServer.Server.DB, users[3], folders[1].ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.Viewer, accessLevel)
+
+	// Assignments that haven't expired are kept.
+	err = testServer.Server.SweepExpiredGrants()
+	require.NoError(t, err)
+	user, err := models.GetUserByID(testServer.Server.DB, users[3].ID)
+	require.NoError(t, err)
+	assert.Len(t, user.UserRoles, len(users[3].UserRoles)+1)
+
+	_, err = models.ExpireGrants(testServer.Server.DB, expiresAt.Add(time.Second))
+	require.NoError(t, err)
+	user, err = models.GetUserByID(testServer.Server.DB, users[3].ID)
+	require.NoError(t, err)
+	assert.Len(t, user.UserRoles, len(users[3].UserRoles))
+	_, accessLevel, err = models.GetUserAuthorizationFolder(testServer.Server.DB, users[3], folders[1].ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.None, accessLevel)
+
+	// The expiry is recorded in the audit trail.
+	req, err = http.NewRequest("GET", "/grant-audit?limit=1", nil)
+	require.NoError(t, err)
+	rr = httptest.NewRecorder()
+	testServer.Server.GetGrantAudit(rr, req, users[0])
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "1", rr.Header().Get("X-Total-Count"))
+	var entries []models.GrantAuditEntry
+	err = json.Unmarshal(rr.Body.Bytes(), &entries)
+	require.NoError(t, err)
+	if assert.Len(t, entries, 1) {
+		assert.Equal(t, models.GrantExpired, entries[0].Event)
+		assert.Equal(t, userRole.ID, entries[0].UserRoleID)
+		assert.Equal(t, users[3].ID, entries[0].UserID)
+	}
+
+	// Assignments can't end before they start.
+	body = fmt.Sprintf(`{"id": %d, "starts_at": %q, "expires_at": %q}`, userRole.ID,
+		time.Now().Format(time.RFC3339), time.Now().Add(-time.Hour).Format(time.RFC3339))
+	req, err = http.NewRequest("POST", "/users/user-roles", bytes.NewBufferString(body))
+	require.NoError(t, err)
+	req = mux.SetURLVars(req, map[string]string{"id": fmt.Sprint(users[3].ID)})
+	rr = httptest.NewRecorder()
+	testServer.Server.AddUserRole(rr, req, users[0])
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
@@ -0,0 +1,163 @@
+package controllertests
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/invincibot/penn-spark-server/api/controllers"
+	"github.com/invincibot/penn-spark-server/api/models"
+)
+
+// importArchive sends a ZIP archive with the given entries to be imported into a folder.
+// Entries ending with a slash are folders.
+func importArchive(t *testing.T, folderID uint, user models.User, entries []string) *httptest.ResponseRecorder {
+	var archiveData bytes.Buffer
+	archive := zip.NewWriter(&archiveData)
+	for _, entry := range entries {
+		writer, err := archive.Create(entry)
+		require.NoError(t, err)
+		if entry[len(entry)-1] != '/' {
+			_, err = writer.Write([]byte("data of " + entry))
+			require.NoError(t, err)
+		}
+	}
+	require.NoError(t, archive.Close())
+
+	var b bytes.Buffer
+	w := multipart.NewWriter(&b)
+	fw, err := w.CreateFormFile("file", "archive.zip")
+	require.NoError(t, err)
+	_, err = fw.Write(archiveData.Bytes())
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	req, err := http.NewRequest("POST", "/folders/import", &b)
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", w.FormDataContentType())
+	req = mux.SetURLVars(req, map[string]string{"id": fmt.Sprint(folderID)})
+	rr := httptest.NewRecorder()
+	testServer.Server.ImportFolderArchive(rr, req, user)
+	return rr
+}
+
+func TestImportFolderArchive(t *testing.T) {
+	testServer.RefreshFileSystem()
+
+	err := testServer.SeedData()
+	require.NoError(t, err)
+
+	users := testServer.Data.Users
+	folders := testServer.Data.Folders
+
+	publisher, err := testServer.GrantAccess(users[2], folders[0].ID, models.Publisher)
+	require.NoError(t, err)
+	uploader, err := testServer.GrantAccess(users[3], folders[0].ID, models.Uploader)
+	require.NoError(t, err)
+
+	rr := importArchive(t, folders[0].ID, publisher, []string{
+		"docs/",
+		"docs/handbook.txt",
+		"docs/sub/notes.txt",
+		"file1",
+		"../outside.txt",
+	})
+	var report controllers.ImportReport
+	err = json.Unmarshal(rr.Body.Bytes(), &report)
+	require.NoError(t, err)
+	if assert.Equal(t, http.StatusCreated, rr.Code) {
+		if assert.Len(t, report.Folders, 2) {
+			assert.Equal(t, "docs", report.Folders[0].Name)
+			assert.Equal(t, folders[0].ID, *report.Folders[0].ParentFolderID)
+			assert.Equal(t, "sub", report.Folders[1].Name)
+			assert.Equal(t, report.Folders[0].ID, *report.Folders[1].ParentFolderID)
+		}
+		if assert.Len(t, report.Files, 2) {
+			assert.Equal(t, "handbook.txt", report.Files[0].Name)
+			assert.Equal(t, report.Folders[0].ID, report.Files[0].FolderID)
+			assert.False(t, report.Files[0].IsPublished)
+			assert.Equal(t, publisher.ID, report.Files[0].LastEditorID)
+			assert.Equal(t, int64(len("data of docs/handbook.txt")), report.Files[0].Size)
+			assert.Equal(t, "notes.txt", report.Files[1].Name)
+			assert.Equal(t, report.Folders[1].ID, report.Files[1].FolderID)
+
+			version, err := models.GetLatestFileVersion(testServer.Server.DB, report.Files[1].ID)
+			require.NoError(t, err)
+			data, err := testServer.Server.FileSystem.GetBlobRaw(version.Checksum)
+			require.NoError(t, err)
+			b, err := ioutil.ReadAll(data)
+			require.NoError(t, err)
+			data.Close()
+			assert.Equal(t, "data of docs/sub/notes.txt", string(b))
+		}
+		assert.ElementsMatch(t, []controllers.ImportConflict{
+			{Path: "file1", Error: models.ErrFileAlreadyExists.Error()},
+			{Path: "../outside.txt", Error: controllers.ErrInvalidArchivePath.Error()},
+		}, report.Conflicts)
+	}
+
+	// Uploaders can add files, but not folders.
+	rr = importArchive(t, folders[0].ID, uploader, []string{
+		"top.txt",
+		"new/inside.txt",
+	})
+	report = controllers.ImportReport{}
+	err = json.Unmarshal(rr.Body.Bytes(), &report)
+	require.NoError(t, err)
+	if assert.Equal(t, http.StatusCreated, rr.Code) {
+		assert.Len(t, report.Folders, 0)
+		if assert.Len(t, report.Files, 1) {
+			assert.Equal(t, "top.txt", report.Files[0].Name)
+		}
+		assert.ElementsMatch(t, []controllers.ImportConflict{
+			{Path: "new", Error: controllers.ErrUserForbidden.Error()},
+			{Path: "new/inside.txt", Error: controllers.ErrUserForbidden.Error()},
+		}, report.Conflicts)
+	}
+
+	rr = importArchive(t, folders[1].ID, uploader, []string{"file.txt"})
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+}
+
+func TestImportFolderArchiveLimits(t *testing.T) {
+	testServer.RefreshFileSystem()
+
+	err := testServer.SeedData()
+	require.NoError(t, err)
+
+	users := testServer.Data.Users
+	folders := testServer.Data.Folders
+
+	publisher, err := testServer.GrantAccess(users[2], folders[0].ID, models.Publisher)
+	require.NoError(t, err)
+
+	testServer.Server.MaxImportEntries = 2
+	rr := importArchive(t, folders[0].ID, publisher, []string{"a.txt", "b.txt", "c.txt"})
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rr.Code)
+	testServer.Server.MaxImportEntries = 0
+
+	// Each file holds "data of " followed by its name, so two files are 26 bytes in total.
+	testServer.Server.MaxImportSize = 25
+	rr = importArchive(t, folders[0].ID, publisher, []string{"a.txt", "b.txt"})
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rr.Code)
+
+	testServer.Server.MaxImportSize = 26
+	rr = importArchive(t, folders[0].ID, publisher, []string{"a.txt", "b.txt"})
+	assert.Equal(t, http.StatusCreated, rr.Code)
+	testServer.Server.MaxImportSize = 0
+
+	var count int64
+	err = testServer.Server.DB.Model(&models.File{}).Where("name IN ?", []string{"a.txt", "b.txt", "c.txt"}).Count(&count).Error
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), count)
+}
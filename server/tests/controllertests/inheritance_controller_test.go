@@ -0,0 +1,92 @@
+package controllertests
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/invincibot/penn-spark-server/api/models"
+)
+
+func TestUpdateFolderInheritance(t *testing.T) {
+	err := testServer.SeedData()
+	require.NoError(t, err)
+
+	users := testServer.Data.Users
+	folders := testServer.Data.Folders
+
+	inheritable := true
+	user, err := testServer.GrantAccessRole(users[3], models.AccessRole{
+		FolderID:    folders[1].ID,
+		AccessLevel: models.Viewer,
+		Inheritable: &inheritable,
+	})
+	require.NoError(t, err)
+
+	getFolder := func() int {
+		req, err := http.NewRequest("GET", "/folders", nil)
+		require.NoError(t, err)
+		req = mux.SetURLVars(req, map[string]string{"id": fmt.Sprint(folders[2].ID)})
+		rr := httptest.NewRecorder()
+		testServer.Server.GetFolderByID(rr, req, user)
+		return rr.Code
+	}
+	require.Equal(t, http.StatusOK, getFolder())
+
+	testCases := []struct {
+		id                       uint
+		body                     string
+		statusCode               int
+		expectedBreakInheritance bool
+		expectedGetStatusCode    int
+	}{
+		{
+			id:                       folders[2].ID,
+			body:                     `{"break_inheritance": true}`,
+			statusCode:               http.StatusOK,
+			expectedBreakInheritance: true,
+			expectedGetStatusCode:    http.StatusForbidden,
+		},
+		{
+			id:                    folders[2].ID,
+			body:                  `{"break_inheritance": false}`,
+			statusCode:            http.StatusOK,
+			expectedGetStatusCode: http.StatusOK,
+		},
+		{
+			id:         999,
+			body:       `{"break_inheritance": true}`,
+			statusCode: http.StatusBadRequest,
+		},
+		{
+			id:         folders[2].ID,
+			body:       `break`,
+			statusCode: http.StatusUnprocessableEntity,
+		},
+	}
+
+	for i, testCase := range testCases {
+		req, err := http.NewRequest("PUT", "/inheritance", bytes.NewBufferString(testCase.body))
+		require.NoError(t, err)
+		req = mux.SetURLVars(req, map[string]string{"id": fmt.Sprint(testCase.id)})
+		rr := httptest.NewRecorder()
+		testServer.Server.UpdateFolderInheritance(rr, req, users[0])
+		require.Equal(t, testCase.statusCode, rr.Code, i)
+		if testCase.statusCode != http.StatusOK {
+			continue
+		}
+
+		var folder models.Folder
+		err = json.Unmarshal(rr.Body.Bytes(), &folder)
+		require.NoError(t, err)
+		assert.Equal(t, testCase.expectedBreakInheritance, folder.BreakInheritance, i)
+		assert.Equal(t, testCase.expectedGetStatusCode, getFolder(), i)
+	}
+}
@@ -0,0 +1,101 @@
+package controllertests
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/invincibot/penn-spark-server/api/controllers"
+	"github.com/invincibot/penn-spark-server/api/models"
+)
+
+func TestGetIntegrityReport(t *testing.T) {
+	testServer.RefreshFileSystem()
+
+	err := testServer.SeedData()
+	require.NoError(t, err)
+
+	users := testServer.Data.Users
+	files := testServer.Data.Files
+	fs := testServer.Server.FileSystem
+
+	uploadFileData(t, files[0].ID, users[0], "intact")
+	uploadFileData(t, files[1].ID, users[1], "corrupted")
+	uploadFileData(t, files[2].ID, users[2], "missing")
+
+	// Corrupt and remove stored data behind the server's back.
+	err = afero.WriteFile(fs, fs.FilePath+"/"+blobKey("corrupted"), []byte("modified"), 0666)
+	require.NoError(t, err)
+	err = fs.Remove(fs.FilePath + "/" + blobKey("missing"))
+	require.NoError(t, err)
+	_, err = models.AddBlobReference(testServer.Server.DB, checksum("intact"), int64(len("intact")))
+	require.NoError(t, err)
+
+	// Data stored before versioning was introduced still belongs to its file.
+	err = afero.WriteFile(fs, fs.FilePath+"/"+strconv.Itoa(int(files[0].ID)), []byte("legacy"), 0666)
+	require.NoError(t, err)
+	_, _, err = fs.CreateBlobRaw(bytes.NewBufferString("orphaned"))
+	require.NoError(t, err)
+	err = afero.WriteFile(fs, fs.FilePath+"/999.1", []byte("orphaned"), 0666)
+	require.NoError(t, err)
+	err = afero.WriteFile(fs, fs.FilePath+"/unknown", []byte("unknown"), 0666)
+	require.NoError(t, err)
+	orphanedData := []string{"999.1", "unknown", blobKey("orphaned")}
+
+	req, err := http.NewRequest("GET", "/fsck", nil)
+	require.NoError(t, err)
+	rr := httptest.NewRecorder()
+	testServer.Server.GetIntegrityReport(rr, req, users[0])
+
+	var report controllers.IntegrityReport
+	err = json.Unmarshal(rr.Body.Bytes(), &report)
+	require.NoError(t, err)
+	if assert.Equal(t, http.StatusOK, rr.Code) {
+		assert.True(t, report.HasProblems())
+		assert.Equal(t, 3, report.CheckedCount)
+		assert.ElementsMatch(t, orphanedData, report.OrphanedData)
+		if assert.Len(t, report.MissingData, 1) {
+			assert.Equal(t, checksum("missing"), report.MissingData[0].Checksum)
+			assert.Equal(t, []uint{files[2].ID}, report.MissingData[0].FileIDs)
+		}
+		if assert.Len(t, report.ChecksumMismatches, 1) {
+			assert.Equal(t, []uint{files[1].ID}, report.ChecksumMismatches[0].FileIDs)
+			assert.Equal(t, int64(len("corrupted")), report.ChecksumMismatches[0].ExpectedSize)
+			assert.Equal(t, int64(len("modified")), report.ChecksumMismatches[0].ActualSize)
+		}
+		if assert.Len(t, report.RefCountMismatches, 1) {
+			assert.Equal(t, checksum("intact"), report.RefCountMismatches[0].Checksum)
+			assert.Equal(t, int64(1), report.RefCountMismatches[0].ExpectedRefCount)
+			assert.Equal(t, int64(2), report.RefCountMismatches[0].ActualRefCount)
+		}
+	}
+
+	// Data of deleted files is kept until they are purged, so it isn't orphaned.
+	err = models.DeleteFile(testServer.Server.DB, files[0].ID, users[0].ID)
+	require.NoError(t, err)
+
+	report, err = testServer.Server.CheckIntegrity()
+	require.NoError(t, err)
+	assert.ElementsMatch(t, orphanedData, report.OrphanedData)
+}
+
+// checksum returns the hex-encoded SHA-256 checksum of data.
+func checksum(data string) string {
+	sum := sha256.Sum256([]byte(data))
+	return hex.EncodeToString(sum[:])
+}
+
+// blobKey returns the key data is stored under by its checksum.
+func blobKey(data string) string {
+	sum := checksum(data)
+	return "blobs/" + sum[:2] + "/" + sum
+}
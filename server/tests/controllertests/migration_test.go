@@ -0,0 +1,125 @@
+package controllertests
+
+import (
+	"bytes"
+	"io/ioutil"
+	"strconv"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/invincibot/penn-spark-server/api/models"
+)
+
+func TestMigrateBlobs(t *testing.T) {
+	testServer.RefreshFileSystem()
+
+	err := testServer.SeedData()
+	require.NoError(t, err)
+
+	users := testServer.Data.Users
+	files := testServer.Data.Files
+	fs := testServer.Server.FileSystem
+	db := testServer.Server.DB
+
+	// Versions stored by file id have no blob yet.
+	for i, data := range []string{"handbook", "corrupted"} {
+		err = afero.WriteFile(fs, fs.FilePath+"/"+strconv.Itoa(int(files[i].ID))+".1", []byte(data), 0666)
+		require.NoError(t, err)
+		err = db.Create(&models.FileVersion{
+			FileID:     files[i].ID,
+			Number:     1,
+			Size:       int64(len("handbook")),
+			Checksum:   checksum("handbook"),
+			UploaderID: users[i].ID,
+		}).Error
+		require.NoError(t, err)
+	}
+
+	// Data stored before versioning was introduced becomes the first version of its file,
+	// unless the file already has versions.
+	err = afero.WriteFile(fs, fs.FilePath+"/"+strconv.Itoa(int(files[2].ID)), []byte("handbook"), 0666)
+	require.NoError(t, err)
+	err = afero.WriteFile(fs, fs.FilePath+"/"+strconv.Itoa(int(files[0].ID)), []byte("replaced"), 0666)
+	require.NoError(t, err)
+	err = afero.WriteFile(fs, fs.FilePath+"/999", []byte("orphaned"), 0666)
+	require.NoError(t, err)
+
+	migrated, err := testServer.Server.MigrateBlobs()
+	require.NoError(t, err)
+	assert.Equal(t, 2, migrated)
+
+	version, err := models.GetLatestFileVersion(db, files[2].ID)
+	require.NoError(t, err)
+	assert.Equal(t, checksum("handbook"), version.Checksum)
+	assert.Equal(t, files[2].LastEditorID, version.UploaderID)
+
+	blob, err := models.GetBlob(db, checksum("handbook"))
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), blob.RefCount)
+
+	data, err := fs.GetBlobRaw(checksum("handbook"))
+	require.NoError(t, err)
+	b, err := ioutil.ReadAll(data)
+	require.NoError(t, err)
+	data.Close()
+	assert.Equal(t, "handbook", string(b))
+
+	// Corrupted and orphaned data is left for the integrity check.
+	storedData, err := fs.ListStoredDataRaw()
+	require.NoError(t, err)
+	var names []string
+	for _, data := range storedData {
+		names = append(names, data.Name)
+	}
+	assert.ElementsMatch(t, []string{blobKey("handbook"), strconv.Itoa(int(files[1].ID)) + ".1", "999"}, names)
+
+	migrated, err = testServer.Server.MigrateBlobs()
+	require.NoError(t, err)
+	assert.Equal(t, 0, migrated)
+}
+
+func TestMigrateBlobsInterrupted(t *testing.T) {
+	testServer.RefreshFileSystem()
+
+	err := testServer.SeedData()
+	require.NoError(t, err)
+
+	users := testServer.Data.Users
+	files := testServer.Data.Files
+	fs := testServer.Server.FileSystem
+	db := testServer.Server.DB
+
+	err = afero.WriteFile(fs, fs.FilePath+"/"+strconv.Itoa(int(files[0].ID))+".1", []byte("handbook"), 0666)
+	require.NoError(t, err)
+	err = db.Create(&models.FileVersion{
+		FileID:     files[0].ID,
+		Number:     1,
+		Size:       int64(len("handbook")),
+		Checksum:   checksum("handbook"),
+		UploaderID: users[0].ID,
+	}).Error
+	require.NoError(t, err)
+
+	// The previous run stored and referenced the data, but stopped before removing the data stored by file id.
+	_, _, err = fs.CreateBlobRaw(bytes.NewBufferString("handbook"))
+	require.NoError(t, err)
+	_, err = models.AddBlobReference(db, checksum("handbook"), int64(len("handbook")))
+	require.NoError(t, err)
+
+	migrated, err := testServer.Server.MigrateBlobs()
+	require.NoError(t, err)
+	assert.Equal(t, 1, migrated)
+
+	blob, err := models.GetBlob(db, checksum("handbook"))
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), blob.RefCount)
+
+	storedData, err := fs.ListStoredDataRaw()
+	require.NoError(t, err)
+	if assert.Len(t, storedData, 1) {
+		assert.Equal(t, blobKey("handbook"), storedData[0].Name)
+	}
+}
@@ -0,0 +1,195 @@
+package controllertests
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/invincibot/penn-spark-server/api/controllers"
+	"github.com/invincibot/penn-spark-server/api/models"
+)
+
+func TestGetFolderPath(t *testing.T) {
+	err := testServer.SeedData()
+	require.NoError(t, err)
+
+	users := testServer.Data.Users
+	folders := testServer.Data.Folders
+
+	users[0], err = testServer.GrantAccess(users[0], folders[0].ID, models.Publisher)
+	require.NoError(t, err)
+	users[1], err = testServer.GrantAccess(users[1], folders[1].ID, models.Uploader)
+	require.NoError(t, err)
+	users[1], err = testServer.GrantAccess(users[1], folders[2].ID, models.Viewer)
+	require.NoError(t, err)
+
+	testCases := []struct {
+		user                 models.User
+		id                   string
+		statusCode           int
+		expectedAccessLevels []models.AccessLevel
+	}{
+		{
+			// Folders the user can't view are still part of the path.
+			user:                 users[1],
+			id:                   fmt.Sprint(folders[2].ID),
+			statusCode:           http.StatusOK,
+			expectedAccessLevels: []models.AccessLevel{models.None, models.Uploader, models.Viewer},
+		},
+		{
+			user:                 users[0],
+			id:                   fmt.Sprint(folders[1].ID),
+			statusCode:           http.StatusOK,
+			expectedAccessLevels: []models.AccessLevel{models.Publisher, models.Viewer},
+		},
+		{
+			user:       users[1],
+			id:         fmt.Sprint(folders[0].ID),
+			statusCode: http.StatusForbidden,
+		},
+		{
+			user:       users[3],
+			id:         fmt.Sprint(folders[2].ID),
+			statusCode: http.StatusForbidden,
+		},
+		{
+			user:       users[0],
+			id:         "999",
+			statusCode: http.StatusBadRequest,
+		},
+		{
+			user:       users[0],
+			id:         "folder",
+			statusCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, testCase := range testCases {
+		req, err := http.NewRequest("GET", "/folders/path", nil)
+		require.NoError(t, err)
+		req = mux.SetURLVars(req, map[string]string{"id": testCase.id})
+		rr := httptest.NewRecorder()
+		testServer.Server.GetFolderPath(rr, req, testCase.user)
+		require.Equal(t, testCase.statusCode, rr.Code, testCase.id)
+		if testCase.statusCode != http.StatusOK {
+			continue
+		}
+
+		var path []controllers.PathFolder
+		err = json.Unmarshal(rr.Body.Bytes(), &path)
+		require.NoError(t, err)
+		if assert.Len(t, path, len(testCase.expectedAccessLevels)) {
+			for i, folder := range path {
+				assert.Equal(t, folders[i].ID, folder.ID)
+				assert.Equal(t, folders[i].Name, folder.Name)
+				assert.Equal(t, testCase.expectedAccessLevels[i], folder.AccessLevel)
+			}
+			assert.Equal(t, uint(0), path[0].ParentFolderID)
+		}
+	}
+}
+
+func TestResolvePath(t *testing.T) {
+	err := testServer.SeedData()
+	require.NoError(t, err)
+
+	users := testServer.Data.Users
+	folders := testServer.Data.Folders
+	files := testServer.Data.Files
+
+	users[0], err = testServer.GrantAccess(users[0], folders[0].ID, models.Publisher)
+	require.NoError(t, err)
+	users[1], err = testServer.GrantAccess(users[1], folders[1].ID, models.Uploader)
+	require.NoError(t, err)
+	users[1], err = testServer.GrantAccess(users[1], folders[2].ID, models.Viewer)
+	require.NoError(t, err)
+
+	testCases := []struct {
+		user           models.User
+		path           string
+		statusCode     int
+		expectedErr    error
+		expectedResult controllers.PathResult
+	}{
+		{
+			user:       users[1],
+			path:       "/root/folder1",
+			statusCode: http.StatusOK,
+			expectedResult: controllers.PathResult{
+				Type:     controllers.SearchResultFolder,
+				ID:       folders[1].ID,
+				FolderID: folders[0].ID,
+			},
+		},
+		{
+			// Uploaders can find their own drafts.
+			user:       users[1],
+			path:       "/root/folder1/file2",
+			statusCode: http.StatusOK,
+			expectedResult: controllers.PathResult{
+				Type:     controllers.SearchResultFile,
+				ID:       files[1].ID,
+				FolderID: folders[1].ID,
+			},
+		},
+		{
+			// Paths the user can't view look the same as paths that don't exist.
+			user:        users[1],
+			path:        "/root",
+			statusCode:  http.StatusBadRequest,
+			expectedErr: models.ErrPathNotFound,
+		},
+		{
+			// Admins can view every folder, but can only see drafts in folders they can publish in.
+			user:        users[0],
+			path:        "/root/folder1/file2",
+			statusCode:  http.StatusBadRequest,
+			expectedErr: models.ErrPathNotFound,
+		},
+		{
+			user:        users[2],
+			path:        "/root/folder1/file2",
+			statusCode:  http.StatusBadRequest,
+			expectedErr: models.ErrPathNotFound,
+		},
+		{
+			user:        users[1],
+			path:        "/root/folder1/missing",
+			statusCode:  http.StatusBadRequest,
+			expectedErr: models.ErrPathNotFound,
+		},
+		{
+			user:        users[1],
+			path:        "root",
+			statusCode:  http.StatusBadRequest,
+			expectedErr: models.ErrInvalidPath,
+		},
+	}
+
+	for _, testCase := range testCases {
+		req, err := http.NewRequest("GET", "/resolve?"+url.Values{"path": {testCase.path}}.Encode(), nil)
+		require.NoError(t, err)
+		rr := httptest.NewRecorder()
+		testServer.Server.ResolvePath(rr, req, testCase.user)
+		require.Equal(t, testCase.statusCode, rr.Code, testCase.path)
+		if testCase.statusCode != http.StatusOK {
+			responseMap := make(map[string]interface{})
+			err = json.Unmarshal(rr.Body.Bytes(), &responseMap)
+			require.NoError(t, err)
+			assert.Equal(t, testCase.expectedErr.Error(), responseMap["error"], testCase.path)
+			continue
+		}
+
+		var result controllers.PathResult
+		err = json.Unmarshal(rr.Body.Bytes(), &result)
+		require.NoError(t, err)
+		assert.Equal(t, testCase.expectedResult, result, testCase.path)
+	}
+}
@@ -0,0 +1,246 @@
+package controllertests
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/invincibot/penn-spark-server/api/models"
+	"github.com/invincibot/penn-spark-server/api/scanner"
+)
+
+// newFakeClamd starts a daemon speaking the clamd INSTREAM protocol on a local port.
+// Data containing "EICAR" is reported as infected, and data longer than maxLength as too large to scan.
+// The data of each scan is sent on the returned channel.
+func newFakeClamd(t *testing.T, maxLength int) (string, <-chan []byte) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		listener.Close()
+	})
+
+	received := make(chan []byte, 10)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			reader := bufio.NewReader(conn)
+			command, err := reader.ReadString(0)
+			if err != nil || command != "zINSTREAM\x00" {
+				conn.Write([]byte("UNKNOWN COMMAND\x00"))
+				conn.Close()
+				continue
+			}
+
+			var data []byte
+			for {
+				var length uint32
+				err = binary.Read(reader, binary.BigEndian, &length)
+				if err != nil || length == 0 {
+					break
+				}
+				chunk := make([]byte, length)
+				_, err = io.ReadFull(reader, chunk)
+				if err != nil {
+					break
+				}
+				data = append(data, chunk...)
+			}
+			received <- data
+
+			switch {
+			case len(data) > maxLength:
+				conn.Write([]byte("INSTREAM size limit exceeded. ERROR\x00"))
+			case bytes.Contains(data, []byte("EICAR")):
+				conn.Write([]byte("stream: Eicar-Signature FOUND\x00"))
+			default:
+				conn.Write([]byte("stream: OK\x00"))
+			}
+			conn.Close()
+		}
+	}()
+	return listener.Addr().String(), received
+}
+
+func TestClamdScanner(t *testing.T) {
+	address, received := newFakeClamd(t, 100000)
+	clamd := &scanner.ClamdScanner{Network: "tcp", Address: address, Timeout: time.Second}
+
+	// Data larger than a chunk is sent in several chunks.
+	largeData := strings.Repeat("a", 70000)
+
+	testCases := []struct {
+		data           string
+		expectedResult scanner.Result
+		expectedErr    error
+	}{
+		{
+			data:           "clean data",
+			expectedResult: scanner.Result{},
+		},
+		{
+			data:           largeData,
+			expectedResult: scanner.Result{},
+		},
+		{
+			data:           "X5O!P%@AP[4\\PZX54(P^)7CC)7}$EICAR-STANDARD-ANTIVIRUS-TEST-FILE!$H+H*",
+			expectedResult: scanner.Result{Infected: true, Signature: "Eicar-Signature"},
+		},
+		{
+			data:        largeData + largeData,
+			expectedErr: scanner.ErrScanFailed,
+		},
+	}
+
+	for _, testCase := range testCases {
+		result, err := clamd.Scan(strings.NewReader(testCase.data))
+		if testCase.expectedErr != nil {
+			assert.True(t, errors.Is(err, testCase.expectedErr))
+		} else if assert.NoError(t, err) {
+			assert.Equal(t, testCase.expectedResult, result)
+		}
+		assert.Equal(t, testCase.data, string(<-received))
+	}
+
+	_, err := (&scanner.ClamdScanner{Network: "unix", Address: "/nonexistent/clamd.ctl"}).Scan(strings.NewReader("data"))
+	assert.Error(t, err)
+}
+
+// fakeScanner reports data containing "virus" as infected, and fails every scan while unavailable.
+type fakeScanner struct {
+	unavailable bool
+}
+
+func (f *fakeScanner) Scan(reader io.Reader) (scanner.Result, error) {
+	if f.unavailable {
+		return scanner.Result{}, errors.New("scanner unavailable")
+	}
+	data, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return scanner.Result{}, err
+	}
+	return scanner.Result{Infected: bytes.Contains(data, []byte("virus"))}, nil
+}
+
+func TestScanFileData(t *testing.T) {
+	testServer.RefreshFileSystem()
+
+	err := testServer.SeedData()
+	require.NoError(t, err)
+
+	users := testServer.Data.Users
+	folders := testServer.Data.Folders
+	files := testServer.Data.Files
+
+	// Publishers can see the draft file as well.
+	publisher := users[3]
+	for _, folder := range folders {
+		publisher, err = testServer.GrantAccess(publisher, folder.ID, models.Publisher)
+		require.NoError(t, err)
+	}
+
+	fake := &fakeScanner{}
+	testServer.Server.Scanner = fake
+	defer func() {
+		testServer.Server.Scanner = nil
+	}()
+
+	getFileData := func(fileID uint) *httptest.ResponseRecorder {
+		req, err := http.NewRequest("GET", "/file-data", nil)
+		require.NoError(t, err)
+		req = mux.SetURLVars(req, map[string]string{"id": fmt.Sprint(fileID)})
+		rr := httptest.NewRecorder()
+		testServer.Server.GetFileData(rr, req, publisher)
+		return rr
+	}
+
+	testCases := []struct {
+		fileID         uint
+		user           models.User
+		data           string
+		unavailable    bool
+		expectedStatus models.ScanStatus
+		statusCode     int
+		expectedErr    error
+	}{
+		{
+			fileID:         files[0].ID,
+			user:           users[0],
+			data:           "clean data",
+			expectedStatus: models.ScanClean,
+			statusCode:     http.StatusOK,
+		},
+		{
+			fileID:         files[2].ID,
+			user:           users[2],
+			data:           "data with a virus",
+			expectedStatus: models.ScanInfected,
+			statusCode:     http.StatusForbidden,
+			expectedErr:    models.ErrFileInfected,
+		},
+		{
+			fileID:         files[1].ID,
+			user:           users[1],
+			data:           "data that can't be scanned yet",
+			unavailable:    true,
+			expectedStatus: models.ScanPending,
+			statusCode:     http.StatusConflict,
+			expectedErr:    models.ErrScanPending,
+		},
+	}
+
+	for _, testCase := range testCases {
+		fake.unavailable = testCase.unavailable
+		rr := sendFileData(t, testCase.fileID, testCase.user, testCase.data)
+		var file models.File
+		err = json.Unmarshal(rr.Body.Bytes(), &file)
+		require.NoError(t, err)
+		if assert.Equal(t, http.StatusOK, rr.Code) {
+			assert.Equal(t, testCase.expectedStatus, file.ScanStatus)
+		}
+
+		rr = getFileData(testCase.fileID)
+		if assert.Equal(t, testCase.statusCode, rr.Code) && testCase.expectedErr != nil {
+			responseMap := make(map[string]interface{})
+			err = json.Unmarshal(rr.Body.Bytes(), &responseMap)
+			require.NoError(t, err)
+			assert.Equal(t, testCase.expectedErr.Error(), responseMap["error"])
+		}
+	}
+
+	// Data that couldn't be scanned is scanned again once the scanner is available.
+	fake.unavailable = false
+	err = testServer.Server.ScanPendingFileVersions()
+	require.NoError(t, err)
+
+	file, err := models.GetFileByID(testServer.Server.DB, files[1].ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.ScanClean, file.ScanStatus)
+	rr := getFileData(files[1].ID)
+	if assert.Equal(t, http.StatusOK, rr.Code) {
+		assert.Equal(t, "data that can't be scanned yet", rr.Body.String())
+	}
+
+	// Infected data stays quarantined.
+	file, err = models.GetFileByID(testServer.Server.DB, files[2].ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.ScanInfected, file.ScanStatus)
+}
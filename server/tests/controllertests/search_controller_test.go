@@ -0,0 +1,191 @@
+package controllertests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/invincibot/penn-spark-server/api/controllers"
+	"github.com/invincibot/penn-spark-server/api/models"
+)
+
+// search sends a search request with the given query parameters and returns the response.
+func search(t *testing.T, user models.User, params url.Values) *httptest.ResponseRecorder {
+	req, err := http.NewRequest("GET", "/search?"+params.Encode(), nil)
+	require.NoError(t, err)
+	rr := httptest.NewRecorder()
+	testServer.Server.Search(rr, req, user)
+	return rr
+}
+
+func TestSearch(t *testing.T) {
+	testServer.RefreshFileSystem()
+
+	err := testServer.SeedData()
+	require.NoError(t, err)
+
+	users := testServer.Data.Users
+	files := testServer.Data.Files
+	folders := testServer.Data.Folders
+
+	// The uploader of the draft file2 can only view folder1, while the admin can view every folder.
+	for _, folder := range folders {
+		users[0], err = testServer.GrantAccess(users[0], folder.ID, models.Viewer)
+		require.NoError(t, err)
+	}
+	users[1], err = testServer.GrantAccess(users[1], folders[1].ID, models.Uploader)
+	require.NoError(t, err)
+
+	uploadFileData(t, files[0].ID, users[0], "The annual budget & forecast")
+	uploadFileData(t, files[1].ID, users[1], "Draft budget for the annual retreat")
+
+	testCases := []struct {
+		user           models.User
+		params         url.Values
+		expectedStatus int
+		expectedIDs    []uint
+	}{
+		{
+			// The draft is hidden from users who can't publish it.
+			user:           users[0],
+			params:         url.Values{"q": {"budget"}},
+			expectedStatus: http.StatusOK,
+			expectedIDs:    []uint{files[0].ID},
+		},
+		{
+			// Only the draft is in a folder the uploader can view.
+			user:           users[1],
+			params:         url.Values{"q": {"annual budget"}},
+			expectedStatus: http.StatusOK,
+			expectedIDs:    []uint{files[1].ID},
+		},
+		{
+			user:           users[0],
+			params:         url.Values{"q": {"fold"}},
+			expectedStatus: http.StatusOK,
+			expectedIDs:    []uint{folders[1].ID, folders[2].ID},
+		},
+		{
+			user:           users[3],
+			params:         url.Values{"q": {"fold"}},
+			expectedStatus: http.StatusOK,
+			expectedIDs:    []uint{},
+		},
+		{
+			user:           users[0],
+			params:         url.Values{"q": {"-*"}},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			user:           users[0],
+			params:         url.Values{"q": {"fold"}, "limit": {"101"}},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			user:           users[0],
+			params:         url.Values{"q": {"fold"}, "cursor": {"invalid"}},
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, testCase := range testCases {
+		rr := search(t, testCase.user, testCase.params)
+		require.Equal(t, testCase.expectedStatus, rr.Code, rr.Body.String())
+		if testCase.expectedStatus != http.StatusOK {
+			continue
+		}
+
+		var results []controllers.SearchResult
+		err = json.Unmarshal(rr.Body.Bytes(), &results)
+		require.NoError(t, err)
+
+		ids := []uint{}
+		for _, result := range results {
+			ids = append(ids, result.ID)
+		}
+		assert.ElementsMatch(t, testCase.expectedIDs, ids, testCase.params.Encode())
+	}
+
+	// Paging through the results one at a time returns every result once.
+	rr := search(t, users[0], url.Values{"q": {"fold"}, "limit": {"1"}})
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "2", rr.Header().Get("X-Total-Count"))
+	cursor := rr.Header().Get("X-Next-Cursor")
+	require.NotEmpty(t, cursor)
+	var firstPage []controllers.SearchResult
+	err = json.Unmarshal(rr.Body.Bytes(), &firstPage)
+	require.NoError(t, err)
+	require.Len(t, firstPage, 1)
+
+	rr = search(t, users[0], url.Values{"q": {"fold"}, "limit": {"1"}, "cursor": {cursor}})
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Empty(t, rr.Header().Get("X-Next-Cursor"))
+	var secondPage []controllers.SearchResult
+	err = json.Unmarshal(rr.Body.Bytes(), &secondPage)
+	require.NoError(t, err)
+	require.Len(t, secondPage, 1)
+	assert.ElementsMatch(t, []uint{folders[1].ID, folders[2].ID}, []uint{firstPage[0].ID, secondPage[0].ID})
+
+	// Snippets are escaped, with the matching words marked.
+	rr = search(t, users[0], url.Values{"q": {"forecast"}})
+	require.Equal(t, http.StatusOK, rr.Code)
+	var results []controllers.SearchResult
+	err = json.Unmarshal(rr.Body.Bytes(), &results)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, controllers.SearchResultFile, results[0].Type)
+	assert.Equal(t, folders[0].ID, *results[0].FolderID)
+	assert.Equal(t, "The annual budget &amp; <mark>forecast</mark>", results[0].Snippet)
+}
+
+func TestIndexFileContents(t *testing.T) {
+	testServer.RefreshFileSystem()
+
+	err := testServer.SeedData()
+	require.NoError(t, err)
+
+	users := testServer.Data.Users
+	file := testServer.Data.Files[0]
+	users[0], err = testServer.GrantAccess(users[0], file.FolderID, models.Viewer)
+	require.NoError(t, err)
+
+	// Data that isn't clean yet isn't indexed until it has been scanned.
+	testServer.Server.Scanner = &fakeScanner{unavailable: true}
+	defer func() {
+		testServer.Server.Scanner = nil
+	}()
+	uploadFileData(t, file.ID, users[0], "minutes of the meeting")
+	assert.Empty(t, searchIDs(t, users[0], "minutes"))
+
+	testServer.Server.Scanner = nil
+	require.NoError(t, testServer.Server.ScanPendingFileVersions())
+	assert.Empty(t, searchIDs(t, users[0], "minutes"))
+
+	require.NoError(t, testServer.Server.IndexFileContents())
+	assert.Equal(t, []uint{file.ID}, searchIDs(t, users[0], "minutes"))
+
+	unindexed, err := models.GetUnindexedFiles(testServer.Server.DB)
+	require.NoError(t, err)
+	assert.Empty(t, unindexed)
+}
+
+// searchIDs returns the ids of the results of searching for the given text.
+func searchIDs(t *testing.T, user models.User, text string) []uint {
+	rr := search(t, user, url.Values{"q": {text}})
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	var results []controllers.SearchResult
+	err := json.Unmarshal(rr.Body.Bytes(), &results)
+	require.NoError(t, err)
+
+	ids := []uint{}
+	for _, result := range results {
+		ids = append(ids, result.ID)
+	}
+	return ids
+}
@@ -0,0 +1,251 @@
+package controllertests
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/invincibot/penn-spark-server/api/controllers"
+	"github.com/invincibot/penn-spark-server/api/models"
+)
+
+func TestGetTrash(t *testing.T) {
+	err := testServer.SeedData()
+	require.NoError(t, err)
+
+	users := testServer.Data.Users
+	folders := testServer.Data.Folders
+	files := testServer.Data.Files
+
+	publisher, err := testServer.GrantAccess(users[1], folders[2].ID, models.Publisher)
+	require.NoError(t, err)
+
+	err = models.DeleteFile(testServer.Server.DB, files[2].ID, publisher.ID)
+	require.NoError(t, err)
+	err = models.DeleteFile(testServer.Server.DB, files[1].ID, users[2].ID)
+	require.NoError(t, err)
+
+	testCases := []struct {
+		user          models.User
+		expectedItems []uint
+	}{
+		{
+			user:          publisher,
+			expectedItems: []uint{files[2].ID},
+		},
+		{
+			// Users need to be able to restore the item to see it.
+			user:          users[2],
+			expectedItems: []uint{},
+		},
+		{
+			// Admins still need publisher access to restore items.
+			user:          users[0],
+			expectedItems: []uint{},
+		},
+	}
+
+	for _, testCase := range testCases {
+		req, err := http.NewRequest("GET", "/trash", nil)
+		require.NoError(t, err)
+		rr := httptest.NewRecorder()
+		testServer.Server.GetTrash(rr, req, testCase.user)
+
+		var items []map[string]interface{}
+		err = json.Unmarshal(rr.Body.Bytes(), &items)
+		require.NoError(t, err)
+		if assert.Equal(t, http.StatusOK, rr.Code) && assert.Len(t, items, len(testCase.expectedItems)) {
+			for i, id := range testCase.expectedItems {
+				assert.Equal(t, float64(id), items[i]["id"])
+				assert.Equal(t, models.TrashItemFile, items[i]["type"])
+			}
+		}
+	}
+}
+
+func TestRestoreTrashItem(t *testing.T) {
+	err := testServer.SeedData()
+	require.NoError(t, err)
+
+	users := testServer.Data.Users
+	folders := testServer.Data.Folders
+	files := testServer.Data.Files
+
+	publisher, err := testServer.GrantAccess(users[1], folders[2].ID, models.Publisher)
+	require.NoError(t, err)
+
+	err = models.DeleteFile(testServer.Server.DB, files[2].ID, publisher.ID)
+	require.NoError(t, err)
+
+	testCases := []struct {
+		itemType    string
+		id          uint
+		user        models.User
+		statusCode  int
+		expectedErr error
+	}{
+		{
+			itemType:    models.TrashItemFile,
+			id:          files[2].ID,
+			user:        users[2],
+			statusCode:  http.StatusForbidden,
+			expectedErr: controllers.ErrUserForbidden,
+		},
+		{
+			itemType:    models.TrashItemFile,
+			id:          files[2].ID,
+			user:        users[0],
+			statusCode:  http.StatusForbidden,
+			expectedErr: controllers.ErrUserForbidden,
+		},
+		{
+			itemType:    "invalid",
+			id:          files[2].ID,
+			user:        publisher,
+			statusCode:  http.StatusBadRequest,
+			expectedErr: models.ErrInvalidTrashItemType,
+		},
+		{
+			itemType:   models.TrashItemFile,
+			id:         files[2].ID,
+			user:       publisher,
+			statusCode: http.StatusOK,
+		},
+		{
+			itemType:    models.TrashItemFile,
+			id:          files[2].ID,
+			user:        publisher,
+			statusCode:  http.StatusBadRequest,
+			expectedErr: models.ErrTrashItemNotFound,
+		},
+	}
+
+	for _, testCase := range testCases {
+		req, err := http.NewRequest("POST", "/trash/restore", nil)
+		require.NoError(t, err)
+		req = mux.SetURLVars(req, map[string]string{"type": testCase.itemType, "id": fmt.Sprint(testCase.id)})
+		rr := httptest.NewRecorder()
+		testServer.Server.RestoreTrashItem(rr, req, testCase.user)
+
+		responseMap := make(map[string]interface{})
+		err = json.Unmarshal(rr.Body.Bytes(), &responseMap)
+		require.NoError(t, err)
+		if assert.Equal(t, testCase.statusCode, rr.Code) {
+			switch testCase.statusCode {
+			case http.StatusOK:
+				assert.Equal(t, float64(testCase.id), responseMap["id"])
+				file, err := models.GetFileByID(testServer.Server.DB, testCase.id)
+				require.NoError(t, err)
+				assert.Equal(t, publisher.ID, file.LastEditorID)
+			case http.StatusBadRequest:
+				assert.Equal(t, testCase.expectedErr.Error(), responseMap["error"])
+			}
+		}
+	}
+}
+
+func TestPurgeTrashItem(t *testing.T) {
+	testServer.RefreshFileSystem()
+
+	err := testServer.SeedData()
+	require.NoError(t, err)
+
+	users := testServer.Data.Users
+	file := testServer.Data.Files[2]
+	otherFile := testServer.Data.Files[1]
+
+	uploadFileData(t, file.ID, users[2], "text")
+	uploadFileData(t, file.ID, users[2], "shared")
+	uploadFileData(t, otherFile.ID, users[1], "shared")
+	versions, err := models.GetFileVersions(testServer.Server.DB, file.ID)
+	require.NoError(t, err)
+	err = models.DeleteFile(testServer.Server.DB, file.ID, users[0].ID)
+	require.NoError(t, err)
+
+	testCases := []struct {
+		id          uint
+		statusCode  int
+		expectedErr error
+	}{
+		{
+			id:         file.ID,
+			statusCode: http.StatusNoContent,
+		},
+		{
+			id:          file.ID,
+			statusCode:  http.StatusBadRequest,
+			expectedErr: models.ErrTrashItemNotFound,
+		},
+	}
+
+	for _, testCase := range testCases {
+		req, err := http.NewRequest("DELETE", "/trash", nil)
+		require.NoError(t, err)
+		req = mux.SetURLVars(req, map[string]string{"type": models.TrashItemFile, "id": fmt.Sprint(testCase.id)})
+		rr := httptest.NewRecorder()
+		testServer.Server.PurgeTrashItem(rr, req, users[0])
+
+		if assert.Equal(t, testCase.statusCode, rr.Code) {
+			switch testCase.statusCode {
+			case http.StatusNoContent:
+				_, err = testServer.Server.FileSystem.GetBlobRaw(versions[0].Checksum)
+				assert.Error(t, err)
+
+				// Data shared with another file is kept.
+				data, err := testServer.Server.FileSystem.GetBlobRaw(versions[1].Checksum)
+				if assert.NoError(t, err) {
+					data.Close()
+				}
+				blob, err := models.GetBlob(testServer.Server.DB, versions[1].Checksum)
+				require.NoError(t, err)
+				assert.Equal(t, int64(1), blob.RefCount)
+			case http.StatusBadRequest:
+				responseMap := make(map[string]interface{})
+				err = json.Unmarshal(rr.Body.Bytes(), &responseMap)
+				require.NoError(t, err)
+				assert.Equal(t, testCase.expectedErr.Error(), responseMap["error"])
+			}
+		}
+	}
+}
+
+func TestSweepTrash(t *testing.T) {
+	testServer.RefreshFileSystem()
+
+	err := testServer.SeedData()
+	require.NoError(t, err)
+
+	users := testServer.Data.Users
+	file := testServer.Data.Files[2]
+
+	uploadFileData(t, file.ID, users[2], "text")
+	version, err := models.GetLatestFileVersion(testServer.Server.DB, file.ID)
+	require.NoError(t, err)
+	err = models.DeleteFile(testServer.Server.DB, file.ID, users[0].ID)
+	require.NoError(t, err)
+	err = models.DeleteFolder(testServer.Server.DB, file.FolderID, users[0].ID)
+	require.NoError(t, err)
+
+	// Items still within the retention period are kept.
+	err = testServer.Server.SweepTrash(time.Hour)
+	require.NoError(t, err)
+	items, err := models.GetTrash(testServer.Server.DB, 0)
+	require.NoError(t, err)
+	assert.Len(t, items, 2)
+
+	err = testServer.Server.SweepTrash(0)
+	require.NoError(t, err)
+	items, err = models.GetTrash(testServer.Server.DB, 0)
+	require.NoError(t, err)
+	assert.Len(t, items, 0)
+
+	_, err = testServer.Server.FileSystem.GetBlobRaw(version.Checksum)
+	assert.Error(t, err)
+}
@@ -0,0 +1,249 @@
+package controllertests
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/invincibot/penn-spark-server/api/controllers"
+	"github.com/invincibot/penn-spark-server/api/filesystem"
+	"github.com/invincibot/penn-spark-server/api/models"
+)
+
+// pngHeader is the start of a PNG image, which is enough for its content type to be detected.
+const pngHeader = "\x89PNG\r\n\x1a\n"
+
+// sendFileData uploads data to a file and returns the response.
+func sendFileData(t *testing.T, fileID uint, user models.User, data string) *httptest.ResponseRecorder {
+	var b bytes.Buffer
+	w := multipart.NewWriter(&b)
+	fw, err := w.CreateFormFile("file", "file")
+	require.NoError(t, err)
+	_, err = fw.Write([]byte(data))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	req, err := http.NewRequest("PUT", "/file-data", &b)
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", w.FormDataContentType())
+	req = mux.SetURLVars(req, map[string]string{"id": fmt.Sprint(fileID)})
+	rr := httptest.NewRecorder()
+	testServer.Server.CreateFileData(rr, req, user)
+	return rr
+}
+
+func TestUpdateUploadPolicy(t *testing.T) {
+	err := testServer.SeedData()
+	require.NoError(t, err)
+
+	users := testServer.Data.Users
+	folders := testServer.Data.Folders
+
+	viewer, err := testServer.GrantAccess(users[3], folders[2].ID, models.Viewer)
+	require.NoError(t, err)
+
+	testCases := []struct {
+		id          uint
+		body        string
+		statusCode  int
+		expectedErr error
+	}{
+		{
+			id:         folders[1].ID,
+			body:       `{"allowed_types": ["image/*", "text/plain"], "max_file_size": 20}`,
+			statusCode: http.StatusOK,
+		},
+		{
+			id:          folders[1].ID,
+			body:        `{"allowed_types": ["exe"]}`,
+			statusCode:  http.StatusBadRequest,
+			expectedErr: models.ErrInvalidContentType,
+		},
+		{
+			id:          999,
+			body:        `{}`,
+			statusCode:  http.StatusBadRequest,
+			expectedErr: models.ErrFolderNotFound,
+		},
+	}
+
+	for _, testCase := range testCases {
+		req, err := http.NewRequest("PUT", "/folders/upload-policy", bytes.NewBufferString(testCase.body))
+		require.NoError(t, err)
+		req = mux.SetURLVars(req, map[string]string{"id": fmt.Sprint(testCase.id)})
+		rr := httptest.NewRecorder()
+		testServer.Server.UpdateUploadPolicy(rr, req, users[0])
+
+		responseMap := make(map[string]interface{})
+		err = json.Unmarshal(rr.Body.Bytes(), &responseMap)
+		require.NoError(t, err)
+		if assert.Equal(t, testCase.statusCode, rr.Code) {
+			switch testCase.statusCode {
+			case http.StatusOK:
+				assert.Equal(t, float64(testCase.id), responseMap["folder_id"])
+				assert.Equal(t, []interface{}{"image/*", "text/plain"}, responseMap["allowed_types"])
+				assert.Equal(t, []interface{}{}, responseMap["denied_types"])
+				assert.Equal(t, float64(20), responseMap["max_file_size"])
+			default:
+				assert.Equal(t, testCase.expectedErr.Error(), responseMap["error"])
+			}
+		}
+	}
+
+	// Viewers of a folder can see the policy set on its ancestors.
+	req, err := http.NewRequest("GET", "/folders/upload-policy", nil)
+	require.NoError(t, err)
+	req = mux.SetURLVars(req, map[string]string{"id": fmt.Sprint(folders[2].ID)})
+	rr := httptest.NewRecorder()
+	testServer.Server.GetUploadPolicy(rr, req, viewer)
+	var policy models.UploadPolicy
+	err = json.Unmarshal(rr.Body.Bytes(), &policy)
+	require.NoError(t, err)
+	if assert.Equal(t, http.StatusOK, rr.Code) {
+		assert.Equal(t, folders[1].ID, policy.FolderID)
+		assert.Equal(t, int64(20), policy.MaxFileSize)
+	}
+
+	req, err = http.NewRequest("GET", "/folders/upload-policy", nil)
+	require.NoError(t, err)
+	req = mux.SetURLVars(req, map[string]string{"id": fmt.Sprint(folders[1].ID)})
+	rr = httptest.NewRecorder()
+	testServer.Server.GetUploadPolicy(rr, req, viewer)
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+
+	req, err = http.NewRequest("DELETE", "/folders/upload-policy", nil)
+	require.NoError(t, err)
+	req = mux.SetURLVars(req, map[string]string{"id": fmt.Sprint(folders[1].ID)})
+	rr = httptest.NewRecorder()
+	testServer.Server.DeleteUploadPolicy(rr, req, users[0])
+	assert.Equal(t, http.StatusNoContent, rr.Code)
+
+	_, err = models.GetUploadPolicy(testServer.Server.DB, folders[1].ID)
+	assert.Equal(t, models.ErrUploadPolicyNotFound, err)
+}
+
+func TestCreateFileDataUploadPolicy(t *testing.T) {
+	testServer.RefreshFileSystem()
+
+	err := testServer.SeedData()
+	require.NoError(t, err)
+
+	users := testServer.Data.Users
+	folders := testServer.Data.Folders
+	files := testServer.Data.Files
+
+	_, err = models.UpsertUploadPolicy(testServer.Server.DB, models.UploadPolicy{
+		FolderID:     folders[1].ID,
+		AllowedTypes: []string{"image/*", "text/*"},
+		MaxFileSize:  20,
+		LastEditorID: users[0].ID,
+	})
+	require.NoError(t, err)
+
+	// The policy of folder1 applies to file3 in folder2.
+	testCases := []struct {
+		data                string
+		statusCode          int
+		expectedContentType string
+		expectedErr         error
+	}{
+		{
+			data:                "plain text",
+			statusCode:          http.StatusOK,
+			expectedContentType: "text/plain; charset=utf-8",
+		},
+		{
+			data:                pngHeader + "image",
+			statusCode:          http.StatusOK,
+			expectedContentType: "image/png",
+		},
+		{
+			data:        "MZ\x90\x00\x03\x00\x00\x00\x04\x00",
+			statusCode:  http.StatusUnsupportedMediaType,
+			expectedErr: models.ErrContentTypeNotAllowed,
+		},
+		{
+			data:        "text that is too long for the folder",
+			statusCode:  http.StatusRequestEntityTooLarge,
+			expectedErr: filesystem.ErrFileTooLarge,
+		},
+	}
+
+	for _, testCase := range testCases {
+		rr := sendFileData(t, files[2].ID, users[2], testCase.data)
+
+		responseMap := make(map[string]interface{})
+		err = json.Unmarshal(rr.Body.Bytes(), &responseMap)
+		require.NoError(t, err)
+		if assert.Equal(t, testCase.statusCode, rr.Code) {
+			switch testCase.statusCode {
+			case http.StatusOK:
+				assert.Equal(t, testCase.expectedContentType, responseMap["content_type"])
+				assert.Equal(t, float64(len(testCase.data)), responseMap["size"])
+			default:
+				assert.Equal(t, testCase.expectedErr.Error(), responseMap["error"])
+			}
+		}
+	}
+
+	// The stored content type is sent with the data instead of one guessed from the name.
+	req, err := http.NewRequest("GET", "/file-data", nil)
+	require.NoError(t, err)
+	req = mux.SetURLVars(req, map[string]string{"id": fmt.Sprint(files[2].ID)})
+	rr := httptest.NewRecorder()
+	testServer.Server.GetFileData(rr, req, users[0])
+	if assert.Equal(t, http.StatusOK, rr.Code) {
+		assert.Equal(t, "image/png", rr.Header().Get("Content-Type"))
+		assert.Equal(t, "nosniff", rr.Header().Get("X-Content-Type-Options"))
+	}
+
+	// Files outside the folder aren't restricted.
+	rr = sendFileData(t, files[0].ID, users[0], "MZ\x90\x00\x03\x00\x00\x00\x04\x00 and more than twenty bytes")
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	// Uploads in sessions are checked once their data is complete.
+	users[2], err = testServer.GrantAccess(users[2], files[2].FolderID, models.Uploader)
+	require.NoError(t, err)
+	data := "MZ\x90\x00\x03\x00\x00\x00\x04\x00"
+	session := createUploadSession(t, files[2].ID, users[2], int64(len(data)))
+	rr = uploadChunk(session.ID, 1, 0, users[2], data)
+	require.Equal(t, http.StatusOK, rr.Code)
+	req, err = http.NewRequest("POST", "/uploads/finalize", nil)
+	require.NoError(t, err)
+	req = mux.SetURLVars(req, map[string]string{"id": fmt.Sprint(session.ID)})
+	rr = httptest.NewRecorder()
+	testServer.Server.FinalizeUploadSession(rr, req, users[2])
+	assert.Equal(t, http.StatusUnsupportedMediaType, rr.Code)
+
+	body, err := json.Marshal(map[string]interface{}{"file_id": files[2].ID, "length": 21})
+	require.NoError(t, err)
+	req, err = http.NewRequest("POST", "/uploads", bytes.NewBuffer(body))
+	require.NoError(t, err)
+	rr = httptest.NewRecorder()
+	testServer.Server.CreateUploadSession(rr, req, users[2])
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rr.Code)
+
+	// Imported files the policy doesn't allow are reported as conflicts.
+	publisher, err := testServer.GrantAccess(users[3], folders[1].ID, models.Publisher)
+	require.NoError(t, err)
+	rr = importArchive(t, folders[1].ID, publisher, []string{"notes.csv", "notes-with-a-long-name.txt"})
+	var report controllers.ImportReport
+	err = json.Unmarshal(rr.Body.Bytes(), &report)
+	require.NoError(t, err)
+	if assert.Equal(t, http.StatusCreated, rr.Code) {
+		if assert.Len(t, report.Files, 1) {
+			assert.Equal(t, "text/csv; charset=utf-8", report.Files[0].ContentType)
+		}
+		assert.Equal(t, []controllers.ImportConflict{
+			{Path: "notes-with-a-long-name.txt", Error: filesystem.ErrFileTooLarge.Error()},
+		}, report.Conflicts)
+	}
+}
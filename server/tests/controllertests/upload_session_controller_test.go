@@ -0,0 +1,353 @@
+package controllertests
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/invincibot/penn-spark-server/api/controllers"
+	"github.com/invincibot/penn-spark-server/api/filesystem"
+	"github.com/invincibot/penn-spark-server/api/models"
+)
+
+func createUploadSession(t *testing.T, fileID uint, user models.User, length int64) models.UploadSession {
+	body, err := json.Marshal(map[string]interface{}{"file_id": fileID, "length": length})
+	require.NoError(t, err)
+
+	req, err := http.NewRequest("POST", "/uploads", bytes.NewBuffer(body))
+	require.NoError(t, err)
+	rr := httptest.NewRecorder()
+	testServer.Server.CreateUploadSession(rr, req, user)
+	require.Equal(t, http.StatusCreated, rr.Code)
+
+	var session models.UploadSession
+	err = json.Unmarshal(rr.Body.Bytes(), &session)
+	require.NoError(t, err)
+	return session
+}
+
+func uploadChunk(sessionID, number uint, offset int64, user models.User, data string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest("PUT", "/uploads/chunks", bytes.NewBufferString(data))
+	req.Header.Set("Upload-Offset", fmt.Sprint(offset))
+	req = mux.SetURLVars(req, map[string]string{"id": fmt.Sprint(sessionID), "number": fmt.Sprint(number)})
+	rr := httptest.NewRecorder()
+	testServer.Server.UploadChunk(rr, req, user)
+	return rr
+}
+
+func TestCreateUploadSession(t *testing.T) {
+	err := testServer.SeedData()
+	require.NoError(t, err)
+
+	users := testServer.Data.Users
+	file := testServer.Data.Files[1]
+
+	testServer.Server.UploadSessionLifetime = time.Hour
+	testServer.Server.MaxUploadSize = 100
+	defer func() {
+		testServer.Server.MaxUploadSize = 0
+	}()
+
+	testCases := []struct {
+		fileID      uint
+		user        models.User
+		length      int64
+		statusCode  int
+		expectedErr error
+	}{
+		{
+			fileID:     file.ID,
+			user:       users[1],
+			length:     10,
+			statusCode: http.StatusCreated,
+		},
+		{
+			fileID:      file.ID,
+			user:        users[2],
+			length:      10,
+			statusCode:  http.StatusForbidden,
+			expectedErr: controllers.ErrUserForbidden,
+		},
+		{
+			fileID:      file.ID,
+			user:        users[1],
+			length:      101,
+			statusCode:  http.StatusRequestEntityTooLarge,
+			expectedErr: nil,
+		},
+		{
+			fileID:      100,
+			user:        users[1],
+			length:      10,
+			statusCode:  http.StatusBadRequest,
+			expectedErr: models.ErrFileNotFound,
+		},
+	}
+
+	for _, testCase := range testCases {
+		body, err := json.Marshal(map[string]interface{}{"file_id": testCase.fileID, "length": testCase.length})
+		require.NoError(t, err)
+
+		req, err := http.NewRequest("POST", "/uploads", bytes.NewBuffer(body))
+		require.NoError(t, err)
+		rr := httptest.NewRecorder()
+		testServer.Server.CreateUploadSession(rr, req, testCase.user)
+
+		responseMap := make(map[string]interface{})
+		err = json.Unmarshal(rr.Body.Bytes(), &responseMap)
+		require.NoError(t, err)
+		if assert.Equal(t, testCase.statusCode, rr.Code) {
+			switch testCase.statusCode {
+			case http.StatusCreated:
+				assert.Equal(t, float64(testCase.fileID), responseMap["file_id"])
+				assert.Equal(t, "0", rr.Header().Get("Upload-Offset"))
+				assert.Equal(t, fmt.Sprint(testCase.length), rr.Header().Get("Upload-Length"))
+				assert.NotEmpty(t, rr.Header().Get("Upload-Expires"))
+			case http.StatusBadRequest:
+				assert.Equal(t, testCase.expectedErr.Error(), responseMap["error"])
+			}
+		}
+	}
+}
+
+func TestUploadChunk(t *testing.T) {
+	testServer.RefreshFileSystem()
+
+	err := testServer.SeedData()
+	require.NoError(t, err)
+
+	users := testServer.Data.Users
+	file := testServer.Data.Files[1]
+
+	testServer.Server.UploadSessionLifetime = time.Hour
+	session := createUploadSession(t, file.ID, users[1], 8)
+
+	testCases := []struct {
+		number         uint
+		offset         int64
+		user           models.User
+		data           string
+		statusCode     int
+		expectedErr    error
+		expectedOffset string
+	}{
+		{
+			number:         2,
+			offset:         4,
+			user:           users[1],
+			data:           "data",
+			statusCode:     http.StatusOK,
+			expectedOffset: "0",
+		},
+		{
+			number:      1,
+			offset:      0,
+			user:        users[2],
+			data:        "text",
+			statusCode:  http.StatusForbidden,
+			expectedErr: controllers.ErrUserForbidden,
+		},
+		{
+			number:      3,
+			offset:      6,
+			user:        users[1],
+			data:        "text",
+			statusCode:  http.StatusBadRequest,
+			expectedErr: models.ErrChunkOutOfRange,
+		},
+		{
+			number:      3,
+			offset:      2,
+			user:        users[1],
+			data:        "text",
+			statusCode:  http.StatusBadRequest,
+			expectedErr: models.ErrChunkOverlaps,
+		},
+		{
+			number:         1,
+			offset:         0,
+			user:           users[1],
+			data:           "text",
+			statusCode:     http.StatusOK,
+			expectedOffset: "8",
+		},
+	}
+
+	for _, testCase := range testCases {
+		rr := uploadChunk(session.ID, testCase.number, testCase.offset, testCase.user, testCase.data)
+
+		responseMap := make(map[string]interface{})
+		err = json.Unmarshal(rr.Body.Bytes(), &responseMap)
+		require.NoError(t, err)
+		if assert.Equal(t, testCase.statusCode, rr.Code) {
+			switch testCase.statusCode {
+			case http.StatusOK:
+				assert.Equal(t, testCase.expectedOffset, rr.Header().Get("Upload-Offset"))
+			case http.StatusBadRequest:
+				assert.Equal(t, testCase.expectedErr.Error(), responseMap["error"])
+			}
+		}
+	}
+
+	req, err := http.NewRequest("GET", "/uploads", nil)
+	require.NoError(t, err)
+	req = mux.SetURLVars(req, map[string]string{"id": fmt.Sprint(session.ID)})
+	rr := httptest.NewRecorder()
+	testServer.Server.GetUploadSession(rr, req, users[1])
+
+	err = json.Unmarshal(rr.Body.Bytes(), &session)
+	require.NoError(t, err)
+	if assert.Equal(t, http.StatusOK, rr.Code) {
+		assert.Equal(t, int64(8), session.Offset)
+		assert.Equal(t, [][2]int64{{0, 8}}, session.ReceivedRanges)
+	}
+}
+
+// failingBlobStore is a BlobStore that can't store any data.
+type failingBlobStore struct {
+	filesystem.BlobStore
+}
+
+func (failingBlobStore) Put(string, io.Reader, int64) error {
+	return errors.New("store unavailable")
+}
+
+func TestUploadChunkStoreFailure(t *testing.T) {
+	testServer.RefreshFileSystem()
+
+	err := testServer.SeedData()
+	require.NoError(t, err)
+
+	users := testServer.Data.Users
+	file := testServer.Data.Files[1]
+
+	testServer.Server.UploadSessionLifetime = time.Hour
+	session := createUploadSession(t, file.ID, users[1], 8)
+
+	// The chunk isn't recorded when its data can't be stored.
+	testServer.Server.FileSystem.Store = failingBlobStore{}
+	rr := uploadChunk(session.ID, 1, 0, users[1], "text")
+	testServer.RefreshFileSystem()
+	assert.Equal(t, http.StatusInternalServerError, rr.Code)
+
+	session, err = models.GetUploadSessionByID(testServer.Server.DB, session.ID)
+	require.NoError(t, err)
+	assert.Len(t, session.Chunks, 0)
+	assert.Equal(t, int64(0), session.Offset)
+}
+
+func TestFinalizeUploadSession(t *testing.T) {
+	testServer.RefreshFileSystem()
+
+	err := testServer.SeedData()
+	require.NoError(t, err)
+
+	users := testServer.Data.Users
+	file := testServer.Data.Files[1]
+
+	users[1], err = testServer.GrantAccess(users[1], file.FolderID, models.Uploader)
+	require.NoError(t, err)
+
+	testServer.Server.UploadSessionLifetime = time.Hour
+	session := createUploadSession(t, file.ID, users[1], 8)
+
+	finalize := func(user models.User) *httptest.ResponseRecorder {
+		req, err := http.NewRequest("POST", "/uploads/finalize", nil)
+		require.NoError(t, err)
+		req = mux.SetURLVars(req, map[string]string{"id": fmt.Sprint(session.ID)})
+		rr := httptest.NewRecorder()
+		testServer.Server.FinalizeUploadSession(rr, req, user)
+		return rr
+	}
+
+	rr := uploadChunk(session.ID, 2, 4, users[1], "data")
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	rr = finalize(users[1])
+	responseMap := make(map[string]interface{})
+	err = json.Unmarshal(rr.Body.Bytes(), &responseMap)
+	require.NoError(t, err)
+	if assert.Equal(t, http.StatusBadRequest, rr.Code) {
+		assert.Equal(t, models.ErrUploadIncomplete.Error(), responseMap["error"])
+	}
+
+	rr = uploadChunk(session.ID, 1, 0, users[1], "text")
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	rr = finalize(users[2])
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+
+	rr = finalize(users[1])
+	var version models.FileVersion
+	err = json.Unmarshal(rr.Body.Bytes(), &version)
+	require.NoError(t, err)
+	if assert.Equal(t, http.StatusCreated, rr.Code) {
+		assert.Equal(t, uint(1), version.Number)
+		assert.Equal(t, int64(8), version.Size)
+
+		data, err := testServer.Server.FileSystem.GetBlobRaw(version.Checksum)
+		require.NoError(t, err)
+		content, err := ioutil.ReadAll(data)
+		require.NoError(t, err)
+		assert.Equal(t, "textdata", string(content))
+	}
+
+	// The session is removed once it is finalized.
+	_, err = models.GetUploadSessionByID(testServer.Server.DB, session.ID)
+	assert.Equal(t, models.ErrUploadSessionNotFound, err)
+
+	// Sessions can't be finalized once another user has taken over the file.
+	session = createUploadSession(t, file.ID, users[1], 4)
+	rr = uploadChunk(session.ID, 1, 0, users[1], "text")
+	require.Equal(t, http.StatusOK, rr.Code)
+	file.LastEditorID = users[2].ID
+	_, err = models.UpdateFile(testServer.Server.DB, file)
+	require.NoError(t, err)
+	rr = finalize(users[1])
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+}
+
+func TestSweepUploadSessions(t *testing.T) {
+	testServer.RefreshFileSystem()
+
+	err := testServer.SeedData()
+	require.NoError(t, err)
+
+	users := testServer.Data.Users
+	file := testServer.Data.Files[1]
+
+	testServer.Server.UploadSessionLifetime = time.Hour
+	session := createUploadSession(t, file.ID, users[1], 8)
+	rr := uploadChunk(session.ID, 1, 0, users[1], "text")
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	// Sessions that haven't expired are kept.
+	err = testServer.Server.SweepUploadSessions()
+	require.NoError(t, err)
+	_, err = models.GetUploadSessionByID(testServer.Server.DB, session.ID)
+	require.NoError(t, err)
+
+	err = testServer.Server.DB.Model(&session).Update("expires_at", time.Now().Add(-time.Minute)).Error
+	require.NoError(t, err)
+
+	err = testServer.Server.SweepUploadSessions()
+	require.NoError(t, err)
+	_, err = models.GetUploadSessionByID(testServer.Server.DB, session.ID)
+	assert.Equal(t, models.ErrUploadSessionNotFound, err)
+
+	exists, err := afero.Exists(testServer.Server.FileSystem, fmt.Sprintf("%s/uploads/%d", testServer.Server.FileSystem.FilePath, session.ID))
+	require.NoError(t, err)
+	assert.False(t, exists)
+}
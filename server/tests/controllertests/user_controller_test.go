@@ -82,7 +82,7 @@ func TestGetUsers(t *testing.T) {
 	require.NoError(t, err)
 
 	rr := httptest.NewRecorder()
-	testServer.Server.GetUsers(rr, req, models.User{})
+	testServer.Server.GetAllUsers(rr, req, models.User{})
 
 	var returnedUsers []map[string]interface{}
 	err = json.Unmarshal([]byte(rr.Body.String()), &returnedUsers)
@@ -94,6 +94,48 @@ func TestGetUsers(t *testing.T) {
 	}
 }
 
+func TestGetUsersPage(t *testing.T) {
+	err := testServer.SeedData()
+	require.NoError(t, err)
+
+	users := testServer.Data.Users
+
+	// The users are sorted by their Model.ID by default, so they are returned in the order they were created.
+	returnedUsers := []map[string]interface{}{}
+	cursor := ""
+	for {
+		req, err := http.NewRequest("GET", "/users/admin?limit=3&cursor="+cursor, nil)
+		require.NoError(t, err)
+		rr := httptest.NewRecorder()
+		testServer.Server.GetAllUsers(rr, req, models.User{})
+		require.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, fmt.Sprint(len(users)), rr.Header().Get("X-Total-Count"))
+
+		var page []map[string]interface{}
+		err = json.Unmarshal(rr.Body.Bytes(), &page)
+		require.NoError(t, err)
+		returnedUsers = append(returnedUsers, page...)
+
+		cursor = rr.Header().Get("X-Next-Cursor")
+		if cursor == "" {
+			break
+		}
+	}
+
+	require.Len(t, returnedUsers, len(users))
+	for i, user := range users {
+		util.CheckUsersEqual(t, user, returnedUsers[i])
+	}
+
+	for _, query := range []string{"sort=password", "order=up", "limit=-1", "cursor=invalid"} {
+		req, err := http.NewRequest("GET", "/users/admin?"+query, nil)
+		require.NoError(t, err)
+		rr := httptest.NewRecorder()
+		testServer.Server.GetAllUsers(rr, req, models.User{})
+		assert.Equal(t, http.StatusBadRequest, rr.Code, query)
+	}
+}
+
 func TestGetUserByID(t *testing.T) {
 	err := testServer.SeedData()
 	require.NoError(t, err)
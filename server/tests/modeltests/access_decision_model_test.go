@@ -0,0 +1,143 @@
+package modeltests
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/invincibot/penn-spark-server/api/models"
+)
+
+func TestGetUserAccessDecision(t *testing.T) {
+	err := testServer.SeedData()
+	require.NoError(t, err)
+
+	admin := testServer.Data.Users[0]
+	user := testServer.Data.Users[3]
+	root := testServer.Data.Folders[0]
+
+	createFolder := func(name string, parentFolderID uint) models.Folder {
+		folder, err := models.CreateFolder(testServer.Server.DB, models.Folder{
+			Name:           name,
+			ParentFolderID: &parentFolderID,
+			LastEditorID:   admin.ID,
+		})
+		require.NoError(t, err)
+		return folder
+	}
+	department := createFolder("department", root.ID)
+	public := createFolder("public", department.ID)
+	hr := createFolder("hr", department.ID)
+	payroll := createFolder("payroll", hr.ID)
+	reviews := createFolder("reviews", hr.ID)
+
+	inheritable := true
+	grant := func(user models.User, folderID uint, accessLevel models.AccessLevel, inherited bool) models.User {
+		accessRole := models.AccessRole{FolderID: folderID, AccessLevel: accessLevel}
+		if inherited {
+			accessRole.Inheritable = &inheritable
+		}
+		user, err := testServer.GrantAccessRole(user, accessRole)
+		require.NoError(t, err)
+		return user
+	}
+
+	// The department has broad access, except for the hr folder.
+	user = grant(user, department.ID, models.Viewer, true)
+	user = grant(user, hr.ID, models.None, true)
+	user = grant(user, hr.ID, models.Uploader, false)
+	user = grant(user, payroll.ID, models.Uploader, false)
+	admin = grant(admin, hr.ID, models.None, true)
+
+	testCases := []struct {
+		user             models.User
+		folderID         uint
+		expectedDecision models.AccessDecision
+	}{
+		{
+			user:     user,
+			folderID: root.ID,
+			expectedDecision: models.AccessDecision{
+				AccessLevel: models.None,
+				Reason:      models.ReasonDefault,
+			},
+		},
+		{
+			user:     user,
+			folderID: public.ID,
+			expectedDecision: models.AccessDecision{
+				AccessLevel:    models.Viewer,
+				Reason:         models.ReasonInherited,
+				SourceFolderID: department.ID,
+			},
+		},
+		{
+			// A denial overrides the grants of other user roles in the same folder.
+			user:     user,
+			folderID: hr.ID,
+			expectedDecision: models.AccessDecision{
+				AccessLevel:    models.None,
+				Reason:         models.ReasonDenied,
+				SourceFolderID: hr.ID,
+			},
+		},
+		{
+			// A denial overrides the grants inherited from further ancestors.
+			user:     user,
+			folderID: reviews.ID,
+			expectedDecision: models.AccessDecision{
+				AccessLevel:    models.None,
+				Reason:         models.ReasonInheritedDenied,
+				SourceFolderID: hr.ID,
+			},
+		},
+		{
+			// A grant in a folder overrides a denial inherited from its ancestors.
+			user:     user,
+			folderID: payroll.ID,
+			expectedDecision: models.AccessDecision{
+				AccessLevel:    models.Uploader,
+				Reason:         models.ReasonGranted,
+				SourceFolderID: payroll.ID,
+			},
+		},
+		{
+			// Admins keep their default access.
+			user:     admin,
+			folderID: reviews.ID,
+			expectedDecision: models.AccessDecision{
+				AccessLevel: models.Viewer,
+				Reason:      models.ReasonAdmin,
+			},
+		},
+	}
+
+	for i, testCase := range testCases {
+		decision, err := models.GetUserAccessDecision(testServer.Server.DB, testCase.user, testCase.folderID)
+		require.NoError(t, err)
+		assert.Equal(t, testCase.folderID, decision.FolderID, i)
+		assert.Equal(t, testCase.expectedDecision.AccessLevel, decision.AccessLevel, i)
+		assert.Equal(t, testCase.expectedDecision.Reason, decision.Reason, i)
+		assert.Equal(t, testCase.expectedDecision.SourceFolderID, decision.SourceFolderID, i)
+		assert.Equal(t, testCase.expectedDecision.SourceFolderID != 0, decision.AccessRoleID != 0, i)
+		assert.NotEmpty(t, decision.Explanation, i)
+
+		_, accessLevel, err := models.GetUserAuthorizationFolder(testServer.Server.DB, testCase.user, testCase.folderID)
+		require.NoError(t, err)
+		assert.Equal(t, decision.AccessLevel, accessLevel, i)
+	}
+
+	// Breaking inheritance also ignores inherited denials.
+	_, err = models.SetFolderInheritance(testServer.Server.DB, reviews.ID, true)
+	require.NoError(t, err)
+	decision, err := models.GetUserAccessDecision(testServer.Server.DB, user, reviews.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.ReasonDefault, decision.Reason)
+
+	_, err = models.GetUserAccessDecision(testServer.Server.DB, user, 0)
+	assert.Equal(t, models.ErrRequiredFolderID, err)
+
+	_, err = models.GetUserAccessDecision(testServer.Server.DB, user, 999)
+	assert.Equal(t, models.ErrFolderNotFound, err)
+}
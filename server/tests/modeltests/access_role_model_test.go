@@ -12,6 +12,7 @@ import (
 func checkAccessRolesEqual(t *testing.T, expectedRole, actualRole models.AccessRole) {
 	assert.Equal(t, expectedRole.FolderID, actualRole.FolderID)
 	assert.Equal(t, expectedRole.UserRoleID, actualRole.UserRoleID)
+	assert.Equal(t, expectedRole.UserID, actualRole.UserID)
 	assert.Equal(t, expectedRole.AccessLevel, actualRole.AccessLevel)
 }
 
@@ -21,6 +22,7 @@ func TestCreateAccessRole(t *testing.T) {
 
 	folders := testServer.Data.Folders
 	userRole := testServer.Data.UserRoles[0]
+	user := testServer.Data.Users[1]
 
 	err = testServer.RefreshTable(&models.AccessRole{})
 	require.NoError(t, err)
@@ -99,6 +101,39 @@ func TestCreateAccessRole(t *testing.T) {
 				AccessLevel: models.Publisher,
 			},
 		},
+		{
+			// Access roles can be granted directly to a user in a folder where their user role has one.
+			role: models.AccessRole{
+				FolderID:    folders[0].ID,
+				UserID:      user.ID,
+				AccessLevel: models.Viewer,
+			},
+		},
+		{
+			role: models.AccessRole{
+				FolderID:    folders[0].ID,
+				UserID:      user.ID,
+				AccessLevel: models.Uploader,
+			},
+			expectedErr: models.ErrAccessRoleAlreadyExists,
+		},
+		{
+			role: models.AccessRole{
+				FolderID:    folders[0].ID,
+				UserID:      999,
+				AccessLevel: models.Viewer,
+			},
+			expectedErr: models.ErrUserNotFound,
+		},
+		{
+			role: models.AccessRole{
+				FolderID:    folders[2].ID,
+				UserRoleID:  userRole.ID,
+				UserID:      user.ID,
+				AccessLevel: models.Viewer,
+			},
+			expectedErr: models.ErrInvalidAccessRoleTarget,
+		},
 	}
 
 	for _, testCase := range testCases {
@@ -296,3 +331,61 @@ func TestDeleteAccessRole(t *testing.T) {
 	require.NoError(t, err)
 	require.Len(t, folder.AccessRoles, 3)
 }
+
+func TestGetDirectAccessRoles(t *testing.T) {
+	err := testServer.SeedData()
+	require.NoError(t, err)
+
+	users := testServer.Data.Users
+	folders := testServer.Data.Folders
+
+	accessRoles, err := models.GetDirectAccessRoles(testServer.Server.DB, users[3].ID)
+	require.NoError(t, err)
+	assert.Len(t, accessRoles, 0)
+
+	accessRole, err := models.CreateAccessRole(testServer.Server.DB, models.AccessRole{
+		FolderID:    folders[1].ID,
+		UserID:      users[3].ID,
+		AccessLevel: models.Uploader,
+	})
+	require.NoError(t, err)
+
+	accessRoles, err = models.GetDirectAccessRoles(testServer.Server.DB, users[3].ID)
+	require.NoError(t, err)
+	if assert.Len(t, accessRoles, 1) {
+		checkAccessRolesEqual(t, accessRole, accessRoles[0])
+	}
+
+	// Direct grants are merged with the grants of the user's user roles.
+	_, accessLevel, err := models.GetUserAuthorizationFolder(testServer.Server.DB, users[3], folders[1].ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.Uploader, accessLevel)
+
+	// Direct grants can't be moved to a user role, but can be moved to another user.
+	_, err = models.UpdateAccessRole(testServer.Server.DB, models.AccessRole{
+		ID:         accessRole.ID,
+		UserRoleID: testServer.Data.UserRoles[0].ID,
+	})
+	assert.Equal(t, models.ErrInvalidAccessRoleTarget, err)
+
+	accessRole, err = models.UpdateAccessRole(testServer.Server.DB, models.AccessRole{
+		ID:     accessRole.ID,
+		UserID: users[2].ID,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, users[2].ID, accessRole.UserID)
+	assert.Equal(t, models.Uploader, accessRole.AccessLevel)
+
+	_, accessLevel, err = models.GetUserAuthorizationFolder(testServer.Server.DB, users[3], folders[1].ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.None, accessLevel)
+
+	// Direct grants are removed along with the user.
+	err = models.DeleteUser(testServer.Server.DB, users[2].ID)
+	require.NoError(t, err)
+	_, err = models.GetAccessRoleByID(testServer.Server.DB, accessRole.ID)
+	assert.Equal(t, models.ErrAccessRoleNotFound, err)
+
+	_, err = models.GetDirectAccessRoles(testServer.Server.DB, 999)
+	assert.Equal(t, models.ErrUserNotFound, err)
+}
@@ -0,0 +1,81 @@
+package modeltests
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/invincibot/penn-spark-server/api/models"
+)
+
+func TestBlobReferences(t *testing.T) {
+	err := testServer.SeedData()
+	require.NoError(t, err)
+
+	_, err = models.AddBlobReference(testServer.Server.DB, "", 4)
+	assert.Equal(t, models.ErrRequiredChecksum, err)
+
+	blob, err := models.AddBlobReference(testServer.Server.DB, "checksum", 4)
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), blob.Size)
+	assert.Equal(t, int64(1), blob.RefCount)
+
+	blob, err = models.AddBlobReference(testServer.Server.DB, "checksum", 4)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), blob.RefCount)
+
+	blobs, err := models.GetAllBlobs(testServer.Server.DB)
+	require.NoError(t, err)
+	assert.Len(t, blobs, 1)
+
+	unreferenced, err := models.RemoveBlobReference(testServer.Server.DB, "checksum")
+	require.NoError(t, err)
+	assert.False(t, unreferenced)
+
+	blob, err = models.GetBlob(testServer.Server.DB, "checksum")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), blob.RefCount)
+
+	// The blob is removed along with its last reference.
+	unreferenced, err = models.RemoveBlobReference(testServer.Server.DB, "checksum")
+	require.NoError(t, err)
+	assert.True(t, unreferenced)
+
+	_, err = models.GetBlob(testServer.Server.DB, "checksum")
+	assert.Equal(t, models.ErrBlobNotFound, err)
+
+	unreferenced, err = models.RemoveBlobReference(testServer.Server.DB, "checksum")
+	require.NoError(t, err)
+	assert.False(t, unreferenced)
+}
+
+func TestCreateInitialFileVersion(t *testing.T) {
+	err := testServer.SeedData()
+	require.NoError(t, err)
+
+	users := testServer.Data.Users
+	files := testServer.Data.Files
+
+	// Data of deleted files is migrated as well.
+	err = models.DeleteFile(testServer.Server.DB, files[1].ID, users[1].ID)
+	require.NoError(t, err)
+
+	version, err := models.CreateInitialFileVersion(testServer.Server.DB, files[1].ID, 6, "checksum")
+	require.NoError(t, err)
+	assert.Equal(t, uint(1), version.Number)
+	assert.Equal(t, files[1].LastEditorID, version.UploaderID)
+
+	count, err := models.CountBlobReferences(testServer.Server.DB, "checksum")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+
+	_, err = models.CreateInitialFileVersion(testServer.Server.DB, files[1].ID, 6, "checksum")
+	assert.Equal(t, models.ErrFileVersionAlreadyExists, err)
+
+	_, err = models.CreateInitialFileVersion(testServer.Server.DB, 999, 6, "checksum")
+	assert.Equal(t, models.ErrFileNotFound, err)
+
+	_, err = models.CreateInitialFileVersion(testServer.Server.DB, files[0].ID, 6, "")
+	assert.Equal(t, models.ErrRequiredChecksum, err)
+}
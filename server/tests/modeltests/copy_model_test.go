@@ -0,0 +1,142 @@
+package modeltests
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/invincibot/penn-spark-server/api/models"
+)
+
+func TestCopyFile(t *testing.T) {
+	err := testServer.SeedData()
+	require.NoError(t, err)
+
+	users := testServer.Data.Users
+	folders := testServer.Data.Folders
+	files := testServer.Data.Files
+
+	_, err = models.CreateFileVersion(testServer.Server.DB, models.FileVersion{
+		FileID:      files[0].ID,
+		Number:      1,
+		Size:        4,
+		Checksum:    "checksum",
+		ContentType: "text/plain; charset=utf-8",
+		ScanStatus:  models.ScanClean,
+		UploaderID:  users[0].ID,
+	})
+	require.NoError(t, err)
+
+	// The copy shares the data of the file, and is uploaded by the user copying it.
+	file, err := models.CopyFile(testServer.Server.DB, files[0].ID, folders[1].ID, "", users[1].ID, false)
+	require.NoError(t, err)
+	assert.Equal(t, files[0].Name, file.Name)
+	assert.Equal(t, folders[1].ID, file.FolderID)
+	assert.Equal(t, users[1].ID, file.LastEditorID)
+	assert.False(t, file.IsPublished)
+	assert.Equal(t, int64(4), file.Size)
+	assert.Equal(t, "checksum", file.Checksum)
+	assert.Equal(t, models.ScanClean, file.ScanStatus)
+
+	version, err := models.GetLatestFileVersion(testServer.Server.DB, file.ID)
+	require.NoError(t, err)
+	assert.Equal(t, uint(1), version.Number)
+	assert.Equal(t, users[1].ID, version.UploaderID)
+	assert.Equal(t, "text/plain; charset=utf-8", version.ContentType)
+
+	blob, err := models.GetBlob(testServer.Server.DB, "checksum")
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), blob.RefCount)
+
+	// Names are escaped once, and files without data are copied without data.
+	file, err = models.CopyFile(testServer.Server.DB, files[1].ID, folders[1].ID, "R&D copy", users[0].ID, true)
+	require.NoError(t, err)
+	assert.Equal(t, "R&amp;D copy", file.Name)
+	assert.True(t, file.IsPublished)
+	assert.Empty(t, file.Checksum)
+	_, err = models.GetLatestFileVersion(testServer.Server.DB, file.ID)
+	assert.Equal(t, models.ErrFileVersionNotFound, err)
+
+	_, err = models.CopyFile(testServer.Server.DB, files[0].ID, folders[0].ID, "", users[0].ID, true)
+	assert.Equal(t, models.ErrFileAlreadyExists, err)
+
+	_, err = models.CopyFile(testServer.Server.DB, files[0].ID, 999, "", users[0].ID, true)
+	assert.Equal(t, models.ErrFolderNotFound, err)
+
+	_, err = models.CopyFile(testServer.Server.DB, 999, folders[0].ID, "", users[0].ID, true)
+	assert.Equal(t, models.ErrFileNotFound, err)
+}
+
+func TestCopyFolder(t *testing.T) {
+	err := testServer.SeedData()
+	require.NoError(t, err)
+
+	users := testServer.Data.Users
+	folders := testServer.Data.Folders
+	files := testServer.Data.Files
+
+	folder, err := models.CopyFolder(testServer.Server.DB, models.FolderCopy{
+		ParentFolderID:  folders[0].ID,
+		Name:            "project",
+		EditorID:        users[0].ID,
+		FolderIDs:       []uint{folders[1].ID, folders[2].ID},
+		FileIDs:         []uint{files[1].ID, files[2].ID},
+		CopyAccessRoles: true,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "project", folder.Name)
+	assert.Equal(t, folders[0].ID, *folder.ParentFolderID)
+	assert.Equal(t, users[0].ID, folder.LastEditorID)
+	assert.Len(t, folder.AccessRoles, len(folders[1].AccessRoles))
+	if assert.Len(t, folder.Files, 1) {
+		assert.Equal(t, files[1].Name, folder.Files[0].Name)
+		assert.Equal(t, files[1].IsPublished, folder.Files[0].IsPublished)
+	}
+	if assert.Len(t, folder.ChildFolders, 1) {
+		childFolder, err := models.GetFolderByID(testServer.Server.DB, folder.ChildFolders[0].ID)
+		require.NoError(t, err)
+		assert.Equal(t, folders[2].Name, childFolder.Name)
+		assert.Len(t, childFolder.AccessRoles, len(folders[2].AccessRoles))
+		if assert.Len(t, childFolder.Files, 1) {
+			assert.Equal(t, files[2].Name, childFolder.Files[0].Name)
+		}
+	}
+
+	// Files in folders that aren't copied are left out, and access roles are only copied when asked to.
+	folder, err = models.CopyFolder(testServer.Server.DB, models.FolderCopy{
+		ParentFolderID: folders[2].ID,
+		EditorID:       users[0].ID,
+		FolderIDs:      []uint{folders[1].ID},
+		FileIDs:        []uint{files[1].ID, files[2].ID},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, folders[1].Name, folder.Name)
+	assert.Len(t, folder.AccessRoles, 0)
+	assert.Len(t, folder.ChildFolders, 0)
+	assert.Len(t, folder.Files, 1)
+
+	// Nothing is copied if any part of the copy fails.
+	subtree, err := models.GetFolderSubtree(testServer.Server.DB, folders[0].ID)
+	require.NoError(t, err)
+	_, err = models.CopyFolder(testServer.Server.DB, models.FolderCopy{
+		ParentFolderID: folders[0].ID,
+		Name:           "project",
+		EditorID:       users[0].ID,
+		FolderIDs:      []uint{folders[1].ID, folders[2].ID},
+	})
+	assert.Equal(t, models.ErrFolderAlreadyExists, err)
+	unchanged, err := models.GetFolderSubtree(testServer.Server.DB, folders[0].ID)
+	require.NoError(t, err)
+	assert.Equal(t, subtree, unchanged)
+
+	_, err = models.CopyFolder(testServer.Server.DB, models.FolderCopy{
+		ParentFolderID: folders[0].ID,
+		EditorID:       users[0].ID,
+		FolderIDs:      []uint{999},
+	})
+	assert.Equal(t, models.ErrFolderNotFound, err)
+
+	_, err = models.CopyFolder(testServer.Server.DB, models.FolderCopy{ParentFolderID: folders[0].ID, EditorID: users[0].ID})
+	assert.Equal(t, models.ErrRequiredFolderID, err)
+}
@@ -0,0 +1,116 @@
+package modeltests
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/invincibot/penn-spark-server/api/models"
+)
+
+func TestGetFolderEffectiveAccess(t *testing.T) {
+	err := testServer.SeedData()
+	require.NoError(t, err)
+
+	users := testServer.Data.Users
+	folders := testServer.Data.Folders
+
+	inheritable := true
+	users[3], err = testServer.GrantAccessRole(users[3], models.AccessRole{
+		FolderID:    folders[1].ID,
+		AccessLevel: models.Viewer,
+		Inheritable: &inheritable,
+	})
+	require.NoError(t, err)
+	direct, err := models.CreateAccessRole(testServer.Server.DB, models.AccessRole{
+		FolderID:    folders[2].ID,
+		UserID:      users[2].ID,
+		AccessLevel: models.Uploader,
+	})
+	require.NoError(t, err)
+
+	effectiveAccess, err := models.GetFolderEffectiveAccess(testServer.Server.DB, folders[2].ID)
+	require.NoError(t, err)
+	require.Len(t, effectiveAccess, len(users))
+
+	// Every user is listed with the same access level they are authorized with.
+	for i, access := range effectiveAccess {
+		assert.Equal(t, users[i].ID, access.UserID)
+		assert.Equal(t, users[i].Username, access.Username)
+		assert.Equal(t, folders[2].ID, access.FolderID)
+		assert.Equal(t, folders[2].Name, access.FolderName)
+
+		_, accessLevel, err := models.GetUserAuthorizationFolder(testServer.Server.DB, users[i], folders[2].ID)
+		require.NoError(t, err)
+		assert.Equal(t, accessLevel, access.AccessLevel, i)
+	}
+
+	assert.Equal(t, models.ReasonInherited, effectiveAccess[3].Reason)
+	assert.Equal(t, folders[1].ID, effectiveAccess[3].SourceFolderID)
+	assert.NotZero(t, effectiveAccess[3].UserRoleID)
+
+	assert.Equal(t, models.Uploader, effectiveAccess[2].AccessLevel)
+	assert.Equal(t, direct.ID, effectiveAccess[2].AccessRoleID)
+	assert.Zero(t, effectiveAccess[2].UserRoleID)
+
+	_, err = models.GetFolderEffectiveAccess(testServer.Server.DB, 0)
+	assert.Equal(t, models.ErrRequiredFolderID, err)
+
+	_, err = models.GetFolderEffectiveAccess(testServer.Server.DB, 999)
+	assert.Equal(t, models.ErrFolderNotFound, err)
+}
+
+func TestGetUserEffectiveAccess(t *testing.T) {
+	err := testServer.SeedData()
+	require.NoError(t, err)
+
+	users := testServer.Data.Users
+	folders := testServer.Data.Folders
+
+	inheritable := true
+	users[3], err = testServer.GrantAccessRole(users[3], models.AccessRole{
+		FolderID:    folders[1].ID,
+		AccessLevel: models.Viewer,
+		Inheritable: &inheritable,
+	})
+	require.NoError(t, err)
+
+	folderIDs := make([]uint, len(folders))
+	for i, folder := range folders {
+		folderIDs[i] = folder.ID
+	}
+
+	// Only the folders the user can view are listed.
+	for _, user := range users {
+		accessLevels, err := models.GetUserAuthorizationFolders(testServer.Server.DB, user, folderIDs)
+		require.NoError(t, err)
+		expectedAccessLevels := map[uint]models.AccessLevel{}
+		for folderID, accessLevel := range accessLevels {
+			if accessLevel >= models.Viewer {
+				expectedAccessLevels[folderID] = accessLevel
+			}
+		}
+
+		effectiveAccess, err := models.GetUserEffectiveAccess(testServer.Server.DB, user.ID)
+		require.NoError(t, err)
+		actualAccessLevels := map[uint]models.AccessLevel{}
+		for _, access := range effectiveAccess {
+			assert.Equal(t, user.ID, access.UserID)
+			actualAccessLevels[access.FolderID] = access.AccessLevel
+		}
+		assert.Equal(t, expectedAccessLevels, actualAccessLevels, user.ID)
+	}
+
+	effectiveAccess, err := models.GetUserEffectiveAccess(testServer.Server.DB, users[3].ID)
+	require.NoError(t, err)
+	if assert.Len(t, effectiveAccess, 2) {
+		assert.Equal(t, folders[1].ID, effectiveAccess[0].FolderID)
+		assert.Equal(t, models.ReasonGranted, effectiveAccess[0].Reason)
+		assert.Equal(t, folders[2].ID, effectiveAccess[1].FolderID)
+		assert.Equal(t, models.ReasonInherited, effectiveAccess[1].Reason)
+	}
+
+	_, err = models.GetUserEffectiveAccess(testServer.Server.DB, 999)
+	assert.Equal(t, models.ErrUserNotFound, err)
+}
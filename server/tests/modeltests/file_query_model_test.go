@@ -0,0 +1,179 @@
+package modeltests
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/invincibot/penn-spark-server/api/models"
+)
+
+// fileIDs returns the Model.ID of each File.
+func fileIDs(files []models.File) []uint {
+	ids := []uint{}
+	for _, file := range files {
+		ids = append(ids, file.ID)
+	}
+	return ids
+}
+
+func TestQueryFiles(t *testing.T) {
+	err := testServer.SeedData()
+	require.NoError(t, err)
+
+	users := testServer.Data.Users
+	folders := testServer.Data.Folders
+	files := testServer.Data.Files
+
+	// Add a few more files to the root folder, one of them a draft.
+	for i, name := range []string{"report_a", "report%b", "Report c"} {
+		file, err := models.CreateFile(testServer.Server.DB, models.File{
+			Name:         name,
+			FolderID:     folders[0].ID,
+			LastEditorID: users[1].ID,
+			IsPublished:  i != 1,
+		})
+		require.NoError(t, err)
+		files = append(files, file)
+	}
+
+	allFolderIDs := []uint{folders[0].ID, folders[1].ID, folders[2].ID}
+	published, draft := true, false
+
+	testCases := []struct {
+		query       models.FileQuery
+		expectedIDs []uint
+		expectedErr error
+	}{
+		{
+			// Drafts are left out by default.
+			// Names are sorted as stored, so "Report c" comes before the lowercase names.
+			query:       models.FileQuery{FolderIDs: allFolderIDs, Page: models.Page{Limit: 10}},
+			expectedIDs: []uint{files[5].ID, files[0].ID, files[2].ID, files[3].ID},
+		},
+		{
+			query:       models.FileQuery{FolderIDs: allFolderIDs, DraftFolderIDs: []uint{folders[0].ID}, Page: models.Page{Limit: 10}},
+			expectedIDs: []uint{files[5].ID, files[0].ID, files[2].ID, files[4].ID, files[3].ID},
+		},
+		{
+			query:       models.FileQuery{FolderIDs: allFolderIDs, DraftEditorID: users[1].ID, IsPublished: &draft, Page: models.Page{Limit: 10}},
+			expectedIDs: []uint{files[1].ID, files[4].ID},
+		},
+		{
+			query:       models.FileQuery{FolderIDs: []uint{folders[0].ID}, IsPublished: &published, Page: models.Page{Limit: 10}},
+			expectedIDs: []uint{files[5].ID, files[0].ID, files[3].ID},
+		},
+		{
+			query:       models.FileQuery{FolderIDs: allFolderIDs, LastEditorID: users[1].ID, Page: models.Page{Limit: 10}},
+			expectedIDs: []uint{files[5].ID, files[3].ID},
+		},
+		{
+			// Wildcards in the prefix are matched literally, and letters are matched regardless of case.
+			query:       models.FileQuery{FolderIDs: allFolderIDs, DraftEditorID: users[1].ID, NamePrefix: "report_", Page: models.Page{Limit: 10}},
+			expectedIDs: []uint{files[3].ID},
+		},
+		{
+			query:       models.FileQuery{FolderIDs: allFolderIDs, NamePrefix: "REPORT", Page: models.Page{Limit: 10}},
+			expectedIDs: []uint{files[5].ID, files[3].ID},
+		},
+		{
+			query:       models.FileQuery{FolderIDs: allFolderIDs, Page: models.Page{Sort: "created_at", Descending: true, Limit: 10}},
+			expectedIDs: []uint{files[5].ID, files[3].ID, files[2].ID, files[0].ID},
+		},
+		{
+			query:       models.FileQuery{FolderIDs: allFolderIDs, UpdatedAfter: time.Now().Add(time.Hour), Page: models.Page{Limit: 10}},
+			expectedIDs: []uint{},
+		},
+		{
+			query:       models.FileQuery{FolderIDs: []uint{}, Page: models.Page{Limit: 10}},
+			expectedIDs: []uint{},
+		},
+		{
+			query:       models.FileQuery{FolderIDs: allFolderIDs, Page: models.Page{Sort: "size", Limit: 10}},
+			expectedErr: models.ErrInvalidSort,
+		},
+		{
+			query:       models.FileQuery{FolderIDs: allFolderIDs, Page: models.Page{Limit: -1}},
+			expectedErr: models.ErrInvalidLimit,
+		},
+		{
+			query:       models.FileQuery{FolderIDs: allFolderIDs, Page: models.Page{Cursor: "not a cursor", Limit: 10}},
+			expectedErr: models.ErrInvalidCursor,
+		},
+	}
+
+	for i, testCase := range testCases {
+		result, info, err := models.QueryFiles(testServer.Server.DB, testCase.query)
+		assert.Equal(t, testCase.expectedErr, err, i)
+		if err == nil {
+			assert.Equal(t, testCase.expectedIDs, fileIDs(result), i)
+			assert.Equal(t, int64(len(testCase.expectedIDs)), info.Total, i)
+			assert.Empty(t, info.NextCursor, i)
+		}
+	}
+}
+
+func TestQueryFilesCursor(t *testing.T) {
+	err := testServer.SeedData()
+	require.NoError(t, err)
+
+	folder := testServer.Data.Folders[0]
+	user := testServer.Data.Users[0]
+
+	// Files sharing the same update time are still paged in a stable order.
+	expectedIDs := []uint{}
+	for i := 0; i < 5; i++ {
+		file, err := models.CreateFile(testServer.Server.DB, models.File{
+			Name:         fmt.Sprintf("page%d", i),
+			FolderID:     folder.ID,
+			LastEditorID: user.ID,
+			IsPublished:  true,
+		})
+		require.NoError(t, err)
+		expectedIDs = append(expectedIDs, file.ID)
+	}
+	updatedAt := time.Now()
+	err = testServer.Server.DB.Model(&models.File{}).Where("id IN ?", expectedIDs[1:4]).
+		Update("updated_at", updatedAt).Error
+	require.NoError(t, err)
+	expectedIDs = append([]uint{testServer.Data.Files[0].ID, expectedIDs[0], expectedIDs[4]}, expectedIDs[1:4]...)
+
+	for _, descending := range []bool{false, true} {
+		query := models.FileQuery{
+			FolderIDs: []uint{folder.ID},
+			Page:      models.Page{Sort: "updated_at", Descending: descending, Limit: 2},
+		}
+
+		ids := []uint{}
+		pages := 0
+		for {
+			files, info, err := models.QueryFiles(testServer.Server.DB, query)
+			require.NoError(t, err)
+			assert.Equal(t, int64(len(expectedIDs)), info.Total)
+			ids = append(ids, fileIDs(files)...)
+			pages++
+			if info.NextCursor == "" {
+				break
+			}
+			query.Page.Cursor = info.NextCursor
+		}
+		assert.Equal(t, 3, pages)
+
+		if descending {
+			reversed := make([]uint, len(ids))
+			for i, id := range ids {
+				reversed[len(ids)-1-i] = id
+			}
+			ids = reversed
+		}
+		assert.Equal(t, expectedIDs, ids)
+
+		// A cursor can't be used with a different sort order.
+		query.Page.Descending = !descending
+		_, _, err = models.QueryFiles(testServer.Server.DB, query)
+		assert.Equal(t, models.ErrInvalidCursor, err)
+	}
+}
@@ -0,0 +1,182 @@
+package modeltests
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/invincibot/penn-spark-server/api/models"
+)
+
+func checkFileVersionsEqual(t *testing.T, expectedVersion, actualVersion models.FileVersion) {
+	assert.Equal(t, expectedVersion.FileID, actualVersion.FileID)
+	assert.Equal(t, expectedVersion.Number, actualVersion.Number)
+	assert.Equal(t, expectedVersion.Size, actualVersion.Size)
+	assert.Equal(t, expectedVersion.Checksum, actualVersion.Checksum)
+	assert.Equal(t, expectedVersion.UploaderID, actualVersion.UploaderID)
+}
+
+func TestCreateFileVersion(t *testing.T) {
+	err := testServer.SeedData()
+	require.NoError(t, err)
+
+	user := testServer.Data.Users[0]
+	file := testServer.Data.Files[0]
+
+	testCases := []struct {
+		version     models.FileVersion
+		expectedErr error
+	}{
+		{
+			version: models.FileVersion{
+				FileID:     file.ID,
+				Number:     1,
+				Size:       4,
+				Checksum:   "checksum",
+				UploaderID: user.ID,
+			},
+			expectedErr: nil,
+		},
+		{
+			version: models.FileVersion{
+				FileID:     0,
+				Number:     1,
+				UploaderID: user.ID,
+			},
+			expectedErr: models.ErrRequiredFileID,
+		},
+		{
+			version: models.FileVersion{
+				FileID:     file.ID,
+				Number:     0,
+				UploaderID: user.ID,
+			},
+			expectedErr: models.ErrRequiredFileVersionNumber,
+		},
+		{
+			version: models.FileVersion{
+				FileID:     file.ID,
+				Number:     2,
+				UploaderID: 0,
+			},
+			expectedErr: models.ErrRequiredUploaderID,
+		},
+		{
+			version: models.FileVersion{
+				FileID:     file.ID,
+				Number:     2,
+				UploaderID: user.ID,
+			},
+			expectedErr: models.ErrRequiredChecksum,
+		},
+		{
+			version: models.FileVersion{
+				FileID:     999,
+				Number:     1,
+				Checksum:   "checksum",
+				UploaderID: user.ID,
+			},
+			expectedErr: models.ErrFileNotFound,
+		},
+		{
+			version: models.FileVersion{
+				FileID:     file.ID,
+				Number:     1,
+				Checksum:   "checksum",
+				UploaderID: user.ID,
+			},
+			expectedErr: models.ErrFileVersionAlreadyExists,
+		},
+		{
+			version: models.FileVersion{
+				FileID:     file.ID,
+				Number:     2,
+				Size:       8,
+				Checksum:   "other checksum",
+				UploaderID: testServer.Data.Users[1].ID,
+			},
+			expectedErr: nil,
+		},
+	}
+
+	for _, testCase := range testCases {
+		actualVersion, err := models.CreateFileVersion(testServer.Server.DB, testCase.version)
+
+		if assert.Equal(t, testCase.expectedErr, err) && testCase.expectedErr == nil {
+			checkFileVersionsEqual(t, testCase.version, actualVersion)
+		}
+	}
+
+	// The file describes the data of its latest version.
+	file, err = models.GetFileByID(testServer.Server.DB, file.ID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(8), file.Size)
+	assert.Equal(t, "other checksum", file.Checksum)
+
+	// Each version references the blob holding its data.
+	blob, err := models.GetBlob(testServer.Server.DB, "checksum")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), blob.RefCount)
+}
+
+func TestGetFileVersions(t *testing.T) {
+	err := testServer.SeedData()
+	require.NoError(t, err)
+
+	user := testServer.Data.Users[0]
+	file := testServer.Data.Files[0]
+
+	versions, err := models.GetFileVersions(testServer.Server.DB, file.ID)
+	require.NoError(t, err)
+	assert.Len(t, versions, 0)
+
+	_, err = models.GetLatestFileVersion(testServer.Server.DB, file.ID)
+	assert.Equal(t, models.ErrFileVersionNotFound, err)
+
+	number, err := models.GetNextFileVersionNumber(testServer.Server.DB, file.ID)
+	require.NoError(t, err)
+	assert.Equal(t, uint(1), number)
+
+	var expectedVersions []models.FileVersion
+	for i := uint(1); i <= 3; i++ {
+		version, err := models.CreateFileVersion(testServer.Server.DB, models.FileVersion{
+			FileID:     file.ID,
+			Number:     i,
+			Size:       int64(i),
+			Checksum:   "checksum",
+			UploaderID: user.ID,
+		})
+		require.NoError(t, err)
+		expectedVersions = append(expectedVersions, version)
+	}
+
+	versions, err = models.GetFileVersions(testServer.Server.DB, file.ID)
+	require.NoError(t, err)
+	if assert.Len(t, versions, len(expectedVersions)) {
+		for i := range expectedVersions {
+			checkFileVersionsEqual(t, expectedVersions[i], versions[i])
+		}
+	}
+
+	latestVersion, err := models.GetLatestFileVersion(testServer.Server.DB, file.ID)
+	require.NoError(t, err)
+	checkFileVersionsEqual(t, expectedVersions[2], latestVersion)
+
+	number, err = models.GetNextFileVersionNumber(testServer.Server.DB, file.ID)
+	require.NoError(t, err)
+	assert.Equal(t, uint(4), number)
+
+	version, err := models.GetFileVersion(testServer.Server.DB, file.ID, 2)
+	require.NoError(t, err)
+	checkFileVersionsEqual(t, expectedVersions[1], version)
+
+	_, err = models.GetFileVersion(testServer.Server.DB, file.ID, 0)
+	assert.Equal(t, models.ErrRequiredFileVersionNumber, err)
+
+	_, err = models.GetFileVersion(testServer.Server.DB, file.ID, 999)
+	assert.Equal(t, models.ErrFileVersionNotFound, err)
+
+	_, err = models.GetFileVersions(testServer.Server.DB, 0)
+	assert.Equal(t, models.ErrRequiredFileID, err)
+}
@@ -401,7 +401,193 @@ func TestGetUserAuthorizationFolder(t *testing.T) {
 		actualFolder, actualAccessLevel, err := models.GetUserAuthorizationFolder(testServer.Server.DB, testCase.user, testCase.folderID)
 		if assert.Equal(t, testCase.expectedErr, err) && testCase.expectedErr == nil {
 			assert.Equal(t, testCase.expectedAccessLevel, actualAccessLevel)
-			checkFoldersEqual(t, folders[testCase.folderID-1], actualFolder)
+			// The files of the folder aren't loaded, as they are only needed a page at a time.
+			expectedFolder := folders[testCase.folderID-1]
+			expectedFolder.Files = nil
+			checkFoldersEqual(t, expectedFolder, actualFolder)
 		}
 	}
 }
+
+func TestGetFolderSubtree(t *testing.T) {
+	err := testServer.SeedData()
+	require.NoError(t, err)
+
+	folders := testServer.Data.Folders
+	files := testServer.Data.Files
+
+	subtree, err := models.GetFolderSubtree(testServer.Server.DB, folders[1].ID)
+	require.NoError(t, err)
+	assert.Equal(t, []uint{folders[1].ID, folders[2].ID}, subtree.FolderIDs)
+	assert.ElementsMatch(t, []uint{files[1].ID, files[2].ID}, subtree.FileIDs)
+	assert.Len(t, subtree.AccessRoleIDs, len(folders[1].AccessRoles)+len(folders[2].AccessRoles))
+	assert.Equal(t, 2, subtree.FolderCount)
+	assert.Equal(t, 2, subtree.FileCount)
+	assert.Equal(t, len(subtree.AccessRoleIDs), subtree.AccessRoleCount)
+
+	subtree, err = models.GetFolderSubtree(testServer.Server.DB, folders[2].ID)
+	require.NoError(t, err)
+	assert.Equal(t, []uint{folders[2].ID}, subtree.FolderIDs)
+	assert.Equal(t, []uint{files[2].ID}, subtree.FileIDs)
+
+	_, err = models.GetFolderSubtree(testServer.Server.DB, 0)
+	assert.Equal(t, models.ErrRequiredFolderID, err)
+
+	_, err = models.GetFolderSubtree(testServer.Server.DB, 999)
+	assert.Equal(t, models.ErrFolderNotFound, err)
+}
+
+func TestDeleteFolderRecursive(t *testing.T) {
+	err := testServer.SeedData()
+	require.NoError(t, err)
+
+	user := testServer.Data.Users[1]
+	folders := testServer.Data.Folders
+	files := testServer.Data.Files
+
+	subtree, err := models.DeleteFolderRecursive(testServer.Server.DB, folders[1].ID, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, []uint{folders[1].ID, folders[2].ID}, subtree.FolderIDs)
+
+	for _, folder := range folders[1:] {
+		_, err = models.GetFolderByID(testServer.Server.DB, folder.ID)
+		assert.Equal(t, models.ErrFolderNotFound, err)
+
+		for _, accessRole := range folder.AccessRoles {
+			_, err = models.GetAccessRoleByID(testServer.Server.DB, accessRole.ID)
+			assert.Equal(t, models.ErrAccessRoleNotFound, err)
+		}
+	}
+	for _, file := range files[1:] {
+		_, err = models.GetFileByID(testServer.Server.DB, file.ID)
+		assert.Equal(t, models.ErrFileNotFound, err)
+	}
+
+	// The deleted items are kept in the trash of the deleting user.
+	items, err := models.GetTrash(testServer.Server.DB, user.ID)
+	require.NoError(t, err)
+	assert.Len(t, items, 4)
+
+	folder, err := models.GetFolderByID(testServer.Server.DB, folders[0].ID)
+	require.NoError(t, err)
+	assert.Len(t, folder.ChildFolders, 0)
+	assert.Len(t, folder.Files, 1)
+
+	_, err = models.DeleteFolderRecursive(testServer.Server.DB, folders[1].ID, user.ID)
+	assert.Equal(t, models.ErrFolderNotFound, err)
+
+	_, err = models.DeleteFolderRecursive(testServer.Server.DB, folders[0].ID, 0)
+	assert.Equal(t, models.ErrRequiredLastEditorID, err)
+}
+
+func TestGetUserAuthorizationFolderInheritance(t *testing.T) {
+	err := testServer.SeedData()
+	require.NoError(t, err)
+
+	user := testServer.Data.Users[3]
+	admin := testServer.Data.Users[0]
+	root := testServer.Data.Folders[0]
+
+	createFolder := func(name string, parentFolderID uint) models.Folder {
+		folder, err := models.CreateFolder(testServer.Server.DB, models.Folder{
+			Name:           name,
+			ParentFolderID: &parentFolderID,
+			LastEditorID:   admin.ID,
+		})
+		require.NoError(t, err)
+		return folder
+	}
+	project := createFolder("project", root.ID)
+	child := createFolder("child", project.ID)
+	grandchild := createFolder("grandchild", child.ID)
+	other := createFolder("other", root.ID)
+
+	inheritable := true
+	user, err = testServer.GrantAccessRole(user, models.AccessRole{
+		FolderID:    project.ID,
+		AccessLevel: models.Viewer,
+		Inheritable: &inheritable,
+	})
+	require.NoError(t, err)
+
+	checkAccessLevels := func(expectedAccessLevels map[uint]models.AccessLevel) {
+		folderIDs := make([]uint, 0, len(expectedAccessLevels))
+		for folderID, expectedAccessLevel := range expectedAccessLevels {
+			folderIDs = append(folderIDs, folderID)
+			_, accessLevel, err := models.GetUserAuthorizationFolder(testServer.Server.DB, user, folderID)
+			require.NoError(t, err)
+			assert.Equal(t, expectedAccessLevel, accessLevel, folderID)
+		}
+		accessLevels, err := models.GetUserAuthorizationFolders(testServer.Server.DB, user, folderIDs)
+		require.NoError(t, err)
+		assert.Equal(t, expectedAccessLevels, accessLevels)
+	}
+
+	// The access role applies to all the descendants of the folder, but not its ancestors.
+	checkAccessLevels(map[uint]models.AccessLevel{
+		root.ID:       models.None,
+		project.ID:    models.Viewer,
+		child.ID:      models.Viewer,
+		grandchild.ID: models.Viewer,
+		other.ID:      models.None,
+	})
+
+	// Access roles that aren't inheritable only apply to their folder, and the highest access level applies.
+	user, err = testServer.GrantAccess(user, child.ID, models.Publisher)
+	require.NoError(t, err)
+	checkAccessLevels(map[uint]models.AccessLevel{
+		child.ID:      models.Publisher,
+		grandchild.ID: models.Viewer,
+	})
+
+	// Breaking inheritance stops inheritable access roles of the ancestors applying to the folder and its descendants.
+	folder, err := models.SetFolderInheritance(testServer.Server.DB, child.ID, true)
+	require.NoError(t, err)
+	assert.True(t, folder.BreakInheritance)
+	checkAccessLevels(map[uint]models.AccessLevel{
+		project.ID:    models.Viewer,
+		child.ID:      models.Publisher,
+		grandchild.ID: models.None,
+	})
+
+	// Updating the folder keeps inheritance broken.
+	folder, err = models.UpdateFolder(testServer.Server.DB, models.Folder{
+		Model:        models.Model{ID: child.ID},
+		Name:         "renamed",
+		LastEditorID: admin.ID,
+	})
+	require.NoError(t, err)
+	assert.True(t, folder.BreakInheritance)
+
+	_, err = models.SetFolderInheritance(testServer.Server.DB, child.ID, false)
+	require.NoError(t, err)
+	checkAccessLevels(map[uint]models.AccessLevel{
+		grandchild.ID: models.Viewer,
+	})
+
+	// Moved folders pick up the inherited access roles of their new ancestors, and lose those of their old ones.
+	_, err = models.UpdateFolder(testServer.Server.DB, models.Folder{
+		Model:          models.Model{ID: other.ID},
+		ParentFolderID: &grandchild.ID,
+		LastEditorID:   admin.ID,
+	})
+	require.NoError(t, err)
+	checkAccessLevels(map[uint]models.AccessLevel{
+		other.ID: models.Viewer,
+	})
+
+	_, err = models.UpdateFolder(testServer.Server.DB, models.Folder{
+		Model:          models.Model{ID: child.ID},
+		ParentFolderID: &root.ID,
+		LastEditorID:   admin.ID,
+	})
+	require.NoError(t, err)
+	checkAccessLevels(map[uint]models.AccessLevel{
+		child.ID:      models.Publisher,
+		grandchild.ID: models.None,
+		other.ID:      models.None,
+	})
+
+	_, err = models.SetFolderInheritance(testServer.Server.DB, 999, true)
+	assert.Equal(t, models.ErrFolderNotFound, err)
+}
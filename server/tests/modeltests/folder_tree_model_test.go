@@ -0,0 +1,90 @@
+package modeltests
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/invincibot/penn-spark-server/api/models"
+)
+
+func TestGetFolderTree(t *testing.T) {
+	err := testServer.SeedData()
+	require.NoError(t, err)
+
+	users := testServer.Data.Users
+	folders := testServer.Data.Folders
+	files := testServer.Data.Files
+
+	sibling, err := models.CreateFolder(testServer.Server.DB, models.Folder{
+		Name:           "archive",
+		ParentFolderID: &folders[0].ID,
+		LastEditorID:   users[0].ID,
+	})
+	require.NoError(t, err)
+	_, err = models.CreateFile(testServer.Server.DB, models.File{
+		Name:         "draft",
+		FolderID:     folders[0].ID,
+		LastEditorID: users[1].ID,
+	})
+	require.NoError(t, err)
+
+	// Deleted files aren't counted.
+	err = models.DeleteFile(testServer.Server.DB, files[2].ID, users[0].ID)
+	require.NoError(t, err)
+
+	nodes, err := models.GetFolderTree(testServer.Server.DB, folders[0].ID, models.MaxFolderTreeDepth, users[1].ID)
+	require.NoError(t, err)
+	assert.Equal(t, []models.FolderTreeNode{
+		{
+			ID:                   folders[0].ID,
+			Name:                 folders[0].Name,
+			PublishedFileCount:   1,
+			DraftFileCount:       1,
+			EditorDraftFileCount: 1,
+		},
+		{
+			ID:             sibling.ID,
+			Name:           sibling.Name,
+			ParentFolderID: folders[0].ID,
+			Depth:          1,
+		},
+		{
+			ID:                   folders[1].ID,
+			Name:                 folders[1].Name,
+			ParentFolderID:       folders[0].ID,
+			Depth:                1,
+			DraftFileCount:       1,
+			EditorDraftFileCount: 1,
+		},
+		{
+			ID:             folders[2].ID,
+			Name:           folders[2].Name,
+			ParentFolderID: folders[1].ID,
+			Depth:          2,
+		},
+	}, nodes)
+
+	nodes, err = models.GetFolderTree(testServer.Server.DB, folders[0].ID, 1, users[0].ID)
+	require.NoError(t, err)
+	if assert.Len(t, nodes, 3) {
+		assert.Equal(t, int64(0), nodes[0].EditorDraftFileCount)
+	}
+
+	nodes, err = models.GetFolderTree(testServer.Server.DB, folders[1].ID, 0, users[0].ID)
+	require.NoError(t, err)
+	if assert.Len(t, nodes, 1) {
+		assert.Equal(t, folders[1].ID, nodes[0].ID)
+		assert.Equal(t, 0, nodes[0].Depth)
+	}
+
+	_, err = models.GetFolderTree(testServer.Server.DB, folders[0].ID, -1, users[0].ID)
+	assert.Equal(t, models.ErrInvalidDepth, err)
+
+	_, err = models.GetFolderTree(testServer.Server.DB, folders[0].ID, models.MaxFolderTreeDepth+1, users[0].ID)
+	assert.Equal(t, models.ErrInvalidDepth, err)
+
+	_, err = models.GetFolderTree(testServer.Server.DB, 999, 1, users[0].ID)
+	assert.Equal(t, models.ErrFolderNotFound, err)
+}
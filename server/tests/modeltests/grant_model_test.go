@@ -0,0 +1,204 @@
+package modeltests
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/invincibot/penn-spark-server/api/models"
+)
+
+func TestGrantWindow(t *testing.T) {
+	err := testServer.SeedData()
+	require.NoError(t, err)
+
+	users := testServer.Data.Users
+	folders := testServer.Data.Folders
+
+	past := time.Now().Add(-time.Hour)
+	future := time.Now().Add(time.Hour)
+
+	_, err = models.CreateAccessRole(testServer.Server.DB, models.AccessRole{
+		FolderID:    folders[0].ID,
+		UserID:      users[3].ID,
+		AccessLevel: models.Viewer,
+		GrantWindow: models.GrantWindow{StartsAt: &future, ExpiresAt: &past},
+	})
+	assert.Equal(t, models.ErrInvalidGrantWindow, err)
+
+	// Access roles only grant access during their window.
+	accessRole, err := models.CreateAccessRole(testServer.Server.DB, models.AccessRole{
+		FolderID:    folders[0].ID,
+		UserID:      users[3].ID,
+		AccessLevel: models.Viewer,
+		GrantWindow: models.GrantWindow{StartsAt: &future},
+	})
+	require.NoError(t, err)
+	_, accessLevel, err := models.GetUserAuthorizationFolder(testServer.Server.DB, users[3], folders[0].ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.None, accessLevel)
+
+	_, err = models.UpdateAccessRole(testServer.Server.DB, models.AccessRole{
+		ID:          accessRole.ID,
+		GrantWindow: models.GrantWindow{ExpiresAt: &past},
+	})
+	assert.Equal(t, models.ErrInvalidGrantWindow, err)
+
+	_, err = models.UpdateAccessRole(testServer.Server.DB, models.AccessRole{
+		ID:          accessRole.ID,
+		GrantWindow: models.GrantWindow{StartsAt: &past, ExpiresAt: &future},
+	})
+	require.NoError(t, err)
+	_, accessLevel, err = models.GetUserAuthorizationFolder(testServer.Server.DB, users[3], folders[0].ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.Viewer, accessLevel)
+
+	// Listed window bounds are cleared even though they are nil.
+	accessRole, err = models.UpdateAccessRole(testServer.Server.DB, models.AccessRole{ID: accessRole.ID}, "starts_at")
+	require.NoError(t, err)
+	assert.Nil(t, accessRole.StartsAt)
+	assert.NotNil(t, accessRole.ExpiresAt)
+
+	// User roles only grant access during the window they are assigned for.
+	userRole, err := models.CreateUserRole(testServer.Server.DB, models.UserRole{Name: "auditors"})
+	require.NoError(t, err)
+	_, err = models.CreateAccessRole(testServer.Server.DB, models.AccessRole{
+		FolderID:    folders[1].ID,
+		UserRoleID:  userRole.ID,
+		AccessLevel: models.Uploader,
+	})
+	require.NoError(t, err)
+
+	_, err = models.AssignUserRole(testServer.Server.DB, users[3].ID, userRole,
+		models.GrantWindow{StartsAt: &future, ExpiresAt: &past})
+	assert.Equal(t, models.ErrInvalidGrantWindow, err)
+
+	user, err := models.AssignUserRole(testServer.Server.DB, users[3].ID, userRole, models.GrantWindow{ExpiresAt: &past})
+	require.NoError(t, err)
+	_, accessLevel, err = models.GetUserAuthorizationFolder(testServer.Server.DB, user, folders[1].ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.None, accessLevel)
+
+	_, err = models.RemoveUserRole(testServer.Server.DB, users[3].ID, userRole)
+	require.NoError(t, err)
+	user, err = models.AssignUserRole(testServer.Server.DB, users[3].ID, userRole, models.GrantWindow{ExpiresAt: &future})
+	require.NoError(t, err)
+	_, accessLevel, err = models.GetUserAuthorizationFolder(testServer.Server.DB, user, folders[1].ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.Uploader, accessLevel)
+}
+
+func TestGrantWindowTimeZone(t *testing.T) {
+	err := testServer.SeedData()
+	require.NoError(t, err)
+
+	users := testServer.Data.Users
+	folders := testServer.Data.Folders
+
+	// Windows sent in a time zone ahead of the server's are compared at the same instant.
+	_, offset := time.Now().Zone()
+	ahead := time.FixedZone("", offset+5*60*60)
+	past := time.Now().Add(-time.Hour).In(ahead)
+	_, err = models.CreateAccessRole(testServer.Server.DB, models.AccessRole{
+		FolderID:    folders[0].ID,
+		UserID:      users[3].ID,
+		AccessLevel: models.Viewer,
+		GrantWindow: models.GrantWindow{ExpiresAt: &past},
+	})
+	require.NoError(t, err)
+	_, accessLevel, err := models.GetUserAuthorizationFolder(testServer.Server.DB, users[3], folders[0].ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.None, accessLevel)
+
+	// Windows sent in a time zone behind the server's aren't expired early.
+	behind := time.FixedZone("", offset-5*60*60)
+	future := time.Now().Add(time.Hour).In(behind)
+	userRole, err := models.CreateUserRole(testServer.Server.DB, models.UserRole{Name: "auditors"})
+	require.NoError(t, err)
+	_, err = models.CreateAccessRole(testServer.Server.DB, models.AccessRole{
+		FolderID:    folders[1].ID,
+		UserRoleID:  userRole.ID,
+		AccessLevel: models.Uploader,
+	})
+	require.NoError(t, err)
+	user, err := models.AssignUserRole(testServer.Server.DB, users[3].ID, userRole, models.GrantWindow{ExpiresAt: &future})
+	require.NoError(t, err)
+
+	entries, err := models.ExpireGrants(testServer.Server.DB, time.Now())
+	require.NoError(t, err)
+	if assert.Len(t, entries, 1) {
+		assert.Equal(t, users[3].ID, entries[0].UserID)
+		assert.Equal(t, folders[0].ID, entries[0].FolderID)
+	}
+	_, accessLevel, err = models.GetUserAuthorizationFolder(testServer.Server.DB, user, folders[1].ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.Uploader, accessLevel)
+}
+
+func TestExpireGrants(t *testing.T) {
+	err := testServer.SeedData()
+	require.NoError(t, err)
+
+	users := testServer.Data.Users
+	folders := testServer.Data.Folders
+
+	now := time.Now()
+	past := now.Add(-time.Hour)
+	future := now.Add(time.Hour)
+
+	expired, err := models.CreateAccessRole(testServer.Server.DB, models.AccessRole{
+		FolderID:    folders[0].ID,
+		UserID:      users[3].ID,
+		AccessLevel: models.Viewer,
+		GrantWindow: models.GrantWindow{ExpiresAt: &past},
+	})
+	require.NoError(t, err)
+	pending, err := models.CreateAccessRole(testServer.Server.DB, models.AccessRole{
+		FolderID:    folders[1].ID,
+		UserID:      users[3].ID,
+		AccessLevel: models.Viewer,
+		GrantWindow: models.GrantWindow{StartsAt: &future},
+	})
+	require.NoError(t, err)
+
+	userRole, err := models.CreateUserRole(testServer.Server.DB, models.UserRole{Name: "temps"})
+	require.NoError(t, err)
+	_, err = models.AssignUserRole(testServer.Server.DB, users[2].ID, userRole, models.GrantWindow{ExpiresAt: &past})
+	require.NoError(t, err)
+
+	entries, err := models.ExpireGrants(testServer.Server.DB, now)
+	require.NoError(t, err)
+	if assert.Len(t, entries, 2) {
+		assert.Equal(t, models.GrantExpired, entries[0].Event)
+		assert.Equal(t, expired.ID, entries[0].AccessRoleID)
+		assert.Equal(t, folders[0].ID, entries[0].FolderID)
+		assert.Equal(t, users[3].ID, entries[0].UserID)
+		assert.Equal(t, models.Viewer, entries[0].AccessLevel)
+		assert.Equal(t, uint(0), entries[1].AccessRoleID)
+		assert.Equal(t, userRole.ID, entries[1].UserRoleID)
+		assert.Equal(t, users[2].ID, entries[1].UserID)
+	}
+
+	// Expired grants are removed, and the others are kept.
+	_, err = models.GetAccessRoleByID(testServer.Server.DB, expired.ID)
+	assert.Equal(t, models.ErrAccessRoleNotFound, err)
+	_, err = models.GetAccessRoleByID(testServer.Server.DB, pending.ID)
+	assert.NoError(t, err)
+	user, err := models.GetUserByID(testServer.Server.DB, users[2].ID)
+	require.NoError(t, err)
+	for _, role := range user.UserRoles {
+		assert.NotEqual(t, userRole.ID, role.ID)
+	}
+
+	audit, info, err := models.GetGrantAuditPage(testServer.Server.DB, models.Page{})
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), info.Total)
+	assert.Len(t, audit, 2)
+
+	// Nothing is left to expire.
+	entries, err = models.ExpireGrants(testServer.Server.DB, now)
+	require.NoError(t, err)
+	assert.Len(t, entries, 0)
+}
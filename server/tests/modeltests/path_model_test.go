@@ -0,0 +1,133 @@
+package modeltests
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/invincibot/penn-spark-server/api/models"
+)
+
+func TestGetFolderPath(t *testing.T) {
+	err := testServer.SeedData()
+	require.NoError(t, err)
+
+	folders := testServer.Data.Folders
+
+	path, err := models.GetFolderPath(testServer.Server.DB, folders[2].ID)
+	require.NoError(t, err)
+	if assert.Len(t, path, 3) {
+		for i, folder := range folders {
+			assert.Equal(t, folder.ID, path[i].ID)
+			assert.Equal(t, folder.Name, path[i].Name)
+		}
+		assert.Equal(t, uint(0), *path[0].ParentFolderID)
+	}
+
+	path, err = models.GetFolderPath(testServer.Server.DB, folders[0].ID)
+	require.NoError(t, err)
+	if assert.Len(t, path, 1) {
+		assert.Equal(t, folders[0].ID, path[0].ID)
+	}
+
+	_, err = models.GetFolderPath(testServer.Server.DB, 0)
+	assert.Equal(t, models.ErrRequiredFolderID, err)
+
+	_, err = models.GetFolderPath(testServer.Server.DB, 999)
+	assert.Equal(t, models.ErrFolderNotFound, err)
+}
+
+func TestResolvePath(t *testing.T) {
+	err := testServer.SeedData()
+	require.NoError(t, err)
+
+	users := testServer.Data.Users
+	folders := testServer.Data.Folders
+	files := testServer.Data.Files
+
+	// Names are matched as they were given, even though they are stored escaped.
+	escapedFolder, err := models.CreateFolder(testServer.Server.DB, models.Folder{
+		Name:           "R&D <team>",
+		ParentFolderID: &folders[0].ID,
+		LastEditorID:   users[0].ID,
+	})
+	require.NoError(t, err)
+
+	// Folders are found before files with the same name.
+	_, err = models.CreateFile(testServer.Server.DB, models.File{
+		Name:         folders[1].Name,
+		FolderID:     folders[0].ID,
+		LastEditorID: users[0].ID,
+	})
+	require.NoError(t, err)
+
+	err = models.DeleteFile(testServer.Server.DB, files[2].ID, users[0].ID)
+	require.NoError(t, err)
+
+	testCases := []struct {
+		path             string
+		expectedResolved models.ResolvedPath
+		expectedErr      error
+	}{
+		{
+			path:             "/root",
+			expectedResolved: models.ResolvedPath{ID: folders[0].ID},
+		},
+		{
+			path:             "/root/folder1/folder2/",
+			expectedResolved: models.ResolvedPath{ID: folders[2].ID, FolderID: folders[1].ID},
+		},
+		{
+			path:             "/root/folder1",
+			expectedResolved: models.ResolvedPath{ID: folders[1].ID, FolderID: folders[0].ID},
+		},
+		{
+			path:             "/root/file1",
+			expectedResolved: models.ResolvedPath{ID: files[0].ID, FolderID: folders[0].ID, IsFile: true},
+		},
+		{
+			path:             "/root/folder1/file2",
+			expectedResolved: models.ResolvedPath{ID: files[1].ID, FolderID: folders[1].ID, IsFile: true},
+		},
+		{
+			path:             "/root/R&D <team>",
+			expectedResolved: models.ResolvedPath{ID: escapedFolder.ID, FolderID: folders[0].ID},
+		},
+		{
+			path:        "/root/folder1/folder2/file3",
+			expectedErr: models.ErrPathNotFound,
+		},
+		{
+			path:        "/root/folder2",
+			expectedErr: models.ErrPathNotFound,
+		},
+		{
+			path:        "/folder1",
+			expectedErr: models.ErrPathNotFound,
+		},
+		{
+			path:        "root/folder1",
+			expectedErr: models.ErrInvalidPath,
+		},
+		{
+			path:        "/root//folder1",
+			expectedErr: models.ErrInvalidPath,
+		},
+		{
+			path:        "/",
+			expectedErr: models.ErrInvalidPath,
+		},
+		{
+			path:        "/root" + strings.Repeat("/folder", models.MaxPathSegments),
+			expectedErr: models.ErrInvalidPath,
+		},
+	}
+
+	for _, testCase := range testCases {
+		resolved, err := models.ResolvePath(testServer.Server.DB, testCase.path)
+		assert.Equal(t, testCase.expectedErr, err, testCase.path)
+		assert.Equal(t, testCase.expectedResolved, resolved, testCase.path)
+	}
+}
@@ -0,0 +1,138 @@
+package modeltests
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/invincibot/penn-spark-server/api/models"
+)
+
+func TestSearchQuery(t *testing.T) {
+	testCases := []struct {
+		text          string
+		expectedQuery string
+		expectedErr   error
+	}{
+		{
+			text:          "Annual Report",
+			expectedQuery: "annual* report*",
+		},
+		{
+			text:          `"budget" OR -draft NEAR(x)`,
+			expectedQuery: "budget* or* draft* near* x*",
+		},
+		{
+			text:          "résumé 2021",
+			expectedQuery: "résumé* 2021*",
+		},
+		{
+			text:        " *()- ",
+			expectedErr: models.ErrRequiredSearchQuery,
+		},
+	}
+
+	for _, testCase := range testCases {
+		query, err := models.SearchQuery(testCase.text)
+		assert.Equal(t, testCase.expectedErr, err)
+		assert.Equal(t, testCase.expectedQuery, query)
+	}
+}
+
+func TestSearchIndex(t *testing.T) {
+	err := testServer.SeedData()
+	require.NoError(t, err)
+
+	files := testServer.Data.Files
+	folders := testServer.Data.Folders
+
+	search := func(text string) []models.SearchHit {
+		query, err := models.SearchQuery(text)
+		require.NoError(t, err)
+		hits, err := models.SearchIndex(testServer.Server.DB, query)
+		require.NoError(t, err)
+		return hits
+	}
+
+	// Names of the seeded files and folders are indexed.
+	hits := search("folder2")
+	require.Len(t, hits, 1)
+	assert.Equal(t, folders[2].ID, hits[0].FolderID)
+	assert.Zero(t, hits[0].FileID)
+	assert.Equal(t, models.SnippetMatchStart+"folder2"+models.SnippetMatchEnd, hits[0].Snippet)
+
+	// Prefixes of names match, while indexed text matches by contents.
+	err = models.UpdateFileSearchText(testServer.Server.DB, files[1].ID, "checksum", "the quarterly report, see file1")
+	require.NoError(t, err)
+	hits = search("fil")
+	require.Len(t, hits, 3)
+	// A match in the name ranks above a match in the contents.
+	hits = search("file1")
+	require.Len(t, hits, 2)
+	assert.Equal(t, files[0].ID, hits[0].FileID)
+	assert.Equal(t, files[1].ID, hits[1].FileID)
+	assert.Greater(t, hits[0].Score, hits[1].Score)
+
+	hits = search("quarterly report")
+	require.Len(t, hits, 1)
+	assert.Equal(t, files[1].ID, hits[0].FileID)
+	assert.Contains(t, hits[0].Snippet, models.SnippetMatchStart+"quarterly"+models.SnippetMatchEnd)
+
+	// Renamed files are found by their new name only.
+	file := files[0]
+	file.Name = "budget"
+	_, err = models.UpdateFile(testServer.Server.DB, file)
+	require.NoError(t, err)
+	assert.Len(t, search("budget"), 1)
+	hits = search("file1")
+	require.Len(t, hits, 1)
+	assert.Equal(t, files[1].ID, hits[0].FileID)
+
+	// Deleted files aren't found.
+	err = models.DeleteFile(testServer.Server.DB, files[1].ID, files[1].LastEditorID)
+	require.NoError(t, err)
+	assert.Empty(t, search("quarterly"))
+
+	err = models.UpdateFileSearchText(testServer.Server.DB, 0, "", "")
+	assert.Equal(t, models.ErrRequiredFileID, err)
+}
+
+func TestGetUnindexedFiles(t *testing.T) {
+	err := testServer.SeedData()
+	require.NoError(t, err)
+
+	file := testServer.Data.Files[0]
+
+	// Files without data have nothing to index.
+	unindexed, err := models.GetUnindexedFiles(testServer.Server.DB)
+	require.NoError(t, err)
+	assert.Empty(t, unindexed)
+
+	version, err := models.CreateFileVersion(testServer.Server.DB, models.FileVersion{
+		FileID:     file.ID,
+		Number:     1,
+		Size:       4,
+		Checksum:   "checksum",
+		UploaderID: file.LastEditorID,
+	})
+	require.NoError(t, err)
+
+	// Data is only indexed once it has been scanned and found clean.
+	unindexed, err = models.GetUnindexedFiles(testServer.Server.DB)
+	require.NoError(t, err)
+	assert.Empty(t, unindexed)
+
+	_, err = models.UpdateFileVersionScanStatus(testServer.Server.DB, version.ID, models.ScanClean)
+	require.NoError(t, err)
+	unindexed, err = models.GetUnindexedFiles(testServer.Server.DB)
+	require.NoError(t, err)
+	require.Len(t, unindexed, 1)
+	assert.Equal(t, file.ID, unindexed[0].ID)
+
+	err = models.UpdateFileSearchText(testServer.Server.DB, file.ID, "checksum", "text")
+	require.NoError(t, err)
+	unindexed, err = models.GetUnindexedFiles(testServer.Server.DB)
+	require.NoError(t, err)
+	assert.Empty(t, unindexed)
+}
@@ -0,0 +1,193 @@
+package modeltests
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/invincibot/penn-spark-server/api/models"
+)
+
+func TestGetTrash(t *testing.T) {
+	err := testServer.SeedData()
+	require.NoError(t, err)
+
+	users := testServer.Data.Users
+	folders := testServer.Data.Folders
+	files := testServer.Data.Files
+
+	err = models.DeleteFile(testServer.Server.DB, files[2].ID, users[0].ID)
+	require.NoError(t, err)
+	err = models.DeleteFolder(testServer.Server.DB, folders[2].ID, users[0].ID)
+	require.NoError(t, err)
+	err = models.DeleteFile(testServer.Server.DB, files[1].ID, users[1].ID)
+	require.NoError(t, err)
+
+	items, err := models.GetTrash(testServer.Server.DB, 0)
+	require.NoError(t, err)
+	if assert.Len(t, items, 3) {
+		assert.Equal(t, models.TrashItemFolder, items[0].Type)
+		assert.Equal(t, folders[2].ID, items[0].ID)
+		assert.Equal(t, folders[1].ID, items[0].ParentFolderID)
+		assert.Equal(t, models.TrashItemFile, items[1].Type)
+		assert.Equal(t, files[2].ID, items[1].ID)
+		assert.Equal(t, users[0].ID, items[1].DeletedByID)
+	}
+
+	items, err = models.GetTrash(testServer.Server.DB, users[1].ID)
+	require.NoError(t, err)
+	if assert.Len(t, items, 1) {
+		assert.Equal(t, files[1].ID, items[0].ID)
+		assert.Equal(t, files[1].Name, items[0].Name)
+	}
+
+	items, err = models.GetExpiredTrash(testServer.Server.DB, time.Now().Add(-time.Hour))
+	require.NoError(t, err)
+	assert.Len(t, items, 0)
+
+	items, err = models.GetExpiredTrash(testServer.Server.DB, time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	assert.Len(t, items, 3)
+
+	_, err = models.GetTrashItem(testServer.Server.DB, models.TrashItemFile, files[0].ID)
+	assert.Equal(t, models.ErrTrashItemNotFound, err)
+
+	_, err = models.GetTrashItem(testServer.Server.DB, "invalid", files[1].ID)
+	assert.Equal(t, models.ErrInvalidTrashItemType, err)
+}
+
+func TestRestoreTrashItem(t *testing.T) {
+	err := testServer.SeedData()
+	require.NoError(t, err)
+
+	users := testServer.Data.Users
+	folders := testServer.Data.Folders
+	files := testServer.Data.Files
+
+	err = models.DeleteFile(testServer.Server.DB, files[2].ID, users[0].ID)
+	require.NoError(t, err)
+	err = models.DeleteFolder(testServer.Server.DB, folders[2].ID, users[0].ID)
+	require.NoError(t, err)
+
+	// Take the deleted folder's name so the restored folder has to be renamed.
+	_, err = models.CreateFolder(testServer.Server.DB, models.Folder{
+		Name:           folders[2].Name,
+		ParentFolderID: &folders[1].ID,
+		LastEditorID:   users[0].ID,
+	})
+	require.NoError(t, err)
+
+	item, err := models.GetTrashItem(testServer.Server.DB, models.TrashItemFile, files[2].ID)
+	require.NoError(t, err)
+
+	restoreFolderID, err := models.GetRestoreFolderID(testServer.Server.DB, item)
+	require.NoError(t, err)
+	assert.Equal(t, folders[1].ID, restoreFolderID)
+
+	restoredItem, err := models.RestoreTrashItem(testServer.Server.DB, item, users[1].ID)
+	require.NoError(t, err)
+	assert.Equal(t, files[2].Name, restoredItem.Name)
+
+	// The parent folder is restored along with the file.
+	folder, err := models.GetFolderByID(testServer.Server.DB, folders[2].ID)
+	require.NoError(t, err)
+	assert.Equal(t, folders[2].Name+" (1)", folder.Name)
+	assert.Equal(t, users[1].ID, folder.LastEditorID)
+	if assert.Len(t, folder.Files, 1) {
+		assert.Equal(t, files[2].ID, folder.Files[0].ID)
+	}
+
+	items, err := models.GetTrash(testServer.Server.DB, 0)
+	require.NoError(t, err)
+	assert.Len(t, items, 0)
+
+	_, err = models.RestoreTrashItem(testServer.Server.DB, item, users[1].ID)
+	assert.Equal(t, models.ErrTrashItemNotFound, err)
+}
+
+func TestRestoreTrashItemRecursive(t *testing.T) {
+	err := testServer.SeedData()
+	require.NoError(t, err)
+
+	users := testServer.Data.Users
+	folders := testServer.Data.Folders
+	files := testServer.Data.Files
+
+	// file3 is deleted on its own before its folder is deleted along with folder1.
+	err = models.DeleteFile(testServer.Server.DB, files[2].ID, users[0].ID)
+	require.NoError(t, err)
+	_, err = models.DeleteFolderRecursive(testServer.Server.DB, folders[1].ID, users[0].ID)
+	require.NoError(t, err)
+
+	item, err := models.GetTrashItem(testServer.Server.DB, models.TrashItemFolder, folders[1].ID)
+	require.NoError(t, err)
+	_, err = models.RestoreTrashItem(testServer.Server.DB, item, users[1].ID)
+	require.NoError(t, err)
+
+	// Everything deleted along with folder1 is restored, but file3 stays in the trash.
+	folder, err := models.GetFolderByID(testServer.Server.DB, folders[2].ID)
+	require.NoError(t, err)
+	assert.Equal(t, users[1].ID, folder.LastEditorID)
+	assert.Empty(t, folder.Files)
+
+	file, err := models.GetFileByID(testServer.Server.DB, files[1].ID)
+	require.NoError(t, err)
+	assert.Equal(t, users[1].ID, file.LastEditorID)
+
+	items, err := models.GetTrash(testServer.Server.DB, 0)
+	require.NoError(t, err)
+	if assert.Len(t, items, 1) {
+		assert.Equal(t, models.TrashItemFile, items[0].Type)
+		assert.Equal(t, files[2].ID, items[0].ID)
+	}
+}
+
+func TestPurgeTrashItem(t *testing.T) {
+	err := testServer.SeedData()
+	require.NoError(t, err)
+
+	users := testServer.Data.Users
+	folders := testServer.Data.Folders
+	files := testServer.Data.Files
+
+	_, err = models.CreateFileVersion(testServer.Server.DB, models.FileVersion{
+		FileID:     files[2].ID,
+		Number:     1,
+		Checksum:   "checksum",
+		UploaderID: users[0].ID,
+	})
+	require.NoError(t, err)
+
+	err = models.DeleteFile(testServer.Server.DB, files[2].ID, users[0].ID)
+	require.NoError(t, err)
+	err = models.DeleteFolder(testServer.Server.DB, folders[2].ID, users[0].ID)
+	require.NoError(t, err)
+
+	item, err := models.GetTrashItem(testServer.Server.DB, models.TrashItemFolder, folders[2].ID)
+	require.NoError(t, err)
+
+	purged, err := models.PurgeTrashItem(testServer.Server.DB, item)
+	require.NoError(t, err)
+	assert.Equal(t, []uint{files[2].ID}, purged.FileIDs)
+	assert.Equal(t, []string{"checksum"}, purged.Checksums)
+
+	items, err := models.GetTrash(testServer.Server.DB, 0)
+	require.NoError(t, err)
+	assert.Len(t, items, 0)
+
+	versions, err := models.GetFileVersions(testServer.Server.DB, files[2].ID)
+	require.NoError(t, err)
+	assert.Len(t, versions, 0)
+
+	_, err = models.PurgeTrashItem(testServer.Server.DB, item)
+	assert.Equal(t, models.ErrTrashItemNotFound, err)
+
+	// Items that haven't been deleted can't be purged.
+	_, err = models.PurgeTrashItem(testServer.Server.DB, models.TrashItem{
+		Type: models.TrashItemFile,
+		ID:   files[0].ID,
+	})
+	assert.Equal(t, models.ErrTrashItemNotFound, err)
+}
@@ -0,0 +1,130 @@
+package modeltests
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/invincibot/penn-spark-server/api/models"
+)
+
+func TestUpsertUploadPolicy(t *testing.T) {
+	err := testServer.SeedData()
+	require.NoError(t, err)
+
+	user := testServer.Data.Users[0]
+	folders := testServer.Data.Folders
+
+	testCases := []struct {
+		policy      models.UploadPolicy
+		expectedErr error
+	}{
+		{
+			policy: models.UploadPolicy{
+				FolderID:     folders[1].ID,
+				AllowedTypes: []string{"Image/*", "application/pdf", "image/*"},
+				DeniedTypes:  []string{"image/svg+xml"},
+				MaxFileSize:  100,
+				LastEditorID: user.ID,
+			},
+		},
+		{
+			policy: models.UploadPolicy{
+				FolderID:     folders[1].ID,
+				AllowedTypes: []string{"pdf"},
+				LastEditorID: user.ID,
+			},
+			expectedErr: models.ErrInvalidContentType,
+		},
+		{
+			policy: models.UploadPolicy{
+				FolderID:     folders[1].ID,
+				DeniedTypes:  []string{"*/html"},
+				LastEditorID: user.ID,
+			},
+			expectedErr: models.ErrInvalidContentType,
+		},
+		{
+			policy: models.UploadPolicy{
+				FolderID:     folders[1].ID,
+				MaxFileSize:  -1,
+				LastEditorID: user.ID,
+			},
+			expectedErr: models.ErrInvalidMaxFileSize,
+		},
+		{
+			policy: models.UploadPolicy{
+				FolderID: folders[1].ID,
+			},
+			expectedErr: models.ErrRequiredLastEditorID,
+		},
+		{
+			policy: models.UploadPolicy{
+				FolderID:     999,
+				LastEditorID: user.ID,
+			},
+			expectedErr: models.ErrFolderNotFound,
+		},
+	}
+
+	for _, testCase := range testCases {
+		policy, err := models.UpsertUploadPolicy(testServer.Server.DB, testCase.policy)
+		assert.Equal(t, testCase.expectedErr, err)
+		if testCase.expectedErr == nil {
+			assert.Equal(t, []string{"image/*", "application/pdf"}, policy.AllowedTypes)
+			assert.Equal(t, []string{"image/svg+xml"}, policy.DeniedTypes)
+			assert.Equal(t, int64(100), policy.MaxFileSize)
+		}
+	}
+}
+
+func TestGetEffectiveUploadPolicy(t *testing.T) {
+	err := testServer.SeedData()
+	require.NoError(t, err)
+
+	user := testServer.Data.Users[0]
+	folders := testServer.Data.Folders
+
+	policy, err := models.GetEffectiveUploadPolicy(testServer.Server.DB, folders[2].ID)
+	require.NoError(t, err)
+	assert.Equal(t, uint(0), policy.FolderID)
+	assert.True(t, policy.AllowsContentType("application/x-msdownload"))
+	assert.True(t, policy.AllowsSize(1<<40))
+
+	_, err = models.UpsertUploadPolicy(testServer.Server.DB, models.UploadPolicy{
+		FolderID:     folders[1].ID,
+		AllowedTypes: []string{"image/*", "text/plain"},
+		DeniedTypes:  []string{"image/svg+xml"},
+		MaxFileSize:  10,
+		LastEditorID: user.ID,
+	})
+	require.NoError(t, err)
+
+	// The policy of the closest ancestor applies to folders without their own policy.
+	policy, err = models.GetEffectiveUploadPolicy(testServer.Server.DB, folders[2].ID)
+	require.NoError(t, err)
+	assert.Equal(t, folders[1].ID, policy.FolderID)
+	assert.True(t, policy.AllowsContentType("image/png"))
+	assert.True(t, policy.AllowsContentType("text/plain; charset=utf-8"))
+	assert.False(t, policy.AllowsContentType("image/svg+xml"))
+	assert.False(t, policy.AllowsContentType("application/octet-stream"))
+	assert.True(t, policy.AllowsSize(10))
+	assert.False(t, policy.AllowsSize(11))
+
+	policy, err = models.GetEffectiveUploadPolicy(testServer.Server.DB, folders[0].ID)
+	require.NoError(t, err)
+	assert.Equal(t, uint(0), policy.FolderID)
+
+	err = models.DeleteUploadPolicy(testServer.Server.DB, folders[1].ID)
+	require.NoError(t, err)
+	policy, err = models.GetEffectiveUploadPolicy(testServer.Server.DB, folders[2].ID)
+	require.NoError(t, err)
+	assert.Equal(t, uint(0), policy.FolderID)
+
+	err = models.DeleteUploadPolicy(testServer.Server.DB, folders[1].ID)
+	assert.Equal(t, models.ErrUploadPolicyNotFound, err)
+
+	_, err = models.GetEffectiveUploadPolicy(testServer.Server.DB, 999)
+	assert.Equal(t, models.ErrFolderNotFound, err)
+}
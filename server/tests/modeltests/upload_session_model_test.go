@@ -0,0 +1,216 @@
+package modeltests
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/invincibot/penn-spark-server/api/models"
+)
+
+func TestCreateUploadSession(t *testing.T) {
+	err := testServer.SeedData()
+	require.NoError(t, err)
+
+	user := testServer.Data.Users[0]
+	file := testServer.Data.Files[0]
+	expiresAt := time.Now().Add(time.Hour)
+
+	testCases := []struct {
+		session     models.UploadSession
+		expectedErr error
+	}{
+		{
+			session: models.UploadSession{
+				FileID:     file.ID,
+				UploaderID: user.ID,
+				Length:     10,
+				ExpiresAt:  expiresAt,
+			},
+			expectedErr: nil,
+		},
+		{
+			session: models.UploadSession{
+				UploaderID: user.ID,
+				Length:     10,
+				ExpiresAt:  expiresAt,
+			},
+			expectedErr: models.ErrRequiredFileID,
+		},
+		{
+			session: models.UploadSession{
+				FileID:    file.ID,
+				Length:    10,
+				ExpiresAt: expiresAt,
+			},
+			expectedErr: models.ErrRequiredUploaderID,
+		},
+		{
+			session: models.UploadSession{
+				FileID:     file.ID,
+				UploaderID: user.ID,
+				Length:     -1,
+				ExpiresAt:  expiresAt,
+			},
+			expectedErr: models.ErrInvalidUploadLength,
+		},
+		{
+			session: models.UploadSession{
+				FileID:     file.ID,
+				UploaderID: user.ID,
+				Length:     10,
+			},
+			expectedErr: models.ErrRequiredExpiresAt,
+		},
+		{
+			session: models.UploadSession{
+				FileID:     100,
+				UploaderID: user.ID,
+				Length:     10,
+				ExpiresAt:  expiresAt,
+			},
+			expectedErr: models.ErrFileNotFound,
+		},
+	}
+
+	for _, testCase := range testCases {
+		session, err := models.CreateUploadSession(testServer.Server.DB, testCase.session)
+		assert.Equal(t, testCase.expectedErr, err)
+		if err == nil {
+			assert.NotZero(t, session.ID)
+			assert.Equal(t, testCase.session.Length, session.Length)
+			assert.Equal(t, int64(0), session.Offset)
+			assert.Empty(t, session.ReceivedRanges)
+		}
+	}
+}
+
+func TestAddUploadChunk(t *testing.T) {
+	err := testServer.SeedData()
+	require.NoError(t, err)
+
+	user := testServer.Data.Users[0]
+	file := testServer.Data.Files[0]
+	expiresAt := time.Now().Add(time.Hour)
+
+	session, err := models.CreateUploadSession(testServer.Server.DB, models.UploadSession{
+		FileID:     file.ID,
+		UploaderID: user.ID,
+		Length:     10,
+		ExpiresAt:  expiresAt,
+	})
+	require.NoError(t, err)
+
+	testCases := []struct {
+		chunk          models.UploadChunk
+		expectedErr    error
+		expectedOffset int64
+		expectedRanges [][2]int64
+	}{
+		{
+			chunk:          models.UploadChunk{Number: 2, Offset: 4, Size: 3},
+			expectedRanges: [][2]int64{{4, 7}},
+		},
+		{
+			chunk:          models.UploadChunk{Number: 1, Offset: 0, Size: 2},
+			expectedOffset: 2,
+			expectedRanges: [][2]int64{{0, 2}, {4, 7}},
+		},
+		{
+			// Resending a chunk replaces it.
+			chunk:          models.UploadChunk{Number: 1, Offset: 0, Size: 4},
+			expectedOffset: 7,
+			expectedRanges: [][2]int64{{0, 7}},
+		},
+		{
+			chunk:       models.UploadChunk{Number: 3, Offset: 6, Size: 4},
+			expectedErr: models.ErrChunkOverlaps,
+		},
+		{
+			chunk:       models.UploadChunk{Number: 3, Offset: 7, Size: 4},
+			expectedErr: models.ErrChunkOutOfRange,
+		},
+		{
+			chunk:       models.UploadChunk{Number: 0, Offset: 7, Size: 3},
+			expectedErr: models.ErrRequiredChunkNumber,
+		},
+		{
+			chunk:          models.UploadChunk{Number: 3, Offset: 7, Size: 3},
+			expectedOffset: 10,
+			expectedRanges: [][2]int64{{0, 10}},
+		},
+	}
+
+	for _, testCase := range testCases {
+		testCase.chunk.UploadSessionID = session.ID
+		updatedSession, err := models.AddUploadChunk(testServer.Server.DB, testCase.chunk, expiresAt)
+		assert.Equal(t, testCase.expectedErr, err)
+		if err == nil {
+			assert.Equal(t, testCase.expectedOffset, updatedSession.Offset)
+			assert.Equal(t, testCase.expectedRanges, updatedSession.ReceivedRanges)
+		}
+	}
+
+	session, err = models.GetUploadSessionByID(testServer.Server.DB, session.ID)
+	require.NoError(t, err)
+	assert.True(t, session.IsComplete())
+	assert.Len(t, session.Chunks, 3)
+
+	_, err = models.AddUploadChunk(testServer.Server.DB, models.UploadChunk{
+		UploadSessionID: 100,
+		Number:          1,
+	}, expiresAt)
+	assert.Equal(t, models.ErrUploadSessionNotFound, err)
+}
+
+func TestDeleteUploadSession(t *testing.T) {
+	err := testServer.SeedData()
+	require.NoError(t, err)
+
+	user := testServer.Data.Users[0]
+	file := testServer.Data.Files[0]
+
+	expiredSession, err := models.CreateUploadSession(testServer.Server.DB, models.UploadSession{
+		FileID:     file.ID,
+		UploaderID: user.ID,
+		Length:     10,
+		ExpiresAt:  time.Now().Add(-time.Hour),
+	})
+	require.NoError(t, err)
+	_, err = models.AddUploadChunk(testServer.Server.DB, models.UploadChunk{
+		UploadSessionID: expiredSession.ID,
+		Number:          1,
+		Size:            5,
+	}, time.Now().Add(-time.Minute))
+	require.NoError(t, err)
+
+	_, err = models.CreateUploadSession(testServer.Server.DB, models.UploadSession{
+		FileID:     file.ID,
+		UploaderID: user.ID,
+		Length:     10,
+		ExpiresAt:  time.Now().Add(time.Hour),
+	})
+	require.NoError(t, err)
+
+	sessions, err := models.GetExpiredUploadSessions(testServer.Server.DB, time.Now())
+	require.NoError(t, err)
+	if assert.Len(t, sessions, 1) {
+		assert.Equal(t, expiredSession.ID, sessions[0].ID)
+	}
+
+	err = models.DeleteUploadSession(testServer.Server.DB, expiredSession.ID)
+	require.NoError(t, err)
+
+	_, err = models.GetUploadSessionByID(testServer.Server.DB, expiredSession.ID)
+	assert.Equal(t, models.ErrUploadSessionNotFound, err)
+
+	var chunkCount int64
+	err = testServer.Server.DB.Model(&models.UploadChunk{}).Count(&chunkCount).Error
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), chunkCount)
+
+	err = models.DeleteUploadSession(testServer.Server.DB, expiredSession.ID)
+	assert.Equal(t, models.ErrUploadSessionNotFound, err)
+}
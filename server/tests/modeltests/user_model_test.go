@@ -1,6 +1,7 @@
 package modeltests
 
 import (
+	"sort"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -117,6 +118,44 @@ func TestGetAllUsers(t *testing.T) {
 	}
 }
 
+func TestGetUserPage(t *testing.T) {
+	err := testServer.SeedData()
+	require.NoError(t, err)
+
+	// Users sharing a last name are ordered by their Model.ID.
+	expectedUsers := make([]models.User, len(testServer.Data.Users))
+	copy(expectedUsers, testServer.Data.Users)
+	sort.SliceStable(expectedUsers, func(i, j int) bool {
+		return expectedUsers[i].LastName < expectedUsers[j].LastName
+	})
+
+	page := models.Page{Sort: "last_name", Limit: 2}
+	foundUsers := []models.User{}
+	for {
+		users, info, err := models.GetUserPage(testServer.Server.DB, page)
+		require.NoError(t, err)
+		assert.Equal(t, int64(len(expectedUsers)), info.Total)
+		assert.LessOrEqual(t, len(users), page.Limit)
+		foundUsers = append(foundUsers, users...)
+		if info.NextCursor == "" {
+			break
+		}
+		page.Cursor = info.NextCursor
+	}
+
+	require.Len(t, foundUsers, len(expectedUsers))
+	for i := range expectedUsers {
+		checkUsersEqual(t, expectedUsers[i], foundUsers[i])
+		assert.Empty(t, foundUsers[i].Password)
+	}
+
+	_, _, err = models.GetUserPage(testServer.Server.DB, models.Page{Sort: "password"})
+	assert.Equal(t, models.ErrInvalidSort, err)
+
+	_, _, err = models.GetUserPage(testServer.Server.DB, models.Page{Sort: "username", Cursor: page.Cursor})
+	assert.Equal(t, models.ErrInvalidCursor, err)
+}
+
 func TestGetUserByID(t *testing.T) {
 	err := testServer.SeedData()
 
@@ -89,6 +89,28 @@ func TestGetUserRoleByID(t *testing.T) {
 	assert.Equal(t, models.ErrUserRoleNotFound, err)
 }
 
+func TestGetUserRolePage(t *testing.T) {
+	err := testServer.SeedData()
+	require.NoError(t, err)
+
+	userRoles := testServer.Data.UserRoles
+	foundRoles, info, err := models.GetUserRolePage(testServer.Server.DB,
+		models.Page{Sort: "name", Descending: true, Limit: len(userRoles) - 1})
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(userRoles)), info.Total)
+	assert.NotEmpty(t, info.NextCursor)
+
+	// The seeded roles are named in ascending order.
+	if assert.Len(t, foundRoles, len(userRoles)-1) {
+		for i := range foundRoles {
+			checkUserRolesEqual(t, userRoles[len(userRoles)-1-i], foundRoles[i])
+		}
+	}
+
+	_, _, err = models.GetUserRolePage(testServer.Server.DB, models.Page{Sort: "created_at"})
+	assert.Equal(t, models.ErrInvalidSort, err)
+}
+
 func TestUpdateUserRole(t *testing.T) {
 	err := testServer.SeedData()
 	require.NoError(t, err)
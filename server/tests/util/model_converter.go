@@ -12,6 +12,7 @@ func AccessRoleToJSON(accessRole models.AccessRole) string {
 	params := []string{
 		WrapUint("folder_id", accessRole.FolderID),
 		WrapUint("user_role_id", accessRole.UserRoleID),
+		WrapUint("user_id", accessRole.UserID),
 		WrapUint("access_level", uint(accessRole.AccessLevel)),
 	}
 
@@ -21,6 +22,7 @@ func AccessRoleToJSON(accessRole models.AccessRole) string {
 func CheckAccessRolesEqual(t *testing.T, expectedAccessRole models.AccessRole, response map[string]interface{}) {
 	assert.Equal(t, float64(expectedAccessRole.FolderID), response["folder_id"])
 	assert.Equal(t, float64(expectedAccessRole.UserRoleID), response["user_role_id"])
+	assert.Equal(t, float64(expectedAccessRole.UserID), response["user_id"])
 	assert.Equal(t, float64(expectedAccessRole.AccessLevel), response["access_level"])
 }
 
@@ -110,11 +110,17 @@ type TestServer struct {
 }
 
 type SeedData struct {
-	Users       []models.User
-	Folders     []models.Folder
-	Files       []models.File
-	AccessRoles []models.AccessRole
-	UserRoles   []models.UserRole
+	Users          []models.User
+	Folders        []models.Folder
+	Files          []models.File
+	FileVersions   []models.FileVersion
+	Blobs          []models.Blob
+	UploadSessions []models.UploadSession
+	UploadChunks   []models.UploadChunk
+	AccessRoles    []models.AccessRole
+	UserRoles      []models.UserRole
+	UploadPolicies []models.UploadPolicy
+	GrantAudit     []models.GrantAuditEntry
 }
 
 func NewTestServer() *TestServer {
@@ -135,6 +141,11 @@ func NewTestServer() *TestServer {
 		fmt.Printf("connected to the database\n")
 	}
 
+	err = models.SetupJoinTables(s.Server.DB)
+	if err != nil {
+		log.Fatal("cannot set up join tables:", err)
+	}
+
 	return s
 }
 
@@ -172,7 +183,17 @@ func (s *TestServer) RefreshTables() error {
 		}
 	}
 
-	return nil
+	// The search index is dropped after the tables it indexes, whose triggers refer to it.
+	for _, table := range []string{"file_search_index", "folder_search_index"} {
+		if s.Server.DB.Migrator().HasTable(table) {
+			err := s.Server.DB.Exec("DROP TABLE " + table).Error
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return models.MigrateSearchIndex(s.Server.DB)
 }
 
 func (s *TestServer) RefreshFileSystem() {
@@ -279,3 +300,29 @@ func (s *TestServer) SeedData() error {
 
 	return nil
 }
+
+func (s *TestServer) GrantAccess(user models.User, folderID uint, accessLevel models.AccessLevel) (models.User, error) {
+	return s.GrantAccessRole(user, models.AccessRole{FolderID: folderID, AccessLevel: accessLevel})
+}
+
+func (s *TestServer) GrantAccessRole(user models.User, accessRole models.AccessRole) (models.User, error) {
+	userRole, err := models.CreateUserRole(s.Server.DB, models.UserRole{
+		Name: fmt.Sprintf("grant-%d-%d-%d", user.ID, accessRole.FolderID, len(user.UserRoles)),
+	})
+	if err != nil {
+		return models.User{}, err
+	}
+
+	accessRole.UserRoleID = userRole.ID
+	_, err = models.CreateAccessRole(s.Server.DB, accessRole)
+	if err != nil {
+		return models.User{}, err
+	}
+
+	_, err = models.AddUserRole(s.Server.DB, user.ID, userRole)
+	if err != nil {
+		return models.User{}, err
+	}
+
+	return models.GetUserByID(s.Server.DB, user.ID)
+}